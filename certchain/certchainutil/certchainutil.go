@@ -21,11 +21,15 @@ import (
 	"crypto/x509"
 	"encoding/pem"
 	"io/ioutil"
+	"log"
 	"net/http"
 	"net/url"
 	"os"
+	"path"
+	"time"
 
 	"github.com/WICG/webpackage/go/signedexchange"
+	"github.com/google/renameio"
 	"github.com/layer0-platform/webpackager/certchain"
 	"golang.org/x/xerrors"
 )
@@ -40,6 +44,39 @@ func FetchAugmentedChain(url *url.URL) (*certchain.AugmentedChain, error) {
 	return certchain.ReadAugmentedChain(resp.Body)
 }
 
+// FetchAugmentedChainWithCache is like FetchAugmentedChain, but caches
+// the AugmentedChain in the file at cachePath. It reuses the cached chain
+// without fetching from url until the OCSP response in it reaches its
+// NextUpdate as of now, and refetches it afterwards. Errors with reading
+// or writing the cache are logged and otherwise ignored.
+//
+// The cache does not record url, so cachePath should not be shared among
+// different certificate chains.
+func FetchAugmentedChainWithCache(url *url.URL, cachePath string, now time.Time) (*certchain.AugmentedChain, error) {
+	cached, err := ReadAugmentedChainFile(cachePath)
+	switch {
+	case err == nil && now.Before(cached.OCSPResp.NextUpdate):
+		return cached, nil
+	case err == nil:
+		log.Printf("cached cert chain at %s is stale; refetching", cachePath)
+	case !os.IsNotExist(err):
+		log.Printf("warning: failed to read cached cert chain at %s: %v", cachePath, err)
+	}
+
+	ac, err := FetchAugmentedChain(url)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := ac.WriteCBOR(&buf); err != nil {
+		return nil, err
+	}
+	if err := renameio.WriteFile(cachePath, buf.Bytes(), 0600); err != nil {
+		log.Printf("warning: failed to cache cert chain at %s: %v", cachePath, err)
+	}
+	return ac, nil
+}
+
 // ReadRawChainFile reads a PEM file to retrieve a RawChain.
 func ReadRawChainFile(filename string) (*certchain.RawChain, error) {
 	pem, err := ioutil.ReadFile(filename)
@@ -71,13 +108,21 @@ func ReadAugmentedChainFile(filename string) (*certchain.AugmentedChain, error)
 }
 
 // ReadPrivateKeyFile reads a PEM file and returns a PrivateKey usable
-// for signing exchanges.
+// for signing exchanges. It returns an error if the key is not usable
+// with any of the supported signing algorithms.
 func ReadPrivateKeyFile(filename string) (crypto.PrivateKey, error) {
 	pem, err := ioutil.ReadFile(filename)
 	if err != nil {
 		return nil, err
 	}
-	return signedexchange.ParsePrivateKey(pem)
+	key, err := signedexchange.ParsePrivateKey(pem)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := certchain.SigningAlgorithmForKey(key); err != nil {
+		return nil, err
+	}
+	return key, nil
 }
 
 // ReadCertificateRequestFile reads a PEM file and returns a Certificate
@@ -98,6 +143,26 @@ func ReadCertificateRequestFile(filename string) (*x509.CertificateRequest, erro
 	return csr, nil
 }
 
+// CertURLPath returns the last path segment of the cert-url webpkgserver
+// expects for ac, i.e. what its cert handler accepts after CertPath. It is
+// the same as ac.Digest: the SHA-256 hash of the DER encodings of all
+// certificates in the chain, concatenated in order starting with the leaf,
+// encoded in unpadded URL-safe base64 (RFC 4648, section 5). Third-party
+// servers can compute the hash the same way to serve the chain under
+// the matching URL.
+func CertURLPath(ac *certchain.AugmentedChain) string {
+	return ac.Digest
+}
+
+// CertURL returns the cert-url for ac served under base, by appending
+// CertURLPath(ac) to the path of base. The last path element of base is
+// kept whether or not base has a trailing slash: "/webpkg/cert" and
+// "/webpkg/cert/" both result in "/webpkg/cert/<digest>".
+func CertURL(base *url.URL, ac *certchain.AugmentedChain) *url.URL {
+	urlPath := path.Join(base.Path, CertURLPath(ac))
+	return base.ResolveReference(&url.URL{Path: urlPath})
+}
+
 // WrapToCertFetcher wraps an AugmentedChain into a signedexchange.CertFetcher.
 // The CertFetcher does not inspect the url argument.
 func WrapToCertFetcher(c *certchain.AugmentedChain) signedexchange.CertFetcher {
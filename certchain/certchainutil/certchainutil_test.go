@@ -0,0 +1,57 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package certchainutil_test
+
+import (
+	"testing"
+
+	"github.com/layer0-platform/webpackager/certchain/certchainutil"
+	"github.com/layer0-platform/webpackager/internal/urlutil"
+)
+
+// digest is the SHA-256 of the DER certificates in ecdsap256.pem, encoded
+// in unpadded base64url.
+const digest = "qwk4hz4Swff9wKMvr1hri3YH4MeFAH8_PE9jnJ9nx6A"
+
+func TestCertURLPath(t *testing.T) {
+	ac, err := certchainutil.ReadAugmentedChainFile("../../testdata/certs/cbor/ecdsap256_nosct.cbor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := certchainutil.CertURLPath(ac); got != digest {
+		t.Errorf("CertURLPath() = %q, want %q", got, digest)
+	}
+}
+
+func TestCertURL(t *testing.T) {
+	ac, err := certchainutil.ReadAugmentedChainFile("../../testdata/certs/cbor/ecdsap256_nosct.cbor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		base string
+		want string
+	}{
+		{"https://example.com/webpkg/cert", "https://example.com/webpkg/cert/" + digest},
+		{"https://example.com/webpkg/cert/", "https://example.com/webpkg/cert/" + digest},
+		{"/webpkg/cert", "/webpkg/cert/" + digest},
+	}
+	for _, test := range tests {
+		got := certchainutil.CertURL(urlutil.MustParse(test.base), ac)
+		if got.String() != test.want {
+			t.Errorf("CertURL(%q) = %q, want %q", test.base, got, test.want)
+		}
+	}
+}
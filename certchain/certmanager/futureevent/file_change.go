@@ -0,0 +1,80 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package futureevent
+
+import (
+	"os"
+	"time"
+)
+
+// OnFileChange returns a new Event kicked when the file at path is modified,
+// replaced, created, or removed. It checks the file every interval, comparing
+// the modification time, the size, and the identity (see os.SameFile), thus
+// also detects atomic replacements, e.g. Kubernetes updating the files of
+// a mounted Secret by swapping a symbolic link.
+//
+// OnFileChange panics if interval is not positive.
+func OnFileChange(path string, interval time.Duration) Event {
+	e := &onFileChange{
+		c:    make(chan time.Time, 1),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	orig, origErr := os.Stat(path)
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer close(e.done)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				fi, err := os.Stat(path)
+				if isFileChanged(orig, origErr, fi, err) {
+					e.c <- time.Now()
+					return
+				}
+			case <-e.stop:
+				return
+			}
+		}
+	}()
+
+	return e
+}
+
+type onFileChange struct {
+	c    chan time.Time
+	stop chan struct{}
+	done chan struct{}
+}
+
+func (e *onFileChange) Chan() <-chan time.Time {
+	return e.c
+}
+
+func (e *onFileChange) Cancel() {
+	close(e.stop)
+	<-e.done
+	drainOneTime(e.c)
+	close(e.c)
+}
+
+func isFileChanged(fi1 os.FileInfo, err1 error, fi2 os.FileInfo, err2 error) bool {
+	if err1 != nil || err2 != nil {
+		return (err1 == nil) != (err2 == nil)
+	}
+	return !os.SameFile(fi1, fi2) || !fi1.ModTime().Equal(fi2.ModTime()) || fi1.Size() != fi2.Size()
+}
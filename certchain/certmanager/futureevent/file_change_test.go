@@ -0,0 +1,121 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package futureevent_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/layer0-platform/webpackager/certchain/certmanager/futureevent"
+)
+
+const pollInterval = 10 * time.Millisecond
+
+func makeTempFile(t *testing.T, content string) (path string, cleanup func()) {
+	dir, err := ioutil.TempDir("", "futureevent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path = filepath.Join(dir, "cert.pem")
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func TestOnFileChange(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(path string) error
+	}{
+		{
+			name: "Modified",
+			change: func(path string) error {
+				return ioutil.WriteFile(path, []byte("new content"), 0644)
+			},
+		},
+		{
+			name: "Replaced",
+			change: func(path string) error {
+				tmp := path + ".tmp"
+				if err := ioutil.WriteFile(tmp, []byte("content"), 0644); err != nil {
+					return err
+				}
+				return os.Rename(tmp, path)
+			},
+		},
+		{
+			name:   "Removed",
+			change: os.Remove,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path, cleanup := makeTempFile(t, "content")
+			defer cleanup()
+
+			e := futureevent.OnFileChange(path, pollInterval)
+			if err := test.change(path); err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case _, ok := <-e.Chan():
+				if !ok {
+					t.Error("the notification channel has been closed")
+				}
+			case <-time.After(defaultTimeout):
+				t.Error("timeout")
+			}
+		})
+	}
+}
+
+func TestOnFileChangeNotWithoutChange(t *testing.T) {
+	path, cleanup := makeTempFile(t, "content")
+	defer cleanup()
+
+	e := futureevent.OnFileChange(path, pollInterval)
+	defer e.Cancel()
+	select {
+	case _, ok := <-e.Chan():
+		if ok {
+			t.Error("got notified of the event without changing the file")
+		} else {
+			t.Error("the notification channel has been closed")
+		}
+	case <-time.After(5 * pollInterval):
+	}
+}
+
+func TestOnFileChangeCancel(t *testing.T) {
+	path, cleanup := makeTempFile(t, "content")
+	defer cleanup()
+
+	e := futureevent.OnFileChange(path, pollInterval)
+	e.Cancel()
+	select {
+	case _, ok := <-e.Chan():
+		if ok {
+			t.Error("the event hasn't been canceled: got notified")
+		}
+	default:
+		t.Error("the event hasn't been canceled: still waiting")
+	}
+}
@@ -15,6 +15,10 @@
 package certmanager
 
 import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"errors"
 	"time"
 
 	"github.com/layer0-platform/webpackager/certchain"
@@ -33,7 +37,12 @@ type LocalCertFileConfig struct {
 	Path string
 
 	// FetchTiming controls the frequency of checking for the certificate.
-	// nil implies certmanager.FetchHourly.
+	// nil implies certmanager.FetchHourly. Use FetchOnFileChange to reload
+	// the certificate as soon as the file is updated.
+	//
+	// The new certificate replaces the current one only if it passes
+	// the verification below. Otherwise the current one is kept in use and
+	// the error is logged by Manager.
 	FetchTiming FetchTiming
 
 	// AllowTestCert specifies whether to allow test certificates.
@@ -43,6 +52,13 @@ type LocalCertFileConfig struct {
 	// is set true, LocalCertFile skips VerifySXGCriteria and accepts any
 	// RawChain as long as it is valid in terms of VerifyChain.
 	AllowTestCert bool
+
+	// PrivateKey is the key used to sign exchanges with the certificate.
+	// If set, LocalCertFile rejects the certificate chain unless its leaf
+	// certificate has the public key paired with PrivateKey, so that
+	// updating the file with a chain for another key does not break
+	// the signing. nil skips the check.
+	PrivateKey crypto.PrivateKey
 }
 
 var _ RawChainSource = (*LocalCertFile)(nil)
@@ -70,6 +86,32 @@ func (l *LocalCertFile) Fetch(chain *certchain.RawChain, now func() time.Time) (
 	if err := c.VerifySXGCriteria(); !l.AllowTestCert && err != nil {
 		return nil, l.FetchTiming.GetNextRun(), err
 	}
+	if l.PrivateKey != nil {
+		if err := verifyKeyPair(c, l.PrivateKey); err != nil {
+			return nil, l.FetchTiming.GetNextRun(), err
+		}
+	}
 
 	return c, l.FetchTiming.GetNextRun(), nil
 }
+
+// verifyKeyPair verifies that the leaf certificate of c has the public key
+// paired with key.
+func verifyKeyPair(c *certchain.RawChain, key crypto.PrivateKey) error {
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return errors.New("certmanager: private key does not provide its public key")
+	}
+	want, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		return err
+	}
+	got, err := x509.MarshalPKIXPublicKey(c.Leaf.PublicKey)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(got, want) {
+		return errors.New("certmanager: certificate does not match the private key")
+	}
+	return nil
+}
@@ -146,6 +146,58 @@ func TestLocalCertFileError(t *testing.T) {
 	}
 }
 
+func TestLocalCertFilePrivateKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		wantErr bool
+	}{
+		{
+			name:    "Match",
+			key:     "../../testdata/keys/ecdsap256.key",
+			wantErr: false,
+		},
+		{
+			name:    "Mismatch",
+			key:     "../../testdata/keys/ecdsap384.key",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tempFile := createTempFile()
+			defer os.Remove(tempFile)
+
+			c := certmanager.LocalCertFileConfig{
+				Path: tempFile,
+				FetchTiming: certmanager.FetchAtIntervalsWithEventFactory(
+					time.Hour,
+					newDummyFutureEvent,
+				),
+				PrivateKey: certchaintest.MustReadPrivateKeyFile(test.key),
+			}
+			l := certmanager.NewLocalCertFile(c)
+
+			copyFile("../../testdata/certs/chain/certmanager_0415.pem", tempFile)
+			oldChain := certchaintest.MustReadRawChainFile("../../testdata/certs/chain/certmanager_0401.pem")
+
+			now := time.Date(2020, time.April, 15, 15, 0, 0, 0, time.UTC)
+			timeutil.StubNowWithFixedTime(now)
+			defer timeutil.ResetNow()
+			newChain, _, err := l.Fetch(oldChain, func() time.Time { return now })
+
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("l.Fetch() = %#v (success), want error", newChain)
+				}
+			} else if err != nil {
+				t.Errorf("l.Fetch() = error(%q), want success", err)
+			}
+		})
+	}
+}
+
 func TestLocalCertFileAllowTestCert(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -18,9 +18,11 @@ import (
 	"errors"
 	"log"
 	"sync"
+	"time"
 
 	"github.com/layer0-platform/webpackager/certchain"
 	"github.com/layer0-platform/webpackager/internal/chanutil"
+	"github.com/layer0-platform/webpackager/internal/timeutil"
 )
 
 // Config configures Manager.
@@ -41,6 +43,14 @@ type Config struct {
 	// Cache specifies where to cache the signed exchange certificates.
 	// nil implies NullCache, i.e. no caching.
 	Cache Cache
+
+	// RetainPrevious specifies how long Manager keeps an AugmentedChain in
+	// memory after it is replaced with another one with a different digest
+	// (e.g. at the certificate rotation), so GetAugmentedChainByDigest can
+	// still return it for signed exchanges produced before the replacement.
+	// It should be as long as the lifetime of those signed exchanges. Zero
+	// implies the replaced AugmentedChains are dropped immediately.
+	RetainPrevious time.Duration
 }
 
 // Producer produces a new AugmentedChain repeatedly and sends it through
@@ -77,9 +87,18 @@ type Cache interface {
 type Manager struct {
 	dataMu   sync.RWMutex
 	data     *certchain.AugmentedChain
+	previous []retainedChain
 	producer Producer
 	Cache    Cache
 	killer   *chanutil.Killer
+	retain   time.Duration
+}
+
+// retainedChain is an AugmentedChain replaced with a newer one, kept until
+// the expiry.
+type retainedChain struct {
+	ac     *certchain.AugmentedChain
+	expiry time.Time
 }
 
 // NewManager creates and initializes a new Manager.
@@ -94,7 +113,7 @@ func NewManager(c Config) *Manager {
 		cache = NullCache
 	}
 
-	return &Manager{producer: producer, Cache: cache}
+	return &Manager{producer: producer, Cache: cache, retain: c.RetainPrevious}
 }
 
 // Start starts managing the certificate. It starts Producer and waits for
@@ -123,15 +142,33 @@ func (m *Manager) daemon() {
 		select {
 		case data := <-m.producer.Out():
 			go m.onReceive(data)
-			m.dataMu.Lock()
-			m.data = data
-			m.dataMu.Unlock()
+			m.setData(data)
 		case <-m.killer.C:
 			return
 		}
 	}
 }
 
+// setData replaces m.data with data, retaining the old one if it has
+// a different digest.
+func (m *Manager) setData(data *certchain.AugmentedChain) {
+	m.dataMu.Lock()
+	defer m.dataMu.Unlock()
+
+	now := timeutil.Now()
+	kept := m.previous[:0]
+	for _, r := range m.previous {
+		if r.ac.Digest != data.Digest && now.Before(r.expiry) {
+			kept = append(kept, r)
+		}
+	}
+	m.previous = kept
+	if m.retain > 0 && m.data.Digest != data.Digest {
+		m.previous = append(m.previous, retainedChain{m.data, now.Add(m.retain)})
+	}
+	m.data = data
+}
+
 func (m *Manager) onReceive(ac *certchain.AugmentedChain) {
 	if err := m.Cache.Write(ac); err != nil {
 		log.Printf("cannot cache the latest AugmentedChain: %v", err)
@@ -155,3 +192,21 @@ func (m *Manager) GetAugmentedChain() *certchain.AugmentedChain {
 	defer m.dataMu.RUnlock()
 	return m.data
 }
+
+// GetAugmentedChainByDigest returns the AugmentedChain with the provided
+// digest among the one that m currently holds and those replaced within
+// RetainPrevious. It returns nil if there is no such AugmentedChain.
+func (m *Manager) GetAugmentedChainByDigest(digest string) *certchain.AugmentedChain {
+	m.dataMu.RLock()
+	defer m.dataMu.RUnlock()
+	if m.data != nil && m.data.Digest == digest {
+		return m.data
+	}
+	now := timeutil.Now()
+	for _, r := range m.previous {
+		if r.ac.Digest == digest && now.Before(r.expiry) {
+			return r.ac
+		}
+	}
+	return nil
+}
@@ -16,10 +16,13 @@ package certmanager_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/layer0-platform/webpackager/certchain"
 	"github.com/layer0-platform/webpackager/certchain/certmanager"
 	"github.com/layer0-platform/webpackager/internal/certchaintest"
+	"github.com/layer0-platform/webpackager/internal/timeutil"
 )
 
 func TestManager(t *testing.T) {
@@ -94,3 +97,63 @@ func TestManager(t *testing.T) {
 		}
 	}
 }
+
+func TestManagerRetainPrevious(t *testing.T) {
+	augm0409 := certchaintest.MustReadAugmentedChainFile("../../testdata/certs/cbor/certmanager_0401_0409.cbor")
+	augm0415 := certchaintest.MustReadAugmentedChainFile("../../testdata/certs/cbor/certmanager_0415_0415.cbor")
+
+	now := time.Date(2020, time.April, 15, 12, 0, 0, 0, time.UTC)
+	timeutil.StubNowWithFixedTime(now)
+	defer timeutil.ResetNow()
+
+	producer := newStubProducer()
+	cache := newStubCache()
+
+	m := certmanager.NewManager(certmanager.Config{
+		Producer:       producer,
+		Cache:          cache,
+		RetainPrevious: time.Hour,
+	})
+
+	producer.out <- augm0409
+
+	if err := m.Start(); err != nil {
+		t.Fatalf("m.Start() = error(%q), want success", err)
+	}
+	if cache.WaitForAvail(defaultTimeout) != waitSuccess {
+		t.Error("timed out waiting for cache avail\n")
+	}
+
+	producer.out <- augm0415
+	if cache.WaitForAvail(defaultTimeout) != waitSuccess {
+		t.Error("timed out waiting for cache avail\n")
+	}
+	// The cache is written before m updates the current AugmentedChain.
+	for deadline := time.Now().Add(defaultTimeout); time.Now().Before(deadline); {
+		if m.GetAugmentedChain() == augm0415 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	tests := []struct {
+		name   string
+		now    time.Time
+		digest string
+		want   *certchain.AugmentedChain
+	}{
+		{"Current", now, augm0415.Digest, augm0415},
+		{"Previous", now, augm0409.Digest, augm0409},
+		{"PreviousExpired", now.Add(time.Hour), augm0409.Digest, nil},
+		{"Unknown", now, "47DEQpj8HBSa-_TImW+5JCeuQeRkm5NMpJWZG3hSuFUK", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			timeutil.StubNowWithFixedTime(test.now)
+			got := m.GetAugmentedChainByDigest(test.digest)
+			if diff := cmp.Diff(test.want, got, certComparer); diff != "" {
+				t.Errorf("m.GetAugmentedChainByDigest(%q) mismatch (-want +got):\n%s", test.digest, diff)
+			}
+		})
+	}
+}
@@ -67,6 +67,16 @@ func FetchOnSignal(sig os.Signal) FetchTiming {
 	})
 }
 
+// FetchOnFileChange makes Fetch called when the file at path changes, which
+// is checked every interval (see futureevent.OnFileChange). Used with
+// LocalCertFile, it reloads the certificate as soon as the file is updated,
+// e.g. by the rotation of a mounted Kubernetes Secret, without restarting.
+func FetchOnFileChange(path string, interval time.Duration) FetchTiming {
+	return FetchTimingFunc(func() futureevent.Event {
+		return futureevent.OnFileChange(path, interval)
+	})
+}
+
 // FetchOnlyOnce makes Fetch called only once, not repeatedly.
 func FetchOnlyOnce() FetchTiming {
 	return FetchTimingFunc(func() futureevent.Event {
@@ -50,3 +50,15 @@ func TestOnSignal(t *testing.T) {
 		t.Errorf("waitForEvent() = %v, want waitSuccess", r)
 	}
 }
+
+func TestFetchOnFileChange(t *testing.T) {
+	tempFile := createTempFile()
+	defer os.Remove(tempFile)
+
+	timing := certmanager.FetchOnFileChange(tempFile, 10*time.Millisecond)
+	nextRun := timing.GetNextRun()
+	copyFile("../../testdata/certs/chain/certmanager_0415.pem", tempFile)
+	if r := waitForEvent(nextRun, defaultTimeout); r != waitSuccess {
+		t.Errorf("waitForEvent() = %v, want waitSuccess", r)
+	}
+}
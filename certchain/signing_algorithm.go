@@ -0,0 +1,71 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package certchain
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"fmt"
+)
+
+// SigningAlgorithm identifies the algorithm to sign exchanges with. The
+// values are the names of TLS 1.3 SignatureScheme.
+type SigningAlgorithm string
+
+// These are the signing algorithms supported by the signedexchange library.
+// Note that the specification only allows ECDSAP256SHA256 at the moment;
+// signed exchanges with other algorithms are rejected by browsers.
+const (
+	ECDSAP256SHA256 SigningAlgorithm = "ecdsa_secp256r1_sha256"
+	ECDSAP384SHA384 SigningAlgorithm = "ecdsa_secp384r1_sha384"
+)
+
+// DefaultSigningAlgorithm is the signing algorithm used when unspecified.
+const DefaultSigningAlgorithm = ECDSAP256SHA256
+
+// SigningAlgorithmForKey returns the SigningAlgorithm to sign exchanges with
+// key. It returns an error if key is not usable with any supported algorithm.
+func SigningAlgorithmForKey(key crypto.PrivateKey) (SigningAlgorithm, error) {
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return "", fmt.Errorf("certchain: unsupported private key type %T", key)
+	}
+	switch ecKey.Curve {
+	case elliptic.P256():
+		return ECDSAP256SHA256, nil
+	case elliptic.P384():
+		return ECDSAP384SHA384, nil
+	}
+	return "", fmt.Errorf("certchain: unsupported curve %s", ecKey.Curve.Params().Name)
+}
+
+// CheckPrivateKey returns an error if key cannot be used to sign exchanges
+// with a. It also returns an error if a is not a supported algorithm.
+func (a SigningAlgorithm) CheckPrivateKey(key crypto.PrivateKey) error {
+	switch a {
+	case ECDSAP256SHA256, ECDSAP384SHA384:
+	default:
+		return fmt.Errorf("certchain: unsupported signing algorithm %q", a)
+	}
+	keyAlg, err := SigningAlgorithmForKey(key)
+	if err != nil {
+		return err
+	}
+	if keyAlg != a {
+		return fmt.Errorf("certchain: private key is for %s, not %s", keyAlg, a)
+	}
+	return nil
+}
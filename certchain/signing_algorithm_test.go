@@ -0,0 +1,125 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package certchain_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+
+	"github.com/layer0-platform/webpackager/certchain"
+)
+
+func mustGenerateECDSAKey(curve elliptic.Curve) crypto.PrivateKey {
+	key, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+func TestSigningAlgorithmForKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		key     crypto.PrivateKey
+		want    certchain.SigningAlgorithm
+		wantErr bool
+	}{
+		{
+			name: "P256",
+			key:  mustGenerateECDSAKey(elliptic.P256()),
+			want: certchain.ECDSAP256SHA256,
+		},
+		{
+			name: "P384",
+			key:  mustGenerateECDSAKey(elliptic.P384()),
+			want: certchain.ECDSAP384SHA384,
+		},
+		{
+			name:    "P521",
+			key:     mustGenerateECDSAKey(elliptic.P521()),
+			wantErr: true,
+		},
+		{
+			name:    "RSA",
+			key:     rsaKey,
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := certchain.SigningAlgorithmForKey(test.key)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("SigningAlgorithmForKey() = %q, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SigningAlgorithmForKey() = error(%q), want success", err)
+			}
+			if got != test.want {
+				t.Errorf("SigningAlgorithmForKey() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCheckPrivateKey(t *testing.T) {
+	p256Key := mustGenerateECDSAKey(elliptic.P256())
+
+	tests := []struct {
+		name    string
+		alg     certchain.SigningAlgorithm
+		wantErr bool
+	}{
+		{
+			name:    "Match",
+			alg:     certchain.ECDSAP256SHA256,
+			wantErr: false,
+		},
+		{
+			name:    "Mismatch",
+			alg:     certchain.ECDSAP384SHA384,
+			wantErr: true,
+		},
+		{
+			name:    "Unsupported",
+			alg:     "ed25519",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.alg.CheckPrivateKey(p256Key)
+			if test.wantErr && err == nil {
+				t.Error("CheckPrivateKey() = nil, want error")
+			}
+			if !test.wantErr && err != nil {
+				t.Errorf("CheckPrivateKey() = error(%q), want success", err)
+			}
+		})
+	}
+}
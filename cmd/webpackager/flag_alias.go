@@ -0,0 +1,116 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/layer0-platform/webpackager"
+	"github.com/layer0-platform/webpackager/internal/customflag"
+	"github.com/layer0-platform/webpackager/resource"
+	"github.com/layer0-platform/webpackager/resource/cache/filewrite"
+)
+
+var (
+	flagHostAlias = customflag.MultiString("host_alias", `Host to also sign each URL under, e.g. "www.example.com" for "example.com". The signed exchange is re-signed under the alias without fetching again, and saved to a subdirectory of --sxg_dir named after the alias. Subresources are not aliased. (repeatable)`)
+)
+
+// aliasWriter saves the signed exchanges of the main resource r re-signed
+// under the alias hosts.
+type aliasWriter func(pkg *webpackager.Packager, r *resource.Resource) error
+
+func getAliasWriterFromFlags() (aliasWriter, error) {
+	if len(*flagHostAlias) == 0 {
+		return func(*webpackager.Packager, *resource.Resource) error { return nil }, nil
+	}
+	if *flagOutput != outputSXG || *flagSXGDir == "" {
+		return nil, errors.New("--host_alias requires --output=sxg and --sxg_dir")
+	}
+	physPath, err := getPhysicalPathRuleFromFlags()
+	if err != nil {
+		return nil, err
+	}
+
+	var hosts []string
+	seen := make(map[string]bool)
+	for _, h := range *flagHostAlias {
+		h = strings.ToLower(h)
+		if h == "" || strings.ContainsAny(h, "/\\") {
+			return nil, errors.New("invalid --host_alias: " + h)
+		}
+		if !seen[h] {
+			hosts = append(hosts, h)
+			seen[h] = true
+		}
+	}
+	mapping := filewrite.AppendExt(physPath, *flagSXGExt)
+
+	return func(pkg *webpackager.Packager, r *resource.Resource) error {
+		return writeAliases(pkg, r, hosts, mapping)
+	}, nil
+}
+
+func writeAliases(pkg *webpackager.Packager, r *resource.Resource, hosts []string, mapping filewrite.MappingRule) error {
+	fty, err := pkg.ExchangeFactory.Get()
+	if err != nil {
+		return err
+	}
+	signed, err := url.Parse(r.Exchange.RequestURI)
+	if err != nil {
+		return err
+	}
+	path, err := mapping.Map(r)
+	if err != nil {
+		return err
+	}
+
+	for _, host := range hosts {
+		// The exchange signed under the alias would be identical.
+		if strings.EqualFold(host, signed.Host) {
+			continue
+		}
+		e, err := fty.NewAliasExchange(r.Exchange, withHost(signed, host), withHost(r.ValidityURL, host))
+		if err != nil {
+			return err
+		}
+		aliasPath := filepath.Join(*flagSXGDir, host, path)
+		if err := os.MkdirAll(filepath.Dir(aliasPath), 0755); err != nil {
+			return err
+		}
+		file, err := os.Create(aliasPath)
+		if err != nil {
+			return err
+		}
+		err = e.Write(file)
+		if cerr := file.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withHost returns a copy of u with the host replaced by host.
+func withHost(u *url.URL, host string) *url.URL {
+	v := *u
+	v.Host = host
+	return &v
+}
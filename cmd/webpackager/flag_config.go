@@ -0,0 +1,78 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	multierror "github.com/hashicorp/go-multierror"
+)
+
+var flagConfig = flag.String("config", "", `File to read flags from, with one "name=value" on each line, e.g. "expiry=24h". Lines starting with "#" are comments. Repeatable flags may appear on multiple lines. Flags given on the command line take precedence.`)
+
+// applyConfigFile sets the flags listed in the file specified by --config,
+// except those given on the command line. It must be called after
+// flag.Parse.
+func applyConfigFile() error {
+	if *flagConfig == "" {
+		return nil
+	}
+	f, err := os.Open(*flagConfig)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	given := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+
+	errs := new(multierror.Error)
+
+	scanner := bufio.NewScanner(f)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.IndexByte(line, '=')
+		if i < 0 {
+			errs = multierror.Append(errs, fmt.Errorf("%s:%d: missing \"=\"", *flagConfig, lineno))
+			continue
+		}
+		name, value := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		switch {
+		case flag.Lookup(name) == nil:
+			errs = multierror.Append(errs, fmt.Errorf("%s:%d: unknown flag %q", *flagConfig, lineno, name))
+		case name == "config":
+			errs = multierror.Append(errs, fmt.Errorf("%s:%d: --config may not be nested", *flagConfig, lineno))
+		case given[name]:
+			// The command line takes precedence.
+		default:
+			if err := flag.Set(name, value); err != nil {
+				errs = multierror.Append(errs, fmt.Errorf("%s:%d: invalid value %q for %s: %v", *flagConfig, lineno, value, name, err))
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		errs = multierror.Append(errs, err)
+	}
+	return errs.ErrorOrNil()
+}
@@ -22,7 +22,8 @@ import (
 )
 
 var (
-	flagDate = flag.String("date", dateNowString, `Timestamp of signed exchanges in RFC 3339 format ("2006-01-02T15:04:05Z") or "now".`)
+	flagDate      = flag.String("date", dateNowString, `Timestamp of signed exchanges in RFC 3339 format ("2006-01-02T15:04:05Z") or "now".`)
+	flagValidFrom = flag.String("valid_from", "", `Future timestamp in RFC 3339 format to sign exchanges with, for scheduled publishing. Browsers reject the signed exchanges until then. The certificate must cover the whole validity period. Exclusive with --date.`)
 )
 
 const (
@@ -30,6 +31,16 @@ const (
 )
 
 func getDateFromFlags() (time.Time, error) {
+	if *flagValidFrom != "" {
+		if *flagDate != dateNowString {
+			return time.Time{}, errors.New("--date and --valid_from are exclusive")
+		}
+		date, err := parseValidFrom(*flagValidFrom)
+		if err != nil {
+			return date, fmt.Errorf("invalid --valid_from: %v", err)
+		}
+		return date, nil
+	}
 	date, err := parseDate(*flagDate)
 	if err != nil {
 		return date, fmt.Errorf("invalid --date: %v", err)
@@ -53,3 +64,14 @@ func parseDate(s string) (time.Time, error) {
 	}
 	return t, nil
 }
+
+func parseValidFrom(s string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return t, err
+	}
+	if !t.After(time.Now()) {
+		return t, errors.New("not in the future; use --date instead")
+	}
+	return t, nil
+}
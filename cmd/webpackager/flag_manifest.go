@@ -0,0 +1,226 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+
+	"github.com/layer0-platform/webpackager"
+	"github.com/layer0-platform/webpackager/exchange"
+	"github.com/layer0-platform/webpackager/resource"
+	"github.com/layer0-platform/webpackager/resource/cache"
+	"github.com/layer0-platform/webpackager/resource/cache/filewrite"
+)
+
+var (
+	flagManifest    = flag.String("manifest", "", `JSON file listing the signed exchanges produced by the previous run with the hashes of their content. The resources whose content is unchanged keep their signed exchange files untouched, unless they expire within --renew_before. The file is updated at the end of the run. A full run takes place when the file is missing or invalid.`)
+	flagRenewBefore = flag.String("renew_before", "24h", `How long before the expiry to renew signed exchanges listed in --manifest regardless of content changes.`)
+	flagBuildID     = flag.String("build_id", "", `Identifier of the build or config, e.g. a commit hash, recorded in --manifest for each signed exchange produced in the run, to trace signed exchanges back to the run producing them. Not included in the signed exchanges.`)
+)
+
+// manifestWriter saves the manifest for the next run.
+type manifestWriter func() error
+
+// manifestFile is the JSON representation of the manifest.
+type manifestFile struct {
+	Resources []manifestEntry `json:"resources"`
+}
+
+type manifestEntry struct {
+	URL         string `json:"url"`
+	PhysicalURL string `json:"physicalURL"`
+	ContentHash string `json:"contentHash"`
+	File        string `json:"file"`
+
+	// BuildID is --build_id of the run producing the signed exchange. It is
+	// kept for the signed exchanges reused from the previous run.
+	BuildID string `json:"buildID,omitempty"`
+}
+
+// manifestCache wraps the ResourceCache of the run to keep the files for
+// the resources reused from the previous run untouched, and records all
+// stored resources for the updated manifest.
+type manifestCache struct {
+	cache.ResourceCache
+	mapping  filewrite.MappingRule
+	previous map[string]*resource.Resource
+	reused   map[string]*resource.Resource
+	entries  map[string]manifestEntry
+	buildID  string
+}
+
+func getManifestFromFlags(cfg *webpackager.Config) (manifestWriter, error) {
+	if *flagManifest == "" {
+		if *flagBuildID != "" {
+			return nil, errors.New("--build_id requires --manifest")
+		}
+		return func() error { return nil }, nil
+	}
+	if *flagOutput != outputSXG || *flagSXGDir == "" {
+		return nil, errors.New("--manifest requires --output=sxg and --sxg_dir")
+	}
+	renewBefore, err := parseDuration(*flagRenewBefore, maxExpiry)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --renew_before: %v", err)
+	}
+	physPath, err := getPhysicalPathRuleFromFlags()
+	if err != nil {
+		return nil, err
+	}
+
+	mc := &manifestCache{
+		ResourceCache: cfg.ResourceCache,
+		mapping:       filewrite.AddBaseDir(filewrite.AppendExt(physPath, *flagSXGExt), *flagSXGDir),
+		previous:      make(map[string]*resource.Resource),
+		reused:        make(map[string]*resource.Resource),
+		entries:       make(map[string]manifestEntry),
+		buildID:       *flagBuildID,
+	}
+	mc.readManifest(*flagManifest)
+
+	cfg.ResourceCache = mc
+	cfg.PreviousResources = previousResources(mc.previous)
+	cfg.RenewBefore = renewBefore
+
+	return func() error { return mc.writeManifest(*flagManifest) }, nil
+}
+
+// readManifest loads the resources listed in the manifest at path. It only
+// logs a warning on failures, so the resources are just packaged again.
+func (mc *manifestCache) readManifest(path string) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		log.Printf("no manifest at %s; packaging all resources", path)
+		return
+	}
+	var mf manifestFile
+	if err == nil {
+		err = json.Unmarshal(data, &mf)
+	}
+	if err != nil {
+		log.Printf("warning: ignoring the manifest at %s: %v", path, err)
+		return
+	}
+	for _, entry := range mf.Resources {
+		r, err := loadManifestEntry(entry)
+		if err != nil {
+			log.Printf("warning: dropped %s from the manifest: %v", entry.URL, err)
+			continue
+		}
+		mc.previous[entry.URL] = r
+		mc.entries[entry.URL] = entry
+	}
+}
+
+func loadManifestEntry(entry manifestEntry) (*resource.Resource, error) {
+	u, err := url.Parse(entry.URL)
+	if err != nil {
+		return nil, err
+	}
+	pu, err := url.Parse(entry.PhysicalURL)
+	if err != nil {
+		return nil, err
+	}
+	e, err := exchange.ReadExchangeFile(entry.File)
+	if err != nil {
+		return nil, err
+	}
+	su, err := url.Parse(e.RequestURI)
+	if err != nil {
+		return nil, err
+	}
+	vu, err := exchange.GetValidityURL(e)
+	if err != nil {
+		return nil, err
+	}
+
+	r := resource.NewResource(u)
+	r.PhysicalURL = pu
+	r.SignedURL = su
+	r.ValidityURL = vu
+	r.ContentHash = entry.ContentHash
+	if err := r.SetExchange(e); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (mc *manifestCache) Lookup(req *http.Request) (*resource.Resource, error) {
+	if r, ok := mc.reused[req.URL.String()]; ok {
+		return r, nil
+	}
+	return mc.ResourceCache.Lookup(req)
+}
+
+func (mc *manifestCache) Store(r *resource.Resource) error {
+	url := r.RequestURL.String()
+	if prev := mc.previous[url]; prev != nil && prev.Exchange == r.Exchange {
+		// The file is already there.
+		mc.reused[url] = r
+		return nil
+	}
+	delete(mc.reused, url)
+	if err := mc.ResourceCache.Store(r); err != nil {
+		return err
+	}
+	file, err := mc.mapping.Map(r)
+	if err != nil {
+		return err
+	}
+	mc.entries[url] = manifestEntry{
+		URL:         url,
+		PhysicalURL: r.PhysicalURL.String(),
+		ContentHash: r.ContentHash,
+		File:        file,
+		BuildID:     mc.buildID,
+	}
+	return nil
+}
+
+func (mc *manifestCache) writeManifest(path string) error {
+	var mf manifestFile
+	for _, entry := range mc.entries {
+		mf.Resources = append(mf.Resources, entry)
+	}
+	sort.Slice(mf.Resources, func(i, j int) bool {
+		return mf.Resources[i].URL < mf.Resources[j].URL
+	})
+	data, err := json.MarshalIndent(&mf, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// previousResources is a read-only ResourceCache for the resources loaded
+// from the manifest.
+type previousResources map[string]*resource.Resource
+
+func (pr previousResources) Lookup(req *http.Request) (*resource.Resource, error) {
+	return pr[req.URL.String()], nil
+}
+
+func (pr previousResources) Store(r *resource.Resource) error {
+	return errors.New("previous resources are read-only")
+}
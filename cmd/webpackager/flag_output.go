@@ -0,0 +1,100 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/layer0-platform/webpackager"
+	"github.com/layer0-platform/webpackager/resource"
+	"github.com/layer0-platform/webpackager/resource/cache/filewrite"
+	"github.com/layer0-platform/webpackager/webbundle"
+)
+
+var (
+	flagOutput = flag.String("output", outputSXG, `Output format: "sxg" for signed exchange files, or "wbn" for a Web Bundle per URL containing the signed exchanges of the page and its subresources, each served as application/signed-exchange (the bundles themselves are not signed). Web Bundles are saved to --sxg_dir.`)
+	flagWBNExt = flag.String("wbn_ext", ".wbn", `File extension for Web Bundle files.`)
+	flagStdout = flag.Bool("stdout", false, `Write the signed exchange to stdout instead of --sxg_dir, e.g. to pipe it to dump-signedexchange. Requires exactly one URL. The subresources are still processed for preloading, but not saved.`)
+)
+
+const (
+	outputSXG = "sxg"
+	outputWBN = "wbn"
+)
+
+// outputWriter saves the output for the main resource r. Signed exchange
+// files are saved by ResourceCache, so outputWriter does nothing for them
+// unless they go to stdout.
+type outputWriter func(pkg *webpackager.Packager, r *resource.Resource) error
+
+func getOutputWriterFromFlags() (outputWriter, error) {
+	if *flagStdout {
+		if *flagOutput != outputSXG {
+			return nil, errors.New("--stdout requires --output=sxg")
+		}
+		if len(*flagHostAlias) != 0 || *flagManifest != "" {
+			return nil, errors.New("--stdout may not be used with --host_alias or --manifest")
+		}
+		return func(pkg *webpackager.Packager, r *resource.Resource) error {
+			return r.Exchange.Write(os.Stdout)
+		}, nil
+	}
+	switch *flagOutput {
+	case outputSXG:
+		return func(*webpackager.Packager, *resource.Resource) error { return nil }, nil
+	case outputWBN:
+		if *flagSXGDir == "" {
+			return nil, errors.New("--output=wbn requires --sxg_dir")
+		}
+		physPath, err := getPhysicalPathRuleFromFlags()
+		if err != nil {
+			return nil, err
+		}
+		mapping := filewrite.AddBaseDir(
+			filewrite.AppendExt(physPath, *flagWBNExt),
+			*flagSXGDir,
+		)
+		return func(pkg *webpackager.Packager, r *resource.Resource) error {
+			return writeWebBundle(pkg, r, mapping)
+		}, nil
+	default:
+		return nil, fmt.Errorf("invalid --output: %q", *flagOutput)
+	}
+}
+
+func writeWebBundle(pkg *webpackager.Packager, r *resource.Resource, mapping filewrite.MappingRule) error {
+	b, err := webbundle.Build(r, pkg.ResourceCache)
+	if err != nil {
+		return err
+	}
+	path, err := mapping.Map(r)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = b.WriteTo(file)
+	return err
+}
@@ -15,13 +15,18 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"log"
 	"net/http"
 	"net/url"
+	"os"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/WICG/webpackage/go/signedexchange/version"
@@ -32,10 +37,14 @@ import (
 	"github.com/layer0-platform/webpackager/fetch"
 	"github.com/layer0-platform/webpackager/internal/customflag"
 	"github.com/layer0-platform/webpackager/processor"
+	"github.com/layer0-platform/webpackager/processor/commonproc"
 	"github.com/layer0-platform/webpackager/processor/complexproc"
+	"github.com/layer0-platform/webpackager/processor/htmlproc"
 	"github.com/layer0-platform/webpackager/processor/htmlproc/htmltask"
+	"github.com/layer0-platform/webpackager/processor/preverify"
 	"github.com/layer0-platform/webpackager/resource/cache"
 	"github.com/layer0-platform/webpackager/resource/cache/filewrite"
+	"github.com/layer0-platform/webpackager/urlmatcher"
 	"github.com/layer0-platform/webpackager/urlrewrite"
 	"github.com/layer0-platform/webpackager/validity"
 	multierror "github.com/hashicorp/go-multierror"
@@ -44,37 +53,107 @@ import (
 var (
 	// RequestTweaker
 	flagRequestHeader = customflag.MultiString("request_header", `Request headers, e.g. "Accept-Language: en-US, en;q=0.5". (repeatable)`)
+	flagIdentity      = flag.String("identity", "", `Value of the header to identify the packager's requests to origin servers, e.g. "webpackager". Sent in X-Webpackager unless --identity_header is set. Disabled when empty.`)
+	flagIdentityHdr   = flag.String("identity_header", "", `Header key for --identity, e.g. "Via". Defaults to "X-Webpackager".`)
+
+	// FetchClient
+	flagFetchTimeout   = flag.String("fetch_timeout", "30s", `Time limit for fetching each resource, including reading the response body. The resource fails with a timeout error, telling the phase that timed out, when it takes longer. Also applies to fetching --url_file and --sitemap. "0" disables the limit.`)
+	flagDialTimeout    = flag.String("dial_timeout", "30s", `Time limit for connecting to origin servers, including the name resolution.`)
+	flagHeaderTimeout  = flag.String("response_header_timeout", "0", `Time limit for receiving the response headers after sending each request. "0" disables the limit.`)
+	flagDebugRequests  = flag.Bool("debug_requests", false, `Log each request sent to origin servers, with the headers after --request_header and --identity are applied. The values of Authorization, Cookie, and Proxy-Authorization are redacted. Intended for debugging.`)
+	flagClientCert     = flag.String("client_cert", "", `PEM file of the TLS client certificate chain to present to origin servers requesting one, e.g. for mutual TLS. Requires --client_key.`)
+	flagClientKey      = flag.String("client_key", "", `PEM file of the private key for --client_cert.`)
+	flagClientCertHost = customflag.MultiString("client_cert_host", `Hostname of the origin servers to present --client_cert to, e.g. "backend.example.com". All origin servers when unspecified. (repeatable)`)
+	flagResolve        = customflag.MultiString("resolve", `Address to connect to for a host in place of DNS, in the curl's syntax "host:port:addr", e.g. "example.com:443:10.0.0.1". The port may be omitted to apply to any port. The TLS server name and the Host header are not changed. (repeatable)`)
 
 	// ExchangeFactory
-	flagVersion      = flag.String("version", "1b3", `Signed exchange version.`)
-	flagMIRecordSize = flag.String("mi_record_size", "4096", `Merkle Integration content encoding record size.`)
-	flagCertCBOR     = flag.String("cert_cbor", "", `Certificate chain CBOR file. Fetched from --cert_url when unspecified.`)
-	flagCertURL      = flag.String("cert_url", "", `Certficiate chain URL. (required)`)
-	flagPrivateKey   = flag.String("private_key", "", `Private key PEM file. (required)`)
+	flagVersion          = flag.String("version", "1b3", `Signed exchange version.`)
+	flagMIRecordSize     = flag.String("mi_record_size", "4096", `Merkle Integration content encoding record size.`)
+	flagMaxMIRecords     = flag.Int("max_mi_records", 0, `Maximum number of Merkle Integration records per resource. Larger record sizes, up to 16KiB, are used for payloads exceeding the limit with --mi_record_size. "0" disables the limit.`)
+	flagCertCBOR         = flag.String("cert_cbor", "", `Certificate chain CBOR file. Fetched from --cert_url when unspecified.`)
+	flagCertCache        = flag.String("cert_cache", "", `File to cache the certificate chain fetched from --cert_url. The cached chain is reused until its OCSP response reaches nextUpdate. Ignored with --cert_cbor.`)
+	flagCertURL          = flag.String("cert_url", "", `Certficiate chain URL. (required)`)
+	flagPrivateKey       = flag.String("private_key", "", `Private key PEM file. (required)`)
+	flagVerifyAtExpiry   = flag.Bool("verify_at_expiry", false, `Also verify signed exchanges at their expiry, and fail if the certificate expires before them.`)
+	flagDebugCertOut     = flag.String("debug_cert_out", "", `File to write the certificate chain CBOR used for signing, to verify the signed exchanges offline. Intended for debugging.`)
+	flagAllowedOrigin    = customflag.MultiString("allowed_origin", `Origin allowed to sign, e.g. "https://example.com". Signing other origins fails. All origins are allowed when unspecified. (repeatable)`)
+	flagAbsolutePreloads = flag.Bool("absolute_preloads", false, `Resolve preload link URLs against the document URL in signed exchanges, for distributors not accepting relative URLs.`)
+	flagCanonicalHeaders = flag.Bool("canonical_headers", false, `Canonicalize the casing of response header names and sort multi-valued headers before signing, to get identical signed exchanges for the same content.`)
+	flagCrossOriginPath  = customflag.MultiString("cross_origin_path", `Regular expression for the URL paths of subresources loaded cross-origin, e.g. "^/static/". Their signed exchanges get "Cross-Origin-Resource-Policy: cross-origin" and "Access-Control-Allow-Origin: *" unless the server sends them, and fail if the server sends other values. (repeatable)`)
+	flagSignedHeader     = customflag.MultiString("signed_header", `Response headers to add to signed exchanges, e.g. "Content-Security-Policy: default-src 'self'". Headers sent by the server take precedence. (repeatable)`)
 
 	// Processor
-	flagSizeLimit  = flag.String("size_limit", "4194304", `Maximum size of resources in bytes allowed for signed exchanges, or "none" to set no limit.`)
-	flagPreloadCSS = flag.Bool("preload_css", true, `Get CSS preloaded.`)
-	flagPreloadJS  = flag.Bool("preload_js", false, `Get JavaScript preloaded. USE WITH CAUTION: your scripts may remain cached and used until the expiry, even if you find security issues later.`)
+	flagSizeLimit        = flag.String("size_limit", "4194304", `Maximum size of resources in bytes allowed for signed exchanges, or "none" to set no limit.`)
+	flagSizeLimitAction  = flag.String("size_limit_action", sizeLimitFail, `Action taken for resources larger than --size_limit: "fail" to report an error, or "skip" to skip them with a warning and continue.`)
+	flagHeaderSizeLimit  = flag.String("header_size_limit", noSizeLimitString, `Maximum size of response headers in bytes allowed for signed exchanges, including preload links, or "none" to set no limit.`)
+	flagPreloadCSS       = flag.Bool("preload_css", true, `Get CSS preloaded.`)
+	flagPreloadJS        = flag.Bool("preload_js", false, `Get JavaScript preloaded. USE WITH CAUTION: your scripts may remain cached and used until the expiry, even if you find security issues later.`)
+	flagPreloadAll       = flag.Bool("preload_non_blocking", false, `Also preload stylesheets and scripts which do not block the rendering, such as media="print" stylesheets and async or defer scripts, with --preload_css and --preload_js.`)
+	flagPreloadCSSBgs    = flag.Bool("preload_css_backgrounds", false, `Preload same-origin images referenced by background and background-image in inline <style> elements, such as hero images.`)
+	flagPreconnect       = flag.Bool("preconnect", false, `Add preconnect links for the origins of cross-origin subresources.`)
+	flagReportLazyImages = flag.Bool("report_lazy_images", false, `Warn about images likely above the fold with loading="lazy", which delay the page rendering.`)
+	flagStripQueryParam  = customflag.MultiString("strip_query_param", `Query parameter to remove from the URLs of subresources in HTML, e.g. "utm_source", so the subresources get the same URLs across pages. A trailing "*" matches any parameter with the prefix, e.g. "utm_*". Rewrites HTML. (repeatable)`)
+	flagStripComments    = flag.Bool("strip_comments", false, `Remove HTML comments to shrink documents, except those specified by --keep_comment and --keep_conditional_comments. Rewrites HTML.`)
+	flagKeepComment      = customflag.MultiString("keep_comment", `Regular expression for the text of HTML comments to keep with --strip_comments, e.g. "^esi". (repeatable)`)
+	flagKeepCondComments = flag.Bool("keep_conditional_comments", false, `Keep conditional comments (e.g. "<!--[if IE]>") with --strip_comments.`)
+	flagCSPNonce         = flag.String("insecure_csp_nonce", "", `Fixed nonce to set on inline <script> and <style> elements and add to Content-Security-Policy. USE WITH CAUTION: the nonce is exposed in the signed exchanges and stays valid until they expire.`)
+	flagUpdateIntegrity  = flag.Bool("update_integrity", false, `Verify the integrity attributes of subresources and update them to match the output of --transform_command. Fetches the subresources twice.`)
+	flagValidateHTML     = flag.String("validate_html", validateOff, `Check HTML for serious errors, such as unclosed <script> and duplicate IDs: "off" for no check, "report" to log warnings, or "fail" to refuse signing.`)
+	flagValidateJSONLD   = flag.String("validate_jsonld", validateOff, `Check <script type="application/ld+json"> blocks in HTML are valid JSON after all processing, including --transform_command: "off" for no check, "report" to log warnings, or "fail" to refuse signing.`)
+	flagCheckRobots      = flag.String("check_robots", validateOff, `Check responses for robots directives against distribution ("noindex", "noarchive", or "none") in X-Robots-Tag and <meta name="robots">: "off" for no check, "report" to log warnings, or "fail" to refuse signing.`)
+	flagCheckCanonical   = flag.String("check_canonical", validateOff, `Check the canonical URL in the Link header and <link rel="canonical"> matches the URL signed for, to catch packaging non-canonical variants of pages: "off" for no check, "report" to log warnings, or "fail" to refuse signing.`)
+	flagCheckViewport    = flag.String("check_viewport", validateOff, `Check HTML declares a mobile-friendly viewport with <meta name="viewport" content="width=device-width"> in the <head>: "off" for no check, "report" to log warnings, or "fail" to refuse signing.`)
+	flagSniffContentType = flag.Bool("sniff_content_type", false, `Infer Content-Type from the URL or the content when the server does not send it.`)
+	flagNoJS             = flag.Bool("no_js", false, `Refuse to generate signed exchanges for JavaScript, skipping them with a warning.`)
+	flagRequireUTF8      = flag.Bool("require_utf8", false, `Refuse to generate signed exchanges for text resources not well-formed in UTF-8, unless they declare another charset.`)
+	flagCheckPreloads    = flag.Bool("check_preloads", false, `Send HEAD requests to preload targets and drop preloads for resources not responding with 200. Slow.`)
+	flagPreloadCoverage  = flag.String("preload_coverage", "", `File to write the preload coverage report to, as a JSON object per line for each HTML page listing its stylesheets, scripts, images, and preload targets, with whether each was preloaded and, if not, why (e.g. "cross-origin", "media", "async", "over-cap").`)
+	flagLogTransforms    = flag.Bool("log_transformations", false, `Log the changes made to each resource before signing, such as removed headers and dropped preloads.`)
+	flagSignTransforms   = flag.Bool("sign_transformations", false, `Add a Warning header (214 Transformation Applied) to signed exchanges for each change made to the resource before signing.`)
+	flagTransformCommand = flag.String("transform_command", "", `Command to pipe each payload through before signing. It receives the request URL and Content-Type in the WEBPACKAGER_URL and WEBPACKAGER_CONTENT_TYPE environment variables.`)
 
 	// ValidPeriodRule
 	flagExpiry           = flag.String("expiry", "72h", `Lifetime of signed exchanges. This value is not applied to JavaScript (see: --js_expiry). Maximum is "168h".`)
-	flagJSExpiry         = flag.String("js_expiry", "12h", `Lifetime of signed exchanges for JavaScript. Also applied to HTML with inline JavaScript. Maximum is "24h" by default, "168h" with --insecure_js_expiry.`)
-	flagInsecureJSExpiry = flag.Bool("insecure_js_expiry", false, `Allow --js_expiry to be longer than "24h". USE WITH CAUTION: your scripts may remain cached and used until the expiry, even if you find security issues later.`)
+	flagJSExpiry         = flag.String("js_expiry", "12h", `Lifetime of signed exchanges for JavaScript. Also applied to HTML with inline JavaScript, i.e. <script> without src, event handler attributes, or javascript: URLs. Maximum is "24h" by default, "168h" with --insecure_js_expiry. Must not exceed --expiry; the default is lowered to --expiry if it does.`)
+	flagExpireAt         = flag.String("expire_at", "", `Timestamp in RFC 3339 format when the content is expected to be updated next, e.g. the next deployment. Signed exchanges expire then if it comes before --expiry or --js_expiry, but last at least --min_expiry.`)
+	flagMinExpiry        = flag.String("min_expiry", "1h", `Minimum lifetime of signed exchanges shortened by --expire_at.`)
+	flagInsecureJSExpiry = flag.Bool("insecure_js_expiry", false, `Allow --js_expiry to be longer than "24h" and than --expiry. USE WITH CAUTION: your scripts may remain cached and used until the expiry, even if you find security issues later.`)
+	flagNextOverlap      = flag.String("next_overlap", "", `Also produce signed exchanges for the next period, starting this duration before the current ones expire. They are saved with the extension --sxg_ext plus ".next".`)
+
+	// Packager
+	flagMaxResources = flag.Int("max_resources", 0, `Maximum number of resources, including subresources, to package in the whole run. Resources beyond the limit are skipped with a warning; direct subresources of the given URLs take precedence over deeper ones. "0" means no limit.`)
 
 	// PhysicalURLRule
-	flagIndexFile = flag.String("index_file", "index.html", `Filename assumed for slash-ended URLs.`)
+	flagIndexFile    = flag.String("index_file", "index.html", `Filename assumed for slash-ended URLs.`)
+	flagPathCleaning = flag.String("path_cleaning", pathCleaningLenient, `How to handle URL paths with multiple slashes, "." or ".." segments, or encoded slashes or dots: "lenient" to clean them for the output filenames, or "strict" to fail the resource.`)
 
 	// ResourceCache, ValidityURLRule
-	flagSXGExt      = flag.String("sxg_ext", ".sxg", `File extension for signed exchange files.`)
-	flagSXGDir      = flag.String("sxg_dir", "sxg/", `Directory to output signed exchange files.`)
-	flagValidityExt = flag.String("validity_ext", ".validity", `File extension for validity files. Note it is followed by a UNIX timestamp.`)
-	flagValidityDir = flag.String("validity_dir", "", `Directory to output validity files. (unimplemented)`)
+	flagSXGExt       = flag.String("sxg_ext", ".sxg", `File extension for signed exchange files.`)
+	flagQueryPolicy  = flag.String("query_policy", queryPolicyDrop, `How to name files for URLs with a query string: "drop" to ignore the query (variants overwrite each other), "hash" to append a hash of the query to the filename, or "reject" to fail.`)
+	flagSXGDir       = flag.String("sxg_dir", "sxg/", `Directory to output signed exchange files.`)
+	flagValidityExt  = flag.String("validity_ext", ".validity", `File extension for validity files. Note it is followed by a UNIX timestamp.`)
+	flagValidityDir  = flag.String("validity_dir", "", `Directory to output validity files, which carry the signatures for the period following the signed exchanges so clients can update them. The files are placed according to the validity URLs.`)
+	flagLastModified = flag.String("last_modified", "", `Time used in place of Last-Modified to derive validity URLs, in RFC 1123 format ("Mon, 02 Jan 2006 15:04:05 GMT") or UNIX time, for stable validity URLs across rebuilds. Last-Modified from the server is used when unspecified.`)
+	flagUnsignedExt  = flag.String("unsigned_ext", "", `File extension for the unsigned HTTP responses, e.g. ".http". When set, they are saved in the HTTP/1.1 wire format to --sxg_dir alongside signed exchange files.`)
 )
 
 const (
 	noSizeLimitString = "none"
 
+	validateOff    = "off"
+	validateReport = "report"
+	validateFail   = "fail"
+
+	queryPolicyDrop   = "drop"
+	queryPolicyHash   = "hash"
+	queryPolicyReject = "reject"
+
+	sizeLimitFail = "fail"
+	sizeLimitSkip = "skip"
+
+	pathCleaningLenient = "lenient"
+	pathCleaningStrict  = "strict"
+
 	maxExpiry       = 7 * (24 * time.Hour)
 	maxGoodJSExpiry = 1 * (24 * time.Hour)
 )
@@ -86,6 +165,8 @@ func getConfigFromFlags() (*webpackager.Config, error) {
 
 	cfg.RequestTweaker, err = getRequestTweakerFromFlags()
 	errs = multierror.Append(errs, err)
+	cfg.FetchClient, err = getFetchClientFromFlags()
+	errs = multierror.Append(errs, err)
 	cfg.PhysicalURLRule, err = getPhysicalURLRuleFromFlags()
 	errs = multierror.Append(errs, err)
 	cfg.ValidityURLRule, err = getValidityURLRuleFromFlags()
@@ -99,6 +180,24 @@ func getConfigFromFlags() (*webpackager.Config, error) {
 	cfg.ResourceCache, err = getResourceCacheFromFlags()
 	errs = multierror.Append(errs, err)
 
+	cfg.KeepUnsignedResponse = (*flagUnsignedExt != "")
+	cfg.SignValidityData = (*flagValidityDir != "")
+	cfg.VerifyAtExpiry = *flagVerifyAtExpiry
+	cfg.LogTransformations = *flagLogTransforms
+	cfg.SignTransformations = *flagSignTransforms
+
+	if *flagMaxResources < 0 {
+		errs = multierror.Append(errs, errors.New("invalid --max_resources: must not be negative"))
+	}
+	cfg.MaxResources = *flagMaxResources
+
+	if *flagNextOverlap != "" {
+		cfg.NextExchangeOverlap, err = parseDuration(*flagNextOverlap, maxExpiry)
+		if err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("invalid --next_overlap: %v", err))
+		}
+	}
+
 	if err := errs.ErrorOrNil(); err != nil {
 		return nil, err
 	}
@@ -139,6 +238,18 @@ func parseDuration(s string, max time.Duration) (time.Duration, error) {
 	return v, nil
 }
 
+// isFlagGiven reports whether the flag name is set on the command line or
+// in --config.
+func isFlagGiven(name string) bool {
+	given := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			given = true
+		}
+	})
+	return given
+}
+
 func parseSizeLimit(s string) (int, error) {
 	if s == noSizeLimitString {
 		return -1, nil
@@ -178,11 +289,104 @@ func getRequestTweakerFromFlags() (fetch.RequestTweaker, error) {
 		return nil, err
 	}
 
-	t := fetch.DefaultRequestTweaker
+	seq := fetch.RequestTweakerSequence{fetch.DefaultRequestTweaker}
+	switch *flagPathCleaning {
+	case pathCleaningLenient:
+	case pathCleaningStrict:
+		seq = append(seq, &strictPathChecker{})
+	default:
+		return nil, fmt.Errorf("invalid --path_cleaning: %q", *flagPathCleaning)
+	}
 	if len(header) != 0 {
-		t = fetch.RequestTweakerSequence{t, fetch.SetCustomHeaders(header)}
+		seq = append(seq, fetch.SetCustomHeaders(header))
+	}
+	if *flagIdentity != "" {
+		seq = append(seq, fetch.AddIdentity(*flagIdentityHdr, *flagIdentity))
+	}
+	if len(seq) == 1 {
+		return seq[0], nil
 	}
-	return t, nil
+	return seq, nil
+}
+
+// strictPathChecker fails the requests with URL paths needing cleaning.
+type strictPathChecker struct{}
+
+func (*strictPathChecker) Tweak(req, parent *http.Request) error {
+	if err := urlrewrite.CheckCleanPath(req.URL); err != nil {
+		return fmt.Errorf("unclean URL path %q (--path_cleaning=strict): %v", req.URL.EscapedPath(), err)
+	}
+	return nil
+}
+
+// getFetchTimeoutFromFlags returns the --fetch_timeout value. Zero means
+// no limit.
+func getFetchTimeoutFromFlags() (time.Duration, error) {
+	timeout, err := time.ParseDuration(*flagFetchTimeout)
+	if err != nil {
+		return 0, fmt.Errorf("invalid --fetch_timeout: %v", err)
+	}
+	if timeout < 0 {
+		return 0, errors.New("invalid --fetch_timeout: duration must not be negative")
+	}
+	return timeout, nil
+}
+
+func getFetchClientFromFlags() (fetch.FetchClient, error) {
+	timeout, err := getFetchTimeoutFromFlags()
+	if err != nil {
+		return nil, err
+	}
+	dialTimeout, err := time.ParseDuration(*flagDialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --dial_timeout: %v", err)
+	}
+	if dialTimeout <= 0 {
+		return nil, errors.New("invalid --dial_timeout: duration must be positive")
+	}
+	headerTimeout, err := time.ParseDuration(*flagHeaderTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --response_header_timeout: %v", err)
+	}
+	if headerTimeout < 0 {
+		return nil, errors.New("invalid --response_header_timeout: duration must not be negative")
+	}
+	overrides, err := fetch.ParseResolveOverrides(*flagResolve)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --resolve: %v", err)
+	}
+	certs, err := getClientCertificatesFromFlags()
+	if err != nil {
+		return nil, err
+	}
+	client := fetch.NewHTTPFetchClient(fetch.TransportConfig{
+		DialTimeout:           dialTimeout,
+		ResponseHeaderTimeout: headerTimeout,
+		RequestTimeout:        timeout,
+		ResolveOverrides:      overrides,
+		ClientCertificates:    certs,
+	})
+	if *flagDebugRequests {
+		return fetch.WithRequestLogging(client, nil), nil
+	}
+	return client, nil
+}
+
+func getClientCertificatesFromFlags() ([]fetch.ClientCertificate, error) {
+	if *flagClientCert == "" && *flagClientKey == "" {
+		if len(*flagClientCertHost) != 0 {
+			return nil, errors.New("--client_cert_host requires --client_cert")
+		}
+		return nil, nil
+	}
+	if *flagClientCert == "" || *flagClientKey == "" {
+		return nil, errors.New("--client_cert and --client_key must be used together")
+	}
+	cert, err := fetch.LoadClientCertificate(*flagClientCert, *flagClientKey, *flagClientCertHost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load client certificate: %v", err)
+	}
+	return []fetch.ClientCertificate{cert}, nil
 }
 
 func getPhysicalURLRuleFromFlags() (urlrewrite.Rule, error) {
@@ -194,9 +398,23 @@ func getPhysicalURLRuleFromFlags() (urlrewrite.Rule, error) {
 }
 
 func getValidityURLRuleFromFlags() (validity.URLRule, error) {
+	if *flagLastModified != "" {
+		date, err := parseLastModified(*flagLastModified)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --last_modified: %v", err)
+		}
+		return validity.AppendExtDotFixedDate(*flagValidityExt, date), nil
+	}
 	return validity.AppendExtDotLastModified(*flagValidityExt), nil
 }
 
+func parseLastModified(s string) (time.Time, error) {
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	return http.ParseTime(s)
+}
+
 func getProcessorFromFlags() (processor.Processor, error) {
 	var cfg complexproc.Config
 	var err error
@@ -207,7 +425,105 @@ func getProcessorFromFlags() (processor.Processor, error) {
 		errs = multierror.Append(errs, fmt.Errorf("invalid --size_limit: %v", err))
 	}
 
+	switch *flagSizeLimitAction {
+	case sizeLimitFail:
+		cfg.Preverify.SizeLimitAction = preverify.SizeLimitFail
+	case sizeLimitSkip:
+		cfg.Preverify.SizeLimitAction = preverify.SizeLimitSkip
+	default:
+		errs = multierror.Append(errs, fmt.Errorf("invalid --size_limit_action: %q", *flagSizeLimitAction))
+	}
+
+	headerSizeLimit, err := parseSizeLimit(*flagHeaderSizeLimit)
+	if err != nil {
+		errs = multierror.Append(errs, fmt.Errorf("invalid --header_size_limit: %v", err))
+	}
+
+	cfg.Preverify.RequireValidUTF8 = *flagRequireUTF8
+
 	cfg.HTML.TaskSet = getHTMLTaskSetFromFlags()
+	if len(*flagStripQueryParam) > 0 {
+		cfg.HTML.ModifyHTML = true
+	}
+	if *flagStripComments {
+		var comments htmltask.CommentConfig
+		for _, s := range *flagKeepComment {
+			re, err := regexp.Compile(s)
+			if err != nil {
+				errs = multierror.Append(errs, fmt.Errorf("invalid --keep_comment: %v", err))
+				continue
+			}
+			comments.Keep = append(comments.Keep, re)
+		}
+		comments.KeepConditional = *flagKeepCondComments
+		cfg.HTML.TaskSet = append(cfg.HTML.TaskSet, htmltask.StripComments(comments))
+		cfg.HTML.ModifyHTML = true
+	}
+	cfg.HTML.Validation, err = parseValidationMode(*flagValidateHTML)
+	if err != nil {
+		errs = multierror.Append(errs, fmt.Errorf("invalid --validate_html: %v", err))
+	}
+	jsonLDMode, err := parseValidationMode(*flagValidateJSONLD)
+	if err != nil {
+		errs = multierror.Append(errs, fmt.Errorf("invalid --validate_jsonld: %v", err))
+	}
+	robotsMode, err := parseValidationMode(*flagCheckRobots)
+	if err != nil {
+		errs = multierror.Append(errs, fmt.Errorf("invalid --check_robots: %v", err))
+	}
+	canonicalMode, err := parseValidationMode(*flagCheckCanonical)
+	if err != nil {
+		errs = multierror.Append(errs, fmt.Errorf("invalid --check_canonical: %v", err))
+	}
+	viewportMode, err := parseValidationMode(*flagCheckViewport)
+	if err != nil {
+		errs = multierror.Append(errs, fmt.Errorf("invalid --check_viewport: %v", err))
+	}
+	if canonicalMode != htmlproc.ValidationOff {
+		// Run before the Link headers are removed.
+		cfg.Preverify.CustomProcessors = append(cfg.Preverify.CustomProcessors, htmlproc.CheckCanonical(canonicalMode))
+	}
+	if robotsMode != htmlproc.ValidationOff {
+		cfg.CustomPreprocessors = append(cfg.CustomPreprocessors, htmlproc.CheckRobots(robotsMode, nil))
+	}
+	cfg.SniffContentType = *flagSniffContentType
+	cfg.RejectJS = *flagNoJS
+	if *flagCheckPreloads {
+		cfg.CustomPostprocessors = append(cfg.CustomPostprocessors,
+			commonproc.CheckPreloadTargets(commonproc.CheckPreloadTargetsConfig{}))
+	}
+	var transform processor.Processor
+	if *flagTransformCommand != "" {
+		transform = commonproc.ExternalCommand(commonproc.ExternalCommandConfig{
+			Path: *flagTransformCommand,
+		})
+		cfg.CustomPostprocessors = append(cfg.CustomPostprocessors, transform)
+	}
+	if *flagUpdateIntegrity {
+		cfg.HTML.TaskSet = append(cfg.HTML.TaskSet,
+			htmltask.UpdateSubresourceIntegrity(htmltask.IntegrityConfig{
+				Processor: transform,
+			}))
+		cfg.HTML.ModifyHTML = true
+	}
+	if jsonLDMode != htmlproc.ValidationOff {
+		cfg.CustomPostprocessors = append(cfg.CustomPostprocessors, htmlproc.CheckJSONLD(jsonLDMode))
+	}
+	if viewportMode != htmlproc.ValidationOff {
+		cfg.CustomPostprocessors = append(cfg.CustomPostprocessors, htmlproc.CheckViewport(viewportMode))
+	}
+	if headerSizeLimit >= 0 {
+		cfg.CustomPostprocessors = append(cfg.CustomPostprocessors, preverify.MaxHeaderSize(headerSizeLimit))
+	}
+	if *flagPreloadCoverage != "" {
+		// Run last to see the final preloads.
+		report, err := newCoverageReporter(*flagPreloadCoverage)
+		if err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("invalid --preload_coverage: %v", err))
+		} else {
+			cfg.CustomPostprocessors = append(cfg.CustomPostprocessors, htmlproc.ReportPreloadCoverage(report))
+		}
+	}
 
 	if err := errs.ErrorOrNil(); err != nil {
 		return nil, err
@@ -215,17 +531,74 @@ func getProcessorFromFlags() (processor.Processor, error) {
 	return complexproc.NewComprehensiveProcessor(cfg), nil
 }
 
+// newCoverageReporter returns a function to write preload coverage reports
+// to the file at path, one JSON object per line.
+func newCoverageReporter(path string) (func(*htmlproc.CoverageReport), error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	var mu sync.Mutex
+	return func(report *htmlproc.CoverageReport) {
+		data, err := json.Marshal(report)
+		if err != nil {
+			log.Printf("warning: failed to encode preload coverage for %s: %v", report.URL, err)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if _, err := f.Write(append(data, '\n')); err != nil {
+			log.Printf("warning: failed to write preload coverage for %s: %v", report.URL, err)
+		}
+	}, nil
+}
+
+func parseValidationMode(s string) (htmlproc.ValidationMode, error) {
+	switch s {
+	case validateOff:
+		return htmlproc.ValidationOff, nil
+	case validateReport:
+		return htmlproc.ValidationReport, nil
+	case validateFail:
+		return htmlproc.ValidationFail, nil
+	default:
+		return htmlproc.ValidationOff, fmt.Errorf("unknown mode %q", s)
+	}
+}
+
 func getHTMLTaskSetFromFlags() []htmltask.HTMLTask {
 	var tasks []htmltask.HTMLTask
 
+	if len(*flagStripQueryParam) > 0 {
+		// Run first to get all the preloads normalized.
+		tasks = append(tasks, htmltask.StripQueryParams(*flagStripQueryParam...))
+	}
 	tasks = append(tasks, htmltask.ConservativeTaskSet...)
 
-	if *flagPreloadCSS {
+	switch {
+	case *flagPreloadCSS && *flagPreloadAll:
+		tasks = append(tasks, htmltask.PreloadAllStylesheets())
+	case *flagPreloadCSS:
 		tasks = append(tasks, htmltask.PreloadStylesheets())
 	}
-	if *flagPreloadJS {
+	switch {
+	case *flagPreloadJS && *flagPreloadAll:
+		tasks = append(tasks, htmltask.InsecurePreloadAllScripts())
+	case *flagPreloadJS:
 		tasks = append(tasks, htmltask.InsecurePreloadScripts())
 	}
+	if *flagPreloadCSSBgs {
+		tasks = append(tasks, htmltask.PreloadBackgroundImages())
+	}
+	if *flagPreconnect {
+		tasks = append(tasks, htmltask.PreconnectCrossOrigins(0))
+	}
+	if *flagReportLazyImages {
+		tasks = append(tasks, htmltask.ReportLazyAboveFold(0))
+	}
+	if *flagCSPNonce != "" {
+		tasks = append(tasks, htmltask.InsecureFixedCSPNonce(*flagCSPNonce))
+	}
 
 	return tasks
 }
@@ -251,14 +624,30 @@ func getValidPeriodRuleFromFlags() (vprule.Rule, error) {
 		return nil, err
 	}
 
-	rule := vprule.PerContentType(
-		map[string]vprule.Rule{
-			"application/javascript":   vprule.FixedLifetime(jsExpiry),
-			"text/javascript":          vprule.FixedLifetime(jsExpiry),
-			"application/x-javascript": vprule.FixedLifetime(jsExpiry),
-		},
+	// JavaScript should not outlive the pages, unless --insecure_js_expiry
+	// is given. The default --js_expiry is clamped to --expiry silently.
+	if jsExpiry > expiry && !*flagInsecureJSExpiry {
+		if isFlagGiven("js_expiry") {
+			return nil, fmt.Errorf("invalid --js_expiry: %v exceeds --expiry (%v); set --insecure_js_expiry to allow it", jsExpiry, expiry)
+		}
+		jsExpiry = expiry
+	}
+
+	var rule vprule.Rule = vprule.PerJSContentType(
+		vprule.FixedLifetime(jsExpiry),
 		vprule.FixedLifetime(expiry),
 	)
+	if *flagExpireAt != "" {
+		next, err := time.Parse(time.RFC3339, *flagExpireAt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --expire_at: %v", err)
+		}
+		minExpiry, err := parseDuration(*flagMinExpiry, maxExpiry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --min_expiry: %v", err)
+		}
+		rule = vprule.UntilNextUpdate(rule, next, minExpiry)
+	}
 	return rule, nil
 }
 
@@ -272,10 +661,47 @@ func getExchangeFactoryFromFlags() (*exchange.Factory, error) {
 		errs = multierror.Append(errs, fmt.Errorf("invalid --version: %v", err))
 	}
 
+	fty.AllowedOrigins = *flagAllowedOrigin
+	fty.AbsolutePreloadURLs = *flagAbsolutePreloads
+	fty.CanonicalizeHeaders = *flagCanonicalHeaders
+
+	for _, s := range *flagCrossOriginPath {
+		re, err := regexp.Compile(s)
+		if err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("invalid --cross_origin_path: %v", err))
+			continue
+		}
+		fty.CrossOriginRules = append(fty.CrossOriginRules, exchange.CrossOriginRule{
+			Matcher:        urlmatcher.HasEscapedPathRegexp(re),
+			ResourcePolicy: "cross-origin",
+			AllowOrigin:    "*",
+			AddMissing:     true,
+		})
+	}
+
 	fty.MIRecordSize, err = parseByteSize(*flagMIRecordSize)
 	if err != nil {
 		errs = multierror.Append(errs, fmt.Errorf("invalid --mi_record_size: %v", err))
 	}
+	if *flagMaxMIRecords < 0 {
+		errs = multierror.Append(errs, fmt.Errorf("invalid --max_mi_records: %d", *flagMaxMIRecords))
+	}
+	fty.MaxMIRecords = *flagMaxMIRecords
+
+	for _, s := range *flagSignedHeader {
+		chunks := strings.SplitN(s, ":", 2)
+		if len(chunks) == 2 {
+			if fty.AddSignedHeaders == nil {
+				fty.AddSignedHeaders = make(http.Header)
+			}
+			key := strings.TrimSpace(chunks[0])
+			val := strings.TrimSpace(chunks[1])
+			fty.AddSignedHeaders.Add(key, val)
+		} else {
+			errs = multierror.Append(
+				errs, fmt.Errorf("invalid --signed_header %q", s))
+		}
+	}
 
 	if *flagCertURL == "" {
 		errs = multierror.Append(errs, errors.New("missing --cert_url"))
@@ -290,6 +716,9 @@ func getExchangeFactoryFromFlags() (*exchange.Factory, error) {
 	if *flagCertCBOR != "" {
 		fty.CertChain, err = certchainutil.ReadAugmentedChainFile(*flagCertCBOR)
 		certChainSource = *flagCertCBOR
+	} else if fty.CertURL != nil && *flagCertCache != "" {
+		fty.CertChain, err = certchainutil.FetchAugmentedChainWithCache(fty.CertURL, *flagCertCache, time.Now())
+		certChainSource = fty.CertURL.String()
 	} else if fty.CertURL != nil {
 		fty.CertChain, err = certchainutil.FetchAugmentedChain(fty.CertURL)
 		certChainSource = fty.CertURL.String()
@@ -313,17 +742,69 @@ func getExchangeFactoryFromFlags() (*exchange.Factory, error) {
 	return fty, err
 }
 
+func writeDebugCertChainFromFlags(cfg *webpackager.Config) error {
+	if *flagDebugCertOut == "" {
+		return nil
+	}
+	fty, err := cfg.ExchangeFactory.Get()
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(*flagDebugCertOut)
+	if err != nil {
+		return fmt.Errorf("invalid --debug_cert_out: %v", err)
+	}
+	defer f.Close()
+	return fty.CertChain.WriteCBOR(f)
+}
+
+// getPhysicalPathRuleFromFlags returns the MappingRule to use PhysicalURL
+// with --query_policy applied.
+func getPhysicalPathRuleFromFlags() (filewrite.MappingRule, error) {
+	var policy filewrite.QueryPolicy
+	switch *flagQueryPolicy {
+	case queryPolicyDrop:
+		policy = filewrite.DropQuery
+	case queryPolicyHash:
+		policy = filewrite.HashQuery
+	case queryPolicyReject:
+		policy = filewrite.RejectQuery
+	default:
+		return nil, fmt.Errorf("invalid --query_policy: %q", *flagQueryPolicy)
+	}
+	return filewrite.ApplyQueryPolicy(filewrite.UsePhysicalURLPath(), policy), nil
+}
+
 func getResourceCacheFromFlags() (cache.ResourceCache, error) {
 	config := filewrite.Config{BaseCache: cache.NewOnMemoryCache()}
 
-	if *flagSXGDir != "" {
+	physPath, err := getPhysicalPathRuleFromFlags()
+	if err != nil {
+		return nil, err
+	}
+	if *flagSXGDir != "" && *flagOutput == outputSXG && !*flagStdout {
 		config.ExchangeMapping = filewrite.AddBaseDir(
-			filewrite.AppendExt(filewrite.UsePhysicalURLPath(), *flagSXGExt),
+			filewrite.AppendExt(physPath, *flagSXGExt),
 			*flagSXGDir,
 		)
+		if *flagNextOverlap != "" {
+			config.NextExchangeMapping = filewrite.AddBaseDir(
+				filewrite.AppendExt(physPath, *flagSXGExt+".next"),
+				*flagSXGDir,
+			)
+		}
+		if *flagUnsignedExt != "" {
+			config.UnsignedResponseMapping = filewrite.AddBaseDir(
+				filewrite.AppendExt(physPath, *flagUnsignedExt),
+				*flagSXGDir,
+			)
+		}
 	}
 	if *flagValidityDir != "" {
-		return nil, errors.New("--validity_dir is not implemented yet")
+		config.ValidityMapping = filewrite.AddBaseDir(
+			filewrite.UseValidityURLPath(),
+			*flagValidityDir,
+		)
 	}
 
 	return filewrite.NewFileWriteCache(config), nil
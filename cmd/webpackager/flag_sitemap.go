@@ -0,0 +1,132 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+var (
+	flagSitemap      = flag.String("sitemap", "", `URL or file of a sitemap (or sitemap index) to read the URL list from. Gzipped sitemaps are also accepted.`)
+	flagSitemapSince = flag.String("sitemap_since", "", `Timestamp in RFC 3339 format. With --sitemap, process only URLs with <lastmod> after this time. URLs without <lastmod> are always processed.`)
+)
+
+const (
+	// maxSitemapDepth limits the nesting of sitemap index files.
+	maxSitemapDepth = 4
+)
+
+// lastModLayouts are the W3C Datetime formats allowed in <lastmod>.
+var lastModLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02",
+}
+
+// sitemapDoc represents either <urlset> or <sitemapindex>.
+type sitemapDoc struct {
+	URLs     []sitemapEntry `xml:"url"`
+	Sitemaps []sitemapEntry `xml:"sitemap"`
+}
+
+type sitemapEntry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+func getSitemapURLStringList() ([]string, error) {
+	var since time.Time
+	if *flagSitemapSince != "" {
+		t, err := time.Parse(time.RFC3339, *flagSitemapSince)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --sitemap_since: %v", err)
+		}
+		since = t
+	}
+	r := &sitemapReader{since: since, visited: make(map[string]bool)}
+	if err := r.read(*flagSitemap, 0); err != nil {
+		return nil, fmt.Errorf("invalid --sitemap: %v", err)
+	}
+	return r.urls, nil
+}
+
+type sitemapReader struct {
+	since   time.Time
+	visited map[string]bool
+	urls    []string
+}
+
+func (r *sitemapReader) read(location string, depth int) error {
+	if depth > maxSitemapDepth {
+		return fmt.Errorf("%s: sitemap index nested too deeply", location)
+	}
+	if r.visited[location] {
+		return nil
+	}
+	r.visited[location] = true
+
+	doc, err := readSitemapDoc(location)
+	if err != nil {
+		return fmt.Errorf("%s: %v", location, err)
+	}
+	for _, e := range doc.URLs {
+		if r.isModified(e) {
+			r.urls = append(r.urls, strings.TrimSpace(e.Loc))
+		}
+	}
+	for _, e := range doc.Sitemaps {
+		if !r.isModified(e) {
+			continue
+		}
+		if err := r.read(strings.TrimSpace(e.Loc), depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isModified reports whether e should be processed according to r.since.
+func (r *sitemapReader) isModified(e sitemapEntry) bool {
+	if r.since.IsZero() || e.LastMod == "" {
+		return true
+	}
+	value := strings.TrimSpace(e.LastMod)
+	for _, layout := range lastModLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.After(r.since)
+		}
+	}
+	log.Printf("warning: malformed <lastmod> %q for %s", value, e.Loc)
+	return true
+}
+
+func readSitemapDoc(location string) (*sitemapDoc, error) {
+	rc, err := openInput(location)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	doc := new(sitemapDoc)
+	if err := xml.NewDecoder(rc).Decode(doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
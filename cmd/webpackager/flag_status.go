@@ -0,0 +1,153 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/layer0-platform/webpackager/internal/customflag"
+	"github.com/layer0-platform/webpackager/processor/preverify"
+)
+
+var (
+	flagStatusAction = customflag.MultiString("status_action", `Action taken when the server responds with a status code not eligible for signed exchanges, in the form "code=action", e.g. "404=skip". action is "skip" to ignore the URL, "retry" to retry it up to --max_retries times, or "fail" to report an error. Status codes not specified are "fail", the same as other errors. (repeatable)`)
+	flagMaxRetries   = flag.Int("max_retries", 3, `Maximum number of retries for each URL with --status_action=code=retry.`)
+	flagRetryWait    = flag.String("retry_wait", "1s", `Time to wait before each retry with --status_action=code=retry.`)
+)
+
+type statusAction int
+
+const (
+	statusFail statusAction = iota
+	statusSkip
+	statusRetry
+)
+
+var statusActionNames = map[string]statusAction{
+	"fail":  statusFail,
+	"skip":  statusSkip,
+	"retry": statusRetry,
+}
+
+// statusPolicy decides how the packaging loop handles HTTP status errors.
+type statusPolicy struct {
+	actions    map[int]statusAction
+	maxRetries int
+	retryWait  time.Duration
+}
+
+func getStatusPolicyFromFlags() (*statusPolicy, error) {
+	policy := &statusPolicy{actions: make(map[int]statusAction)}
+	errs := new(multierror.Error)
+
+	for _, s := range *flagStatusAction {
+		code, action, err := parseStatusAction(s)
+		if err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("invalid --status_action %q: %v", s, err))
+			continue
+		}
+		policy.actions[code] = action
+	}
+
+	if *flagMaxRetries < 0 {
+		errs = multierror.Append(errs, errors.New("invalid --max_retries: value must not be negative"))
+	}
+	policy.maxRetries = *flagMaxRetries
+
+	wait, err := time.ParseDuration(*flagRetryWait)
+	if err != nil {
+		errs = multierror.Append(errs, fmt.Errorf("invalid --retry_wait: %v", err))
+	} else if wait < 0 {
+		errs = multierror.Append(errs, errors.New("invalid --retry_wait: duration must not be negative"))
+	}
+	policy.retryWait = wait
+
+	if err := errs.ErrorOrNil(); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+func parseStatusAction(s string) (int, statusAction, error) {
+	chunks := strings.SplitN(s, "=", 2)
+	if len(chunks) != 2 {
+		return 0, 0, errors.New(`must be in the form "code=action"`)
+	}
+	code, err := strconv.Atoi(strings.TrimSpace(chunks[0]))
+	if err != nil || code < 100 || code > 599 {
+		return 0, 0, errors.New("status code must be an integer from 100 to 599")
+	}
+	action, ok := statusActionNames[strings.TrimSpace(chunks[1])]
+	if !ok {
+		return 0, 0, errors.New(`action must be "skip", "retry", or "fail"`)
+	}
+	return code, action, nil
+}
+
+// actionFor returns the action for err. Errors other than HTTP status
+// errors always fail.
+func (p *statusPolicy) actionFor(err error) statusAction {
+	var se *preverify.HTTPStatusError
+	if !errors.As(err, &se) {
+		return statusFail
+	}
+	return p.actions[se.StatusCode]
+}
+
+// shouldRetry reports whether some error in err calls for a retry.
+func (p *statusPolicy) shouldRetry(err error) bool {
+	for _, e := range flattenErrors(err) {
+		if p.actionFor(e) == statusRetry {
+			return true
+		}
+	}
+	return false
+}
+
+// filter removes the errors to skip from err, logging them instead. It
+// returns nil if no error remains. Errors still calling for a retry after
+// exhausting the retries are kept, i.e. fail.
+func (p *statusPolicy) filter(err error) error {
+	errs := new(multierror.Error)
+	for _, e := range flattenErrors(err) {
+		if p.actionFor(e) == statusSkip {
+			log.Printf("skipped: %v", e)
+			continue
+		}
+		errs = multierror.Append(errs, e)
+	}
+	return errs.ErrorOrNil()
+}
+
+func flattenErrors(err error) []error {
+	if err == nil {
+		return nil
+	}
+	if me, ok := err.(*multierror.Error); ok {
+		var errs []error
+		for _, e := range me.Errors {
+			errs = append(errs, flattenErrors(e)...)
+		}
+		return errs
+	}
+	return []error{err}
+}
@@ -16,22 +16,25 @@ package main
 
 import (
 	"bufio"
+	"compress/gzip"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/http"
 	"net/url"
 	"os"
 	"strings"
 
 	"github.com/layer0-platform/webpackager/internal/customflag"
+	"github.com/layer0-platform/webpackager/internal/urlutil"
 	multierror "github.com/hashicorp/go-multierror"
 )
 
 var (
 	flagURL     = customflag.MultiString("url", `URL of an HTML page. Ignored when --url_file is given. (repeatable)`)
-	flagURLFile = flag.String("url_file", "", `File to read the URL list from, or "-" to read it from stdin.`)
+	flagURLFile = flag.String("url_file", "", `File or URL to read the URL list from, or "-" to read it from stdin. Gzipped lists are also accepted.`)
 )
 
 func getURLListFromFlags() ([]*url.URL, error) {
@@ -42,6 +45,9 @@ func getURLListFromFlags() ([]*url.URL, error) {
 	if len(unparsed) == 0 {
 		return nil, errors.New("no urls to process")
 	}
+	if *flagStdout && len(unparsed) > 1 {
+		return nil, errors.New("--stdout may not be used with more than one url")
+	}
 
 	errs := new(multierror.Error)
 
@@ -53,7 +59,9 @@ func getURLListFromFlags() ([]*url.URL, error) {
 				err = uerr.Err
 			}
 			errs = multierror.Append(errs, fmt.Errorf("malformed url %q: %v", s, err))
+			continue
 		}
+		urlutil.NormalizeIRI(urls[i])
 	}
 	if err := errs.ErrorOrNil(); err != nil {
 		return nil, err
@@ -62,25 +70,114 @@ func getURLListFromFlags() ([]*url.URL, error) {
 }
 
 func getURLStringList() ([]string, error) {
+	if *flagSitemap != "" {
+		if len(*flagURL) != 0 || *flagURLFile != "" {
+			return nil, errors.New("--sitemap may not be used with --url or --url_file")
+		}
+		return getSitemapURLStringList()
+	}
 	if *flagURLFile == "" {
 		return *flagURL, nil
 	}
 	if len(*flagURL) != 0 {
 		return nil, errors.New("--url and --url_file may not be used together")
 	}
-	f, err := openFile(*flagURLFile)
+	f, err := openInput(*flagURLFile)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("invalid --url_file: %s: %v", *flagURLFile, err)
 	}
 	defer f.Close()
-	return readLines(bufio.NewScanner(f))
+	lines, err := readLines(bufio.NewScanner(f))
+	if err != nil {
+		return nil, fmt.Errorf("invalid --url_file: %s: %v", *flagURLFile, err)
+	}
+	return lines, nil
+}
+
+// openInput opens the file or the http(s) URL at location, or stdin if
+// location is "-". The content is decompressed transparently if it is in
+// gzip format. http(s) URLs are fetched within --fetch_timeout, including
+// reading the content.
+func openInput(location string) (io.ReadCloser, error) {
+	var rc io.ReadCloser
+	switch {
+	case location == "-":
+		rc = ioutil.NopCloser(os.Stdin)
+	case strings.HasPrefix(location, "https://") || strings.HasPrefix(location, "http://"):
+		timeout, err := getFetchTimeoutFromFlags()
+		if err != nil {
+			return nil, err
+		}
+		client := &http.Client{Timeout: timeout}
+		resp, err := client.Get(location)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, errors.New(resp.Status)
+		}
+		rc = resp.Body
+	default:
+		f, err := os.Open(location)
+		if err != nil {
+			return nil, err
+		}
+		rc = f
+	}
+	return decompressInput(location, rc)
+}
+
+// decompressInput returns a ReadCloser to read the decompressed content of
+// rc if it is in gzip format, or rc as is otherwise. It closes rc on error.
+//
+// gzip is detected by the magic number rather than the file extension, as
+// the HTTP client may or may not decompress .gz files in transit. Local files
+// with the .gz extension are still required to be in gzip format, to catch
+// truncated or mislabeled files before they feed garbage into the pipeline.
+func decompressInput(location string, rc io.ReadCloser) (io.ReadCloser, error) {
+	br := bufio.NewReader(rc)
+	magic, err := br.Peek(2)
+	if err != nil && err != io.EOF {
+		rc.Close()
+		return nil, err
+	}
+	if len(magic) < 2 || magic[0] != 0x1f || magic[1] != 0x8b {
+		if isLocalFile(location) && strings.HasSuffix(location, ".gz") {
+			rc.Close()
+			return nil, errors.New("malformed gzip: missing gzip header")
+		}
+		return &readCloser{br, rc}, nil
+	}
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		rc.Close()
+		return nil, fmt.Errorf("malformed gzip: %v", err)
+	}
+	return &readCloser{&gzipErrorReader{zr}, rc}, nil
+}
+
+func isLocalFile(location string) bool {
+	return location != "-" && !strings.HasPrefix(location, "https://") && !strings.HasPrefix(location, "http://")
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// gzipErrorReader annotates errors in the gzip stream, such as checksum
+// errors and truncation, detected while reading.
+type gzipErrorReader struct {
+	r io.Reader
 }
 
-func openFile(filename string) (io.ReadCloser, error) {
-	if filename == "-" {
-		return ioutil.NopCloser(os.Stdin), nil
+func (r *gzipErrorReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err != nil && err != io.EOF {
+		err = fmt.Errorf("malformed gzip: %v", err)
 	}
-	return os.Open(filename)
+	return n, err
 }
 
 func removeComment(s string) string {
@@ -21,14 +21,23 @@ package main
 import (
 	"flag"
 	"fmt"
+	"log"
+	"net/http"
 	"os"
+	"sort"
+	"time"
 
 	"github.com/layer0-platform/webpackager"
 	multierror "github.com/hashicorp/go-multierror"
 )
 
+var flagPrintStats = flag.Bool("print_stats", false, `Log statistics on each URL, such as cache hits, stage durations, and sizes.`)
+
 func run() error {
 	flag.Parse()
+	if err := applyConfigFile(); err != nil {
+		return err
+	}
 
 	urls, err := getURLListFromFlags()
 	if err != nil {
@@ -42,15 +51,73 @@ func run() error {
 	if err != nil {
 		return err
 	}
+	writeOutput, err := getOutputWriterFromFlags()
+	if err != nil {
+		return err
+	}
+	writeAliases, err := getAliasWriterFromFlags()
+	if err != nil {
+		return err
+	}
+	writeManifest, err := getManifestFromFlags(cfg)
+	if err != nil {
+		return err
+	}
+	policy, err := getStatusPolicyFromFlags()
+	if err != nil {
+		return err
+	}
+	if err := writeDebugCertChainFromFlags(cfg); err != nil {
+		return err
+	}
 
 	pkg := webpackager.NewPackager(*cfg)
 	errs := new(multierror.Error)
 
 	for _, u := range urls {
-		if _, err := pkg.Run(u, date); err != nil {
+		req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+		if err != nil {
 			errs = multierror.Append(errs, err)
+			continue
+		}
+		r, stats, err := pkg.RunForRequestWithStats(req, date)
+		for retry := 0; retry < policy.maxRetries && policy.shouldRetry(err); retry++ {
+			log.Printf("retrying %v in %v (%d/%d)", u, policy.retryWait, retry+1, policy.maxRetries)
+			time.Sleep(policy.retryWait)
+			req, _ = http.NewRequest(http.MethodGet, u.String(), nil)
+			r, stats, err = pkg.RunForRequestWithStats(req, date)
+		}
+		if err := policy.filter(err); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+		if r != nil && r.Exchange != nil {
+			if err := writeOutput(pkg, r); err != nil {
+				errs = multierror.Append(errs, fmt.Errorf("writing output for %v: %v", u, err))
+			}
+			if err := writeAliases(pkg, r); err != nil {
+				errs = multierror.Append(errs, fmt.Errorf("writing aliases for %v: %v", u, err))
+			}
+		}
+		if *flagPrintStats && stats != nil {
+			log.Printf("stats for %v: %v", u, stats)
 		}
 	}
+	if cfg.MaxResources > 0 {
+		processed, skipped := pkg.ResourceCounts()
+		log.Printf("processed %d resources, skipped %d due to --max_resources", processed, skipped)
+	}
+	skipCounts := pkg.SkipCounts()
+	reasons := make([]string, 0, len(skipCounts))
+	for reason := range skipCounts {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		log.Printf("skipped %d resources due to %s", skipCounts[reason], reason)
+	}
+	if err := writeManifest(); err != nil {
+		errs = multierror.Append(errs, fmt.Errorf("writing manifest: %v", err))
+	}
 	return errs.ErrorOrNil()
 }
 
@@ -65,7 +132,13 @@ func printError(err error) {
 }
 
 func main() {
-	if err := run(); err != nil {
+	var err error
+	if len(os.Args) > 1 && os.Args[1] == verifyCommand {
+		err = runVerify(os.Args[2:])
+	} else {
+		err = run()
+	}
+	if err != nil {
 		printError(err)
 		os.Exit(1)
 	}
@@ -0,0 +1,111 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/layer0-platform/webpackager/certchain/certchainutil"
+	"github.com/layer0-platform/webpackager/exchange"
+)
+
+// verifyCommand is the name of the subcommand to verify signed exchange
+// files, run as "webpackager verify [flags] DIR...".
+const verifyCommand = "verify"
+
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet(verifyCommand, flag.ExitOnError)
+	certCBOR := fs.String("cert_cbor", "", `Certificate chain CBOR file to verify signed exchanges with. (required)`)
+	sxgExt := fs.String("sxg_ext", ".sxg", `File extension for signed exchange files.`)
+	atExpiry := fs.Bool("verify_at_expiry", false, `Also verify signed exchanges at their expiry.`)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s %s [flags] DIR...\n", os.Args[0], verifyCommand)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *certCBOR == "" {
+		return fmt.Errorf("%s: missing --cert_cbor", verifyCommand)
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("%s: missing directory", verifyCommand)
+	}
+	chain, err := certchainutil.ReadAugmentedChainFile(*certCBOR)
+	if err != nil {
+		return fmt.Errorf("failed to load cert chain from %q: %v", *certCBOR, err)
+	}
+	fty := new(exchange.Factory)
+	fty.CertChain = chain
+
+	now := time.Now().Round(0) // Strip the monotonic clock reading.
+	errs := new(multierror.Error)
+	numFiles, numFailed := 0, 0
+	for _, dir := range fs.Args() {
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || !strings.HasSuffix(path, *sxgExt) {
+				return nil
+			}
+			numFiles++
+			if err := verifyFile(fty, path, now, *atExpiry); err != nil {
+				numFailed++
+				errs = multierror.Append(errs, fmt.Errorf("%s: %v", path, err))
+			}
+			return nil
+		})
+		// Errors walking dir are reported but not counted as failed files.
+		if err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+
+	fmt.Printf("verified %d signed exchanges: %d ok, %d failed\n",
+		numFiles, numFiles-numFailed, numFailed)
+	return errs.ErrorOrNil()
+}
+
+// verifyFile verifies the signed exchange in filename at now, and also at
+// its expiry if atExpiry is true.
+func verifyFile(fty *exchange.Factory, filename string, now time.Time, atExpiry bool) error {
+	e, err := exchange.ReadExchangeFile(filename)
+	if err != nil {
+		return fmt.Errorf("parsing signed exchange: %v", err)
+	}
+	if _, err := fty.Verify(e, now); err != nil {
+		return fmt.Errorf("verifying at %v: %v", now, err)
+	}
+	if !atExpiry {
+		return nil
+	}
+	vp, err := exchange.GetValidPeriod(e)
+	if err != nil {
+		return err
+	}
+	if err := fty.CheckCertCoverage(vp); err != nil {
+		return err
+	}
+	if _, err := fty.Verify(e, vp.Expires()); err != nil {
+		return fmt.Errorf("verifying at expiry %v: %v", vp.Expires(), err)
+	}
+	return nil
+}
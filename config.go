@@ -15,6 +15,8 @@
 package webpackager
 
 import (
+	"time"
+
 	"github.com/layer0-platform/webpackager/exchange"
 	"github.com/layer0-platform/webpackager/exchange/vprule"
 	"github.com/layer0-platform/webpackager/fetch"
@@ -43,6 +45,16 @@ type Config struct {
 	// properly configured.
 	FetchClient fetch.FetchClient
 
+	// ResponseTweaker specifies the mutation applied to every response
+	// immediately after FetchClient retrieves it, before it is processed
+	// and even before redirects are checked. It is the response-side analog
+	// of RequestTweaker, e.g. to add a missing Content-Type or to fix a bogus
+	// Last-Modified sent by origins that cannot be fixed. An error fails the
+	// resource. It is not applied to the responses passed to RunForResponse.
+	//
+	// nil implies no mutation.
+	ResponseTweaker func(*exchange.Response) error
+
 	// PhysicalURLRule specifies the rule(s) to simulate the URL rewriting
 	// on the server side, such as appending "index.html" to the path when
 	// it points to a directory.
@@ -53,6 +65,20 @@ type Config struct {
 	// See package urlrewrite for details.
 	PhysicalURLRule urlrewrite.Rule
 
+	// SignedURLRule specifies the rule(s) to determine the URL to sign the
+	// signed exchanges under, for the cases where the public URL differs
+	// from the location to fetch the content from (e.g. fetched from
+	// "/v2/page" but served as "/page"). The rules are applied to a copy of
+	// the request URL; FetchClient still receives the original URL. When
+	// the two URLs differ, both must be https.
+	//
+	// The result is stored in Resource.SignedURL. PhysicalURL and ValidityURL
+	// are still derived from the request URL.
+	//
+	// nil implies no rewriting: signed exchanges are signed under the URL
+	// they are fetched from.
+	SignedURLRule urlrewrite.Rule
+
 	// ValidityURLRule specifies the rule to determine the validity URL,
 	// where the validity data should be served.
 	//
@@ -77,6 +103,93 @@ type Config struct {
 	// nil implies vprule.DefaultRule.
 	ValidPeriodRule vprule.Rule
 
+	// NextExchangeOverlap instructs Packager to also produce the signed
+	// exchange for the next period, stored in Resource.NextExchange. The
+	// next period starts NextExchangeOverlap before the current one expires
+	// and has the same lifetime. The next exchange is produced by re-signing
+	// the current one, thus shares the same payload and MI encoding.
+	//
+	// Zero disables the next exchange. NextExchangeOverlap must be shorter
+	// than the lifetime of the signed exchanges to be effective.
+	NextExchangeOverlap time.Duration
+
+	// SignValidityData instructs Packager to also produce the validity data
+	// for each signed exchange, stored in Resource.ValidityData, carrying
+	// a fresh signature over the same headers and payload for the next
+	// period, so clients can update the signature without downloading
+	// the whole signed exchange again. The next period is the same as for
+	// NextExchangeOverlap, or starts when the current one expires if
+	// NextExchangeOverlap is zero.
+	//
+	// The certificate must cover the next period; otherwise the validity
+	// data is skipped with a warning.
+	SignValidityData bool
+
+	// KeepUnsignedResponse instructs Packager to keep the HTTP response
+	// used to produce each signed exchange, in Resource.UnsignedResponse,
+	// so ResourceCache can store it for dual-serving. It is off by default
+	// to save memory.
+	KeepUnsignedResponse bool
+
+	// StaleIfError allows Packager to reuse the signed exchange in
+	// ResourceCache for up to StaleIfError after its expiry when renewing it
+	// fails because the origin is unavailable, i.e. the fetch fails or the
+	// server responds with a 5xx status code, similarly to the stale-if-error
+	// Cache-Control extension [RFC 5861]. Note the browsers reject the
+	// signed exchange past its expiry; it is still useful to distributors
+	// falling back to Resource.UnsignedResponse (see KeepUnsignedResponse).
+	//
+	// Zero disables the reuse.
+	StaleIfError time.Duration
+
+	// MaxResources limits the number of resources Packager processes,
+	// counting both main resources and subresources, over all runs of
+	// the Packager, to protect against runaway recursion into subresources.
+	// Resources beyond the limit are skipped with a warning, thus left
+	// unpackaged and not preloaded. The direct subresources of each main
+	// resource take precedence over deeper subresources within the limit.
+	//
+	// Zero means no limit.
+	MaxResources int
+
+	// PreviousResources provides the resources produced earlier, e.g. by
+	// the previous run of an incremental build, to reuse when their content
+	// has not changed. When ResourceCache has no valid signed exchange for
+	// a resource, Packager fetches the resource as usual, but then reuses
+	// the Resource found in PreviousResources, instead of processing and
+	// signing the response, if the Resource has the same ContentHash as
+	// the fetched payload and its signed exchange remains valid for at least
+	// RenewBefore. The reused Resource is stored into ResourceCache as is.
+	//
+	// Note the subresources of reused Resources are not visited, thus not
+	// renewed even if they have changed: the reused signed exchange refers
+	// to the previous versions of them through the allowed-alt-sxg links.
+	// Changes to the configuration (e.g. to Processor) are not detected.
+	//
+	// nil disables the reuse.
+	PreviousResources cache.ResourceCache
+
+	// RenewBefore specifies how long before the expiry the signed exchanges
+	// in PreviousResources get renewed regardless of content changes.
+	RenewBefore time.Duration
+
+	// VerifyAtExpiry instructs Packager to verify each new signed exchange
+	// also at the end of its validity period, in addition to the signing
+	// date, and to check the certificate covers the entire validity period.
+	// It catches signed exchanges which would stop validating before they
+	// expire, e.g. due to the certificate expiring earlier.
+	VerifyAtExpiry bool
+
+	// LogTransformations instructs Packager to log the changes processors
+	// made to each resource, as recorded in Resource.Transformations.
+	LogTransformations bool
+
+	// SignTransformations instructs Packager to add a Warning header with
+	// the code 214 (Transformation Applied) for each change processors made
+	// to the response, so the signed exchange itself tells how the content
+	// was modified. It alters the signed exchanges, thus is off by default.
+	SignTransformations bool
+
 	// ExchangeFactory specifies encoding parameters and signing materials
 	// for producing signed exchanges. If you use the same certificate and
 	// private key for the whole lifetime of the Packager, you can specify
@@ -111,6 +224,9 @@ func (cfg *Config) populateDefaults() {
 	if cfg.PhysicalURLRule == nil {
 		cfg.PhysicalURLRule = urlrewrite.DefaultRules
 	}
+	if cfg.SignedURLRule == nil {
+		cfg.SignedURLRule = urlrewrite.RuleSequence{}
+	}
 	if cfg.ValidityURLRule == nil {
 		cfg.ValidityURLRule = validity.DefaultURLRule
 	}
@@ -0,0 +1,55 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exchange
+
+import (
+	"net/http"
+	"net/textproto"
+	"sort"
+)
+
+// orderSensitiveHeaders lists the header fields whose values canonicalHeader
+// keeps in the original order: the order of content codings determines how
+// to decode the payload, and the order of preload links tells the priority.
+var orderSensitiveHeaders = map[string]bool{
+	"Content-Encoding": true,
+	"Link":             true,
+}
+
+// canonicalHeader returns a new http.Header with the same fields as header,
+// with the field names in the canonical form of textproto.CanonicalMIMEHeaderKey
+// and the values of each multi-valued field sorted, except for those listed
+// in orderSensitiveHeaders. Fields differing only in casing are merged.
+func canonicalHeader(header http.Header) http.Header {
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	// Merge the fields in a fixed order, so the values of the merged fields
+	// do not depend on the map iteration order.
+	sort.Strings(keys)
+
+	canonical := make(http.Header, len(header))
+	for _, key := range keys {
+		ckey := textproto.CanonicalMIMEHeaderKey(key)
+		canonical[ckey] = append(canonical[ckey], header[key]...)
+	}
+	for key, values := range canonical {
+		if len(values) > 1 && !orderSensitiveHeaders[key] {
+			sort.Strings(values)
+		}
+	}
+	return canonical
+}
@@ -16,7 +16,9 @@ package exchange
 
 import (
 	"crypto"
+	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/WICG/webpackage/go/signedexchange/version"
 	"github.com/layer0-platform/webpackager/certchain"
@@ -29,10 +31,34 @@ const (
 	DefaultMIRecordSize = 16384
 )
 
+// maxMIRecordSize is the maximum Merkle Integrity record size compliant with
+// the specification.
+const maxMIRecordSize = 16384
+
+// maxSignatureDurations maps the signed exchange versions to the maximum
+// duration of their signatures, i.e. the difference between the date and
+// expires parameters, allowed by the specifications.
+var maxSignatureDurations = map[version.Version]time.Duration{
+	version.Version1b1: 7 * 24 * time.Hour,
+	version.Version1b2: 7 * 24 * time.Hour,
+	version.Version1b3: 7 * 24 * time.Hour,
+}
+
+// MaxSignatureDuration returns the maximum duration of signatures allowed
+// by the specification of v. It returns zero if v is unknown.
+func MaxSignatureDuration(v version.Version) time.Duration {
+	return maxSignatureDurations[v]
+}
+
 // DefaultCertURL is the default value for CertURL in Config.
 var DefaultCertURL = urlutil.MustParse("/cert.cbor")
 
 // Config holds the parameters to produce signed exchanges.
+//
+// Config has no option to compress the payload with Brotli before the MI
+// encoding. Browsers currently accept only the MI encoding alone as
+// Content-Encoding in signed exchanges, and no Brotli encoder is available
+// to this module, so such an option could not be implemented yet.
 type Config struct {
 	// Version specifies the signed exchange version. If Version is empty,
 	// Factory uses DefaultVersion.
@@ -43,6 +69,14 @@ type Config struct {
 	// 16384 (16 KiB) to be compliant with the specification.
 	MIRecordSize int
 
+	// MaxMIRecords specifies the maximum number of Merkle Integrity records
+	// per payload. Factory raises the record size for payloads which would
+	// otherwise be split into more records, to keep the integrity proofs
+	// from bloating the payload, and logs it. The raised size is still at
+	// most 16384 (16 KiB), thus large payloads may have more records. Zero
+	// or negative sets no maximum. See also Factory.MIRecordSizeFor.
+	MaxMIRecords int
+
 	// CertChain specifies the certificate chain. CertChain may not be nil.
 	CertChain *certchain.AugmentedChain
 
@@ -51,16 +85,88 @@ type Config struct {
 	// the request URL. It should still usually contain an absolute path
 	// (e.g. "/cert.cbor", not "cert.cbor"). If CertURL is nil, Factory uses
 	// DefaultCertURL.
+	//
+	// The resolved cert-url must be an absolute https:// URL. Factory fails
+	// to produce signed exchanges otherwise. Note the signature always
+	// carries the resolved absolute URL: the signedexchange library refuses
+	// to sign with a cert-url lacking the https or data scheme, and rejects
+	// validity-url not same-origin with the request URL in verification, so
+	// neither parameter can be emitted as a relative reference.
 	CertURL *url.URL
 
 	// PrivateKey specifies the private key used for signing. PrivateKey may
 	// not be nil.
 	PrivateKey crypto.PrivateKey
 
+	// SigningAlgorithm specifies the algorithm to sign exchanges with. If
+	// SigningAlgorithm is empty, Factory uses
+	// certchain.DefaultSigningAlgorithm. Factory fails to produce signed
+	// exchanges if the algorithm is not supported or PrivateKey cannot be
+	// used with it.
+	SigningAlgorithm certchain.SigningAlgorithm
+
 	// KeepNonSXGPreloads instructs Factory to include preload link headers
 	// that don't have the corresponding allowed-alt-sxg with a valid
 	// header-integrity.
 	KeepNonSXGPreloads bool
+
+	// AbsolutePreloadURLs instructs Factory to resolve the URLs of preload
+	// links against the request URL before writing them into the Link
+	// header, for distributors that do not handle relative references.
+	// The preload links in Response are left as they are.
+	AbsolutePreloadURLs bool
+
+	// CanonicalizeHeaders instructs Factory to canonicalize the casing of
+	// the response header field names (see textproto.CanonicalMIMEHeaderKey),
+	// merging the fields differing only in casing, and to sort the values of
+	// multi-valued fields, so that the signed bytes stay the same across
+	// origins and runs for the same content. Content-Encoding and Link keep
+	// the order of their values, which is significant.
+	CanonicalizeHeaders bool
+
+	// AddSignedHeaders specifies HTTP headers to add to every response in
+	// the signed exchange (e.g. Content-Security-Policy), so they become
+	// part of the signed bytes. They are added after the processors run.
+	// Headers already present in the response are left as is, unless
+	// ReplaceSignedHeaders is true.
+	//
+	// Content-Encoding and Digest cannot be added: they are always set by
+	// the Merkle Integrity encoding.
+	AddSignedHeaders http.Header
+
+	// ReplaceSignedHeaders instructs Factory to have AddSignedHeaders
+	// replace the headers present in the response.
+	ReplaceSignedHeaders bool
+
+	// SignedRequestHeaders specifies the request headers to include in the
+	// request map of signed exchanges, e.g. those the response varies on,
+	// so distributors can match the variants. Request headers not listed
+	// are omitted. nil includes the request headers as they are.
+	//
+	// The request map only exists in versions 1b1 and 1b2. Factory fails to
+	// produce signed exchanges if SignedRequestHeaders is non-empty with
+	// other versions, or contains stateful headers (e.g. Cookie), which the
+	// specification forbids.
+	SignedRequestHeaders []string
+
+	// AllowedOrigins specifies the origins Factory is permitted to sign
+	// for, such as "https://example.com", as a guardrail against signing
+	// unintended content. Factory fails with OriginNotAllowedError for
+	// URLs from other origins. The port must be written exactly as in
+	// the URLs to sign. Empty allows all origins.
+	AllowedOrigins []string
+
+	// CrossOriginRules specifies the headers required for subresources
+	// intended for cross-origin use, such as Cross-Origin-Resource-Policy.
+	// Factory checks the response against the first matching rule after
+	// AddSignedHeaders are added, and fails with CrossOriginHeaderError if
+	// the headers are missing or have other values. Empty checks nothing.
+	CrossOriginRules []CrossOriginRule
+
+	// SigningLimiter specifies the limit on the number of signing
+	// operations running at the same time, shared with other Factories.
+	// nil sets no limit.
+	SigningLimiter *SigningLimiter
 }
 
 func (c *Config) populateDefaults() {
@@ -80,4 +186,7 @@ func (c *Config) populateDefaults() {
 	if c.CertURL == nil {
 		c.CertURL = DefaultCertURL
 	}
+	if c.SigningAlgorithm == "" {
+		c.SigningAlgorithm = certchain.DefaultSigningAlgorithm
+	}
 }
@@ -0,0 +1,100 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exchange
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/layer0-platform/webpackager/urlmatcher"
+)
+
+// CrossOriginRule specifies the headers required in the signed exchanges
+// of subresources loaded cross-origin, e.g. those preloaded by pages served
+// from other origins. Browsers refuse such subresources silently when the
+// signed response lacks the headers.
+type CrossOriginRule struct {
+	// Matcher selects the URLs the rule applies to.
+	Matcher urlmatcher.Matcher
+
+	// ResourcePolicy specifies the value of Cross-Origin-Resource-Policy
+	// required in the response, typically "cross-origin". Empty requires
+	// nothing.
+	ResourcePolicy string
+
+	// AllowOrigin specifies the value of Access-Control-Allow-Origin
+	// required in the response, e.g. "*" or "https://example.com".
+	// A response with "*" satisfies any origin. Empty requires nothing.
+	AllowOrigin string
+
+	// AddMissing instructs Factory to add the required headers missing in
+	// the response instead of failing. Headers present with other values
+	// still make Factory fail, since the server chose them deliberately.
+	AddMissing bool
+}
+
+// CrossOriginHeaderError is returned by Factory when the response to sign
+// lacks the headers required by Config.CrossOriginRules.
+type CrossOriginHeaderError struct {
+	// URL represents the URL requested to be signed.
+	URL *url.URL
+	// Header is the name of the missing or mismatched header.
+	Header string
+	// Got and Want are the actual and the required value of Header.
+	Got, Want string
+}
+
+// Error implements the error interface.
+func (e *CrossOriginHeaderError) Error() string {
+	if e.Got == "" {
+		return fmt.Sprintf("%v: missing %s (want %q) for cross-origin use", e.URL, e.Header, e.Want)
+	}
+	return fmt.Sprintf("%v: %s is %q, want %q for cross-origin use", e.URL, e.Header, e.Got, e.Want)
+}
+
+// applyCrossOriginRules checks header against the first rule in
+// fty.CrossOriginRules matching u, and adds the missing headers if the rule
+// allows. It returns a CrossOriginHeaderError on failure.
+func (fty *Factory) applyCrossOriginRules(u *url.URL, header http.Header) error {
+	for _, rule := range fty.CrossOriginRules {
+		if !rule.Matcher.Match(u) {
+			continue
+		}
+		if err := rule.apply(u, header, "Cross-Origin-Resource-Policy", rule.ResourcePolicy); err != nil {
+			return err
+		}
+		return rule.apply(u, header, "Access-Control-Allow-Origin", rule.AllowOrigin)
+	}
+	return nil
+}
+
+func (rule *CrossOriginRule) apply(u *url.URL, header http.Header, key, want string) error {
+	if want == "" {
+		return nil
+	}
+	got := strings.TrimSpace(header.Get(key))
+	switch {
+	case got == "" && rule.AddMissing:
+		header.Set(key, want)
+		return nil
+	case strings.EqualFold(got, want):
+		return nil
+	case key == "Access-Control-Allow-Origin" && got == "*":
+		return nil
+	}
+	return &CrossOriginHeaderError{u, key, got, want}
+}
@@ -0,0 +1,138 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exchange_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/layer0-platform/webpackager/exchange"
+	"github.com/layer0-platform/webpackager/exchange/exchangetest"
+	"github.com/layer0-platform/webpackager/internal/certchaintest"
+	"github.com/layer0-platform/webpackager/internal/urlutil"
+	"github.com/layer0-platform/webpackager/urlmatcher"
+)
+
+func TestCrossOriginRules(t *testing.T) {
+	vp := exchange.NewValidPeriod(
+		time.Date(2019, time.April, 22, 19, 30, 0, 0, time.UTC),
+		time.Date(2019, time.April, 29, 19, 30, 0, 0, time.UTC))
+	rule := exchange.CrossOriginRule{
+		Matcher:        urlmatcher.HasEscapedPathPrefix("/static/"),
+		ResourcePolicy: "cross-origin",
+		AllowOrigin:    "https://example.com",
+	}
+
+	tests := []struct {
+		name       string
+		url        string
+		header     string
+		addMissing bool
+		want       map[string]string
+		wantErr    bool
+	}{
+		{
+			name: "Present",
+			url:  "https://example.org/static/style.css",
+			header: "Cross-Origin-Resource-Policy: Cross-Origin\r\n" +
+				"Access-Control-Allow-Origin: https://example.com\r\n",
+			want: map[string]string{
+				"Cross-Origin-Resource-Policy": "Cross-Origin",
+				"Access-Control-Allow-Origin":  "https://example.com",
+			},
+		},
+		{
+			name: "Wildcard",
+			url:  "https://example.org/static/style.css",
+			header: "Cross-Origin-Resource-Policy: cross-origin\r\n" +
+				"Access-Control-Allow-Origin: *\r\n",
+			want: map[string]string{
+				"Cross-Origin-Resource-Policy": "cross-origin",
+				"Access-Control-Allow-Origin":  "*",
+			},
+		},
+		{
+			name:    "Missing",
+			url:     "https://example.org/static/style.css",
+			header:  "Cross-Origin-Resource-Policy: cross-origin\r\n",
+			wantErr: true,
+		},
+		{
+			name:       "AddMissing",
+			url:        "https://example.org/static/style.css",
+			header:     "",
+			addMissing: true,
+			want: map[string]string{
+				"Cross-Origin-Resource-Policy": "cross-origin",
+				"Access-Control-Allow-Origin":  "https://example.com",
+			},
+		},
+		{
+			name:       "Mismatch",
+			url:        "https://example.org/static/style.css",
+			header:     "Cross-Origin-Resource-Policy: same-origin\r\n",
+			addMissing: true,
+			wantErr:    true,
+		},
+		{
+			name:   "NotMatched",
+			url:    "https://example.org/index.html",
+			header: "",
+			want: map[string]string{
+				"Cross-Origin-Resource-Policy": "",
+				"Access-Control-Allow-Origin":  "",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule := rule
+			rule.AddMissing = test.addMissing
+			factory := exchange.NewFactory(exchange.Config{
+				CertChain:        certchaintest.MustReadAugmentedChainFile("../testdata/certs/cbor/ecdsap256_nosct.cbor"),
+				CertURL:          urlutil.MustParse("https://example.org/cert.cbor"),
+				PrivateKey:       certchaintest.MustReadPrivateKeyFile("../testdata/keys/ecdsap256.key"),
+				CrossOriginRules: []exchange.CrossOriginRule{rule},
+			})
+			resp := exchangetest.MakeResponse(test.url, fmt.Sprint(
+				"HTTP/1.1 200 OK\r\n",
+				"Content-Type: text/css\r\n",
+				test.header,
+				"\r\n",
+				"body { color: red; }",
+			))
+			e, err := factory.NewExchange(resp, vp, urlutil.MustParse(test.url+".validity"))
+			if test.wantErr {
+				if _, ok := err.(*exchange.CrossOriginHeaderError); !ok {
+					t.Errorf("got %#v, want CrossOriginHeaderError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got error(%q), want success", err)
+			}
+			for key, want := range test.want {
+				if got := e.ResponseHeaders.Get(key); got != want {
+					t.Errorf("ResponseHeaders.Get(%q) = %q, want %q", key, got, want)
+				}
+			}
+			if got := resp.Header.Get("Access-Control-Allow-Origin"); test.header == "" && got != "" {
+				t.Errorf("resp.Header modified: Access-Control-Allow-Origin = %q", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exchangetest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"time"
+
+	"github.com/WICG/webpackage/go/signedexchange"
+	"github.com/layer0-platform/webpackager/certchain"
+	"github.com/layer0-platform/webpackager/certchain/certchainutil"
+	"github.com/layer0-platform/webpackager/exchange"
+	"github.com/layer0-platform/webpackager/validity"
+)
+
+// oidCanSignHTTPExchanges is the OID of the CanSignHttpExchanges extension.
+var oidCanSignHTTPExchanges = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 1, 22}
+
+// Signer produces signed exchanges using a self-signed certificate and
+// a private key generated on the fly. The certificate is valid for any
+// date in practice, so Signer can sign with an arbitrary ValidPeriod.
+type Signer struct {
+	// CertChain is the certificate chain, consisting of the self-signed
+	// certificate with DummyOCSPResponse.
+	CertChain *certchain.AugmentedChain
+
+	// PrivateKey is the private key corresponding to CertChain.
+	PrivateKey *ecdsa.PrivateKey
+
+	// Factory is the Factory used by Sign.
+	Factory *exchange.Factory
+}
+
+// NewSigner creates a new Signer for certificates covering domains,
+// e.g. "example.org". When domains is empty, the certificate covers
+// "example.org" and "example.com".
+//
+// NewSigner panics on error for ease of use in testing.
+func NewSigner(domains ...string) *Signer {
+	if len(domains) == 0 {
+		domains = []string{"example.org", "example.com"}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: domains[0]},
+		DNSNames:     domains,
+		NotBefore:    time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtraExtensions: []pkix.Extension{
+			{Id: oidCanSignHTTPExchanges, Value: asn1.NullBytes},
+		},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		panic(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		panic(err)
+	}
+	rawChain, err := certchain.NewRawChain([]*x509.Certificate{cert})
+	if err != nil {
+		panic(err)
+	}
+
+	chain := certchain.NewAugmentedChain(rawChain, certchain.DummyOCSPResponse, nil)
+	return &Signer{
+		CertChain:  chain,
+		PrivateKey: key,
+		Factory: exchange.NewFactory(exchange.Config{
+			CertChain:  chain,
+			PrivateKey: key,
+		}),
+	}
+}
+
+// Sign produces a signed exchange from resp, valid for vp. The validity URL
+// is determined by validity.DefaultURLRule.
+//
+// Sign panics on error for ease of use in testing.
+func (s *Signer) Sign(resp *exchange.Response, vp exchange.ValidPeriod) *signedexchange.Exchange {
+	validityURL, err := validity.DefaultURLRule.Apply(resp.Request.URL, resp, vp)
+	if err != nil {
+		panic(err)
+	}
+	e, err := s.Factory.NewExchange(resp, vp, validityURL)
+	if err != nil {
+		panic(err)
+	}
+	return e
+}
+
+// CertFetcher returns a signedexchange.CertFetcher to provide CertChain,
+// for use with signedexchange.Exchange.Verify.
+func (s *Signer) CertFetcher() signedexchange.CertFetcher {
+	return certchainutil.WrapToCertFetcher(s.CertChain)
+}
@@ -0,0 +1,52 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exchangetest_test
+
+import (
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/layer0-platform/webpackager/exchange"
+	"github.com/layer0-platform/webpackager/exchange/exchangetest"
+)
+
+func TestSigner(t *testing.T) {
+	date := time.Date(2019, time.May, 13, 12, 34, 56, 0, time.UTC)
+	vp := exchange.NewValidPeriodWithLifetime(date, 24*time.Hour)
+
+	resp := exchangetest.MakeResponse(
+		"https://example.org/hello.html",
+		"HTTP/1.1 200 OK\r\n"+
+			"Content-Type: text/html;charset=utf-8\r\n"+
+			"\r\n"+
+			"<!doctype html><p>Hello, world!</p>")
+
+	s := exchangetest.NewSigner()
+	e := s.Sign(resp, vp)
+
+	var logText strings.Builder
+	payload, ok := e.Verify(date.Add(time.Hour), s.CertFetcher(), log.New(&logText, "", 0))
+	if !ok {
+		t.Fatalf("e.Verify() failed: %s", logText.String())
+	}
+	if got, want := string(payload), "<!doctype html><p>Hello, world!</p>"; got != want {
+		t.Errorf("payload = %q, want %q", got, want)
+	}
+	if got, want := e.RequestURI, "https://example.org/hello.html"; got != want {
+		t.Errorf("e.RequestURI = %q, want %q", got, want)
+	}
+}
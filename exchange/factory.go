@@ -16,13 +16,18 @@ package exchange
 
 import (
 	"errors"
+	"fmt"
 	"log"
+	"net/http"
 	"net/url"
 	"strings"
 	"time"
 
 	"github.com/WICG/webpackage/go/signedexchange"
+	"github.com/WICG/webpackage/go/signedexchange/structuredheader"
+	"github.com/WICG/webpackage/go/signedexchange/version"
 	"github.com/layer0-platform/webpackager/certchain/certchainutil"
+	"github.com/layer0-platform/webpackager/internal/timeutil"
 )
 
 // Factory produces and verifies signed exchanges.
@@ -43,34 +48,341 @@ func NewFactory(c Config) *Factory {
 }
 
 // NewExchange generates a signed exchange from resp, vp, and validityURL.
+// The signed exchange is signed under resp.Request.URL.
 func (fty *Factory) NewExchange(resp *Response, vp ValidPeriod, validityURL *url.URL) (*signedexchange.Exchange, error) {
-	u := resp.Request.URL
+	return fty.NewExchangeForURL(resp.Request.URL, resp, vp, validityURL)
+}
 
+// NewExchangeForURL is like NewExchange, but signs the signed exchange under
+// u instead of resp.Request.URL. u is also used to resolve CertURL.
+//
+// NewExchangeForURL returns an OriginNotAllowedError if the origin of u is
+// not in AllowedOrigins. It also fails if vp is longer than allowed by the
+// specification of Version (see MaxSignatureDuration).
+//
+// vp may start in the future, e.g. to prepare signed exchanges for scheduled
+// publishing; clients reject them until vp.Date(). NewExchangeForURL then
+// also fails if the certificate does not cover the entire vp, since nothing
+// can be done to fix those signed exchanges once they are distributed.
+func (fty *Factory) NewExchangeForURL(u *url.URL, resp *Response, vp ValidPeriod, validityURL *url.URL) (*signedexchange.Exchange, error) {
+	if err := fty.checkOrigin(u); err != nil {
+		return nil, err
+	}
+	if vp.Date().After(timeutil.Now()) {
+		if err := fty.CheckCertCoverage(vp); err != nil {
+			return nil, err
+		}
+	}
+	reqHeader, err := fty.signedRequestHeader(resp.Request.Header)
+	if err != nil {
+		return nil, err
+	}
+	if fty.AbsolutePreloadURLs {
+		resp = resp.withAbsolutePreloads()
+	}
+	respHeader := fty.addSignedHeaders(resp.GetFullHeader(fty.Config.KeepNonSXGPreloads))
+	if err := fty.applyCrossOriginRules(u, respHeader); err != nil {
+		return nil, err
+	}
+	if fty.CanonicalizeHeaders {
+		respHeader = canonicalHeader(respHeader)
+	}
 	e := signedexchange.NewExchange(
 		fty.Version,
 		u.String(),
 		resp.Request.Method,
-		resp.Request.Header,
+		reqHeader,
 		resp.StatusCode,
-		resp.GetFullHeader(fty.Config.KeepNonSXGPreloads),
+		respHeader,
 		resp.Payload)
-	if err := e.MiEncodePayload(fty.MIRecordSize); err != nil {
+	signer, err := fty.newSigner(u, vp, validityURL)
+	if err != nil {
+		return nil, err
+	}
+	recordSize := fty.MIRecordSizeFor(len(resp.Payload))
+	if recordSize != fty.MIRecordSize {
+		log.Printf("%v: raised MI record size from %d to %d to keep %d bytes within %d records",
+			u, fty.MIRecordSize, recordSize, len(resp.Payload), fty.MaxMIRecords)
+	}
+	err = fty.SigningLimiter.Run(func() error {
+		if err := e.MiEncodePayload(recordSize); err != nil {
+			return err
+		}
+		return e.AddSignatureHeader(signer)
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	signer := &signedexchange.Signer{
+	return e, nil
+}
+
+// MIRecordSizeFor returns the Merkle Integrity record size for a payload of
+// payloadSize bytes. It is MIRecordSize unless the payload would be split
+// into more than MaxMIRecords records, in which case it is the smallest size
+// to keep the payload within MaxMIRecords records, or 16384 (16 KiB),
+// whichever is smaller.
+func (fty *Factory) MIRecordSizeFor(payloadSize int) int {
+	if fty.MaxMIRecords <= 0 || fty.MIRecordSize >= maxMIRecordSize {
+		return fty.MIRecordSize
+	}
+	if numRecords(payloadSize, fty.MIRecordSize) <= fty.MaxMIRecords {
+		return fty.MIRecordSize
+	}
+	size := numRecords(payloadSize, fty.MaxMIRecords)
+	if size > maxMIRecordSize {
+		size = maxMIRecordSize
+	}
+	return size
+}
+
+// numRecords returns the number of records needed to hold size bytes,
+// with each record holding recordSize bytes.
+func numRecords(size, recordSize int) int {
+	return (size + recordSize - 1) / recordSize
+}
+
+// ReSign generates a new signed exchange from e with a fresh signature for
+// vp. It reuses the headers and the MI-encoded payload of e as they are,
+// so it is much cheaper than NewExchange for large payloads. The validity
+// URL is taken over from the signature of e; the cert-url is determined in
+// the same way as NewExchange.
+//
+// ReSign returns an error if the certificate does not cover vp. Note that
+// the returned exchange shares the headers and the payload with e, thus is
+// only valid as long as e is not mutated.
+func (fty *Factory) ReSign(e *signedexchange.Exchange, vp ValidPeriod) (*signedexchange.Exchange, error) {
+	if err := fty.CheckCertCoverage(vp); err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(e.RequestURI)
+	if err != nil {
+		return nil, err
+	}
+	if err := fty.checkOrigin(u); err != nil {
+		return nil, err
+	}
+	validityURL, err := GetValidityURL(e)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := fty.newSigner(u, vp, validityURL)
+	if err != nil {
+		return nil, err
+	}
+	resigned := *e
+	err = fty.SigningLimiter.Run(func() error {
+		return resigned.AddSignatureHeader(signer)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &resigned, nil
+}
+
+// NewSignature generates a fresh signature for e valid over vp, without
+// producing a new signed exchange. It signs the same headers and payload as
+// e, with the same validity URL and the cert-url determined in the same way
+// as NewExchange. The returned string is in the format of the Signature
+// header value, suitable for the update signatures in the validity data
+// (see validity.Data).
+//
+// NewSignature returns an error if the certificate does not cover vp.
+func (fty *Factory) NewSignature(e *signedexchange.Exchange, vp ValidPeriod) (string, error) {
+	resigned, err := fty.ReSign(e, vp)
+	if err != nil {
+		return "", err
+	}
+	return resigned.SignatureHeaderValue, nil
+}
+
+// NewAliasExchange generates a signed exchange for u, an alias of the URL e
+// is signed under (e.g. on another host serving the same content), reusing
+// the headers and the MI-encoded payload of e just as ReSign. The signature
+// has the same validity period as e, with validityURL and the cert-url
+// resolved against u.
+//
+// The headers are signed as they are, so absolute URLs in them (e.g. in
+// preload links) still point to the original host. NewAliasExchange returns
+// an OriginNotAllowedError if the origin of u is not in AllowedOrigins.
+func (fty *Factory) NewAliasExchange(e *signedexchange.Exchange, u *url.URL, validityURL *url.URL) (*signedexchange.Exchange, error) {
+	if err := fty.checkOrigin(u); err != nil {
+		return nil, err
+	}
+	vp, err := GetValidPeriod(e)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := fty.newSigner(u, vp, validityURL)
+	if err != nil {
+		return nil, err
+	}
+	alias := *e
+	alias.RequestURI = u.String()
+	err = fty.SigningLimiter.Run(func() error {
+		return alias.AddSignatureHeader(signer)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &alias, nil
+}
+
+// signedRequestHeader returns the request headers to include in the request
+// map, selected from header by SignedRequestHeaders.
+func (fty *Factory) signedRequestHeader(header http.Header) (http.Header, error) {
+	if fty.SignedRequestHeaders == nil {
+		return header, nil
+	}
+	if len(fty.SignedRequestHeaders) > 0 && fty.Version != version.Version1b1 && fty.Version != version.Version1b2 {
+		return nil, fmt.Errorf("version %s has no signed request headers", fty.Version)
+	}
+	selected := make(http.Header)
+	for _, key := range fty.SignedRequestHeaders {
+		if signedexchange.IsStatefulRequestHeader(key) {
+			return nil, fmt.Errorf("stateful request header %q cannot be signed", key)
+		}
+		key = http.CanonicalHeaderKey(key)
+		if values, ok := header[key]; ok {
+			selected[key] = append([]string(nil), values...)
+		}
+	}
+	return selected, nil
+}
+
+// CheckCertCoverage returns an error if the certificate is not valid for
+// the entire vp. Signed exchanges would then fail to validate in some part
+// of their validity period, although Verify does not detect it.
+func (fty *Factory) CheckCertCoverage(vp ValidPeriod) error {
+	leaf := fty.CertChain.Leaf
+	if vp.Date().Before(leaf.NotBefore) || vp.Expires().After(leaf.NotAfter) {
+		return fmt.Errorf("certificate valid from [%s] to [%s] does not cover %v",
+			leaf.NotBefore, leaf.NotAfter, vp)
+	}
+	return nil
+}
+
+// checkValidPeriod returns an error if vp is not allowed for signatures by
+// the specification of fty.Version.
+func (fty *Factory) checkValidPeriod(vp ValidPeriod) error {
+	if vp.Expires().Before(vp.Date()) {
+		return fmt.Errorf("valid period %v ends before it starts", vp)
+	}
+	max := MaxSignatureDuration(fty.Version)
+	if max == 0 {
+		return fmt.Errorf("unknown signed exchange version %q", fty.Version)
+	}
+	if vp.Lifetime() > max {
+		return fmt.Errorf("valid period %v is longer than %v, the maximum for version %s",
+			vp, max, fty.Version)
+	}
+	return nil
+}
+
+// addSignedHeaders adds fty.AddSignedHeaders to header and returns header.
+func (fty *Factory) addSignedHeaders(header http.Header) http.Header {
+	for key, values := range fty.AddSignedHeaders {
+		key = http.CanonicalHeaderKey(key)
+		if key == "Content-Encoding" || key == "Digest" {
+			continue
+		}
+		if _, ok := header[key]; ok && !fty.ReplaceSignedHeaders {
+			continue
+		}
+		header[key] = append([]string(nil), values...)
+	}
+	return header
+}
+
+// newSigner creates a signer for u and vp. It returns an error if the cert-url
+// resolved against u is neither an absolute https:// URL nor a data: URL, or
+// if validityURL is not an absolute https:// URL, in which case clients would
+// reject the signature anyway. It also returns an error if PrivateKey is not
+// usable with SigningAlgorithm.
+func (fty *Factory) newSigner(u *url.URL, vp ValidPeriod, validityURL *url.URL) (*signedexchange.Signer, error) {
+	if err := fty.checkValidPeriod(vp); err != nil {
+		return nil, err
+	}
+	if err := fty.SigningAlgorithm.CheckPrivateKey(fty.PrivateKey); err != nil {
+		return nil, err
+	}
+	certURL := u.ResolveReference(fty.CertURL)
+	if err := checkSignatureURL("cert-url", certURL, true); err != nil {
+		return nil, err
+	}
+	if err := checkSignatureURL("validity-url", validityURL, false); err != nil {
+		return nil, err
+	}
+	return &signedexchange.Signer{
 		Date:        vp.Date(),
 		Expires:     vp.Expires(),
 		Certs:       fty.CertChain.Certs,
-		CertUrl:     u.ResolveReference(fty.CertURL),
+		CertUrl:     certURL,
 		ValidityUrl: validityURL,
 		PrivKey:     fty.PrivateKey,
+	}, nil
+}
+
+// checkSignatureURL checks u is an absolute https:// URL, or a data: URL if
+// allowData is true. The cert-url may embed the certificate chain as a data:
+// URL, but the validity-url must be fetched from the origin.
+func checkSignatureURL(param string, u *url.URL, allowData bool) error {
+	if u == nil {
+		return fmt.Errorf("%s is missing", param)
+	}
+	if allowData && u.Scheme == "data" {
+		return nil
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("%s %q is not an absolute https:// URL", param, u)
 	}
-	if err := e.AddSignatureHeader(signer); err != nil {
+	return nil
+}
+
+// GetValidityURL returns the validity-url parameter of the (first) signature
+// of e.
+func GetValidityURL(e *signedexchange.Exchange) (*url.URL, error) {
+	params, err := getSignatureParams(e)
+	if err != nil {
 		return nil, err
 	}
+	rawurl, ok := params["validity-url"].(string)
+	if !ok {
+		return nil, errors.New("signature missing validity-url")
+	}
+	return url.Parse(rawurl)
+}
 
-	return e, nil
+// GetValidPeriod returns the period e is valid for, as the date and expires
+// parameters of its (first) signature.
+func GetValidPeriod(e *signedexchange.Exchange) (ValidPeriod, error) {
+	params, err := getSignatureParams(e)
+	if err != nil {
+		return ValidPeriod{}, err
+	}
+	date, ok := params["date"].(int64)
+	if !ok {
+		return ValidPeriod{}, errors.New("signature missing date")
+	}
+	expires, ok := params["expires"].(int64)
+	if !ok {
+		return ValidPeriod{}, errors.New("signature missing expires")
+	}
+	return NewValidPeriod(time.Unix(date, 0), time.Unix(expires, 0)), nil
+}
+
+func getSignatureParams(e *signedexchange.Exchange) (structuredheader.Parameters, error) {
+	sigs, err := structuredheader.ParseParameterisedList(e.SignatureHeaderValue)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature header: %v", err)
+	}
+	if len(sigs) == 0 {
+		return nil, errors.New("missing signature")
+	}
+	return sigs[0].Params, nil
 }
 
 // Verify validates the provided signed exchange e at the provided date.
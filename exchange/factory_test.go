@@ -18,14 +18,19 @@ import (
 	"bytes"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"regexp"
 	"testing"
 	"time"
 
 	"github.com/WICG/webpackage/go/signedexchange/structuredheader"
+	"github.com/WICG/webpackage/go/signedexchange/version"
+	"github.com/google/go-cmp/cmp"
+	"github.com/layer0-platform/webpackager/certchain"
 	"github.com/layer0-platform/webpackager/exchange"
 	"github.com/layer0-platform/webpackager/exchange/exchangetest"
 	"github.com/layer0-platform/webpackager/internal/certchaintest"
+	"github.com/layer0-platform/webpackager/internal/timeutil"
 	"github.com/layer0-platform/webpackager/internal/urlutil"
 	"github.com/layer0-platform/webpackager/resource"
 	"github.com/layer0-platform/webpackager/resource/preload"
@@ -201,3 +206,903 @@ func TestRelativeCertURL(t *testing.T) {
 		})
 	}
 }
+
+func TestDataCertURL(t *testing.T) {
+	const certURL = "data:application/cert-chain+cbor;base64,AAAA"
+
+	factory := exchange.NewFactory(exchange.Config{
+		CertChain:  certchaintest.MustReadAugmentedChainFile("../testdata/certs/cbor/ecdsap256_nosct.cbor"),
+		CertURL:    urlutil.MustParse(certURL),
+		PrivateKey: certchaintest.MustReadPrivateKeyFile("../testdata/keys/ecdsap256.key"),
+	})
+	vp := exchange.NewValidPeriod(
+		time.Date(2019, time.April, 22, 19, 30, 0, 0, time.UTC),
+		time.Date(2019, time.April, 29, 19, 30, 0, 0, time.UTC))
+	vu := urlutil.MustParse("https://example.org/index.html.validity")
+
+	resp := exchangetest.MakeEmptyResponse("https://example.org/index.html")
+	e, err := factory.NewExchange(resp, vp, vu)
+	if err != nil {
+		t.Fatalf("got error(%q), want success", err)
+	}
+	sig, err := structuredheader.ParseParameterisedList(e.SignatureHeaderValue)
+	if err != nil {
+		t.Fatalf("ParseParameterizedList() = error(%q), want success", err)
+	}
+	if len(sig) == 0 {
+		t.Fatal("ParseParameterizedList() = empty, want nonempty")
+	}
+	if got := sig[0].Params["cert-url"]; got != certURL {
+		t.Errorf(`sig[0].Params["cert-url"] = %q, want %q`, got, certURL)
+	}
+}
+
+func TestInvalidSignatureURLs(t *testing.T) {
+	vp := exchange.NewValidPeriod(
+		time.Date(2019, time.April, 22, 19, 30, 0, 0, time.UTC),
+		time.Date(2019, time.April, 29, 19, 30, 0, 0, time.UTC))
+
+	tests := []struct {
+		name        string
+		url         string
+		certURL     string
+		validityURL string
+		wantErr     string
+	}{
+		{
+			name:        "Valid",
+			url:         "https://example.org/index.html",
+			certURL:     "/cert.cbor",
+			validityURL: "https://example.org/index.html.validity",
+			wantErr:     "",
+		},
+		{
+			name:        "CertURLData",
+			url:         "https://example.org/index.html",
+			certURL:     "data:application/cert-chain+cbor;base64,AAAA",
+			validityURL: "https://example.org/index.html.validity",
+			wantErr:     "",
+		},
+		{
+			name:        "ValidityURLData",
+			url:         "https://example.org/index.html",
+			certURL:     "/cert.cbor",
+			validityURL: "data:application/cbor;base64,AAAA",
+			wantErr:     `validity-url "data:application/cbor;base64,AAAA" is not an absolute https:// URL`,
+		},
+		{
+			name:        "CertURLResolvedToHTTP",
+			url:         "http://example.org/index.html",
+			certURL:     "/cert.cbor",
+			validityURL: "https://example.org/index.html.validity",
+			wantErr:     `cert-url "http://example.org/cert.cbor" is not an absolute https:// URL`,
+		},
+		{
+			name:        "CertURLNotHTTPS",
+			url:         "https://example.org/index.html",
+			certURL:     "ftp://example.org/cert.cbor",
+			validityURL: "https://example.org/index.html.validity",
+			wantErr:     `cert-url "ftp://example.org/cert.cbor" is not an absolute https:// URL`,
+		},
+		{
+			name:        "ValidityURLRelative",
+			url:         "https://example.org/index.html",
+			certURL:     "/cert.cbor",
+			validityURL: "/index.html.validity",
+			wantErr:     `validity-url "/index.html.validity" is not an absolute https:// URL`,
+		},
+		{
+			name:        "ValidityURLNotHTTPS",
+			url:         "https://example.org/index.html",
+			certURL:     "/cert.cbor",
+			validityURL: "http://example.org/index.html.validity",
+			wantErr:     `validity-url "http://example.org/index.html.validity" is not an absolute https:// URL`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			factory := exchange.NewFactory(exchange.Config{
+				CertChain:  certchaintest.MustReadAugmentedChainFile("../testdata/certs/cbor/ecdsap256_nosct.cbor"),
+				CertURL:    urlutil.MustParse(test.certURL),
+				PrivateKey: certchaintest.MustReadPrivateKeyFile("../testdata/keys/ecdsap256.key"),
+			})
+			resp := exchangetest.MakeEmptyResponse(test.url)
+			vu := urlutil.MustParse(test.validityURL)
+			_, err := factory.NewExchange(resp, vp, vu)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Errorf("got error(%q), want success", err)
+				}
+			} else {
+				if err == nil || err.Error() != test.wantErr {
+					t.Errorf("got error(%v), want error(%q)", err, test.wantErr)
+				}
+			}
+		})
+	}
+}
+
+func TestSigningAlgorithm(t *testing.T) {
+	vp := exchange.NewValidPeriod(
+		time.Date(2019, time.April, 22, 19, 30, 0, 0, time.UTC),
+		time.Date(2019, time.April, 29, 19, 30, 0, 0, time.UTC))
+
+	tests := []struct {
+		name    string
+		alg     certchain.SigningAlgorithm
+		wantErr string
+	}{
+		{
+			name:    "Default",
+			alg:     "",
+			wantErr: "",
+		},
+		{
+			name:    "KeyMismatch",
+			alg:     certchain.ECDSAP384SHA384,
+			wantErr: "certchain: private key is for ecdsa_secp256r1_sha256, not ecdsa_secp384r1_sha384",
+		},
+		{
+			name:    "Unsupported",
+			alg:     "rsa_pss_rsae_sha256",
+			wantErr: `certchain: unsupported signing algorithm "rsa_pss_rsae_sha256"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			factory := exchange.NewFactory(exchange.Config{
+				CertChain:        certchaintest.MustReadAugmentedChainFile("../testdata/certs/cbor/ecdsap256_nosct.cbor"),
+				PrivateKey:       certchaintest.MustReadPrivateKeyFile("../testdata/keys/ecdsap256.key"),
+				SigningAlgorithm: test.alg,
+			})
+			resp := exchangetest.MakeEmptyResponse("https://example.org/index.html")
+			vu := urlutil.MustParse("https://example.org/index.html.validity")
+			_, err := factory.NewExchange(resp, vp, vu)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Errorf("got error(%q), want success", err)
+				}
+			} else {
+				if err == nil || err.Error() != test.wantErr {
+					t.Errorf("got error(%v), want error(%q)", err, test.wantErr)
+				}
+			}
+		})
+	}
+}
+
+func TestSignedRequestHeaders(t *testing.T) {
+	vp := exchange.NewValidPeriod(
+		time.Date(2019, time.April, 22, 19, 30, 0, 0, time.UTC),
+		time.Date(2019, time.April, 29, 19, 30, 0, 0, time.UTC))
+	vu := urlutil.MustParse("https://example.org/index.html.validity")
+
+	tests := []struct {
+		name    string
+		version version.Version
+		headers []string
+		want    http.Header
+		wantErr bool
+	}{
+		{
+			name:    "Selected",
+			version: version.Version1b2,
+			headers: []string{"accept-language", "Accept-Encoding"},
+			want:    http.Header{"Accept-Language": []string{"ja"}},
+		},
+		{
+			name:    "Empty",
+			version: version.Version1b2,
+			headers: []string{},
+			want:    http.Header{},
+		},
+		{
+			name:    "Stateful",
+			version: version.Version1b2,
+			headers: []string{"Cookie"},
+			wantErr: true,
+		},
+		{
+			name:    "NoRequestMap",
+			version: version.Version1b3,
+			headers: []string{"Accept-Language"},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			factory := exchange.NewFactory(exchange.Config{
+				Version:              test.version,
+				CertChain:            certchaintest.MustReadAugmentedChainFile("../testdata/certs/cbor/ecdsap256_nosct.cbor"),
+				PrivateKey:           certchaintest.MustReadPrivateKeyFile("../testdata/keys/ecdsap256.key"),
+				SignedRequestHeaders: test.headers,
+			})
+			resp := exchangetest.MakeEmptyResponse("https://example.org/index.html")
+			resp.Request.Header.Set("Accept-Language", "ja")
+			resp.Request.Header.Set("Cookie", "id=42")
+
+			e, err := factory.NewExchange(resp, vp, vu)
+			if test.wantErr {
+				if err == nil {
+					t.Error("got success, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got error(%q), want success", err)
+			}
+			if diff := cmp.Diff(test.want, e.RequestHeaders); diff != "" {
+				t.Errorf("e.RequestHeaders mismatch (-want +got):\n%s", diff)
+			}
+			if _, err := factory.Verify(e, vp.Date()); err != nil {
+				t.Errorf("Verify() = error(%q), want success", err)
+			}
+		})
+	}
+}
+
+func TestAllowedOrigins(t *testing.T) {
+	factory := exchange.NewFactory(exchange.Config{
+		CertChain:      certchaintest.MustReadAugmentedChainFile("../testdata/certs/cbor/ecdsap256_nosct.cbor"),
+		CertURL:        urlutil.MustParse("/cert.cbor"),
+		PrivateKey:     certchaintest.MustReadPrivateKeyFile("../testdata/keys/ecdsap256.key"),
+		AllowedOrigins: []string{"https://example.org", "https://WWW.example.org/"},
+	})
+	vp := exchange.NewValidPeriod(
+		time.Date(2019, time.April, 22, 19, 30, 0, 0, time.UTC),
+		time.Date(2019, time.April, 29, 19, 30, 0, 0, time.UTC))
+
+	tests := []struct {
+		name    string
+		url     string
+		allowed bool
+	}{
+		{
+			name:    "Allowed",
+			url:     "https://example.org/index.html",
+			allowed: true,
+		},
+		{
+			name:    "AllowedCaseInsensitive",
+			url:     "https://www.example.org/index.html",
+			allowed: true,
+		},
+		{
+			name:    "OtherHost",
+			url:     "https://example.com/index.html",
+			allowed: false,
+		},
+		{
+			name:    "OtherPort",
+			url:     "https://example.org:8443/index.html",
+			allowed: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := exchangetest.MakeEmptyResponse(test.url)
+			vu := urlutil.MustParse(test.url + ".validity")
+			_, err := factory.NewExchange(resp, vp, vu)
+			if test.allowed {
+				if err != nil {
+					t.Errorf("got error(%q), want success", err)
+				}
+				return
+			}
+			if _, ok := err.(*exchange.OriginNotAllowedError); !ok {
+				t.Errorf("got %#v, want OriginNotAllowedError", err)
+			}
+		})
+	}
+}
+
+func TestAddSignedHeaders(t *testing.T) {
+	vp := exchange.NewValidPeriod(
+		time.Date(2019, time.April, 22, 19, 30, 0, 0, time.UTC),
+		time.Date(2019, time.April, 29, 19, 30, 0, 0, time.UTC))
+	vu := urlutil.MustParse("https://example.org/hello.html.validity")
+	respText := fmt.Sprint(
+		"HTTP/1.1 200 OK\r\n",
+		"Content-Length: 35\r\n",
+		"Content-Type: text/html; charset=utf-8\r\n",
+		"X-Content-Type-Options: nosniff\r\n",
+		"\r\n",
+		"<!doctype html><p>Hello, world!</p>",
+	)
+	add := http.Header{
+		"Content-Security-Policy": []string{"default-src 'self'"},
+		"X-Content-Type-Options":  []string{"overridden"},
+		"Digest":                  []string{"invalid"},
+	}
+
+	tests := []struct {
+		name    string
+		replace bool
+		want    map[string]string
+	}{
+		{
+			name:    "Keep",
+			replace: false,
+			want: map[string]string{
+				"Content-Security-Policy": "default-src 'self'",
+				"X-Content-Type-Options":  "nosniff",
+				"Content-Type":            "text/html; charset=utf-8",
+			},
+		},
+		{
+			name:    "Replace",
+			replace: true,
+			want: map[string]string{
+				"Content-Security-Policy": "default-src 'self'",
+				"X-Content-Type-Options":  "overridden",
+				"Content-Type":            "text/html; charset=utf-8",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			factory := exchange.NewFactory(exchange.Config{
+				CertChain:            certchaintest.MustReadAugmentedChainFile("../testdata/certs/cbor/ecdsap256_nosct.cbor"),
+				CertURL:              urlutil.MustParse("https://example.org/cert.cbor"),
+				PrivateKey:           certchaintest.MustReadPrivateKeyFile("../testdata/keys/ecdsap256.key"),
+				AddSignedHeaders:     add,
+				ReplaceSignedHeaders: test.replace,
+			})
+			resp := exchangetest.MakeResponse("https://example.org/hello.html", respText)
+			e, err := factory.NewExchange(resp, vp, vu)
+			if err != nil {
+				t.Fatalf("got error(%q), want success", err)
+			}
+			for key, want := range test.want {
+				if got := e.ResponseHeaders.Get(key); got != want {
+					t.Errorf("ResponseHeaders.Get(%q) = %q, want %q", key, got, want)
+				}
+			}
+			if got := e.ResponseHeaders.Get("Digest"); got == "invalid" {
+				t.Errorf(`ResponseHeaders.Get("Digest") = %q, want the MI digest`, got)
+			}
+			if _, err := factory.Verify(e, vp.Date()); err != nil {
+				t.Errorf("Verify() = error(%q), want success", err)
+			}
+		})
+	}
+}
+
+func TestAbsolutePreloadURLs(t *testing.T) {
+	vp := exchange.NewValidPeriod(
+		time.Date(2019, time.April, 22, 19, 30, 0, 0, time.UTC),
+		time.Date(2019, time.April, 29, 19, 30, 0, 0, time.UTC))
+	vu := urlutil.MustParse("https://example.org/hello.html.validity")
+	respText := fmt.Sprint(
+		"HTTP/1.1 200 OK\r\n",
+		"Content-Length: 35\r\n",
+		"Content-Type: text/html; charset=utf-8\r\n",
+		"\r\n",
+		"<!doctype html><p>Hello, world!</p>",
+	)
+
+	tests := []struct {
+		name     string
+		absolute bool
+		want     string
+	}{
+		{
+			name:     "Absolute",
+			absolute: true,
+			want:     `<https://example.org/a.js>;rel="preload";as="script"`,
+		},
+		{
+			name:     "AsIs",
+			absolute: false,
+			want:     `<a.js>;rel="preload";as="script"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			factory := exchange.NewFactory(exchange.Config{
+				CertChain:           certchaintest.MustReadAugmentedChainFile("../testdata/certs/cbor/ecdsap256_nosct.cbor"),
+				CertURL:             urlutil.MustParse("https://example.org/cert.cbor"),
+				PrivateKey:          certchaintest.MustReadPrivateKeyFile("../testdata/keys/ecdsap256.key"),
+				KeepNonSXGPreloads:  true,
+				AbsolutePreloadURLs: test.absolute,
+			})
+			resp := exchangetest.MakeResponse("https://example.org/hello.html", respText)
+			resp.Preloads = []*preload.Preload{
+				preload.NewPreloadForURL(urlutil.MustParse("a.js"), preload.AsScript),
+			}
+			e, err := factory.NewExchange(resp, vp, vu)
+			if err != nil {
+				t.Fatalf("got error(%q), want success", err)
+			}
+			if got := e.ResponseHeaders.Get("Link"); got != test.want {
+				t.Errorf(`ResponseHeaders.Get("Link") = %q, want %q`, got, test.want)
+			}
+			if got := resp.Preloads[0].URL.String(); got != "a.js" {
+				t.Errorf("resp.Preloads[0].URL = %q, want %q", got, "a.js")
+			}
+		})
+	}
+}
+
+func TestMaxMIRecords(t *testing.T) {
+	tests := []struct {
+		name        string
+		recordSize  int
+		maxRecords  int
+		payloadSize int
+		want        int
+	}{
+		{
+			name:        "NoMaximum",
+			recordSize:  16,
+			maxRecords:  0,
+			payloadSize: 100000,
+			want:        16,
+		},
+		{
+			name:        "WithinMaximum",
+			recordSize:  16,
+			maxRecords:  4,
+			payloadSize: 64,
+			want:        16,
+		},
+		{
+			name:        "Raised",
+			recordSize:  16,
+			maxRecords:  4,
+			payloadSize: 65,
+			want:        17,
+		},
+		{
+			name:        "RaisedToSpecMaximum",
+			recordSize:  4096,
+			maxRecords:  10,
+			payloadSize: 1000000,
+			want:        16384,
+		},
+		{
+			name:        "EmptyPayload",
+			recordSize:  16,
+			maxRecords:  4,
+			payloadSize: 0,
+			want:        16,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			factory := exchange.NewFactory(exchange.Config{
+				MIRecordSize: test.recordSize,
+				MaxMIRecords: test.maxRecords,
+				CertChain:    certchaintest.MustReadAugmentedChainFile("../testdata/certs/cbor/ecdsap256_nosct.cbor"),
+				CertURL:      urlutil.MustParse("https://example.org/cert.cbor"),
+				PrivateKey:   certchaintest.MustReadPrivateKeyFile("../testdata/keys/ecdsap256.key"),
+			})
+			if got := factory.MIRecordSizeFor(test.payloadSize); got != test.want {
+				t.Errorf("MIRecordSizeFor(%d) = %d, want %d", test.payloadSize, got, test.want)
+			}
+		})
+	}
+}
+
+func TestMaxMIRecords_NewExchange(t *testing.T) {
+	factory := exchange.NewFactory(exchange.Config{
+		MIRecordSize: 8,
+		MaxMIRecords: 2,
+		CertChain:    certchaintest.MustReadAugmentedChainFile("../testdata/certs/cbor/ecdsap256_nosct.cbor"),
+		CertURL:      urlutil.MustParse("https://example.org/cert.cbor"),
+		PrivateKey:   certchaintest.MustReadPrivateKeyFile("../testdata/keys/ecdsap256.key"),
+	})
+	vp := exchange.NewValidPeriod(
+		time.Date(2019, time.April, 22, 19, 30, 0, 0, time.UTC),
+		time.Date(2019, time.April, 29, 19, 30, 0, 0, time.UTC))
+	vu := urlutil.MustParse("https://example.org/hello.html.validity")
+	resp := exchangetest.MakeResponse("https://example.org/hello.html", fmt.Sprint(
+		"HTTP/1.1 200 OK\r\n",
+		"Content-Length: 35\r\n",
+		"Content-Type: text/html; charset=utf-8\r\n",
+		"\r\n",
+		"<!doctype html><p>Hello, world!</p>",
+	))
+
+	e, err := factory.NewExchange(resp, vp, vu)
+	if err != nil {
+		t.Fatalf("got error(%q), want success", err)
+	}
+	r := resource.NewResource(urlutil.MustParse("https://example.org/hello.html"))
+	if err := r.SetExchange(e); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := r.MIRecordSize, 18; got != want {
+		t.Errorf("MIRecordSize = %d, want %d", got, want)
+	}
+}
+
+func TestMaxSignatureDuration(t *testing.T) {
+	factory := exchange.NewFactory(exchange.Config{
+		CertChain:  certchaintest.MustReadAugmentedChainFile("../testdata/certs/cbor/ecdsap256_nosct.cbor"),
+		CertURL:    urlutil.MustParse("https://example.org/cert.cbor"),
+		PrivateKey: certchaintest.MustReadPrivateKeyFile("../testdata/keys/ecdsap256.key"),
+	})
+	date := time.Date(2019, time.April, 22, 19, 30, 0, 0, time.UTC)
+	vu := urlutil.MustParse("https://example.org/hello.html.validity")
+
+	tests := []struct {
+		name     string
+		lifetime time.Duration
+		wantErr  bool
+	}{
+		{
+			name:     "Max",
+			lifetime: 7 * 24 * time.Hour,
+			wantErr:  false,
+		},
+		{
+			name:     "TooLong",
+			lifetime: 7*24*time.Hour + time.Second,
+			wantErr:  true,
+		},
+		{
+			name:     "Negative",
+			lifetime: -time.Hour,
+			wantErr:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := exchangetest.MakeResponse("https://example.org/hello.html", fmt.Sprint(
+				"HTTP/1.1 200 OK\r\n",
+				"Content-Length: 35\r\n",
+				"Content-Type: text/html;charset=utf-8\r\n",
+				"\r\n",
+				"<!doctype html><p>Hello, world!</p>",
+			))
+			vp := exchange.NewValidPeriodWithLifetime(date, test.lifetime)
+			_, err := factory.NewExchange(resp, vp, vu)
+			if test.wantErr && err == nil {
+				t.Error("NewExchange() = success, want error")
+			}
+			if !test.wantErr && err != nil {
+				t.Errorf("NewExchange() = error(%q), want success", err)
+			}
+		})
+	}
+}
+
+func TestFutureDate(t *testing.T) {
+	// The certificate is valid from 2020-04-01 to 2020-05-31.
+	factory := exchange.NewFactory(exchange.Config{
+		CertChain:  certchaintest.MustReadAugmentedChainFile("../testdata/certs/cbor/ecdsap256_nosct.cbor"),
+		CertURL:    urlutil.MustParse("https://example.org/cert.cbor"),
+		PrivateKey: certchaintest.MustReadPrivateKeyFile("../testdata/keys/ecdsap256.key"),
+	})
+	vu := urlutil.MustParse("https://example.org/hello.html.validity")
+
+	timeutil.StubNowWithFixedTime(time.Date(2020, time.April, 10, 0, 0, 0, 0, time.UTC))
+	defer timeutil.ResetNow()
+
+	tests := []struct {
+		name    string
+		date    time.Time
+		wantErr bool
+	}{
+		{
+			name:    "WithinCert",
+			date:    time.Date(2020, time.May, 1, 9, 0, 0, 0, time.UTC),
+			wantErr: false,
+		},
+		{
+			name:    "BeyondNotAfter",
+			date:    time.Date(2020, time.May, 28, 9, 0, 0, 0, time.UTC),
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := exchangetest.MakeResponse("https://example.org/hello.html", fmt.Sprint(
+				"HTTP/1.1 200 OK\r\n",
+				"Content-Length: 35\r\n",
+				"Content-Type: text/html;charset=utf-8\r\n",
+				"\r\n",
+				"<!doctype html><p>Hello, world!</p>",
+			))
+			vp := exchange.NewValidPeriodWithLifetime(test.date, 7*24*time.Hour)
+			e, err := factory.NewExchange(resp, vp, vu)
+			if test.wantErr {
+				if err == nil {
+					t.Error("NewExchange() = success, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewExchange() = error(%q), want success", err)
+			}
+			if got, err := exchange.GetValidPeriod(e); err != nil {
+				t.Errorf("GetValidPeriod() = error(%q), want success", err)
+			} else if !got.Date().Equal(test.date) {
+				t.Errorf("GetValidPeriod().Date() = %v, want %v", got.Date(), test.date)
+			}
+			if _, err := factory.Verify(e, test.date.Add(-time.Second)); err == nil {
+				t.Error("Verify() before the date = success, want error")
+			}
+			if _, err := factory.Verify(e, test.date); err != nil {
+				t.Errorf("Verify() at the date = error(%q), want success", err)
+			}
+		})
+	}
+}
+
+func TestCanonicalizeHeaders(t *testing.T) {
+	factory := exchange.NewFactory(exchange.Config{
+		CertChain:           certchaintest.MustReadAugmentedChainFile("../testdata/certs/cbor/ecdsap256_nosct.cbor"),
+		CertURL:             urlutil.MustParse("https://example.org/cert.cbor"),
+		PrivateKey:          certchaintest.MustReadPrivateKeyFile("../testdata/keys/ecdsap256.key"),
+		CanonicalizeHeaders: true,
+	})
+	vp := exchange.NewValidPeriod(
+		time.Date(2019, time.April, 22, 19, 30, 0, 0, time.UTC),
+		time.Date(2019, time.April, 29, 19, 30, 0, 0, time.UTC))
+	vu := urlutil.MustParse("https://example.org/hello.html.validity")
+	respText := fmt.Sprint(
+		"HTTP/1.1 200 OK\r\n",
+		"Content-Length: 35\r\n",
+		"Content-Type: text/html;charset=utf-8\r\n",
+		"\r\n",
+		"<!doctype html><p>Hello, world!</p>",
+	)
+
+	tests := []struct {
+		name   string
+		header http.Header
+	}{
+		{
+			name: "Canonical",
+			header: http.Header{
+				"Vary": []string{"Accept", "Cookie"},
+				"Link": []string{"<a.css>;rel=preload", "<b.css>;rel=preload"},
+			},
+		},
+		{
+			name: "Reordered",
+			header: http.Header{
+				"Vary": []string{"Cookie", "Accept"},
+				"Link": []string{"<a.css>;rel=preload", "<b.css>;rel=preload"},
+			},
+		},
+		{
+			name: "MixedCase",
+			header: http.Header{
+				"vary": []string{"Cookie"},
+				"VARY": []string{"Accept"},
+				"link": []string{"<a.css>;rel=preload", "<b.css>;rel=preload"},
+			},
+		},
+	}
+
+	var want []byte
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := exchangetest.MakeResponse("https://example.org/hello.html", respText)
+			for key, values := range test.header {
+				resp.Header[key] = values
+			}
+			e, err := factory.NewExchange(resp, vp, vu)
+			if err != nil {
+				t.Fatalf("NewExchange() = error(%q), want success", err)
+			}
+			if diff := cmp.Diff([]string{"Accept", "Cookie"}, e.ResponseHeaders["Vary"]); diff != "" {
+				t.Errorf(`ResponseHeaders["Vary"] mismatch (-want +got):\n%s`, diff)
+			}
+			wantLink := []string{"<a.css>;rel=preload", "<b.css>;rel=preload"}
+			if diff := cmp.Diff(wantLink, e.ResponseHeaders["Link"]); diff != "" {
+				t.Errorf(`ResponseHeaders["Link"] mismatch (-want +got):\n%s`, diff)
+			}
+
+			var b bytes.Buffer
+			if err := e.Write(&b); err != nil {
+				t.Fatalf("Write() = error(%q), want success", err)
+			}
+			got := eraseSignature(b.Bytes())
+			if want == nil {
+				want = got
+			} else if !bytes.Equal(got, want) {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestReSign(t *testing.T) {
+	factory := exchange.NewFactory(exchange.Config{
+		CertChain:  certchaintest.MustReadAugmentedChainFile("../testdata/certs/cbor/ecdsap256_nosct.cbor"),
+		CertURL:    urlutil.MustParse("https://example.org/cert.cbor"),
+		PrivateKey: certchaintest.MustReadPrivateKeyFile("../testdata/keys/ecdsap256.key"),
+	})
+	vp := exchange.NewValidPeriod(
+		time.Date(2020, time.April, 22, 19, 30, 0, 0, time.UTC),
+		time.Date(2020, time.April, 29, 19, 30, 0, 0, time.UTC))
+	html := "<!doctype html><p>Hello, world!</p>"
+	resp := exchangetest.MakeResponse("https://example.org/hello.html", fmt.Sprint(
+		"HTTP/1.1 200 OK\r\n",
+		"Cache-Control: public, max-age=604800\r\n",
+		"Content-Length: 35\r\n",
+		"Content-Type: text/html;charset=utf-8\r\n",
+		"\r\n",
+		html,
+	))
+	vu := urlutil.MustParse("https://example.org/hello.html.validity.1587583800")
+
+	e, err := factory.NewExchange(resp, vp, vu)
+	if err != nil {
+		t.Fatalf("NewExchange() = error(%q), want success", err)
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		newVP := exchange.NewValidPeriod(
+			time.Date(2020, time.April, 28, 19, 30, 0, 0, time.UTC),
+			time.Date(2020, time.May, 5, 19, 30, 0, 0, time.UTC))
+		got, err := factory.ReSign(e, newVP)
+		if err != nil {
+			t.Fatalf("ReSign() = error(%q), want success", err)
+		}
+		payload, err := factory.Verify(got, newVP.Expires())
+		if err != nil {
+			t.Fatalf("Verify() = error(%q), want success", err)
+		}
+		if string(payload) != html {
+			t.Errorf("payload = %q, want %q", payload, html)
+		}
+		sig, err := structuredheader.ParseParameterisedList(got.SignatureHeaderValue)
+		if err != nil {
+			t.Fatalf("ParseParameterizedList() = error(%q), want success", err)
+		}
+		if got := sig[0].Params["validity-url"]; got != vu.String() {
+			t.Errorf(`sig[0].Params["validity-url"] = %q, want %q`, got, vu)
+		}
+		if got := sig[0].Params["date"]; got != newVP.Date().Unix() {
+			t.Errorf(`sig[0].Params["date"] = %v, want %v`, got, newVP.Date().Unix())
+		}
+		if _, err := factory.Verify(e, vp.Date()); err != nil {
+			t.Errorf("Verify(original) = error(%q), want success", err)
+		}
+	})
+
+	t.Run("BeyondCertExpiry", func(t *testing.T) {
+		newVP := exchange.NewValidPeriod(
+			time.Date(2020, time.May, 28, 19, 30, 0, 0, time.UTC),
+			time.Date(2020, time.June, 4, 19, 30, 0, 0, time.UTC))
+		if _, err := factory.ReSign(e, newVP); err == nil {
+			t.Error("ReSign() = success, want error")
+		}
+	})
+
+	t.Run("NewSignature", func(t *testing.T) {
+		newVP := exchange.NewValidPeriod(
+			time.Date(2020, time.April, 28, 19, 30, 0, 0, time.UTC),
+			time.Date(2020, time.May, 5, 19, 30, 0, 0, time.UTC))
+		got, err := factory.NewSignature(e, newVP)
+		if err != nil {
+			t.Fatalf("NewSignature() = error(%q), want success", err)
+		}
+		sig, err := structuredheader.ParseParameterisedList(got)
+		if err != nil {
+			t.Fatalf("ParseParameterizedList() = error(%q), want success", err)
+		}
+		if got := sig[0].Params["validity-url"]; got != vu.String() {
+			t.Errorf(`sig[0].Params["validity-url"] = %q, want %q`, got, vu)
+		}
+		if got := sig[0].Params["expires"]; got != newVP.Expires().Unix() {
+			t.Errorf(`sig[0].Params["expires"] = %v, want %v`, got, newVP.Expires().Unix())
+		}
+		if got == e.SignatureHeaderValue {
+			t.Error("NewSignature() = original signature, want fresh one")
+		}
+	})
+}
+
+func TestNewAliasExchange(t *testing.T) {
+	factory := exchange.NewFactory(exchange.Config{
+		CertChain:      certchaintest.MustReadAugmentedChainFile("../testdata/certs/cbor/ecdsap256_nosct.cbor"),
+		CertURL:        urlutil.MustParse("/cert.cbor"),
+		PrivateKey:     certchaintest.MustReadPrivateKeyFile("../testdata/keys/ecdsap256.key"),
+		AllowedOrigins: []string{"https://example.org", "https://www.example.org"},
+	})
+	vp := exchange.NewValidPeriod(
+		time.Date(2020, time.April, 22, 19, 30, 0, 0, time.UTC),
+		time.Date(2020, time.April, 29, 19, 30, 0, 0, time.UTC))
+	html := "<!doctype html><p>Hello, world!</p>"
+	resp := exchangetest.MakeResponse("https://example.org/hello.html", fmt.Sprint(
+		"HTTP/1.1 200 OK\r\n",
+		"Content-Length: 35\r\n",
+		"Content-Type: text/html;charset=utf-8\r\n",
+		"\r\n",
+		html,
+	))
+	e, err := factory.NewExchange(resp, vp, urlutil.MustParse("https://example.org/hello.html.validity"))
+	if err != nil {
+		t.Fatalf("NewExchange() = error(%q), want success", err)
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		u := urlutil.MustParse("https://www.example.org/hello.html")
+		vu := urlutil.MustParse("https://www.example.org/hello.html.validity")
+		got, err := factory.NewAliasExchange(e, u, vu)
+		if err != nil {
+			t.Fatalf("NewAliasExchange() = error(%q), want success", err)
+		}
+		if got.RequestURI != u.String() {
+			t.Errorf("RequestURI = %q, want %q", got.RequestURI, u)
+		}
+		if e.RequestURI != "https://example.org/hello.html" {
+			t.Errorf("original RequestURI = %q, want unchanged", e.RequestURI)
+		}
+		payload, err := factory.Verify(got, vp.Date())
+		if err != nil {
+			t.Fatalf("Verify() = error(%q), want success", err)
+		}
+		if string(payload) != html {
+			t.Errorf("payload = %q, want %q", payload, html)
+		}
+		gotVP, err := exchange.GetValidPeriod(got)
+		if err != nil {
+			t.Fatalf("GetValidPeriod() = error(%q), want success", err)
+		}
+		if !gotVP.Date().Equal(vp.Date()) || !gotVP.Expires().Equal(vp.Expires()) {
+			t.Errorf("GetValidPeriod() = %v, want %v", gotVP, vp)
+		}
+		sig, err := structuredheader.ParseParameterisedList(got.SignatureHeaderValue)
+		if err != nil {
+			t.Fatalf("ParseParameterizedList() = error(%q), want success", err)
+		}
+		if got, want := sig[0].Params["cert-url"], "https://www.example.org/cert.cbor"; got != want {
+			t.Errorf(`sig[0].Params["cert-url"] = %q, want %q`, got, want)
+		}
+	})
+
+	t.Run("OriginNotAllowed", func(t *testing.T) {
+		u := urlutil.MustParse("https://example.com/hello.html")
+		vu := urlutil.MustParse("https://example.com/hello.html.validity")
+		_, err := factory.NewAliasExchange(e, u, vu)
+		if _, ok := err.(*exchange.OriginNotAllowedError); !ok {
+			t.Errorf("got %#v, want OriginNotAllowedError", err)
+		}
+	})
+}
+
+func TestGetValidPeriod(t *testing.T) {
+	factory := exchange.NewFactory(exchange.Config{
+		CertChain:  certchaintest.MustReadAugmentedChainFile("../testdata/certs/cbor/ecdsap256_nosct.cbor"),
+		CertURL:    urlutil.MustParse("https://example.org/cert.cbor"),
+		PrivateKey: certchaintest.MustReadPrivateKeyFile("../testdata/keys/ecdsap256.key"),
+	})
+	vp := exchange.NewValidPeriod(
+		time.Date(2019, time.April, 22, 19, 30, 0, 0, time.UTC),
+		time.Date(2019, time.April, 29, 19, 30, 0, 0, time.UTC))
+	resp := exchangetest.MakeResponse("https://example.org/hello.html", fmt.Sprint(
+		"HTTP/1.1 200 OK\r\n",
+		"Content-Length: 35\r\n",
+		"Content-Type: text/html;charset=utf-8\r\n",
+		"\r\n",
+		"<!doctype html><p>Hello, world!</p>",
+	))
+	e, err := factory.NewExchange(resp, vp, urlutil.MustParse("https://example.org/hello.html.validity"))
+	if err != nil {
+		t.Fatalf("NewExchange() = error(%q), want success", err)
+	}
+
+	got, err := exchange.GetValidPeriod(e)
+	if err != nil {
+		t.Fatalf("GetValidPeriod() = error(%q), want success", err)
+	}
+	if !got.Date().Equal(vp.Date()) || !got.Expires().Equal(vp.Expires()) {
+		t.Errorf("GetValidPeriod() = %v, want %v", got, vp)
+	}
+}
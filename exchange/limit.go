@@ -0,0 +1,97 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exchange
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// SigningLimiter limits the number of CPU-heavy signing operations, namely
+// the Merkle Integrity encoding and the signature generation, running at
+// the same time. Operations over the limit are queued until another one
+// completes. It is safe for concurrent use by multiple goroutines.
+//
+// A SigningLimiter is typically shared by all Factories, so the limit
+// applies across certificate rotations.
+type SigningLimiter struct {
+	sem      chan struct{}
+	queued   int64
+	waits    int64
+	waitTime int64 // in nanoseconds
+}
+
+// NewSigningLimiter creates and initializes a new SigningLimiter to allow
+// at most limit signing operations at a time.
+//
+// NewSigningLimiter panics if limit is not positive.
+func NewSigningLimiter(limit int) *SigningLimiter {
+	if limit <= 0 {
+		panic("exchange: non-positive signing limit")
+	}
+	return &SigningLimiter{sem: make(chan struct{}, limit)}
+}
+
+// Run runs f once the limit allows, and returns the error from f. Run just
+// calls f if l is nil.
+func (l *SigningLimiter) Run(f func() error) error {
+	if l == nil {
+		return f()
+	}
+	l.acquire()
+	defer l.release()
+	return f()
+}
+
+// InFlight returns the number of signing operations currently running.
+func (l *SigningLimiter) InFlight() int {
+	return len(l.sem)
+}
+
+// QueueDepth returns the number of signing operations currently waiting
+// for others to complete.
+func (l *SigningLimiter) QueueDepth() int {
+	return int(atomic.LoadInt64(&l.queued))
+}
+
+// Waits returns the total number of signing operations which have waited
+// in the queue.
+func (l *SigningLimiter) Waits() int {
+	return int(atomic.LoadInt64(&l.waits))
+}
+
+// WaitTime returns the total time signing operations have spent waiting
+// in the queue.
+func (l *SigningLimiter) WaitTime() time.Duration {
+	return time.Duration(atomic.LoadInt64(&l.waitTime))
+}
+
+func (l *SigningLimiter) acquire() {
+	select {
+	case l.sem <- struct{}{}:
+		return
+	default:
+	}
+	atomic.AddInt64(&l.queued, 1)
+	start := time.Now()
+	l.sem <- struct{}{}
+	atomic.AddInt64(&l.waitTime, int64(time.Since(start)))
+	atomic.AddInt64(&l.waits, 1)
+	atomic.AddInt64(&l.queued, -1)
+}
+
+func (l *SigningLimiter) release() {
+	<-l.sem
+}
@@ -0,0 +1,84 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exchange_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/layer0-platform/webpackager/exchange"
+)
+
+func TestSigningLimiter(t *testing.T) {
+	limiter := exchange.NewSigningLimiter(1)
+
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	firstDone := make(chan error)
+	go func() {
+		firstDone <- limiter.Run(func() error {
+			close(started)
+			<-unblock
+			return nil
+		})
+	}()
+	<-started
+	if got := limiter.InFlight(); got != 1 {
+		t.Errorf("InFlight() = %d, want 1", got)
+	}
+
+	errSecond := errors.New("second")
+	secondDone := make(chan error)
+	go func() {
+		secondDone <- limiter.Run(func() error { return errSecond })
+	}()
+	for limiter.QueueDepth() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(unblock)
+
+	if err := <-firstDone; err != nil {
+		t.Errorf("first Run() = error(%q), want success", err)
+	}
+	if err := <-secondDone; err != errSecond {
+		t.Errorf("second Run() = error(%v), want %q", err, errSecond)
+	}
+	if got := limiter.QueueDepth(); got != 0 {
+		t.Errorf("QueueDepth() = %d, want 0", got)
+	}
+	if got := limiter.InFlight(); got != 0 {
+		t.Errorf("InFlight() = %d, want 0", got)
+	}
+	if got := limiter.Waits(); got != 1 {
+		t.Errorf("Waits() = %d, want 1", got)
+	}
+	if got := limiter.WaitTime(); got < 10*time.Millisecond {
+		t.Errorf("WaitTime() = %v, want >= 10ms", got)
+	}
+}
+
+func TestSigningLimiter_Nil(t *testing.T) {
+	var limiter *exchange.SigningLimiter
+	called := false
+	err := limiter.Run(func() error {
+		called = true
+		return nil
+	})
+	if err != nil || !called {
+		t.Errorf("Run() = error(%v), called = %v; want success", err, called)
+	}
+}
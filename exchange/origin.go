@@ -0,0 +1,48 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exchange
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// OriginNotAllowedError is returned by Factory when it is requested to sign
+// a URL whose origin is not in Config.AllowedOrigins.
+type OriginNotAllowedError struct {
+	// URL represents the URL requested to be signed.
+	URL *url.URL
+}
+
+// Error implements the error interface.
+func (e *OriginNotAllowedError) Error() string {
+	return fmt.Sprintf("origin of %v is not allowed to sign", e.URL)
+}
+
+// checkOrigin returns an OriginNotAllowedError if u is not allowed to sign
+// by fty.AllowedOrigins.
+func (fty *Factory) checkOrigin(u *url.URL) error {
+	if len(fty.AllowedOrigins) == 0 {
+		return nil
+	}
+	origin := strings.ToLower(u.Scheme + "://" + u.Host)
+	for _, allowed := range fty.AllowedOrigins {
+		if strings.ToLower(strings.TrimSuffix(allowed, "/")) == origin {
+			return nil
+		}
+	}
+	return &OriginNotAllowedError{u}
+}
@@ -0,0 +1,79 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exchange
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/WICG/webpackage/go/signedexchange"
+)
+
+// redirectCodes is the set of status codes NewRedirectResponse accepts.
+var redirectCodes = map[int]bool{
+	http.StatusMovedPermanently:  true, // 301
+	http.StatusFound:             true, // 302
+	http.StatusSeeOther:          true, // 303
+	http.StatusTemporaryRedirect: true, // 307
+	http.StatusPermanentRedirect: true, // 308
+}
+
+// NewRedirectResponse creates a new Response redirecting a GET request for
+// source to target, with the provided redirect status code (e.g. 301) and
+// an empty payload (declared as text/plain). target may be relative to
+// source; the Location header carries the absolute URL. source must be an
+// absolute URL.
+//
+// The Response is meant to be signed directly by Factory: processors which
+// only allow 200 (OK), such as preverify.HTTPStatusOK, would reject it.
+func NewRedirectResponse(source, target *url.URL, statusCode int) (*Response, error) {
+	if !redirectCodes[statusCode] {
+		return nil, fmt.Errorf("status code %d is not for redirects", statusCode)
+	}
+	if !source.IsAbs() {
+		return nil, fmt.Errorf("source URL %v is not absolute", source)
+	}
+	req, err := http.NewRequest(http.MethodGet, source.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	header := make(http.Header)
+	header.Set("Location", source.ResolveReference(target).String())
+	header.Set("Content-Length", "0")
+	// Verification requires Content-Type even with the empty payload.
+	header.Set("Content-Type", "text/plain; charset=utf-8")
+	resp := &http.Response{
+		Status:     fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+		StatusCode: statusCode,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     header,
+		Body:       http.NoBody,
+		Request:    req,
+	}
+	return &Response{resp, []byte{}, nil, make(http.Header)}, nil
+}
+
+// NewRedirectExchange generates a signed exchange redirecting from source
+// to target with statusCode. See NewRedirectResponse and NewExchange.
+func (fty *Factory) NewRedirectExchange(source, target *url.URL, statusCode int, vp ValidPeriod, validityURL *url.URL) (*signedexchange.Exchange, error) {
+	resp, err := NewRedirectResponse(source, target, statusCode)
+	if err != nil {
+		return nil, err
+	}
+	return fty.NewExchange(resp, vp, validityURL)
+}
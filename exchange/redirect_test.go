@@ -0,0 +1,92 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exchange_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/layer0-platform/webpackager/exchange"
+	"github.com/layer0-platform/webpackager/internal/certchaintest"
+	"github.com/layer0-platform/webpackager/internal/urlutil"
+)
+
+func TestNewRedirectExchange(t *testing.T) {
+	factory := exchange.NewFactory(exchange.Config{
+		CertChain:  certchaintest.MustReadAugmentedChainFile("../testdata/certs/cbor/ecdsap256_nosct.cbor"),
+		CertURL:    urlutil.MustParse("https://example.org/cert.cbor"),
+		PrivateKey: certchaintest.MustReadPrivateKeyFile("../testdata/keys/ecdsap256.key"),
+	})
+	vp := exchange.NewValidPeriod(
+		time.Date(2019, time.April, 22, 19, 30, 0, 0, time.UTC),
+		time.Date(2019, time.April, 29, 19, 30, 0, 0, time.UTC))
+	vu := urlutil.MustParse("https://example.org/old.validity")
+
+	e, err := factory.NewRedirectExchange(
+		urlutil.MustParse("https://example.org/old"),
+		urlutil.MustParse("/new"),
+		http.StatusMovedPermanently, vp, vu)
+	if err != nil {
+		t.Fatalf("NewRedirectExchange() = error(%q), want success", err)
+	}
+	if got, want := e.ResponseStatus, http.StatusMovedPermanently; got != want {
+		t.Errorf("ResponseStatus = %v, want %v", got, want)
+	}
+	if got, want := e.ResponseHeaders.Get("Location"), "https://example.org/new"; got != want {
+		t.Errorf(`ResponseHeaders.Get("Location") = %q, want %q`, got, want)
+	}
+	payload, err := factory.Verify(e, vp.Date())
+	if err != nil {
+		t.Fatalf("Verify() = error(%q), want success", err)
+	}
+	if len(payload) != 0 {
+		t.Errorf("payload = %q, want empty", payload)
+	}
+}
+
+func TestNewRedirectResponse_Error(t *testing.T) {
+	tests := []struct {
+		name       string
+		source     string
+		target     string
+		statusCode int
+	}{
+		{
+			name:       "NotRedirect",
+			source:     "https://example.org/old",
+			target:     "https://example.org/new",
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "RelativeSource",
+			source:     "/old",
+			target:     "https://example.org/new",
+			statusCode: http.StatusMovedPermanently,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := exchange.NewRedirectResponse(
+				urlutil.MustParse(test.source),
+				urlutil.MustParse(test.target),
+				test.statusCode)
+			if err == nil {
+				t.Error("NewRedirectResponse() = success, want error")
+			}
+		})
+	}
+}
@@ -15,17 +15,42 @@
 package exchange
 
 import (
+	"bytes"
+	"io"
 	"io/ioutil"
+	"log"
 	"net/http"
 
+	"github.com/layer0-platform/webpackager/resource/httplink"
 	"github.com/layer0-platform/webpackager/resource/preload"
 )
 
 // These are keys used in ExtraData. They are prefixed with "X-WebPackager"
 // to avoid confusion with real HTTP headers.
 const (
+	// The URL the signed exchange is issued for, which may differ from
+	// the request URL. Set by webpackager.Packager before processing.
+	SignedURL = "Webpackager-Signed-URL"
+
 	// See htmltask.ExtractSubContentTypes.
 	SubContentType = "Webpackager-Sub-Content-Type"
+
+	// See commonproc.SniffContentType.
+	SniffedContentType = "Webpackager-Sniffed-Content-Type"
+
+	// See commonproc.CheckPreloadTargets.
+	DroppedPreload = "Webpackager-Dropped-Preload"
+
+	// See commonproc.ExtractPreloadHeaders.
+	OverCapPreload = "Webpackager-Over-Cap-Preload"
+
+	// See htmltask.ReportLazyAboveFold.
+	LazyAboveFoldImage = "Webpackager-Lazy-Above-Fold-Image"
+
+	// Human-readable descriptions of the changes processors made to
+	// the response, e.g. "removed header Set-Cookie", for auditing. See
+	// resource.Resource.Transformations.
+	AppliedTransformation = "Webpackager-Applied-Transformation"
 )
 
 const linkHeader = "Link"
@@ -71,7 +96,16 @@ func NewResponse(resp *http.Response) (*Response, error) {
 // AddPreload adds p to resp.Preloads if p is not already in resp.Preloads,
 // and reports whether p was added. It considers Preloads to be equal when
 // their Links are equal.
+//
+// AddPreload also refuses p, with a warning, if the response is for an https
+// URL and p references non-https resources: preloading them would result in
+// mixed-content errors.
 func (resp *Response) AddPreload(p *preload.Preload) bool {
+	if resp.isMixedContent(p) {
+		log.Printf("warning: %s: skipped preloading mixed content %s",
+			resp.Request.URL, p.URL)
+		return false
+	}
 	for _, q := range resp.Preloads {
 		if p.Link.Equal(q.Link) {
 			return false
@@ -81,6 +115,33 @@ func (resp *Response) AddPreload(p *preload.Preload) bool {
 	return true
 }
 
+func (resp *Response) isMixedContent(p *preload.Preload) bool {
+	if resp.Request == nil || resp.Request.URL.Scheme != "https" {
+		return false
+	}
+	for _, r := range p.Resources {
+		if r.RequestURL.Scheme != "https" {
+			return true
+		}
+	}
+	return false
+}
+
+// withAbsolutePreloads returns a shallow copy of resp with the preload link
+// URLs resolved against resp.Request.URL.
+func (resp *Response) withAbsolutePreloads() *Response {
+	abs := *resp
+	abs.Preloads = make([]*preload.Preload, len(resp.Preloads))
+	for i, p := range resp.Preloads {
+		link := &httplink.Link{
+			URL:    resp.Request.URL.ResolveReference(p.URL),
+			Params: p.Params,
+		}
+		abs.Preloads[i] = &preload.Preload{Link: link, Resources: p.Resources}
+	}
+	return &abs
+}
+
 // GetFullHeader returns a new http.Header containing all header items
 // from resp.Header and resp.Preloads. GetFullHeader makes a deep copy of
 // resp.Header, thus does not mutate it.
@@ -107,3 +168,31 @@ func (resp *Response) GetFullHeader(keepNonSXGPreloads bool) http.Header {
 
 	return header
 }
+
+// WriteUnsigned writes resp to w in the HTTP/1.1 wire format, with Payload
+// as the body, so the response can be served as is by distributors that
+// also serve the plain content. The preload links are included without
+// the allowed-alt-sxg links, which are meaningful only in signed exchanges.
+// WriteUnsigned does not mutate resp.
+func (resp *Response) WriteUnsigned(w io.Writer) error {
+	header := resp.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	for _, p := range resp.Preloads {
+		header.Add(linkHeader, p.Link.String())
+	}
+	header.Del("Content-Length")
+
+	plain := &http.Response{
+		Status:        resp.Status,
+		StatusCode:    resp.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(resp.Payload)),
+		ContentLength: int64(len(resp.Payload)),
+	}
+	return plain.Write(w)
+}
@@ -101,6 +101,17 @@ func TestAddPreload(t *testing.T) {
 			},
 			added: false,
 		},
+		{
+			name: "MixedContent",
+			pre: []*preload.Preload{
+				pl("https://example.org/foo.css", preload.AsStyle),
+			},
+			item: pl("http://example.org/bar.css", preload.AsStyle),
+			post: []*preload.Preload{
+				pl("https://example.org/foo.css", preload.AsStyle),
+			},
+			added: false,
+		},
 	}
 
 	for _, test := range tests {
@@ -160,3 +171,36 @@ func TestGetFullHeader_PreserveLinkHeader(t *testing.T) {
 		t.Errorf("resp.Header[\"Link\"] mismatch (-want +got):\n%s", diff)
 	}
 }
+
+func TestWriteUnsigned(t *testing.T) {
+	resp := exchangetest.MakeResponse(
+		"https://example.org/hello.html",
+		fmt.Sprint(
+			"HTTP/1.1 200 OK\r\n",
+			"Content-Length: 35\r\n",
+			"Content-Type: text/html; charset=utf-8\r\n",
+			"\r\n",
+			"<!doctype html><p>Hello, world!</p>",
+		))
+	resp.Payload = []byte("<!doctype html><p>Hello!</p>")
+	resp.AddPreload(preloadtest.NewPreloadForRawURL("https://example.org/style.css", preload.AsStyle))
+
+	var buf bytes.Buffer
+	if err := resp.WriteUnsigned(&buf); err != nil {
+		t.Fatalf("WriteUnsigned() = error(%q), want success", err)
+	}
+	want := fmt.Sprint(
+		"HTTP/1.1 200 OK\r\n",
+		"Content-Length: 28\r\n",
+		"Content-Type: text/html; charset=utf-8\r\n",
+		"Link: <https://example.org/style.css>;rel=\"preload\";as=\"style\"\r\n",
+		"\r\n",
+		"<!doctype html><p>Hello!</p>",
+	)
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("WriteUnsigned() mismatch (-want +got):\n%s", diff)
+	}
+	if got := resp.Header.Get("Link"); got != "" {
+		t.Errorf(`resp.Header.Get("Link") = %q, want ""`, got)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exchange
+
+import (
+	"fmt"
+
+	"github.com/WICG/webpackage/go/signedexchange"
+	"github.com/WICG/webpackage/go/signedexchange/version"
+)
+
+// These are the limits signedexchange.Exchange.Write imposes on the 1b2 and
+// 1b3 formats.
+const (
+	maxSignatureHeaderValueLen = 16 * 1024
+	maxHeaderLen               = 512 * 1024
+)
+
+// EncodedSize returns the number of bytes e.Write writes, i.e. the size of e
+// in the application/signed-exchange format, without serializing the payload.
+// It is useful to set Content-Length before streaming e to a client.
+//
+// EncodedSize fails if e.Write would fail for reasons other than I/O errors,
+// e.g. when the headers are too large, so the caller can detect such errors
+// before writing anything.
+func EncodedSize(e *signedexchange.Exchange) (int64, error) {
+	var headers countingWriter
+	if err := e.DumpExchangeHeaders(&headers); err != nil {
+		return 0, err
+	}
+
+	size := int64(len(e.Version.HeaderMagicBytes()))
+	switch e.Version {
+	case version.Version1b1:
+		// sigLength and headerLength.
+		size += 3 + 3
+	case version.Version1b2, version.Version1b3:
+		if len(e.SignatureHeaderValue) > maxSignatureHeaderValueLen {
+			return 0, fmt.Errorf("signature too large: %d bytes", len(e.SignatureHeaderValue))
+		}
+		if headers.n > maxHeaderLen {
+			return 0, fmt.Errorf("headers too large: %d bytes", headers.n)
+		}
+		// fallbackUrlLength, fallbackUrl, sigLength, and headerLength.
+		if len(e.RequestURI) >= 1<<16 {
+			return 0, fmt.Errorf("fallback URL too long: %d bytes", len(e.RequestURI))
+		}
+		size += 2 + int64(len(e.RequestURI)) + 3 + 3
+	default:
+		return 0, fmt.Errorf("unknown version %q", e.Version)
+	}
+	return size + int64(len(e.SignatureHeaderValue)) + headers.n + int64(len(e.Payload)), nil
+}
+
+// countingWriter counts the bytes written to it and discards them.
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
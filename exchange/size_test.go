@@ -0,0 +1,112 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exchange_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/WICG/webpackage/go/signedexchange/version"
+	"github.com/layer0-platform/webpackager/exchange"
+	"github.com/layer0-platform/webpackager/exchange/exchangetest"
+	"github.com/layer0-platform/webpackager/internal/certchaintest"
+	"github.com/layer0-platform/webpackager/internal/urlutil"
+)
+
+func TestEncodedSize(t *testing.T) {
+	tests := []struct {
+		version version.Version
+		body    string
+	}{
+		{version.Version1b1, "<!doctype html><p>Hello, world!</p>"},
+		{version.Version1b2, "<!doctype html><p>Hello, world!</p>"},
+		{version.Version1b3, "<!doctype html><p>Hello, world!</p>"},
+		{version.Version1b3, strings.Repeat("<p>Hello, world!</p>", 10000)},
+		{version.Version1b3, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s_%d", test.version, len(test.body)), func(t *testing.T) {
+			factory := exchange.NewFactory(exchange.Config{
+				Version:      test.version,
+				MIRecordSize: 4096,
+				CertChain:    certchaintest.MustReadAugmentedChainFile("../testdata/certs/cbor/ecdsap256_nosct.cbor"),
+				CertURL:      urlutil.MustParse("https://example.org/cert.cbor"),
+				PrivateKey:   certchaintest.MustReadPrivateKeyFile("../testdata/keys/ecdsap256.key"),
+			})
+			vp := exchange.NewValidPeriod(
+				time.Date(2019, time.April, 22, 19, 30, 0, 0, time.UTC),
+				time.Date(2019, time.April, 29, 19, 30, 0, 0, time.UTC))
+			vu := urlutil.MustParse("https://example.org/hello.html.validity")
+			resp := exchangetest.MakeResponse("https://example.org/hello.html", fmt.Sprint(
+				"HTTP/1.1 200 OK\r\n",
+				"Content-Type: text/html; charset=utf-8\r\n",
+				"\r\n",
+				test.body,
+			))
+			e, err := factory.NewExchange(resp, vp, vu)
+			if err != nil {
+				t.Fatalf("got error(%q), want success", err)
+			}
+
+			var buf bytes.Buffer
+			if err := e.Write(&buf); err != nil {
+				t.Fatal(err)
+			}
+			got, err := exchange.EncodedSize(e)
+			if err != nil {
+				t.Fatalf("EncodedSize() = error(%q), want success", err)
+			}
+			if want := int64(buf.Len()); got != want {
+				t.Errorf("EncodedSize() = %d, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestEncodedSize_TooLarge(t *testing.T) {
+	factory := exchange.NewFactory(exchange.Config{
+		Version:      version.Version1b3,
+		MIRecordSize: 4096,
+		CertChain:    certchaintest.MustReadAugmentedChainFile("../testdata/certs/cbor/ecdsap256_nosct.cbor"),
+		CertURL:      urlutil.MustParse("https://example.org/cert.cbor"),
+		PrivateKey:   certchaintest.MustReadPrivateKeyFile("../testdata/keys/ecdsap256.key"),
+	})
+	vp := exchange.NewValidPeriod(
+		time.Date(2019, time.April, 22, 19, 30, 0, 0, time.UTC),
+		time.Date(2019, time.April, 29, 19, 30, 0, 0, time.UTC))
+	vu := urlutil.MustParse("https://example.org/hello.html.validity")
+	resp := exchangetest.MakeResponse("https://example.org/hello.html", fmt.Sprint(
+		"HTTP/1.1 200 OK\r\n",
+		"Content-Type: text/html; charset=utf-8\r\n",
+		"\r\n",
+		"<!doctype html><p>Hello, world!</p>",
+	))
+	e, err := factory.NewExchange(resp, vp, vu)
+	if err != nil {
+		t.Fatalf("got error(%q), want success", err)
+	}
+	e.ResponseHeaders.Set("X-Large", strings.Repeat("x", 600*1024))
+
+	if err := e.Write(&bytes.Buffer{}); err == nil {
+		t.Fatal("Write() = success, want error")
+	}
+	if _, err := exchange.EncodedSize(e); err == nil {
+		t.Error("EncodedSize() = success, want error")
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vprule
+
+import (
+	"time"
+
+	"github.com/layer0-platform/webpackager/exchange"
+)
+
+// maxLifetime is the maximum lifetime of signed exchanges allowed by the
+// specification.
+const maxLifetime = 7 * (24 * time.Hour)
+
+// AlignedExpiry adjusts the expires parameter computed by base so that it
+// falls on a multiple of boundary. It allows all signed exchanges generated
+// within the same interval to expire at the same wall-clock time, thereby
+// makes cache invalidation predictable. Multiples are counted from the zero
+// time (see time.Time.Truncate); a boundary of 24 hours, for example, aligns
+// the expires parameter to midnight UTC.
+//
+// AlignedExpiry normally rounds the expires parameter down to the boundary.
+// When that would produce a zero or negative lifetime, AlignedExpiry rounds
+// it up instead. In either case the lifetime is clamped to 7 days, the limit
+// set by the specification.
+//
+// AlignedExpiry panics if boundary is not positive.
+func AlignedExpiry(base Rule, boundary time.Duration) Rule {
+	if boundary <= 0 {
+		panic("vprule: boundary must be positive")
+	}
+	return &alignedExpiry{base, boundary}
+}
+
+type alignedExpiry struct {
+	base     Rule
+	boundary time.Duration
+}
+
+func (rule *alignedExpiry) Get(resp *exchange.Response, date time.Time) exchange.ValidPeriod {
+	vp := rule.base.Get(resp, date)
+	limit := vp.Date().Add(maxLifetime)
+
+	expires := vp.Expires()
+	if expires.After(limit) {
+		expires = limit
+	}
+	aligned := expires.Truncate(rule.boundary)
+	if !aligned.After(vp.Date()) {
+		aligned = vp.Date().Truncate(rule.boundary).Add(rule.boundary)
+	}
+	if aligned.After(limit) {
+		aligned = limit
+	}
+	return exchange.NewValidPeriod(vp.Date(), aligned)
+}
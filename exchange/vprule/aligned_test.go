@@ -0,0 +1,83 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vprule_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/layer0-platform/webpackager/exchange"
+	"github.com/layer0-platform/webpackager/exchange/exchangetest"
+	"github.com/layer0-platform/webpackager/exchange/vprule"
+)
+
+func TestAlignedExpiry(t *testing.T) {
+	tests := []struct {
+		name string
+		rule vprule.Rule
+		date time.Time
+		want exchange.ValidPeriod
+	}{
+		{
+			name: "RoundedDown",
+			rule: vprule.AlignedExpiry(vprule.FixedLifetime(48*time.Hour), 24*time.Hour),
+			date: time.Date(2020, time.January, 10, 20, 15, 0, 0, time.UTC),
+			want: exchange.NewValidPeriod(
+				time.Date(2020, time.January, 10, 20, 15, 0, 0, time.UTC),
+				time.Date(2020, time.January, 12, 0, 0, 0, 0, time.UTC)),
+		},
+		{
+			name: "AlreadyAligned",
+			rule: vprule.AlignedExpiry(vprule.FixedLifetime(24*time.Hour), time.Hour),
+			date: time.Date(2020, time.January, 10, 20, 0, 0, 0, time.UTC),
+			want: exchange.NewValidPeriod(
+				time.Date(2020, time.January, 10, 20, 0, 0, 0, time.UTC),
+				time.Date(2020, time.January, 11, 20, 0, 0, 0, time.UTC)),
+		},
+		{
+			name: "RoundedUp",
+			rule: vprule.AlignedExpiry(vprule.FixedLifetime(time.Hour), 24*time.Hour),
+			date: time.Date(2020, time.January, 10, 20, 15, 0, 0, time.UTC),
+			want: exchange.NewValidPeriod(
+				time.Date(2020, time.January, 10, 20, 15, 0, 0, time.UTC),
+				time.Date(2020, time.January, 11, 0, 0, 0, 0, time.UTC)),
+		},
+		{
+			name: "ClampedToMaxLifetime",
+			rule: vprule.AlignedExpiry(vprule.FixedLifetime(10*24*time.Hour), 24*time.Hour),
+			date: time.Date(2020, time.January, 10, 20, 15, 0, 0, time.UTC),
+			want: exchange.NewValidPeriod(
+				time.Date(2020, time.January, 10, 20, 15, 0, 0, time.UTC),
+				time.Date(2020, time.January, 17, 0, 0, 0, 0, time.UTC)),
+		},
+		{
+			name: "RoundedUpBeyondMaxLifetime",
+			rule: vprule.AlignedExpiry(vprule.FixedLifetime(time.Hour), 30*24*time.Hour),
+			date: time.Date(2020, time.January, 10, 20, 15, 0, 0, time.UTC),
+			want: exchange.NewValidPeriod(
+				time.Date(2020, time.January, 10, 20, 15, 0, 0, time.UTC),
+				time.Date(2020, time.January, 17, 20, 15, 0, 0, time.UTC)),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := exchangetest.MakeEmptyResponse("https://example.com/dummy/")
+			if got := test.rule.Get(resp, test.date); got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vprule
+
+// JSMediaTypes lists the media types recognized as JavaScript. They are all
+// in lowercase and include no media parameters.
+//
+// JSMediaTypes is intended to identify JavaScript consistently across the
+// packages: e.g. preverify.RejectJS also refers to this list.
+var JSMediaTypes = []string{
+	"application/javascript",
+	"application/x-javascript",
+	"text/javascript",
+}
+
+// IsJSMediaType reports whether mediaType is one of JSMediaTypes. mediaType
+// must be in lowercase and include no media parameters.
+func IsJSMediaType(mediaType string) bool {
+	for _, t := range JSMediaTypes {
+		if mediaType == t {
+			return true
+		}
+	}
+	return false
+}
+
+// PerJSContentType is a shorthand of PerContentType applying jsRule to all
+// JSMediaTypes and ruleElse to other media types.
+func PerJSContentType(jsRule, ruleElse Rule) Rule {
+	rules := make(map[string]Rule, len(JSMediaTypes))
+	for _, t := range JSMediaTypes {
+		rules[t] = jsRule
+	}
+	return PerContentType(rules, ruleElse)
+}
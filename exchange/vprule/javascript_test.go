@@ -0,0 +1,42 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vprule_test
+
+import (
+	"testing"
+
+	"github.com/layer0-platform/webpackager/exchange/vprule"
+)
+
+func TestIsJSMediaType(t *testing.T) {
+	tests := []struct {
+		mediaType string
+		want      bool
+	}{
+		{"application/javascript", true},
+		{"application/x-javascript", true},
+		{"text/javascript", true},
+		{"text/html", false},
+		{"application/json", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.mediaType, func(t *testing.T) {
+			if got := vprule.IsJSMediaType(test.mediaType); got != test.want {
+				t.Errorf("IsJSMediaType(%q) = %v, want %v", test.mediaType, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vprule
+
+import (
+	"time"
+
+	"github.com/layer0-platform/webpackager/exchange"
+)
+
+// UntilNextUpdate shortens the validity period computed by base so that
+// signed exchanges expire at next, the time the content is expected to be
+// updated next (e.g. the next deployment), rather than linger in caches
+// after that. It never extends the validity period: base determines
+// the maximum lifetime.
+//
+// The lifetime is at least minLifetime, or the lifetime from base if it is
+// shorter, even when next is close to or before the signing date. Thus
+// the validity period never becomes zero or negative.
+//
+// UntilNextUpdate panics if minLifetime is not positive.
+func UntilNextUpdate(base Rule, next time.Time, minLifetime time.Duration) Rule {
+	if minLifetime <= 0 {
+		panic("vprule: minLifetime must be positive")
+	}
+	return &untilNextUpdate{base, next, minLifetime}
+}
+
+type untilNextUpdate struct {
+	base        Rule
+	next        time.Time
+	minLifetime time.Duration
+}
+
+func (rule *untilNextUpdate) Get(resp *exchange.Response, date time.Time) exchange.ValidPeriod {
+	vp := rule.base.Get(resp, date)
+	if !rule.next.Before(vp.Expires()) {
+		return vp
+	}
+	minLifetime := rule.minLifetime
+	if lifetime := vp.Lifetime(); lifetime < minLifetime {
+		minLifetime = lifetime
+	}
+	expires := rule.next
+	if min := vp.Date().Add(minLifetime); expires.Before(min) {
+		expires = min
+	}
+	return exchange.NewValidPeriod(vp.Date(), expires)
+}
@@ -0,0 +1,74 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vprule_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/layer0-platform/webpackager/exchange"
+	"github.com/layer0-platform/webpackager/exchange/exchangetest"
+	"github.com/layer0-platform/webpackager/exchange/vprule"
+)
+
+func TestUntilNextUpdate(t *testing.T) {
+	date := time.Date(2020, time.January, 10, 20, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		rule vprule.Rule
+		want exchange.ValidPeriod
+	}{
+		{
+			name: "Shortened",
+			rule: vprule.UntilNextUpdate(vprule.FixedLifetime(72*time.Hour),
+				date.Add(6*time.Hour), time.Hour),
+			want: exchange.NewValidPeriod(date, date.Add(6*time.Hour)),
+		},
+		{
+			name: "NotExtended",
+			rule: vprule.UntilNextUpdate(vprule.FixedLifetime(24*time.Hour),
+				date.Add(48*time.Hour), time.Hour),
+			want: exchange.NewValidPeriod(date, date.Add(24*time.Hour)),
+		},
+		{
+			name: "ClampedToMinLifetime",
+			rule: vprule.UntilNextUpdate(vprule.FixedLifetime(72*time.Hour),
+				date.Add(10*time.Minute), time.Hour),
+			want: exchange.NewValidPeriod(date, date.Add(time.Hour)),
+		},
+		{
+			name: "NextInPast",
+			rule: vprule.UntilNextUpdate(vprule.FixedLifetime(72*time.Hour),
+				date.Add(-time.Hour), time.Hour),
+			want: exchange.NewValidPeriod(date, date.Add(time.Hour)),
+		},
+		{
+			name: "MinLifetimeLongerThanBase",
+			rule: vprule.UntilNextUpdate(vprule.FixedLifetime(30*time.Minute),
+				date.Add(-time.Hour), time.Hour),
+			want: exchange.NewValidPeriod(date, date.Add(30*time.Minute)),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := exchangetest.MakeEmptyResponse("https://example.com/dummy/")
+			if got := test.rule.Get(resp, date); got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vprule
+
+import (
+	"strings"
+	"time"
+
+	"github.com/layer0-platform/webpackager/exchange"
+)
+
+// PerHost specifies a Rule per host. rules is a map from host names to
+// Rules; ruleElse is the Rule applied to other hosts. The map keys should be
+// all in lowercase and include no port numbers (e.g. "example.com", not
+// "Example.COM" or "example.com:443").
+//
+// PerHost looks up the rule using the host name of resp.Request.URL.
+func PerHost(rules map[string]Rule, ruleElse Rule) Rule {
+	return &perHost{rules, ruleElse}
+}
+
+type perHost struct {
+	rules    map[string]Rule
+	ruleElse Rule
+}
+
+func (p *perHost) Get(resp *exchange.Response, date time.Time) exchange.ValidPeriod {
+	host := strings.ToLower(resp.Request.URL.Hostname())
+	if r, ok := p.rules[host]; ok {
+		return r.Get(resp, date)
+	}
+	return p.ruleElse.Get(resp, date)
+}
@@ -0,0 +1,69 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vprule_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/layer0-platform/webpackager/exchange"
+	"github.com/layer0-platform/webpackager/exchange/exchangetest"
+	"github.com/layer0-platform/webpackager/exchange/vprule"
+)
+
+func TestPerHost(t *testing.T) {
+	rule := vprule.PerHost(
+		map[string]vprule.Rule{
+			"static.example.com": vprule.FixedLifetime(2 * 24 * time.Hour),
+		},
+		vprule.FixedLifetime(7*24*time.Hour),
+	)
+	date := time.Date(2020, time.January, 15, 19, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		url  string
+		want exchange.ValidPeriod
+	}{
+		{
+			name: "Matching",
+			url:  "https://static.example.com/style.css",
+			want: exchange.NewValidPeriod(
+				date, time.Date(2020, time.January, 17, 19, 30, 0, 0, time.UTC)),
+		},
+		{
+			name: "Matching_CaseAndPort",
+			url:  "https://Static.Example.COM:8443/style.css",
+			want: exchange.NewValidPeriod(
+				date, time.Date(2020, time.January, 17, 19, 30, 0, 0, time.UTC)),
+		},
+		{
+			name: "NotMatching",
+			url:  "https://www.example.com/index.html",
+			want: exchange.NewValidPeriod(
+				date, time.Date(2020, time.January, 22, 19, 30, 0, 0, time.UTC)),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := exchangetest.MakeEmptyResponse(test.url)
+			got := rule.Get(resp, date)
+			if got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"crypto/tls"
+	"net/http"
+	"strings"
+)
+
+// ClientCertificate is a TLS client certificate to present to origin
+// servers requesting one, e.g. backends protected with mutual TLS. It is
+// used only in the TLS handshake, thus never appears in signed exchanges.
+type ClientCertificate struct {
+	// Certificate is the certificate chain with the private key. It can be
+	// loaded from PEM files with LoadClientCertificate, or from PEM data in
+	// memory with tls.X509KeyPair.
+	Certificate tls.Certificate
+
+	// Hosts limits the origin servers to present Certificate to, by their
+	// hostnames (without ports), e.g. "backend.example.com". Empty means
+	// all origin servers.
+	Hosts []string
+}
+
+// LoadClientCertificate reads a ClientCertificate from a pair of PEM files,
+// certFile containing the certificate chain and keyFile the private key.
+// hosts is set to the Hosts field.
+func LoadClientCertificate(certFile, keyFile string, hosts []string) (ClientCertificate, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return ClientCertificate{}, err
+	}
+	return ClientCertificate{Certificate: cert, Hosts: hosts}, nil
+}
+
+// clientCertRoundTripper sends requests via the transport presenting the
+// client certificate for the request host.
+type clientCertRoundTripper struct {
+	byHost   map[string]http.RoundTripper
+	fallback http.RoundTripper
+}
+
+// withClientCertificates returns a RoundTripper to send requests via
+// clones of t set up with certs. The first certificate matching the host
+// wins; requests to the hosts matching none are sent via t as is.
+func withClientCertificates(t *http.Transport, certs []ClientCertificate) http.RoundTripper {
+	rt := &clientCertRoundTripper{
+		byHost:   make(map[string]http.RoundTripper),
+		fallback: t,
+	}
+	var fallbackSet bool
+	for _, cc := range certs {
+		if len(cc.Hosts) == 0 {
+			if !fallbackSet {
+				rt.fallback = withCertificate(t, cc.Certificate)
+				fallbackSet = true
+			}
+			continue
+		}
+		ct := withCertificate(t, cc.Certificate)
+		for _, host := range cc.Hosts {
+			host = strings.ToLower(host)
+			if _, ok := rt.byHost[host]; !ok {
+				rt.byHost[host] = ct
+			}
+		}
+	}
+	return rt
+}
+
+// withCertificate returns a clone of t presenting cert to servers.
+func withCertificate(t *http.Transport, cert tls.Certificate) *http.Transport {
+	ct := t.Clone()
+	if ct.TLSClientConfig == nil {
+		ct.TLSClientConfig = new(tls.Config)
+	}
+	ct.TLSClientConfig.Certificates = []tls.Certificate{cert}
+	return ct
+}
+
+func (rt *clientCertRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t, ok := rt.byHost[strings.ToLower(req.URL.Hostname())]; ok {
+		return t.RoundTrip(req)
+	}
+	return rt.fallback.RoundTrip(req)
+}
@@ -0,0 +1,141 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/layer0-platform/webpackager/fetch"
+)
+
+func TestNewHTTPFetchClient_ClientCertificates(t *testing.T) {
+	cert := makeClientCertificate(t, "webpackager")
+	other := makeClientCertificate(t, "other")
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(req.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	// NewHTTPFetchClient clones http.DefaultTransport; get the clones to
+	// trust the test server.
+	dt := http.DefaultTransport.(*http.Transport)
+	savedTLSConfig := dt.TLSClientConfig
+	defer func() { dt.TLSClientConfig = savedTLSConfig }()
+	dt.TLSClientConfig = server.Client().Transport.(*http.Transport).TLSClientConfig
+
+	tests := []struct {
+		name    string
+		certs   []fetch.ClientCertificate
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "NoCertificate",
+			certs:   nil,
+			wantErr: true,
+		},
+		{
+			name:  "AllHosts",
+			certs: []fetch.ClientCertificate{{Certificate: cert}},
+			want:  "webpackager",
+		},
+		{
+			name:  "MatchingHost",
+			certs: []fetch.ClientCertificate{{Certificate: cert, Hosts: []string{"127.0.0.1"}}},
+			want:  "webpackager",
+		},
+		{
+			name:    "OtherHost",
+			certs:   []fetch.ClientCertificate{{Certificate: cert, Hosts: []string{"backend.example.com"}}},
+			wantErr: true,
+		},
+		{
+			name: "HostTakesPrecedence",
+			certs: []fetch.ClientCertificate{
+				{Certificate: other},
+				{Certificate: cert, Hosts: []string{"127.0.0.1"}},
+			},
+			want: "webpackager",
+		},
+		{
+			name: "FirstWins",
+			certs: []fetch.ClientCertificate{
+				{Certificate: other, Hosts: []string{"127.0.0.1"}},
+				{Certificate: cert, Hosts: []string{"127.0.0.1"}},
+			},
+			want: "other",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := fetch.NewHTTPFetchClient(fetch.TransportConfig{
+				ClientCertificates: test.certs,
+			})
+			resp, err := client.Get(server.URL)
+			if test.wantErr {
+				if err == nil {
+					resp.Body.Close()
+					t.Error("got success, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got error(%q), want success", err)
+			}
+			defer resp.Body.Close()
+			body, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(body); got != test.want {
+				t.Errorf("client certificate = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func makeClientCertificate(t *testing.T, commonName string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
@@ -20,13 +20,16 @@ import (
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 
 	"github.com/layer0-platform/webpackager/fetch"
 )
 
-// FetchClient fetches content from a test server.
+// FetchClient fetches content from a test server. It is safe for concurrent
+// use by multiple goroutines.
 type FetchClient struct {
 	client   *http.Client
+	mu       sync.Mutex
 	requests []*http.Request
 }
 
@@ -46,11 +49,15 @@ func NewFetchClient(s *httptest.Server) *FetchClient {
 
 // Requests returns all HTTP requests the FetchClient has received.
 func (c *FetchClient) Requests() []*http.Request {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	return c.requests
 }
 
 // Do sends an HTTP request to the test server and returns an HTTP response.
 func (c *FetchClient) Do(req *http.Request) (*http.Response, error) {
+	c.mu.Lock()
 	c.requests = append(c.requests, req)
+	c.mu.Unlock()
 	return c.client.Do(req)
 }
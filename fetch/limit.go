@@ -0,0 +1,110 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrTooManyFetches is returned by LimitedFetchClient when the request
+// cannot start within the wait time because of the concurrency limit.
+var ErrTooManyFetches = errors.New("fetch: too many concurrent fetches")
+
+// LimitedFetchClient is a FetchClient limiting the number of requests in
+// flight across all hosts. A request stays in flight until the response
+// body is closed. It is safe for concurrent use by multiple goroutines.
+type LimitedFetchClient struct {
+	client   FetchClient
+	sem      chan struct{}
+	maxWait  time.Duration
+	inFlight int64
+}
+
+// WithConcurrencyLimit wraps client to allow at most limit requests in
+// flight. Requests over the limit wait for up to maxWait, then fail with
+// ErrTooManyFetches. Zero maxWait makes them fail immediately.
+//
+// WithConcurrencyLimit panics if limit is not positive.
+func WithConcurrencyLimit(client FetchClient, limit int, maxWait time.Duration) *LimitedFetchClient {
+	if limit <= 0 {
+		panic("fetch: non-positive concurrency limit")
+	}
+	return &LimitedFetchClient{
+		client:  client,
+		sem:     make(chan struct{}, limit),
+		maxWait: maxWait,
+	}
+}
+
+// Do implements the FetchClient interface.
+func (c *LimitedFetchClient) Do(req *http.Request) (*http.Response, error) {
+	if err := c.acquire(); err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		c.release()
+		return nil, err
+	}
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: c.release}
+	return resp, nil
+}
+
+// InFlight returns the number of requests currently in flight.
+func (c *LimitedFetchClient) InFlight() int {
+	return int(atomic.LoadInt64(&c.inFlight))
+}
+
+func (c *LimitedFetchClient) acquire() error {
+	select {
+	case c.sem <- struct{}{}:
+	default:
+		if c.maxWait <= 0 {
+			return ErrTooManyFetches
+		}
+		timer := time.NewTimer(c.maxWait)
+		defer timer.Stop()
+		select {
+		case c.sem <- struct{}{}:
+		case <-timer.C:
+			return ErrTooManyFetches
+		}
+	}
+	atomic.AddInt64(&c.inFlight, 1)
+	return nil
+}
+
+func (c *LimitedFetchClient) release() {
+	atomic.AddInt64(&c.inFlight, -1)
+	<-c.sem
+}
+
+// releasingBody calls release once the body is closed.
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
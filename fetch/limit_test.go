@@ -0,0 +1,96 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/layer0-platform/webpackager/fetch"
+)
+
+func TestWithConcurrencyLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client := fetch.WithConcurrencyLimit(fetch.NewHTTPFetchClient(fetch.TransportConfig{}), 1, 10*time.Millisecond)
+
+	get := func() (*http.Response, error) {
+		req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return client.Do(req)
+	}
+
+	first, err := get()
+	if err != nil {
+		t.Fatalf("first request = error(%q), want success", err)
+	}
+	if got := client.InFlight(); got != 1 {
+		t.Errorf("InFlight() = %d, want 1", got)
+	}
+
+	// The first response body is still open.
+	if _, err := get(); !errors.Is(err, fetch.ErrTooManyFetches) {
+		t.Errorf("second request = error(%v), want %q", err, fetch.ErrTooManyFetches)
+	}
+
+	first.Body.Close()
+	first.Body.Close() // Closing twice should not release twice.
+	if got := client.InFlight(); got != 0 {
+		t.Errorf("InFlight() = %d, want 0", got)
+	}
+
+	third, err := get()
+	if err != nil {
+		t.Fatalf("third request = error(%q), want success", err)
+	}
+	third.Body.Close()
+}
+
+func TestWithConcurrencyLimit_Queue(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client := fetch.WithConcurrencyLimit(fetch.NewHTTPFetchClient(fetch.TransportConfig{}), 1, time.Minute)
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		first.Body.Close()
+	}()
+
+	// The second request waits for the first one to complete.
+	second, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("second request = error(%q), want success", err)
+	}
+	second.Body.Close()
+}
@@ -0,0 +1,91 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// RedactedHeaders are the request headers whose values WithRequestLogging
+// does not write into logs.
+var RedactedHeaders = []string{
+	"Authorization",
+	"Cookie",
+	"Proxy-Authorization",
+}
+
+// WithRequestLogging wraps client to log each request, namely the request
+// line and the headers, just before passing it to client. The request is
+// logged as it is sent to the server, e.g. after RequestTweaker is applied,
+// to help diagnosing requests rejected by the server. The values of
+// RedactedHeaders are replaced with "[redacted]".
+//
+// logf is called once for each request. nil logf implies log.Printf.
+func WithRequestLogging(client FetchClient, logf func(format string, v ...interface{})) FetchClient {
+	if logf == nil {
+		logf = log.Printf
+	}
+	return &withRequestLogging{client, logf}
+}
+
+type withRequestLogging struct {
+	client FetchClient
+	logf   func(format string, v ...interface{})
+}
+
+func (w *withRequestLogging) Do(req *http.Request) (*http.Response, error) {
+	w.logf("fetch: %s", DumpRequest(req))
+	return w.client.Do(req)
+}
+
+// DumpRequest returns the request line and the headers of req in a format
+// similar to HTTP/1.1, with the values of RedactedHeaders replaced with
+// "[redacted]". The request line has the full URL. The headers are sorted
+// by name.
+func DumpRequest(req *http.Request) string {
+	var b strings.Builder
+	b.WriteString(req.Method + " " + req.URL.String() + " " + req.Proto)
+	if req.Host != "" && req.Host != req.URL.Host {
+		b.WriteString("\nHost: " + req.Host)
+	}
+
+	keys := make([]string, 0, len(req.Header))
+	for key := range req.Header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		redacted := isRedacted(key)
+		for _, value := range req.Header[key] {
+			if redacted {
+				value = "[redacted]"
+			}
+			b.WriteString("\n" + key + ": " + value)
+		}
+	}
+	return b.String()
+}
+
+func isRedacted(key string) bool {
+	for _, h := range RedactedHeaders {
+		if strings.EqualFold(key, h) {
+			return true
+		}
+	}
+	return false
+}
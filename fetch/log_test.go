@@ -0,0 +1,103 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/layer0-platform/webpackager/fetch"
+)
+
+func TestWithRequestLogging(t *testing.T) {
+	tests := []struct {
+		name   string
+		host   string
+		header http.Header
+		want   []string
+	}{
+		{
+			name: "Minimal",
+			want: []string{
+				"fetch: GET https://example.com/hello.html HTTP/1.1",
+			},
+		},
+		{
+			name: "Headers",
+			header: http.Header{
+				"User-Agent":      {"webpackager"},
+				"Accept-Language": {"en-US", "ja"},
+			},
+			want: []string{
+				"fetch: GET https://example.com/hello.html HTTP/1.1\n" +
+					"Accept-Language: en-US\n" +
+					"Accept-Language: ja\n" +
+					"User-Agent: webpackager",
+			},
+		},
+		{
+			name: "Redacted",
+			header: http.Header{
+				"Authorization": {"Bearer secret"},
+				"Cookie":        {"session=secret"},
+				"Accept":        {"text/html"},
+			},
+			want: []string{
+				"fetch: GET https://example.com/hello.html HTTP/1.1\n" +
+					"Accept: text/html\n" +
+					"Authorization: [redacted]\n" +
+					"Cookie: [redacted]",
+			},
+		},
+		{
+			name: "HostOverride",
+			host: "www.example.com",
+			want: []string{
+				"fetch: GET https://example.com/hello.html HTTP/1.1\n" +
+					"Host: www.example.com",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []string
+			logf := func(format string, v ...interface{}) {
+				got = append(got, fmt.Sprintf(format, v...))
+			}
+			client := fetch.WithRequestLogging(&stubFetcher{}, logf)
+
+			req, err := http.NewRequest(http.MethodGet, "https://example.com/hello.html", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Host = test.host
+			for key, values := range test.header {
+				req.Header[key] = values
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("Do() = error(%q), want success", err)
+			}
+			resp.Body.Close()
+
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("logs mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
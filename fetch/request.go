@@ -115,3 +115,32 @@ func (sch *setCustomHeaders) Tweak(req, parent *http.Request) error {
 	}
 	return nil
 }
+
+// DefaultIdentityHeader is the HTTP header field used by AddIdentity when
+// no header key is provided.
+const DefaultIdentityHeader = "X-Webpackager"
+
+// AddIdentity adds an HTTP header field that identifies the request as sent
+// by the packager, so origin servers can tell packaging fetches apart from
+// real users (e.g. to exclude them from analytics). key can be "Via" or any
+// custom header key; empty key implies DefaultIdentityHeader. value is added
+// after the existing values, if any, hence keeps the Via chain intact.
+//
+// AddIdentity can be combined with SetCustomHeaders etc. through
+// RequestTweakerSequence.
+func AddIdentity(key, value string) RequestTweaker {
+	if key == "" {
+		key = DefaultIdentityHeader
+	}
+	return &addIdentity{http.CanonicalHeaderKey(key), value}
+}
+
+type addIdentity struct {
+	key   string
+	value string
+}
+
+func (ai *addIdentity) Tweak(req, parent *http.Request) error {
+	req.Header.Add(ai.key, ai.value)
+	return nil
+}
@@ -311,3 +311,62 @@ func TestSetCustomHeaders_SliceCloned(t *testing.T) {
 		t.Errorf(`header["User-Agent"] = %q, want %q`, got, userAgent)
 	}
 }
+
+func TestAddIdentity(t *testing.T) {
+	tests := []struct {
+		name    string
+		before  http.Header
+		tweaker fetch.RequestTweaker
+		after   http.Header
+	}{
+		{
+			name:    "DefaultHeader",
+			before:  http.Header{},
+			tweaker: fetch.AddIdentity("", "webpackager/0.1"),
+			after: http.Header{
+				"X-Webpackager": []string{"webpackager/0.1"},
+			},
+		},
+		{
+			name: "ViaAppended",
+			before: http.Header{
+				"Via": []string{"1.1 proxy.example.com"},
+			},
+			tweaker: fetch.AddIdentity("via", "1.1 webpackager"),
+			after: http.Header{
+				"Via": []string{"1.1 proxy.example.com", "1.1 webpackager"},
+			},
+		},
+		{
+			name: "WithCustomHeaders",
+			before: http.Header{
+				"User-Agent": []string{"request_test/0.1"},
+			},
+			tweaker: fetch.RequestTweakerSequence{
+				fetch.SetCustomHeaders(http.Header{
+					"Accept-Language": []string{"en-US", "en;q=0.9"},
+				}),
+				fetch.AddIdentity("", "webpackager/0.1"),
+			},
+			after: http.Header{
+				"Accept-Language": []string{"en-US", "en;q=0.9"},
+				"User-Agent":      []string{"request_test/0.1"},
+				"X-Webpackager":   []string{"webpackager/0.1"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := newGetRequest("https://example.com/style.css")
+			req.Header = test.before
+
+			if err := test.tweaker.Tweak(req, nil); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.after, req.Header); diff != "" {
+				t.Errorf("req.Header mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
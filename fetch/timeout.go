@@ -0,0 +1,167 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// These are the phases of a fetch reported in TimeoutError.
+const (
+	// Resolving the hostname and establishing the TCP connection.
+	PhaseDial = "dial"
+	// Performing the TLS handshake.
+	PhaseTLSHandshake = "TLS handshake"
+	// Sending the request and waiting for the response headers.
+	PhaseResponseHeader = "response header"
+	// Reading the response body.
+	PhaseResponseBody = "response body"
+)
+
+// TimeoutError is the error returned by FetchClients created with
+// NewHTTPFetchClient when a fetch times out. It tells the phase of the fetch
+// that was in progress, so slow origin servers can be told apart from other
+// failures. TimeoutError is usually wrapped in *url.Error by http.Client;
+// use errors.As to extract it.
+type TimeoutError struct {
+	// Phase is one of the Phase constants.
+	Phase string
+	// URL is the URL of the request that timed out.
+	URL string
+	// Err is the underlying error.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("fetch: %s timeout for %s: %v", e.Phase, e.URL, e.Err)
+}
+
+// Unwrap returns e.Err.
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// Timeout reports true, as a net.Error does for timeouts.
+func (e *TimeoutError) Timeout() bool {
+	return true
+}
+
+// timeoutRoundTripper applies the per-request deadline and turns timeout
+// errors from rt into TimeoutErrors.
+type timeoutRoundTripper struct {
+	rt      http.RoundTripper
+	timeout time.Duration
+}
+
+func (t *timeoutRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := req.Context(), context.CancelFunc(func() {})
+	if t.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, t.timeout)
+	}
+	tracker := &phaseTracker{phase: PhaseDial}
+	ctx = httptrace.WithClientTrace(ctx, tracker.trace())
+
+	resp, err := t.rt.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		if isTimeout(ctx, err) {
+			err = &TimeoutError{tracker.get(), req.URL.String(), err}
+		}
+		return nil, err
+	}
+	resp.Body = &timeoutBody{resp.Body, ctx, cancel, req.URL.String()}
+	return resp, nil
+}
+
+// timeoutBody reports timeouts while reading the body as TimeoutErrors
+// and releases the deadline on Close.
+type timeoutBody struct {
+	io.ReadCloser
+	ctx    context.Context
+	cancel context.CancelFunc
+	url    string
+}
+
+func (b *timeoutBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF && isTimeout(b.ctx, err) {
+		err = &TimeoutError{PhaseResponseBody, b.url, err}
+	}
+	return n, err
+}
+
+func (b *timeoutBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+func isTimeout(ctx context.Context, err error) bool {
+	if ctx.Err() == context.DeadlineExceeded {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// phaseTracker follows the phase of a request through httptrace.
+type phaseTracker struct {
+	mu      sync.Mutex
+	phase   string
+	gotConn bool
+}
+
+func (pt *phaseTracker) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		TLSHandshakeStart: func() {
+			pt.set(PhaseTLSHandshake, false)
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			if err == nil {
+				pt.set(PhaseResponseHeader, false)
+			}
+		},
+		GotConn: func(httptrace.GotConnInfo) {
+			pt.set(PhaseResponseHeader, true)
+		},
+	}
+}
+
+// set moves to phase unless a connection is already obtained; connections
+// dialed in the background may report events after that.
+func (pt *phaseTracker) set(phase string, gotConn bool) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	if pt.gotConn {
+		return
+	}
+	pt.phase, pt.gotConn = phase, gotConn
+}
+
+func (pt *phaseTracker) get() string {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	return pt.phase
+}
@@ -0,0 +1,112 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/layer0-platform/webpackager/fetch"
+)
+
+func TestTimeoutError(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/slow-body" {
+			w.Write([]byte("partial"))
+			w.(http.Flusher).Flush()
+		}
+		<-done
+	}))
+	defer server.Close()
+
+	// silent accepts connections and never responds, stalling TLS handshakes.
+	silent, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+	defer close(done)
+	go func() {
+		for {
+			conn, err := silent.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				<-done
+				conn.Close()
+			}()
+		}
+	}()
+
+	tests := []struct {
+		name   string
+		config fetch.TransportConfig
+		url    string
+		want   string
+	}{
+		{
+			name:   "TLSHandshake",
+			config: fetch.TransportConfig{TLSHandshakeTimeout: 50 * time.Millisecond},
+			url:    "https://" + silent.Addr().String() + "/",
+			want:   fetch.PhaseTLSHandshake,
+		},
+		{
+			name:   "ResponseHeader",
+			config: fetch.TransportConfig{ResponseHeaderTimeout: 50 * time.Millisecond},
+			url:    server.URL + "/slow-header",
+			want:   fetch.PhaseResponseHeader,
+		},
+		{
+			name:   "RequestTimeout_ResponseHeader",
+			config: fetch.TransportConfig{RequestTimeout: 50 * time.Millisecond},
+			url:    server.URL + "/slow-header",
+			want:   fetch.PhaseResponseHeader,
+		},
+		{
+			name:   "RequestTimeout_ResponseBody",
+			config: fetch.TransportConfig{RequestTimeout: 50 * time.Millisecond},
+			url:    server.URL + "/slow-body",
+			want:   fetch.PhaseResponseBody,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := fetch.NewHTTPFetchClient(test.config)
+			resp, err := client.Get(test.url)
+			if err == nil {
+				_, err = ioutil.ReadAll(resp.Body)
+				resp.Body.Close()
+			}
+			var timeoutErr *fetch.TimeoutError
+			if !errors.As(err, &timeoutErr) {
+				t.Fatalf("got error(%v), want TimeoutError", err)
+			}
+			if timeoutErr.Phase != test.want {
+				t.Errorf("timeoutErr.Phase = %q, want %q", timeoutErr.Phase, test.want)
+			}
+			if timeoutErr.URL != test.url {
+				t.Errorf("timeoutErr.URL = %q, want %q", timeoutErr.URL, test.url)
+			}
+		})
+	}
+}
@@ -0,0 +1,306 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TransportConfig tunes the connections NewHTTPFetchClient makes to origin
+// servers. Zero values keep the defaults of http.DefaultTransport.
+type TransportConfig struct {
+	// MaxIdleConnsPerHost specifies the maximum number of idle (keep-alive)
+	// connections to keep per host. Zero implies
+	// http.DefaultMaxIdleConnsPerHost.
+	MaxIdleConnsPerHost int
+
+	// MaxConnsPerHost limits the total number of connections per host,
+	// including those in use. Requests over the limit wait until some
+	// connection becomes available. Zero means no limit.
+	MaxConnsPerHost int
+
+	// IdleConnTimeout specifies how long an idle connection is kept before
+	// it is closed.
+	IdleConnTimeout time.Duration
+
+	// DialTimeout specifies the maximum time to wait for a TCP connection
+	// to be established, including the name resolution.
+	DialTimeout time.Duration
+
+	// TLSHandshakeTimeout specifies the maximum time to wait for the TLS
+	// handshake.
+	TLSHandshakeTimeout time.Duration
+
+	// ResponseHeaderTimeout specifies the maximum time to wait for
+	// the response headers after the request is written. Zero means no
+	// timeout.
+	ResponseHeaderTimeout time.Duration
+
+	// RequestTimeout limits the time each request takes, including
+	// the connection and reading the response body. It is enforced through
+	// the request context, thus the response body must be closed to release
+	// the deadline. Zero means no timeout.
+	RequestTimeout time.Duration
+
+	// HTTP3RoundTripper specifies an HTTP/3 (QUIC) round-tripper, such as
+	// http3.RoundTripper from github.com/lucas-clemente/quic-go, to use for
+	// https:// requests. Requests fall back to HTTP/2 and HTTP/1.1 when it
+	// returns an error, e.g. when the origin does not speak HTTP/3; HTTP/3 is
+	// then not tried for the host for a while (see HTTP3RetryInterval), or
+	// until the host advertises it with Alt-Svc. nil disables HTTP/3. This
+	// module does not depend on any QUIC library by itself; the caller is
+	// responsible for linking one.
+	HTTP3RoundTripper http.RoundTripper
+
+	// ResolveOverrides maps origin servers to the IP addresses to connect
+	// to instead of those resolved through DNS, like the --resolve option
+	// of curl, e.g. to fetch from a staging backend under the production
+	// hostname. The keys are either "host:port" or a host alone, applied to
+	// any port; "host:port" takes precedence. The TLS server name and the
+	// Host header still carry the original host. See ParseResolveOverrides
+	// for populating ResolveOverrides from strings. It does not apply to
+	// HTTP3RoundTripper.
+	ResolveOverrides map[string]string
+
+	// ClientCertificates specifies the TLS client certificates to present
+	// to origin servers requesting one, e.g. for mutual TLS. When more than
+	// one certificate applies to a host, the first one is presented. It
+	// does not apply to HTTP3RoundTripper.
+	ClientCertificates []ClientCertificate
+}
+
+// NewHTTPFetchClient creates a FetchClient like DefaultFetchClient, but with
+// the connections tuned by config. Requests exceeding any of the timeouts in
+// config fail with a TimeoutError telling the phase that timed out.
+func NewHTTPFetchClient(config TransportConfig) *http.Client {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if config.DialTimeout != 0 {
+		t.DialContext = (&net.Dialer{
+			Timeout:   config.DialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+	}
+	if config.MaxIdleConnsPerHost != 0 {
+		t.MaxIdleConnsPerHost = config.MaxIdleConnsPerHost
+	}
+	if config.MaxConnsPerHost != 0 {
+		t.MaxConnsPerHost = config.MaxConnsPerHost
+	}
+	if config.IdleConnTimeout != 0 {
+		t.IdleConnTimeout = config.IdleConnTimeout
+	}
+	if config.TLSHandshakeTimeout != 0 {
+		t.TLSHandshakeTimeout = config.TLSHandshakeTimeout
+	}
+	if config.ResponseHeaderTimeout != 0 {
+		t.ResponseHeaderTimeout = config.ResponseHeaderTimeout
+	}
+	if len(config.ResolveOverrides) > 0 {
+		t.DialContext = overrideResolve(t.DialContext, config.ResolveOverrides)
+	}
+	var rt http.RoundTripper = t
+	if len(config.ClientCertificates) > 0 {
+		rt = withClientCertificates(t, config.ClientCertificates)
+	}
+	if config.HTTP3RoundTripper != nil {
+		rt = newFallbackRoundTripper(config.HTTP3RoundTripper, rt)
+	}
+	return &http.Client{
+		Transport:     &timeoutRoundTripper{rt, config.RequestTimeout},
+		CheckRedirect: NeverRedirect,
+	}
+}
+
+// HTTP3RetryInterval is how long HTTP/3 is not tried for a host after it
+// fails, unless the host advertises HTTP/3 with Alt-Svc in the meantime.
+const HTTP3RetryInterval = 10 * time.Minute
+
+// fallbackRoundTripper sends https:// requests via primary first, then via
+// fallback if primary fails. It remembers the hosts primary failed for to
+// send their requests directly via fallback for HTTP3RetryInterval.
+type fallbackRoundTripper struct {
+	primary  http.RoundTripper
+	fallback http.RoundTripper
+
+	mu sync.Mutex
+	// retryAt maps the hosts primary failed for to the time to retry it.
+	retryAt map[string]time.Time
+	// warned records the hosts already warned about, to log once per host.
+	warned map[string]bool
+}
+
+func newFallbackRoundTripper(primary, fallback http.RoundTripper) *fallbackRoundTripper {
+	return &fallbackRoundTripper{
+		primary:  primary,
+		fallback: fallback,
+		retryAt:  make(map[string]time.Time),
+		warned:   make(map[string]bool),
+	}
+}
+
+func (rt *fallbackRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Requests with a body cannot be retried unless it can be rewound.
+	if req.URL.Scheme != "https" || (req.Body != nil && req.Body != http.NoBody && req.GetBody == nil) {
+		return rt.fallback.RoundTrip(req)
+	}
+	host := req.URL.Host
+	if rt.skipPrimary(host) {
+		return rt.roundTripFallback(req, host)
+	}
+	resp, err := rt.primary.RoundTrip(req)
+	if err == nil {
+		return resp, nil
+	}
+	rt.markFailed(host, err)
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.Body = body
+	}
+	return rt.roundTripFallback(req, host)
+}
+
+// roundTripFallback sends req via fallback. It lets primary be tried again
+// for host if the response advertises HTTP/3.
+func (rt *fallbackRoundTripper) roundTripFallback(req *http.Request, host string) (*http.Response, error) {
+	resp, err := rt.fallback.RoundTrip(req)
+	if err == nil && advertisesHTTP3(resp.Header.Values("Alt-Svc")) {
+		rt.mu.Lock()
+		delete(rt.retryAt, host)
+		rt.mu.Unlock()
+	}
+	return resp, err
+}
+
+func (rt *fallbackRoundTripper) skipPrimary(host string) bool {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	retryAt, ok := rt.retryAt[host]
+	return ok && time.Now().Before(retryAt)
+}
+
+func (rt *fallbackRoundTripper) markFailed(host string, err error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.retryAt[host] = time.Now().Add(HTTP3RetryInterval)
+	if !rt.warned[host] {
+		rt.warned[host] = true
+		log.Printf("warning: HTTP/3 request to %s failed, falling back: %v", host, err)
+	}
+}
+
+// advertisesHTTP3 reports whether the Alt-Svc header values list any HTTP/3
+// alternative service, e.g. `h3=":443"`.
+func advertisesHTTP3(values []string) bool {
+	for _, v := range values {
+		for _, alt := range strings.Split(v, ",") {
+			protocol := strings.TrimSpace(alt)
+			if i := strings.IndexByte(protocol, '='); i >= 0 {
+				protocol = protocol[:i]
+			}
+			if protocol == "h3" || strings.HasPrefix(protocol, "h3-") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// overrideResolve wraps dial to connect to the addresses in overrides.
+func overrideResolve(dial dialFunc, overrides map[string]string) dialFunc {
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return dial(ctx, network, addr)
+		}
+		host = strings.ToLower(host)
+		ip, ok := overrides[net.JoinHostPort(host, port)]
+		if !ok {
+			ip, ok = overrides[host]
+		}
+		if ok {
+			addr = net.JoinHostPort(ip, port)
+		}
+		return dial(ctx, network, addr)
+	}
+}
+
+// ParseResolveOverrides parses specs in the curl's --resolve syntax,
+// "host:port:addr", into the map for TransportConfig.ResolveOverrides.
+// The port may be omitted ("host:addr") to apply to any port. addr is an
+// IP address; IPv6 addresses may be enclosed in brackets.
+func ParseResolveOverrides(specs []string) (map[string]string, error) {
+	overrides := make(map[string]string)
+	for _, spec := range specs {
+		key, ip, err := parseResolveOverride(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid resolve override %q: %v", spec, err)
+		}
+		overrides[key] = ip
+	}
+	return overrides, nil
+}
+
+func parseResolveOverride(spec string) (key, ip string, err error) {
+	i := strings.Index(spec, ":")
+	if i <= 0 {
+		return "", "", errors.New("missing host")
+	}
+	host, rest := strings.ToLower(spec[:i]), spec[i+1:]
+	key = host
+	if addr := parseIP(rest); addr != "" {
+		return key, addr, nil
+	}
+	if j := strings.Index(rest, ":"); j > 0 {
+		if _, err := strconv.ParseUint(rest[:j], 10, 16); err != nil {
+			return "", "", fmt.Errorf("invalid port %q", rest[:j])
+		}
+		key = net.JoinHostPort(host, rest[:j])
+		rest = rest[j+1:]
+	}
+	addr := parseIP(rest)
+	if addr == "" {
+		return "", "", fmt.Errorf("invalid IP address %q", rest)
+	}
+	return key, addr, nil
+}
+
+// parseIP returns the IP address in s, or an empty string if s is not an IP
+// address.
+func parseIP(s string) string {
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
@@ -0,0 +1,362 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/layer0-platform/webpackager/fetch"
+)
+
+func TestNewHTTPFetchClient_MaxConnsPerHost(t *testing.T) {
+	var mu sync.Mutex
+	var active, maxActive int
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch state {
+		case http.StateNew:
+			active++
+			if active > maxActive {
+				maxActive = active
+			}
+		case http.StateClosed, http.StateHijacked:
+			active--
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	client := fetch.NewHTTPFetchClient(fetch.TransportConfig{
+		MaxIdleConnsPerHost: 1,
+		MaxConnsPerHost:     1,
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Get(server.URL)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if maxActive != 1 {
+		t.Errorf("max connections = %d, want 1", maxActive)
+	}
+}
+
+func TestNewHTTPFetchClient_NeverRedirect(t *testing.T) {
+	server := httptest.NewServer(http.RedirectHandler("/dest", http.StatusFound))
+	defer server.Close()
+
+	client := fetch.NewHTTPFetchClient(fetch.TransportConfig{})
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Errorf("resp.StatusCode = %d, want %d", resp.StatusCode, http.StatusFound)
+	}
+}
+
+func TestNewHTTPFetchClient_RequestTimeout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+
+	client := fetch.NewHTTPFetchClient(fetch.TransportConfig{
+		RequestTimeout: 10 * time.Millisecond,
+	})
+	resp, err := client.Get(server.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("client.Get() = success, want timeout error")
+	}
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("client.Get() = error(%q), want timeout error", err)
+	}
+}
+
+type stubRoundTripper struct {
+	resp  *http.Response
+	err   error
+	calls int
+}
+
+func (rt *stubRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.calls++
+	if rt.err != nil {
+		return nil, rt.err
+	}
+	return rt.resp, nil
+}
+
+func TestNewHTTPFetchClient_HTTP3(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	// NewHTTPFetchClient clones http.DefaultTransport; make it trust server.
+	defaultTransport := http.DefaultTransport
+	http.DefaultTransport = server.Client().Transport
+	defer func() { http.DefaultTransport = defaultTransport }()
+
+	tests := []struct {
+		name       string
+		h3         *stubRoundTripper
+		wantStatus int
+		wantCalls  int
+	}{
+		{
+			name:       "H3Success",
+			h3:         &stubRoundTripper{resp: &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}},
+			wantStatus: http.StatusOK,
+			wantCalls:  1,
+		},
+		{
+			name:       "FallBack",
+			h3:         &stubRoundTripper{err: errors.New("no h3")},
+			wantStatus: http.StatusNoContent,
+			wantCalls:  1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := fetch.NewHTTPFetchClient(fetch.TransportConfig{
+				HTTP3RoundTripper: test.h3,
+			})
+			resp, err := client.Get(server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != test.wantStatus {
+				t.Errorf("resp.StatusCode = %d, want %d", resp.StatusCode, test.wantStatus)
+			}
+			if test.h3.calls != test.wantCalls {
+				t.Errorf("HTTP/3 calls = %d, want %d", test.h3.calls, test.wantCalls)
+			}
+		})
+	}
+}
+
+func TestNewHTTPFetchClient_HTTP3Retry(t *testing.T) {
+	tests := []struct {
+		name      string
+		altSvc    string
+		wantCalls int
+	}{
+		{
+			name:      "NoAltSvc",
+			altSvc:    "",
+			wantCalls: 1,
+		},
+		{
+			name:      "AltSvcH2",
+			altSvc:    `h2=":443"; ma=86400`,
+			wantCalls: 1,
+		},
+		{
+			name:      "AltSvcH3",
+			altSvc:    `h3-29=":443"; ma=86400, h3=":443"; ma=86400`,
+			wantCalls: 3,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				if test.altSvc != "" {
+					w.Header().Set("Alt-Svc", test.altSvc)
+				}
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer server.Close()
+
+			defaultTransport := http.DefaultTransport
+			http.DefaultTransport = server.Client().Transport
+			defer func() { http.DefaultTransport = defaultTransport }()
+
+			h3 := &stubRoundTripper{err: errors.New("no h3")}
+			client := fetch.NewHTTPFetchClient(fetch.TransportConfig{
+				HTTP3RoundTripper: h3,
+			})
+			for i := 0; i < 3; i++ {
+				resp, err := client.Get(server.URL)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusNoContent {
+					t.Errorf("resp.StatusCode = %d, want %d", resp.StatusCode, http.StatusNoContent)
+				}
+			}
+			if h3.calls != test.wantCalls {
+				t.Errorf("HTTP/3 calls = %d, want %d", h3.calls, test.wantCalls)
+			}
+		})
+	}
+}
+
+func TestNewHTTPFetchClient_HTTP3NotForHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	h3 := &stubRoundTripper{err: errors.New("no h3")}
+	client := fetch.NewHTTPFetchClient(fetch.TransportConfig{HTTP3RoundTripper: h3})
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if h3.calls != 0 {
+		t.Errorf("HTTP/3 calls = %d, want 0", h3.calls)
+	}
+}
+
+func TestNewHTTPFetchClient_ResolveOverrides(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(req.Host))
+	}))
+	defer server.Close()
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// NewHTTPFetchClient clones http.DefaultTransport; make it trust server.
+	// The certificate of server is valid for example.com.
+	defaultTransport := http.DefaultTransport
+	http.DefaultTransport = server.Client().Transport
+	defer func() { http.DefaultTransport = defaultTransport }()
+
+	tests := []struct {
+		name      string
+		overrides map[string]string
+	}{
+		{"HostAndPort", map[string]string{"example.com:" + port: "127.0.0.1"}},
+		{"HostOnly", map[string]string{"example.com": "127.0.0.1"}},
+		{"HostAndPortFirst", map[string]string{"example.com:" + port: "127.0.0.1", "example.com": "192.0.2.1"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := fetch.NewHTTPFetchClient(fetch.TransportConfig{
+				ResolveOverrides: test.overrides,
+				RequestTimeout:   5 * time.Second,
+			})
+			resp, err := client.Get("https://example.com:" + port + "/")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := string(body), "example.com:"+port; got != want {
+				t.Errorf("Host = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestParseResolveOverrides(t *testing.T) {
+	tests := []struct {
+		name    string
+		specs   []string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name:  "HostPortAddr",
+			specs: []string{"example.com:443:10.0.0.1", "Example.org:8443:10.0.0.2"},
+			want:  map[string]string{"example.com:443": "10.0.0.1", "example.org:8443": "10.0.0.2"},
+		},
+		{
+			name:  "HostAddr",
+			specs: []string{"example.com:10.0.0.1"},
+			want:  map[string]string{"example.com": "10.0.0.1"},
+		},
+		{
+			name:  "IPv6",
+			specs: []string{"example.com:443:[2001:db8::1]", "example.org:::1"},
+			want:  map[string]string{"example.com:443": "2001:db8::1", "example.org": "::1"},
+		},
+		{
+			name:    "MissingHost",
+			specs:   []string{":443:10.0.0.1"},
+			wantErr: true,
+		},
+		{
+			name:    "InvalidPort",
+			specs:   []string{"example.com:https:10.0.0.1"},
+			wantErr: true,
+		},
+		{
+			name:    "NotIPAddress",
+			specs:   []string{"example.com:443:staging.example.com"},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := fetch.ParseResolveOverrides(test.specs)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("ParseResolveOverrides(%q) = %v, want error", test.specs, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseResolveOverrides(%q) = error(%q), want success", test.specs, err)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("ParseResolveOverrides(%q) mismatch (-want +got):\n%s", test.specs, diff)
+			}
+		})
+	}
+}
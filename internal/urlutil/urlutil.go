@@ -16,8 +16,12 @@
 package urlutil
 
 import (
+	"net"
 	"net/url"
 	"path"
+	"strings"
+
+	"golang.org/x/net/idna"
 )
 
 // GetCleanPath returns cleaned u.Path. It is like path.Clean(u.Path) but does
@@ -69,3 +73,84 @@ func MustParse(rawurl string) *url.URL {
 	}
 	return url
 }
+
+// NormalizeIRI turns u, parsed either from a URI or from an IRI (a URL with
+// raw non-ASCII characters), into the canonical URI form in place, so that
+// the same resource always gets the same URL string. Specifically:
+//
+//   - The hostname is converted to lowercase, with internationalized domain
+//     names converted to Punycode (e.g. "xn--e1afmkfd.xn--p1ai").
+//   - Non-ASCII characters and characters not allowed in URIs (e.g. spaces,
+//     "<" and "|") in the path and the query are percent-encoded in UTF-8.
+//   - Hexadecimal digits in percent-encodings are converted to uppercase.
+//     Stray "%" not followed by two hexadecimal digits is encoded as "%25".
+//
+// Characters already percent-encoded stay encoded, and vice versa. In
+// particular, "%2F" in the path is not turned into "/".
+func NormalizeIRI(u *url.URL) {
+	u.Host = normalizeHost(u.Host)
+
+	escaped := u.EscapedPath()
+	if unescaped, err := url.PathUnescape(u.RawPath); err == nil && unescaped == u.Path {
+		// u.RawPath is the path as written, even if it is an IRI.
+		escaped = u.RawPath
+	}
+	escaped = normalizeEscaped(escaped)
+	if unescaped, err := url.PathUnescape(escaped); err == nil {
+		// Keep RawPath empty unless needed, just as url.Parse does.
+		u.Path, u.RawPath = unescaped, ""
+		if u.EscapedPath() != escaped {
+			u.RawPath = escaped
+		}
+	}
+
+	u.RawQuery = normalizeEscaped(u.RawQuery)
+}
+
+func normalizeHost(host string) string {
+	if host == "" || strings.HasPrefix(host, "[") {
+		return strings.ToLower(host)
+	}
+	hostname, port, err := net.SplitHostPort(host)
+	if err != nil {
+		hostname, port = host, ""
+	}
+	if ascii, err := idna.Lookup.ToASCII(hostname); err == nil {
+		hostname = ascii
+	} else {
+		hostname = strings.ToLower(hostname)
+	}
+	if port == "" {
+		return hostname
+	}
+	return net.JoinHostPort(hostname, port)
+}
+
+// unsafeChars are the printable ASCII characters not allowed in URIs
+// (RFC 3986), except "#", "%", and "[]", which url.Parse handles specially.
+const unsafeChars = "\"<>\\^`{|}"
+
+func normalizeEscaped(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]):
+			b.WriteByte('%')
+			b.WriteString(strings.ToUpper(s[i+1 : i+3]))
+			i += 2
+		case c == '%' || c <= 0x20 || c >= 0x7f || strings.IndexByte(unsafeChars, c) >= 0:
+			b.WriteByte('%')
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&15])
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
@@ -220,3 +220,85 @@ func TestIsDir(t *testing.T) {
 		})
 	}
 }
+
+func TestNormalizeIRI(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{
+			name: "ASCII",
+			url:  "https://example.com/foo/bar.html?q=1",
+			want: "https://example.com/foo/bar.html?q=1",
+		},
+		{
+			name: "CyrillicPath",
+			url:  "https://example.com/привет/мир.html",
+			want: "https://example.com/%D0%BF%D1%80%D0%B8%D0%B2%D0%B5%D1%82/%D0%BC%D0%B8%D1%80.html",
+		},
+		{
+			name: "CyrillicPath_Encoded",
+			url:  "https://example.com/%D0%BF%D1%80%D0%B8%D0%B2%D0%B5%D1%82/%D0%BC%D0%B8%D1%80.html",
+			want: "https://example.com/%D0%BF%D1%80%D0%B8%D0%B2%D0%B5%D1%82/%D0%BC%D0%B8%D1%80.html",
+		},
+		{
+			name: "CyrillicPath_LowerHex",
+			url:  "https://example.com/%d0%bf%d1%80%d0%b8%d0%b2%d0%b5%d1%82/%d0%bc%d0%b8%d1%80.html",
+			want: "https://example.com/%D0%BF%D1%80%D0%B8%D0%B2%D0%B5%D1%82/%D0%BC%D0%B8%D1%80.html",
+		},
+		{
+			name: "CJKPathAndQuery",
+			url:  "https://example.com/日本語/?q=東京",
+			want: "https://example.com/%E6%97%A5%E6%9C%AC%E8%AA%9E/?q=%E6%9D%B1%E4%BA%AC",
+		},
+		{
+			name: "CJKQuery_Encoded",
+			url:  "https://example.com/search?q=%e6%9d%b1%e4%ba%ac&lang=ja",
+			want: "https://example.com/search?q=%E6%9D%B1%E4%BA%AC&lang=ja",
+		},
+		{
+			name: "MixedPath",
+			url:  "https://example.com/%E6%97%A5本語/",
+			want: "https://example.com/%E6%97%A5%E6%9C%AC%E8%AA%9E/",
+		},
+		{
+			name: "EncodedSlash",
+			url:  "https://example.com/a%2fb/c",
+			want: "https://example.com/a%2Fb/c",
+		},
+		{
+			name: "UnsafeQuery",
+			url:  "https://example.com/?q=a|b<c>&x=%41&y=100%",
+			want: "https://example.com/?q=a%7Cb%3Cc%3E&x=%41&y=100%25",
+		},
+		{
+			name: "IDN",
+			url:  "https://пример.рф/путь",
+			want: "https://xn--e1afmkfd.xn--p1ai/%D0%BF%D1%83%D1%82%D1%8C",
+		},
+		{
+			name: "IDNWithPort",
+			url:  "https://例え.テスト:8443/",
+			want: "https://xn--r8jz45g.xn--zckzah:8443/",
+		},
+		{
+			name: "UppercaseHost",
+			url:  "https://WWW.Example.COM/",
+			want: "https://www.example.com/",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			u, err := url.Parse(test.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			urlutil.NormalizeIRI(u)
+			if got := u.String(); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
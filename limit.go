@@ -0,0 +1,78 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webpackager
+
+import "sync"
+
+// resourceLimit keeps track of the resources packaged against
+// Config.MaxResources, over the lifetime of Packager. Slots can be reserved
+// for the direct subresources of main resources, so deeper subresources do
+// not use up the limit before them.
+type resourceLimit struct {
+	max int
+
+	mu       sync.Mutex
+	used     int
+	reserved int
+	skipped  int
+}
+
+// take consumes a slot, or a reserved slot if reserved is true. It reports
+// whether the resource may be packaged, and counts it as skipped otherwise.
+func (l *resourceLimit) take(reserved bool) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	switch {
+	case l.max <= 0:
+	case reserved && l.reserved > 0:
+		l.reserved--
+	case l.used+l.reserved < l.max:
+	default:
+		l.skipped++
+		return false
+	}
+	l.used++
+	return true
+}
+
+// reserve reserves up to n slots and returns the number of slots reserved.
+func (l *resourceLimit) reserve(n int) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.max <= 0 {
+		return 0
+	}
+	if free := l.max - l.used - l.reserved; n > free {
+		n = free
+	}
+	l.reserved += n
+	return n
+}
+
+// release returns n reserved slots that were not taken.
+func (l *resourceLimit) release(n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.reserved -= n
+}
+
+// counts returns the number of resources packaged and skipped so far.
+func (l *resourceLimit) counts() (used, skipped int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.used, l.skipped
+}
@@ -15,10 +15,13 @@
 package webpackager
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
 	"time"
 
+	"github.com/layer0-platform/webpackager/exchange"
 	"github.com/layer0-platform/webpackager/resource"
 	"golang.org/x/xerrors"
 )
@@ -26,13 +29,30 @@ import (
 // Packager implements the control flow of Web Packager.
 type Packager struct {
 	Config
+
+	limit *resourceLimit
+	skips *skipCounter
 }
 
 // NewPackager creates and initializes a new Packager with the provided
 // Config. It panics when config.ExchangeFactory is nil.
 func NewPackager(config Config) *Packager {
 	config.populateDefaults()
-	return &Packager{config}
+	return &Packager{config, &resourceLimit{max: config.MaxResources}, new(skipCounter)}
+}
+
+// ResourceCounts returns the number of resources Packager has processed so
+// far, including main resources and subresources, and the number of those
+// skipped due to MaxResources, both over all runs.
+func (pkg *Packager) ResourceCounts() (processed, skipped int) {
+	return pkg.limit.counts()
+}
+
+// SkipCounts returns the number of resources skipped with
+// preverify.SkipError (e.g. preverify.SizeLimitSkip) over all runs, keyed
+// by the reasons. Skipped resources are not counted as errors.
+func (pkg *Packager) SkipCounts() map[string]int {
+	return pkg.skips.get()
 }
 
 // Run runs the process to obtain the signed exchange for url: fetches the
@@ -63,14 +83,75 @@ func (pkg *Packager) Run(url *url.URL, sxgDate time.Time) (*resource.Resource, e
 // RunForRequest uses req directly: RequestTweaker mutates req; FetchClient
 // sends req to retrieve the HTTP response.
 func (pkg *Packager) RunForRequest(req *http.Request, sxgDate time.Time) (*resource.Resource, error) {
+	r, _, err := pkg.runForRequest(req, sxgDate)
+	return r, err
+}
+
+// RunForResponse is like Run, but produces the signed exchange from resp
+// instead of fetching the content from the server. It is useful when
+// the caller already has the content in memory, e.g. generated by its own
+// application. resp is processed and signed as if it were fetched for
+// resp.Request, and subresources are still fetched as usual.
+//
+// resp must have Request with an absolute URL, a non-zero StatusCode,
+// Header, and Payload (empty but non-nil for no content); RunForResponse
+// returns an error otherwise. Unlike Run, RunForResponse does not look up
+// ResourceCache for the existing signed exchange, since the provided
+// content may differ from it. RequestTweaker is not applied either.
+func (pkg *Packager) RunForResponse(resp *exchange.Response, sxgDate time.Time) (*resource.Resource, error) {
+	if err := validateResponse(resp); err != nil {
+		return nil, xerrors.Errorf("packaging: %w", err)
+	}
 	runner, err := newTaskRunner(pkg, sxgDate)
 	if err != nil {
 		return nil, xerrors.Errorf("packaging: %w", err)
 	}
-	r := resource.NewResource(req.URL)
-	runner.run(nil, req, r)
+	r := resource.NewResource(resp.Request.URL)
+	runner.runForResponse(resp, r)
+	return r, runner.err()
+}
+
+func validateResponse(resp *exchange.Response) error {
+	switch {
+	case resp == nil || resp.Response == nil:
+		return errors.New("missing response")
+	case resp.Request == nil || resp.Request.URL == nil:
+		return errors.New("missing request URL")
+	case !resp.Request.URL.IsAbs():
+		return fmt.Errorf("request URL %v is not absolute", resp.Request.URL)
+	case resp.StatusCode == 0:
+		return errors.New("missing status code")
+	case resp.Header == nil:
+		return errors.New("missing header")
+	case resp.Payload == nil:
+		return errors.New("missing payload")
+	}
+	if resp.ExtraData == nil {
+		resp.ExtraData = make(http.Header)
+	}
+	return nil
+}
+
+// RunForRequestWithStats is like RunForRequest, but also returns Stats on
+// the main resource, such as whether it was a cache hit and how long each
+// stage took. Stats is non-nil whenever the process has run, even if it
+// has failed.
+func (pkg *Packager) RunForRequestWithStats(req *http.Request, sxgDate time.Time) (*resource.Resource, *Stats, error) {
+	r, stats, err := pkg.runForRequest(req, sxgDate)
+	if stats != nil && r.Exchange != nil {
+		if size, err := exchange.EncodedSize(r.Exchange); err == nil {
+			stats.ExchangeSize = int(size)
+		}
+	}
+	return r, stats, err
+}
+
+func (pkg *Packager) runForRequest(req *http.Request, sxgDate time.Time) (*resource.Resource, *Stats, error) {
+	runner, err := newTaskRunner(pkg, sxgDate)
 	if err != nil {
-		return nil, xerrors.Errorf("processing: %w", err)
+		return nil, nil, xerrors.Errorf("packaging: %w", err)
 	}
-	return r, runner.err()
+	r := resource.NewResource(req.URL)
+	stats := runner.run(nil, req, r)
+	return r, stats, runner.err()
 }
@@ -15,22 +15,36 @@
 package webpackager_test
 
 import (
+	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/layer0-platform/webpackager"
 	"github.com/layer0-platform/webpackager/exchange"
+	"github.com/layer0-platform/webpackager/exchange/exchangetest"
 	"github.com/layer0-platform/webpackager/exchange/vprule"
 	"github.com/layer0-platform/webpackager/fetch"
 	"github.com/layer0-platform/webpackager/fetch/fetchtest"
 	"github.com/layer0-platform/webpackager/internal/certchaintest"
 	"github.com/layer0-platform/webpackager/internal/urlutil"
+	"github.com/layer0-platform/webpackager/processor"
+	"github.com/layer0-platform/webpackager/processor/commonproc"
 	"github.com/layer0-platform/webpackager/processor/complexproc"
 	"github.com/layer0-platform/webpackager/processor/htmlproc"
 	"github.com/layer0-platform/webpackager/processor/htmlproc/htmltask"
+	"github.com/layer0-platform/webpackager/processor/preverify"
+	"github.com/layer0-platform/webpackager/resource"
+	"github.com/layer0-platform/webpackager/resource/cache"
+	"github.com/layer0-platform/webpackager/validity"
 )
 
 var (
@@ -89,6 +103,78 @@ func TestSameDomain(t *testing.T) {
 	verifyExchange(t, pkg, "https://example.org/style.css", date, "")
 }
 
+func TestRunForResponse(t *testing.T) {
+	handlers := http.NewServeMux()
+	handlers.Handle(
+		"example.org/style.css",
+		stubTextHandler(`body { font-family: sans-serif; }`, "text/css"),
+	)
+	server := httptest.NewTLSServer(handlers)
+	defer server.Close()
+
+	pkg := webpackager.NewPackager(makeConfig(server))
+	resp := exchangetest.MakeResponse("https://example.org/hello.html", fmt.Sprint(
+		"HTTP/1.1 200 OK\r\n",
+		"Content-Type: text/html; charset=utf-8\r\n",
+		"\r\n",
+		`<!doctype html>`,
+		`<link href="https://example.org/style.css" rel="stylesheet">`,
+		`<p>Hello, world!</p>`,
+	))
+	if _, err := pkg.RunForResponse(resp, date); err != nil {
+		t.Fatalf("pkg.RunForResponse() = error(%q), want success", err)
+	}
+
+	// hello.html is not fetched; style.css is.
+	verifyRequests(t, pkg, []string{
+		"https://example.org/style.css",
+	})
+	verifyExchange(t, pkg, "https://example.org/hello.html", date, fmt.Sprint(
+		`<https://example.org/style.css>;rel="allowed-alt-sxg";`+
+			`header-integrity="sha256-+Xd20Pyxhd3oSvNo2ucj9gdj7ZkHavIaDGkucYF76J8=",`,
+		`<https://example.org/style.css>;rel="preload";as="style"`))
+	verifyExchange(t, pkg, "https://example.org/style.css", date, "")
+}
+
+func TestRunForResponseInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(resp *exchange.Response)
+	}{
+		{
+			name:   "NoRequest",
+			mutate: func(resp *exchange.Response) { resp.Request = nil },
+		},
+		{
+			name:   "RelativeURL",
+			mutate: func(resp *exchange.Response) { resp.Request.URL = urlutil.MustParse("/hello.html") },
+		},
+		{
+			name:   "NoHeader",
+			mutate: func(resp *exchange.Response) { resp.Header = nil },
+		},
+		{
+			name:   "NoPayload",
+			mutate: func(resp *exchange.Response) { resp.Payload = nil },
+		},
+	}
+
+	server := httptest.NewTLSServer(http.NewServeMux())
+	defer server.Close()
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pkg := webpackager.NewPackager(makeConfig(server))
+			resp := exchangetest.MakeEmptyResponse("https://example.org/hello.html")
+			test.mutate(resp)
+			if _, err := pkg.RunForResponse(resp, date); err == nil {
+				t.Error("pkg.RunForResponse() = success, want error")
+			}
+			verifyRequests(t, pkg, []string{})
+		})
+	}
+}
+
 func TestCrossDomain(t *testing.T) {
 	handlers := http.NewServeMux()
 	handlers.Handle(
@@ -167,6 +253,426 @@ func TestDupResource(t *testing.T) {
 	verifyExchange(t, pkg, "https://example.org/style.css", date, "")
 }
 
+func TestMIRecordSizeChange(t *testing.T) {
+	handlers := http.NewServeMux()
+	handlers.Handle(
+		"example.org/style.css",
+		stubTextHandler(`body { font-family: sans-serif; }`, "text/css"),
+	)
+	server := httptest.NewTLSServer(handlers)
+	defer server.Close()
+
+	config := makeConfig(server)
+	fty := config.ExchangeFactory.(*exchange.Factory)
+	pkg := webpackager.NewPackager(config)
+
+	fty.MIRecordSize = 4096
+	if _, err := pkg.Run(urlutil.MustParse("https://example.org/style.css"), date); err != nil {
+		t.Fatalf("pkg.Run() = error(%q), want success", err)
+	}
+	if _, err := pkg.Run(urlutil.MustParse("https://example.org/style.css"), date); err != nil {
+		t.Fatalf("pkg.Run() = error(%q), want success", err)
+	}
+	fty.MIRecordSize = 16
+	if _, err := pkg.Run(urlutil.MustParse("https://example.org/style.css"), date); err != nil {
+		t.Fatalf("pkg.Run() = error(%q), want success", err)
+	}
+
+	// style.css should be fetched again only after the record size change.
+	verifyRequests(t, pkg, []string{
+		"https://example.org/style.css",
+		"https://example.org/style.css",
+	})
+	verifyExchange(t, pkg, "https://example.org/style.css", date, "")
+}
+
+func TestNextExchange(t *testing.T) {
+	handlers := http.NewServeMux()
+	handlers.Handle(
+		"example.org/style.css",
+		stubTextHandler(`body { font-family: sans-serif; }`, "text/css"),
+	)
+	server := httptest.NewTLSServer(handlers)
+	defer server.Close()
+
+	// The test certificate is valid from 2020-04-01 to 2020-05-31.
+	date := time.Date(2020, time.April, 10, 10, 30, 0, 0, time.UTC)
+	later := date.Add(10 * 24 * time.Hour)
+
+	config := makeConfig(server)
+	config.NextExchangeOverlap = 24 * time.Hour
+	pkg := webpackager.NewPackager(config)
+	r, err := pkg.Run(urlutil.MustParse("https://example.org/style.css"), date)
+	if err != nil {
+		t.Fatalf("pkg.Run() = error(%q), want success", err)
+	}
+	if r.NextExchange == nil {
+		t.Fatal("r.NextExchange = <nil>, want non-nil")
+	}
+
+	ef, err := pkg.ExchangeFactory.Get()
+	if err != nil {
+		t.Fatalf("ExchangeFactory.Get() = error(%q), want success", err)
+	}
+	if _, err := ef.Verify(r.Exchange, later); err == nil {
+		t.Errorf("Verify(r.Exchange, %v) = success, want error", later)
+	}
+	payload, err := ef.Verify(r.NextExchange, later)
+	if err != nil {
+		t.Fatalf("Verify(r.NextExchange, %v) = error(%q), want success", later, err)
+	}
+	if got, want := string(payload), `body { font-family: sans-serif; }`; got != want {
+		t.Errorf("payload = %q, want %q", got, want)
+	}
+}
+
+func TestSignValidityData(t *testing.T) {
+	handlers := http.NewServeMux()
+	handlers.Handle(
+		"example.org/style.css",
+		stubTextHandler(`body { font-family: sans-serif; }`, "text/css"),
+	)
+	server := httptest.NewTLSServer(handlers)
+	defer server.Close()
+
+	// The test certificate is valid from 2020-04-01 to 2020-05-31.
+	date := time.Date(2020, time.April, 10, 10, 30, 0, 0, time.UTC)
+	later := date.Add(10 * 24 * time.Hour)
+
+	config := makeConfig(server)
+	config.SignValidityData = true
+	pkg := webpackager.NewPackager(config)
+	r, err := pkg.Run(urlutil.MustParse("https://example.org/style.css"), date)
+	if err != nil {
+		t.Fatalf("pkg.Run() = error(%q), want success", err)
+	}
+	if r.ValidityData == nil {
+		t.Fatal("r.ValidityData = <nil>, want non-nil")
+	}
+	data, err := validity.Decode(r.ValidityData)
+	if err != nil {
+		t.Fatalf("validity.Decode() = error(%q), want success", err)
+	}
+	if len(data.Signatures) != 1 {
+		t.Fatalf("len(data.Signatures) = %d, want 1", len(data.Signatures))
+	}
+
+	ef, err := pkg.ExchangeFactory.Get()
+	if err != nil {
+		t.Fatalf("ExchangeFactory.Get() = error(%q), want success", err)
+	}
+	updated := *r.Exchange
+	updated.SignatureHeaderValue = data.Signatures[0]
+	payload, err := ef.Verify(&updated, later)
+	if err != nil {
+		t.Fatalf("Verify(updated, %v) = error(%q), want success", later, err)
+	}
+	if got, want := string(payload), `body { font-family: sans-serif; }`; got != want {
+		t.Errorf("payload = %q, want %q", got, want)
+	}
+}
+
+func TestVerifyAtExpiry(t *testing.T) {
+	handlers := http.NewServeMux()
+	handlers.Handle(
+		"example.org/style.css",
+		stubTextHandler(`body { font-family: sans-serif; }`, "text/css"),
+	)
+	server := httptest.NewTLSServer(handlers)
+	defer server.Close()
+
+	// The test certificate is valid from 2020-04-01 to 2020-05-31.
+	tests := []struct {
+		name    string
+		date    time.Time
+		wantErr bool
+	}{
+		{
+			name:    "CertCovers",
+			date:    time.Date(2020, time.April, 10, 10, 30, 0, 0, time.UTC),
+			wantErr: false,
+		},
+		{
+			name:    "CertExpiresEarlier",
+			date:    time.Date(2020, time.May, 28, 10, 30, 0, 0, time.UTC),
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := makeConfig(server)
+			config.VerifyAtExpiry = true
+			pkg := webpackager.NewPackager(config)
+			_, err := pkg.Run(urlutil.MustParse("https://example.org/style.css"), test.date)
+			if test.wantErr && err == nil {
+				t.Error("pkg.Run() = success, want error")
+			}
+			if !test.wantErr && err != nil {
+				t.Errorf("pkg.Run() = error(%q), want success", err)
+			}
+		})
+	}
+}
+
+func TestKeepUnsignedResponse(t *testing.T) {
+	handlers := http.NewServeMux()
+	handlers.Handle(
+		"example.org/style.css",
+		stubTextHandler(`body { font-family: sans-serif; }`, "text/css"),
+	)
+	server := httptest.NewTLSServer(handlers)
+	defer server.Close()
+
+	config := makeConfig(server)
+	config.KeepUnsignedResponse = true
+	pkg := webpackager.NewPackager(config)
+	r, err := pkg.Run(urlutil.MustParse("https://example.org/style.css"), date)
+	if err != nil {
+		t.Fatalf("pkg.Run() = error(%q), want success", err)
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(r.UnsignedResponse)), nil)
+	if err != nil {
+		t.Fatalf("http.ReadResponse() = error(%q), want success", err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("ioutil.ReadAll() = error(%q), want success", err)
+	}
+	if got, want := string(body), `body { font-family: sans-serif; }`; got != want {
+		t.Errorf("body = %q, want %q", got, want)
+	}
+	if got, want := resp.Header.Get("Content-Type"), "text/css"; got != want {
+		t.Errorf(`resp.Header.Get("Content-Type") = %q, want %q`, got, want)
+	}
+}
+
+func TestStaleIfError(t *testing.T) {
+	const css = `body { font-family: sans-serif; }`
+	later := date.Add(8 * 24 * time.Hour) // One day after the expiry.
+
+	tests := []struct {
+		name         string
+		staleIfError time.Duration
+		failure      http.Handler
+		wantStale    bool
+	}{
+		{
+			name:         "ServerError",
+			staleIfError: 48 * time.Hour,
+			failure:      stubErrorHandler(http.StatusServiceUnavailable),
+			wantStale:    true,
+		},
+		{
+			name:         "OutOfWindow",
+			staleIfError: 12 * time.Hour,
+			failure:      stubErrorHandler(http.StatusServiceUnavailable),
+			wantStale:    false,
+		},
+		{
+			name:         "ClientError",
+			staleIfError: 48 * time.Hour,
+			failure:      stubErrorHandler(http.StatusNotFound),
+			wantStale:    false,
+		},
+		{
+			name:         "Disabled",
+			staleIfError: 0,
+			failure:      stubErrorHandler(http.StatusServiceUnavailable),
+			wantStale:    false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			failing := false
+			ok := stubTextHandler(css, "text/css")
+			handlers := http.NewServeMux()
+			handlers.Handle("example.org/style.css", http.HandlerFunc(
+				func(w http.ResponseWriter, req *http.Request) {
+					if failing {
+						test.failure.ServeHTTP(w, req)
+					} else {
+						ok.ServeHTTP(w, req)
+					}
+				},
+			))
+			server := httptest.NewTLSServer(handlers)
+			defer server.Close()
+
+			config := makeConfig(server)
+			config.StaleIfError = test.staleIfError
+			pkg := webpackager.NewPackager(config)
+			run := func(date time.Time) (*resource.Resource, *webpackager.Stats, error) {
+				req, err := http.NewRequest(http.MethodGet, "https://example.org/style.css", nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return pkg.RunForRequestWithStats(req, date)
+			}
+
+			first, _, err := run(date)
+			if err != nil {
+				t.Fatalf("pkg.RunForRequestWithStats() = error(%q), want success", err)
+			}
+
+			failing = true
+			r, stats, err := run(later)
+			if test.wantStale {
+				if err != nil {
+					t.Fatalf("pkg.RunForRequestWithStats() = error(%q), want success", err)
+				}
+				if r.Exchange != first.Exchange {
+					t.Error("r.Exchange is not the stale signed exchange")
+				}
+				if !stats.Stale {
+					t.Error("stats.Stale = false, want true")
+				}
+			} else {
+				if err == nil {
+					t.Error("pkg.RunForRequestWithStats() = success, want error")
+				}
+				if stats != nil && stats.Stale {
+					t.Error("stats.Stale = true, want false")
+				}
+			}
+		})
+	}
+}
+
+func TestTransformations(t *testing.T) {
+	handlers := http.NewServeMux()
+	handlers.HandleFunc("example.org/style.css", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/css")
+		w.Header().Set("Set-Cookie", "id=0123456789abcdef")
+		w.Write([]byte(`body { font-family: sans-serif; }`))
+	})
+	server := httptest.NewTLSServer(handlers)
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		sign        bool
+		wantWarning []string
+	}{
+		{
+			name:        "Unsigned",
+			sign:        false,
+			wantWarning: nil,
+		},
+		{
+			name:        "Signed",
+			sign:        true,
+			wantWarning: []string{`214 webpackager "removed header Set-Cookie"`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := makeConfig(server)
+			config.SignTransformations = test.sign
+			pkg := webpackager.NewPackager(config)
+			r, err := pkg.Run(urlutil.MustParse("https://example.org/style.css"), date)
+			if err != nil {
+				t.Fatalf("pkg.Run() = error(%q), want success", err)
+			}
+			if diff := cmp.Diff([]string{"removed header Set-Cookie"}, r.Transformations); diff != "" {
+				t.Errorf("r.Transformations mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(test.wantWarning, r.Exchange.ResponseHeaders["Warning"]); diff != "" {
+				t.Errorf("Warning header mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRunForRequestWithStats(t *testing.T) {
+	const css = `body { font-family: sans-serif; }`
+
+	handlers := http.NewServeMux()
+	handlers.Handle("example.org/style.css", stubTextHandler(css, "text/css"))
+	server := httptest.NewTLSServer(handlers)
+	defer server.Close()
+
+	pkg := webpackager.NewPackager(makeConfig(server))
+
+	req, err := http.NewRequest(http.MethodGet, "https://example.org/style.css", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, first, err := pkg.RunForRequestWithStats(req, date)
+	if err != nil {
+		t.Fatalf("pkg.RunForRequestWithStats() = error(%q), want success", err)
+	}
+	if first.CacheHit {
+		t.Errorf("first.CacheHit = true, want false")
+	}
+	if got, want := first.PayloadSize, len(css); got != want {
+		t.Errorf("first.PayloadSize = %d, want %d", got, want)
+	}
+	if first.ExchangeSize <= first.PayloadSize {
+		t.Errorf("first.ExchangeSize = %d, want > %d", first.ExchangeSize, first.PayloadSize)
+	}
+	if sum := first.FetchDuration + first.ProcessDuration + first.SignDuration; first.TotalDuration < sum {
+		t.Errorf("first.TotalDuration = %v, want >= %v", first.TotalDuration, sum)
+	}
+
+	req, err = http.NewRequest(http.MethodGet, "https://example.org/style.css", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, second, err := pkg.RunForRequestWithStats(req, date)
+	if err != nil {
+		t.Fatalf("pkg.RunForRequestWithStats() = error(%q), want success", err)
+	}
+	if !second.CacheHit {
+		t.Errorf("second.CacheHit = false, want true")
+	}
+	if second.FetchDuration != 0 {
+		t.Errorf("second.FetchDuration = %v, want 0", second.FetchDuration)
+	}
+	if got, want := second.ExchangeSize, first.ExchangeSize; got != want {
+		t.Errorf("second.ExchangeSize = %d, want %d", got, want)
+	}
+}
+
+// stripPathPrefix is a urlrewrite.Rule to remove prefix from the path.
+type stripPathPrefix string
+
+func (prefix stripPathPrefix) Rewrite(u *url.URL, respHeader http.Header) {
+	u.Path = strings.TrimPrefix(u.Path, string(prefix))
+}
+
+func TestSignedURLRule(t *testing.T) {
+	handlers := http.NewServeMux()
+	handlers.Handle(
+		"example.org/v2/hello.html",
+		stubHTMLHandler(`<!doctype html><p>Hello, world!</p>`),
+	)
+	server := httptest.NewTLSServer(handlers)
+	defer server.Close()
+
+	config := makeConfig(server)
+	config.SignedURLRule = stripPathPrefix("/v2")
+	pkg := webpackager.NewPackager(config)
+	r, err := pkg.Run(urlutil.MustParse("https://example.org/v2/hello.html"), date)
+	if err != nil {
+		t.Fatalf("pkg.Run() = error(%q), want success", err)
+	}
+
+	verifyRequests(t, pkg, []string{
+		"https://example.org/v2/hello.html",
+	})
+	verifyExchange(t, pkg, "https://example.org/v2/hello.html", date, "")
+	if got, want := r.Exchange.RequestURI, "https://example.org/hello.html"; got != want {
+		t.Errorf("r.Exchange.RequestURI = %q, want %q", got, want)
+	}
+	if got, want := r.SignedURL.String(), "https://example.org/hello.html"; got != want {
+		t.Errorf("r.SignedURL = %q, want %q", got, want)
+	}
+}
+
 func TestRequestTweaker(t *testing.T) {
 	handlers := http.NewServeMux()
 	handlers.Handle(
@@ -199,6 +705,55 @@ func TestRequestTweaker(t *testing.T) {
 	}
 }
 
+func TestResponseTweaker(t *testing.T) {
+	handlers := http.NewServeMux()
+	handlers.Handle(
+		"example.org/hello.html",
+		stubHTMLHandler(`<!doctype html><link href="style.css" rel="stylesheet">`+
+			`<p>Hello, world!</p>`),
+	)
+	handlers.Handle(
+		"example.org/style.css",
+		stubTextHandler(`body { font-family: sans-serif; }`, "text/css"),
+	)
+	server := httptest.NewTLSServer(handlers)
+	defer server.Close()
+
+	config := makeConfig(server)
+	config.ResponseTweaker = func(resp *exchange.Response) error {
+		resp.Header.Set("X-Tweaked", resp.Request.URL.Path)
+		return nil
+	}
+	pkg := webpackager.NewPackager(config)
+	if _, err := pkg.Run(urlutil.MustParse("https://example.org/hello.html"), date); err != nil {
+		t.Fatalf("pkg.Run() = error(%q), want success", err)
+	}
+	for _, url := range []string{"https://example.org/hello.html", "https://example.org/style.css"} {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := pkg.ResourceCache.Lookup(req)
+		if err != nil || r == nil {
+			t.Fatalf("Lookup(%q) = (%v, %v), want resource", url, r, err)
+		}
+		if got, want := r.Exchange.ResponseHeaders.Get("X-Tweaked"), req.URL.Path; got != want {
+			t.Errorf("X-Tweaked for %s = %q, want %q", url, got, want)
+		}
+	}
+
+	errTweak := errors.New("tweak failed")
+	config = makeConfig(server)
+	config.ResponseTweaker = func(resp *exchange.Response) error {
+		return errTweak
+	}
+	pkg = webpackager.NewPackager(config)
+	_, err := pkg.Run(urlutil.MustParse("https://example.org/hello.html"), date)
+	if !errors.Is(err, errTweak) {
+		t.Errorf("pkg.Run() = error(%v), want %v", err, errTweak)
+	}
+}
+
 func TestNoExchanges(t *testing.T) {
 	handlers := http.NewServeMux()
 	handlers.Handle(
@@ -287,6 +842,56 @@ func TestSubresourceErrors(t *testing.T) {
 	verifyExchange(t, pkg, "https://example.org/valid.css", date, "")
 }
 
+func TestCheckPreloadTargets(t *testing.T) {
+	handlers := http.NewServeMux()
+	handlers.Handle(
+		"example.org/hello.html",
+		stubHTMLHandler(`<!doctype html>`+
+			`<link href="valid.css" rel="stylesheet">`+
+			`<link href="nonexistent.css" rel="stylesheet">`+
+			`<p>Hello, world!</p>`),
+	)
+	handlers.Handle(
+		"example.org/valid.css",
+		stubTextHandler(`body { font-family: sans-serif; }`, "text/css"),
+	)
+	server := httptest.NewTLSServer(handlers)
+	defer server.Close()
+
+	var tasks []htmltask.HTMLTask
+	tasks = append(tasks, htmltask.ConservativeTaskSet...)
+	tasks = append(tasks, htmltask.PreloadStylesheets())
+
+	cfg := makeConfig(server)
+	cfg.Processor = complexproc.NewComprehensiveProcessor(complexproc.Config{
+		HTML: htmlproc.Config{TaskSet: tasks},
+		CustomPostprocessors: processor.SequentialProcessor{
+			commonproc.CheckPreloadTargets(commonproc.CheckPreloadTargetsConfig{
+				FetchClient: fetchtest.NewFetchClient(server),
+			}),
+		},
+	})
+	ef, err := cfg.ExchangeFactory.Get()
+	if err != nil {
+		t.Errorf("ExchangeFactory.Get() = error(%q), want success", err)
+	}
+	ef.KeepNonSXGPreloads = true
+	pkg := webpackager.NewPackager(cfg)
+	if _, err := pkg.Run(urlutil.MustParse("https://example.org/hello.html"), date); err != nil {
+		t.Fatalf("pkg.Run() = error(%q), want success", err)
+	}
+
+	// nonexistent.css should be neither fetched nor preloaded.
+	verifyRequests(t, pkg, []string{
+		"https://example.org/hello.html",
+		"https://example.org/valid.css",
+	})
+	verifyExchange(t, pkg, "https://example.org/hello.html", date, fmt.Sprint(
+		`<https://example.org/valid.css>;rel="allowed-alt-sxg";`+
+			`header-integrity="sha256-+Xd20Pyxhd3oSvNo2ucj9gdj7ZkHavIaDGkucYF76J8=",`,
+		`<https://example.org/valid.css>;rel="preload";as="style"`))
+}
+
 func TestSubresourceErrorsKeepPreloads(t *testing.T) {
 	handlers := http.NewServeMux()
 	handlers.Handle(
@@ -330,3 +935,256 @@ func TestSubresourceErrorsKeepPreloads(t *testing.T) {
 		`<https://example.org/nonexistent2.css>;rel="preload";as="style"`))
 	verifyExchange(t, pkg, "https://example.org/valid.css", date, "")
 }
+
+func TestMaxResources(t *testing.T) {
+	withLink := func(h http.Handler, link string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Link", link)
+			h.ServeHTTP(w, req)
+		})
+	}
+	handlers := http.NewServeMux()
+	handlers.Handle(
+		"example.org/hello.html",
+		stubHTMLHandler(`<!doctype html>`+
+			`<link href="https://example.org/a.css" rel="stylesheet">`+
+			`<link href="https://example.org/b.css" rel="stylesheet">`+
+			`<p>Hello, world!</p>`),
+	)
+	handlers.Handle(
+		"example.org/a.css",
+		withLink(stubTextHandler(`body { color: red; }`, "text/css"),
+			`<https://example.org/deep.css>;rel="preload";as="style"`),
+	)
+	handlers.Handle("example.org/b.css", stubTextHandler(`p { color: blue; }`, "text/css"))
+	handlers.Handle("example.org/deep.css", stubTextHandler(`a { color: green; }`, "text/css"))
+	handlers.Handle("example.org/other.html", stubHTMLHandler(`<!doctype html><p>Other</p>`))
+	server := httptest.NewTLSServer(handlers)
+	defer server.Close()
+
+	config := makeConfig(server)
+	config.MaxResources = 3
+	pkg := webpackager.NewPackager(config)
+	if _, err := pkg.Run(urlutil.MustParse("https://example.org/hello.html"), date); err != nil {
+		t.Fatalf("pkg.Run() = error(%q), want success", err)
+	}
+	// deep.css gives way to b.css, a direct subresource of hello.html.
+	verifyRequests(t, pkg, []string{
+		"https://example.org/hello.html",
+		"https://example.org/a.css",
+		"https://example.org/b.css",
+	})
+	if processed, skipped := pkg.ResourceCounts(); processed != 3 || skipped != 1 {
+		t.Errorf("pkg.ResourceCounts() = (%d, %d), want (3, 1)", processed, skipped)
+	}
+
+	// The limit applies across runs.
+	r, err := pkg.Run(urlutil.MustParse("https://example.org/other.html"), date)
+	if err != nil {
+		t.Fatalf("pkg.Run() = error(%q), want success", err)
+	}
+	if r.Exchange != nil {
+		t.Error("r.Exchange = non-nil, want nil")
+	}
+	if processed, skipped := pkg.ResourceCounts(); processed != 3 || skipped != 2 {
+		t.Errorf("pkg.ResourceCounts() = (%d, %d), want (3, 2)", processed, skipped)
+	}
+}
+
+func TestSkipOversizedContent(t *testing.T) {
+	handlers := http.NewServeMux()
+	handlers.Handle(
+		"example.org/hello.html",
+		stubHTMLHandler(`<!doctype html>`+
+			`<link href="https://example.org/small.css" rel="stylesheet">`+
+			`<link href="https://example.org/large.css" rel="stylesheet">`+
+			`<p>Hello, world!</p>`),
+	)
+	handlers.Handle("example.org/small.css", stubTextHandler(`p { color: blue; }`, "text/css"))
+	handlers.Handle("example.org/large.css", stubTextHandler(strings.Repeat(`p { color: red; }`, 20), "text/css"))
+	server := httptest.NewTLSServer(handlers)
+	defer server.Close()
+
+	var tasks []htmltask.HTMLTask
+	tasks = append(tasks, htmltask.ConservativeTaskSet...)
+	tasks = append(tasks, htmltask.PreloadStylesheets())
+
+	cfg := makeConfig(server)
+	cfg.Processor = complexproc.NewComprehensiveProcessor(complexproc.Config{
+		Preverify: preverify.Config{
+			MaxContentLength: 256,
+			SizeLimitAction:  preverify.SizeLimitSkip,
+		},
+		HTML: htmlproc.Config{TaskSet: tasks},
+	})
+	pkg := webpackager.NewPackager(cfg)
+	if _, err := pkg.Run(urlutil.MustParse("https://example.org/hello.html"), date); err != nil {
+		t.Fatalf("pkg.Run() = error(%q), want success", err)
+	}
+	verifyRequests(t, pkg, []string{
+		"https://example.org/hello.html",
+		"https://example.org/small.css",
+		"https://example.org/large.css",
+	})
+	req, err := http.NewRequest(http.MethodGet, "https://example.org/large.css", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := pkg.ResourceCache.Lookup(req); err != nil || got != nil {
+		t.Errorf("Lookup(%q) = (%v, %v), want (nil, nil)", req.URL, got, err)
+	}
+	want := map[string]int{preverify.SkipReasonSizeLimit: 1}
+	if diff := cmp.Diff(want, pkg.SkipCounts()); diff != "" {
+		t.Errorf("pkg.SkipCounts() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestBaseURL(t *testing.T) {
+	handlers := http.NewServeMux()
+	handlers.Handle(
+		"example.org/hello/index.html",
+		stubHTMLHandler(`<!doctype html>`+
+			`<head><base href="/assets/">`+
+			`<link href="style.css" rel="stylesheet"></head>`+
+			`<p>Hello, world!</p>`),
+	)
+	handlers.Handle(
+		"example.org/assets/style.css",
+		stubTextHandler(`body { font-family: sans-serif; }`, "text/css"),
+	)
+	server := httptest.NewTLSServer(handlers)
+	defer server.Close()
+
+	pkg := webpackager.NewPackager(makeConfig(server))
+	r, err := pkg.Run(urlutil.MustParse("https://example.org/hello/index.html"), date)
+	if err != nil {
+		t.Fatalf("pkg.Run() = error(%q), want success", err)
+	}
+
+	// style.css is resolved against <base>, not the document URL.
+	verifyRequests(t, pkg, []string{
+		"https://example.org/hello/index.html",
+		"https://example.org/assets/style.css",
+	})
+	link := strings.Join(r.Exchange.ResponseHeaders["Link"], ",")
+	if want := `<https://example.org/assets/style.css>;rel="preload";as="style"`; !strings.Contains(link, want) {
+		t.Errorf(`ResponseHeaders["Link"] = %#q, want to contain %#q`, link, want)
+	}
+}
+
+func TestPreloadHeaders(t *testing.T) {
+	handlers := http.NewServeMux()
+	handlers.Handle(
+		"example.org/hello.html",
+		http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Add("Link", `<style.css>;rel="preload";as="style"`)
+			w.Header().Add("Link", `<font.woff2>;rel="preload";as="font";crossorigin;nopush`)
+			stubHTMLHandler(`<!doctype html>`+
+				`<link href="style.css" rel="stylesheet">`+
+				`<p>Hello, world!</p>`).ServeHTTP(w, req)
+		}),
+	)
+	handlers.Handle("example.org/style.css", stubTextHandler(`p { color: blue; }`, "text/css"))
+	handlers.Handle("example.org/font.woff2", stubTextHandler(`wOF2`, "font/woff2"))
+	server := httptest.NewTLSServer(handlers)
+	defer server.Close()
+
+	pkg := webpackager.NewPackager(makeConfig(server))
+	r, err := pkg.Run(urlutil.MustParse("https://example.org/hello.html"), date)
+	if err != nil {
+		t.Fatalf("pkg.Run() = error(%q), want success", err)
+	}
+	verifyRequests(t, pkg, []string{
+		"https://example.org/hello.html",
+		"https://example.org/style.css",
+		"https://example.org/font.woff2",
+	})
+	link := strings.Join(r.Exchange.ResponseHeaders["Link"], ",")
+	want := `<https://example.org/style.css>;rel="preload";as="style"`
+	if got := strings.Count(link, want); got != 1 {
+		t.Errorf(`ResponseHeaders["Link"] = %#q, want to contain %#q once`, link, want)
+	}
+	want = `<https://example.org/font.woff2>;rel="preload";as="font";crossorigin;nopush`
+	if !strings.Contains(link, want) {
+		t.Errorf(`ResponseHeaders["Link"] = %#q, want to contain %#q`, link, want)
+	}
+}
+
+func TestPreviousResources(t *testing.T) {
+	const css = `body { font-family: sans-serif; }`
+
+	tests := []struct {
+		name      string
+		date      time.Time
+		content   string
+		wantReuse bool
+	}{
+		{
+			name:      "Unchanged",
+			date:      date.Add(24 * time.Hour),
+			content:   css,
+			wantReuse: true,
+		},
+		{
+			name:      "Changed",
+			date:      date.Add(24 * time.Hour),
+			content:   `body { font-family: serif; }`,
+			wantReuse: false,
+		},
+		{
+			name:      "NearExpiry",
+			date:      date.Add(6*24*time.Hour + 12*time.Hour),
+			content:   css,
+			wantReuse: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			content := css
+			handlers := http.NewServeMux()
+			handlers.Handle("example.org/style.css", http.HandlerFunc(
+				func(w http.ResponseWriter, req *http.Request) {
+					stubTextHandler(content, "text/css").ServeHTTP(w, req)
+				},
+			))
+			server := httptest.NewTLSServer(handlers)
+			defer server.Close()
+
+			previous := cache.NewOnMemoryCache()
+			config := makeConfig(server)
+			config.ResourceCache = previous
+			first, err := webpackager.NewPackager(config).Run(urlutil.MustParse("https://example.org/style.css"), date)
+			if err != nil {
+				t.Fatalf("pkg.Run() = error(%q), want success", err)
+			}
+
+			content = test.content
+			config = makeConfig(server)
+			config.PreviousResources = previous
+			config.RenewBefore = 24 * time.Hour
+			pkg := webpackager.NewPackager(config)
+			req, err := http.NewRequest(http.MethodGet, "https://example.org/style.css", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, stats, err := pkg.RunForRequestWithStats(req, test.date)
+			if err != nil {
+				t.Fatalf("pkg.RunForRequestWithStats() = error(%q), want success", err)
+			}
+
+			// style.css should be fetched even when it is reused.
+			verifyRequests(t, pkg, []string{"https://example.org/style.css"})
+			if got := r.Exchange == first.Exchange; got != test.wantReuse {
+				t.Errorf("reused = %v, want %v", got, test.wantReuse)
+			}
+			if stats.CacheHit != test.wantReuse {
+				t.Errorf("stats.CacheHit = %v, want %v", stats.CacheHit, test.wantReuse)
+			}
+			if want := resource.NewContentHash([]byte(test.content)); r.ContentHash != want {
+				t.Errorf("r.ContentHash = %q, want %q", r.ContentHash, want)
+			}
+			verifyExchange(t, pkg, "https://example.org/style.css", test.date, "")
+		})
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commonproc
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/layer0-platform/webpackager/exchange"
+	"github.com/layer0-platform/webpackager/fetch"
+	"github.com/layer0-platform/webpackager/processor"
+	"github.com/layer0-platform/webpackager/resource/preload"
+)
+
+// DefaultCheckPreloadsConcurrency is the default number of requests
+// CheckPreloadTargets sends in parallel.
+const DefaultCheckPreloadsConcurrency = 4
+
+// CheckPreloadTargetsConfig holds the parameters to CheckPreloadTargets.
+type CheckPreloadTargetsConfig struct {
+	// FetchClient is used to send the HEAD requests. nil implies
+	// fetch.DefaultFetchClient.
+	FetchClient fetch.FetchClient
+
+	// Concurrency specifies the maximum number of requests in flight.
+	// Zero implies DefaultCheckPreloadsConcurrency.
+	Concurrency int
+}
+
+// CheckPreloadTargets returns a processor that sends a HEAD request to every
+// resource referenced from resp.Preloads and drops the preloads that have
+// some resources not responding with 200 (OK). It falls back to GET for
+// servers not supporting HEAD (405 or 501). The URLs of the dropped resources
+// are recorded to ExtraData with the key exchange.DroppedPreload.
+//
+// CheckPreloadTargets is expensive, as it costs extra round trips for each
+// subresource, and it should be run after the processors discovering the
+// preloads (e.g. as a postprocessor).
+func CheckPreloadTargets(config CheckPreloadTargetsConfig) processor.Processor {
+	if config.FetchClient == nil {
+		config.FetchClient = fetch.DefaultFetchClient
+	}
+	if config.Concurrency == 0 {
+		config.Concurrency = DefaultCheckPreloadsConcurrency
+	}
+	return &checkPreloadTargets{config}
+}
+
+type checkPreloadTargets struct {
+	config CheckPreloadTargetsConfig
+}
+
+func (cpt *checkPreloadTargets) Process(resp *exchange.Response) error {
+	if len(resp.Preloads) == 0 {
+		return nil
+	}
+	reachable := cpt.checkAll(resp)
+
+	kept := resp.Preloads[:0]
+	for _, p := range resp.Preloads {
+		ok := true
+		for _, r := range p.Resources {
+			if u := r.RequestURL.String(); !reachable[u] {
+				ok = false
+				resp.ExtraData.Add(exchange.DroppedPreload, u)
+			}
+		}
+		if ok {
+			kept = append(kept, p)
+		} else {
+			log.Printf("warning: dropped unreachable preload %v", p.Link)
+			resp.ExtraData.Add(exchange.AppliedTransformation,
+				fmt.Sprintf("dropped unreachable preload %v", p.Link))
+		}
+	}
+	resp.Preloads = kept
+	return nil
+}
+
+// checkAll reports whether each distinct resource in resp.Preloads is
+// reachable. The results are keyed by the URLs.
+func (cpt *checkPreloadTargets) checkAll(resp *exchange.Response) map[string]bool {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	reachable := make(map[string]bool)
+	sem := make(chan struct{}, cpt.config.Concurrency)
+
+	for _, u := range distinctURLs(resp.Preloads) {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(u string) {
+			defer func() { <-sem; wg.Done() }()
+			ok := cpt.check(u, resp.Request)
+			mu.Lock()
+			reachable[u] = ok
+			mu.Unlock()
+		}(u)
+	}
+	wg.Wait()
+	return reachable
+}
+
+func (cpt *checkPreloadTargets) check(u string, parent *http.Request) bool {
+	status, err := cpt.send(http.MethodHead, u, parent)
+	if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented) {
+		status, err = cpt.send(http.MethodGet, u, parent)
+	}
+	if err != nil {
+		log.Printf("warning: failed to check %v: %v", u, err)
+		return false
+	}
+	return status == http.StatusOK
+}
+
+func (cpt *checkPreloadTargets) send(method, u string, parent *http.Request) (int, error) {
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Referer", parent.URL.String())
+	resp, err := cpt.config.FetchClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+func distinctURLs(preloads []*preload.Preload) []string {
+	var urls []string
+	seen := make(map[string]bool)
+	for _, p := range preloads {
+		for _, r := range p.Resources {
+			u := r.RequestURL.String()
+			if !seen[u] {
+				seen[u] = true
+				urls = append(urls, u)
+			}
+		}
+	}
+	return urls
+}
@@ -0,0 +1,107 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commonproc_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/layer0-platform/webpackager/exchange"
+	"github.com/layer0-platform/webpackager/exchange/exchangetest"
+	"github.com/layer0-platform/webpackager/fetch/fetchtest"
+	"github.com/layer0-platform/webpackager/processor/commonproc"
+	"github.com/layer0-platform/webpackager/resource/preload"
+	"github.com/layer0-platform/webpackager/resource/preload/preloadtest"
+)
+
+func TestCheckPreloadTargets(t *testing.T) {
+	pl := preloadtest.NewPreloadForRawURL
+
+	handlers := http.NewServeMux()
+	handlers.HandleFunc("example.com/style.css", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/css")
+	})
+	handlers.HandleFunc("example.com/nohead.css", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	handlers.Handle("example.com/redirect.css", http.RedirectHandler("style.css", http.StatusFound))
+	server := httptest.NewTLSServer(handlers)
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		preloads    []*preload.Preload
+		wantPreload []*preload.Preload
+		wantDropped []string
+	}{
+		{
+			name: "AllReachable",
+			preloads: []*preload.Preload{
+				pl("https://example.com/style.css", preload.AsStyle),
+				pl("https://example.com/nohead.css", preload.AsStyle),
+			},
+			wantPreload: []*preload.Preload{
+				pl("https://example.com/style.css", preload.AsStyle),
+				pl("https://example.com/nohead.css", preload.AsStyle),
+			},
+			wantDropped: nil,
+		},
+		{
+			name: "SomeUnreachable",
+			preloads: []*preload.Preload{
+				pl("https://example.com/missing.css", preload.AsStyle),
+				pl("https://example.com/style.css", preload.AsStyle),
+				pl("https://example.com/redirect.css", preload.AsStyle),
+			},
+			wantPreload: []*preload.Preload{
+				pl("https://example.com/style.css", preload.AsStyle),
+			},
+			wantDropped: []string{
+				"https://example.com/missing.css",
+				"https://example.com/redirect.css",
+			},
+		},
+		{
+			name:        "NoPreloads",
+			preloads:    nil,
+			wantPreload: nil,
+			wantDropped: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			processor := commonproc.CheckPreloadTargets(commonproc.CheckPreloadTargetsConfig{
+				FetchClient: fetchtest.NewFetchClient(server),
+			})
+			resp := exchangetest.MakeEmptyResponse("https://example.com/index.html")
+			resp.Preloads = test.preloads
+
+			if err := processor.Process(resp); err != nil {
+				t.Fatalf("got error(%q), want success", err)
+			}
+			if diff := cmp.Diff(test.wantPreload, resp.Preloads); diff != "" {
+				t.Errorf("resp.Preloads mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(test.wantDropped, resp.ExtraData[exchange.DroppedPreload]); diff != "" {
+				t.Errorf("resp.ExtraData mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commonproc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/layer0-platform/webpackager/exchange"
+	"github.com/layer0-platform/webpackager/processor"
+)
+
+const (
+	// DefaultExternalCommandTimeout is the default timeout applied to
+	// ExternalCommand.
+	DefaultExternalCommandTimeout = 30 * time.Second
+
+	// DefaultExternalCommandMaxOutput is the default limit on the output
+	// size of ExternalCommand, in bytes.
+	DefaultExternalCommandMaxOutput = 4194304 // 4 MiB
+)
+
+var errOutputTooLarge = errors.New("output too large")
+
+// ExternalCommandConfig holds the parameters to ExternalCommand.
+type ExternalCommandConfig struct {
+	// Path specifies the command to run. It is resolved with exec.LookPath
+	// if it contains no path separators.
+	Path string
+
+	// Args specifies the command line arguments, not including the command
+	// name itself.
+	Args []string
+
+	// Timeout specifies the maximum duration the command is allowed to run.
+	// Zero implies DefaultExternalCommandTimeout.
+	Timeout time.Duration
+
+	// MaxOutput specifies the maximum size of the output, in bytes.
+	// Zero implies DefaultExternalCommandMaxOutput.
+	MaxOutput int
+}
+
+// ExternalCommand returns a processor that pipes the payload through the
+// external command specified by config: the payload is fed to its standard
+// input, and the standard output replaces the payload. The command also
+// receives the request URL and the Content-Type in the WEBPACKAGER_URL and
+// WEBPACKAGER_CONTENT_TYPE environment variables respectively; it should
+// output the payload unchanged if it does not handle the content.
+//
+// ExternalCommand is an escape hatch for transformations not implemented
+// in Go, such as custom minifiers. Its Process method returns an error when
+// the command exits with a non-zero status, runs over the timeout, or emits
+// output over the size limit.
+func ExternalCommand(config ExternalCommandConfig) processor.Processor {
+	if config.Timeout == 0 {
+		config.Timeout = DefaultExternalCommandTimeout
+	}
+	if config.MaxOutput == 0 {
+		config.MaxOutput = DefaultExternalCommandMaxOutput
+	}
+	return &externalCommand{config}
+}
+
+type externalCommand struct {
+	config ExternalCommandConfig
+}
+
+func (ec *externalCommand) Process(resp *exchange.Response) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ec.config.Timeout)
+	defer cancel()
+
+	stdout := &limitedBuffer{limit: ec.config.MaxOutput, onExceed: cancel}
+	var stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, ec.config.Path, ec.config.Args...)
+	cmd.Env = append(os.Environ(),
+		"WEBPACKAGER_URL="+resp.Request.URL.String(),
+		"WEBPACKAGER_CONTENT_TYPE="+resp.Header.Get("Content-Type"))
+	cmd.Stdin = bytes.NewReader(resp.Payload)
+	cmd.Stdout = stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		switch {
+		case stdout.exceeded:
+			err = fmt.Errorf("%v (limit: %d bytes)", errOutputTooLarge, ec.config.MaxOutput)
+		case ctx.Err() == context.DeadlineExceeded:
+			err = fmt.Errorf("timed out after %v", ec.config.Timeout)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("running %s: %v: %s", ec.config.Path, err, msg)
+		}
+		return fmt.Errorf("running %s: %v", ec.config.Path, err)
+	}
+
+	resp.Payload = stdout.Bytes()
+	resp.ExtraData.Add(exchange.AppliedTransformation,
+		fmt.Sprintf("transformed payload with %s", ec.config.Path))
+	if resp.Header.Get("Content-Length") != "" {
+		resp.Header.Set("Content-Length", strconv.Itoa(len(resp.Payload)))
+	}
+	return nil
+}
+
+// limitedBuffer is an io.Writer buffering up to limit bytes. It calls
+// onExceed when the limit is exceeded, to terminate the command early.
+//
+// limitedBuffer does not embed bytes.Buffer, as io.Copy would bypass Write
+// through the promoted ReadFrom method.
+type limitedBuffer struct {
+	buf      bytes.Buffer
+	limit    int
+	onExceed func()
+	exceeded bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.buf.Len()+len(p) > b.limit {
+		b.exceeded = true
+		b.onExceed()
+		return 0, errOutputTooLarge
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) Bytes() []byte {
+	return b.buf.Bytes()
+}
@@ -0,0 +1,109 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commonproc_test
+
+import (
+	"fmt"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/layer0-platform/webpackager/exchange/exchangetest"
+	"github.com/layer0-platform/webpackager/processor/commonproc"
+)
+
+func TestExternalCommand(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	tests := []struct {
+		name    string
+		config  commonproc.ExternalCommandConfig
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Transform",
+			config: commonproc.ExternalCommandConfig{
+				Path: "sh",
+				Args: []string{"-c", "tr a-z A-Z"},
+			},
+			want: "<!DOCTYPE HTML><P>HELLO, WORLD!</P>",
+		},
+		{
+			name: "Environment",
+			config: commonproc.ExternalCommandConfig{
+				Path: "sh",
+				Args: []string{"-c", `printf '%s %s' "$WEBPACKAGER_URL" "$WEBPACKAGER_CONTENT_TYPE"`},
+			},
+			want: "https://example.org/hello.html text/html; charset=utf-8",
+		},
+		{
+			name: "NonZeroExit",
+			config: commonproc.ExternalCommandConfig{
+				Path: "sh",
+				Args: []string{"-c", "echo oops >&2; exit 1"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Timeout",
+			config: commonproc.ExternalCommandConfig{
+				Path:    "sh",
+				Args:    []string{"-c", "exec sleep 10"},
+				Timeout: 100 * time.Millisecond,
+			},
+			wantErr: true,
+		},
+		{
+			name: "OutputTooLarge",
+			config: commonproc.ExternalCommandConfig{
+				Path:      "sh",
+				Args:      []string{"-c", "printf '%060d' 0"},
+				MaxOutput: 48,
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := exchangetest.MakeResponse("https://example.org/hello.html", fmt.Sprint(
+				"HTTP/1.1 200 OK\r\n",
+				"Content-Length: 35\r\n",
+				"Content-Type: text/html; charset=utf-8\r\n",
+				"\r\n",
+				"<!doctype html><p>Hello, world!</p>",
+			))
+			err := commonproc.ExternalCommand(test.config).Process(resp)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("got success, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got error(%q), want success", err)
+			}
+			if got := string(resp.Payload); got != test.want {
+				t.Errorf("resp.Payload = %q, want %q", got, test.want)
+			}
+			if got, want := resp.Header.Get("Content-Length"), fmt.Sprint(len(test.want)); got != want {
+				t.Errorf(`resp.Header.Get("Content-Length") = %q, want %q`, got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commonproc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/layer0-platform/webpackager/exchange"
+	"github.com/layer0-platform/webpackager/processor"
+	"github.com/layer0-platform/webpackager/resource"
+	"github.com/layer0-platform/webpackager/resource/httplink"
+	"github.com/layer0-platform/webpackager/resource/preload"
+)
+
+// MergeDuplicatePreloads collapses the preloads in resp.Preloads that point
+// to the same URL into a single entry. It merges two preloads when their
+// "as" parameters are equal or either is unset, and when all their other
+// parameters but "media" are equal. The merged preload keeps the non-empty
+// "as" and the union of the "media" queries; an unset "media" on either
+// side means the preload applies to all media, thus leaves it unset.
+//
+// Duplicates typically arise when authors declare a preload which some task
+// also adds. MergeDuplicatePreloads should be run after all the processors
+// discovering the preloads (e.g. as a postprocessor).
+var MergeDuplicatePreloads processor.Processor = &mergeDuplicatePreloads{}
+
+type mergeDuplicatePreloads struct{}
+
+func (*mergeDuplicatePreloads) Process(resp *exchange.Response) error {
+	var merged []*preload.Preload
+	for _, p := range resp.Preloads {
+		found := false
+		for i, q := range merged {
+			if canMergePreloads(q, p) {
+				merged[i] = mergePreloads(q, p)
+				resp.ExtraData.Add(exchange.AppliedTransformation,
+					fmt.Sprintf("merged duplicate preloads for %v", p.URL))
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, p)
+		}
+	}
+	resp.Preloads = merged
+	return nil
+}
+
+func canMergePreloads(p, q *preload.Preload) bool {
+	if p.URL.String() != q.URL.String() {
+		return false
+	}
+	pa, qa := p.Params.Get(httplink.ParamAs), q.Params.Get(httplink.ParamAs)
+	if pa != "" && qa != "" && pa != qa {
+		return false
+	}
+	pp, qp := p.Params.Clone(), q.Params.Clone()
+	for _, key := range []string{httplink.ParamAs, httplink.ParamMedia} {
+		delete(pp, key)
+		delete(qp, key)
+	}
+	return pp.Equal(qp)
+}
+
+// mergePreloads returns a new Preload combining p and q, assuming
+// canMergePreloads(p, q) is true. p and q are left unmodified.
+func mergePreloads(p, q *preload.Preload) *preload.Preload {
+	link := &httplink.Link{URL: p.URL, Params: p.Params.Clone()}
+
+	if as := q.Params.Get(httplink.ParamAs); as != "" {
+		link.Params.Set(httplink.ParamAs, as)
+	}
+	media := mergeMedia(
+		p.Params.Get(httplink.ParamMedia), q.Params.Get(httplink.ParamMedia))
+	if media != "" {
+		link.Params.Set(httplink.ParamMedia, media)
+	} else {
+		delete(link.Params, httplink.ParamMedia)
+	}
+
+	resources := append([]*resource.Resource(nil), p.Resources...)
+	seen := make(map[string]bool)
+	for _, r := range resources {
+		seen[r.RequestURL.String()] = true
+	}
+	for _, r := range q.Resources {
+		if u := r.RequestURL.String(); !seen[u] {
+			seen[u] = true
+			resources = append(resources, r)
+		}
+	}
+	return &preload.Preload{Link: link, Resources: resources}
+}
+
+// mergeMedia returns the union of two media query lists. An empty list
+// matches all media, so the union is empty if either is empty.
+func mergeMedia(m1, m2 string) string {
+	if m1 == "" || m2 == "" {
+		return ""
+	}
+	var queries []string
+	seen := make(map[string]bool)
+	for _, m := range []string{m1, m2} {
+		for _, query := range strings.Split(m, ",") {
+			query = strings.TrimSpace(query)
+			if query != "" && !seen[query] {
+				seen[query] = true
+				queries = append(queries, query)
+			}
+		}
+	}
+	return strings.Join(queries, ", ")
+}
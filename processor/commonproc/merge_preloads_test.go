@@ -0,0 +1,114 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commonproc_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/layer0-platform/webpackager/exchange/exchangetest"
+	"github.com/layer0-platform/webpackager/processor/commonproc"
+	"github.com/layer0-platform/webpackager/resource/preload"
+	"github.com/layer0-platform/webpackager/resource/preload/preloadtest"
+)
+
+func TestMergeDuplicatePreloads(t *testing.T) {
+	pl := preloadtest.NewPreloadForRawLink
+
+	tests := []struct {
+		name     string
+		preloads []*preload.Preload
+		want     []*preload.Preload
+	}{
+		{
+			name: "AuthorAndTask",
+			preloads: []*preload.Preload{
+				pl(`<https://example.com/style.css>;rel="preload"`),
+				pl(`<https://example.com/style.css>;rel="preload";as="style"`),
+			},
+			want: []*preload.Preload{
+				pl(`<https://example.com/style.css>;rel="preload";as="style"`),
+			},
+		},
+		{
+			name: "UnionMedia",
+			preloads: []*preload.Preload{
+				pl(`<https://example.com/style.css>;rel="preload";as="style";media="screen"`),
+				pl(`<https://example.com/style.css>;rel="preload";as="style";media="print, screen"`),
+			},
+			want: []*preload.Preload{
+				pl(`<https://example.com/style.css>;rel="preload";as="style";media="screen, print"`),
+			},
+		},
+		{
+			name: "MediaUnset",
+			preloads: []*preload.Preload{
+				pl(`<https://example.com/style.css>;rel="preload";as="style";media="print"`),
+				pl(`<https://example.com/style.css>;rel="preload";as="style"`),
+			},
+			want: []*preload.Preload{
+				pl(`<https://example.com/style.css>;rel="preload";as="style"`),
+			},
+		},
+		{
+			name: "DifferentAs",
+			preloads: []*preload.Preload{
+				pl(`<https://example.com/data.json>;rel="preload";as="fetch"`),
+				pl(`<https://example.com/data.json>;rel="preload";as="script"`),
+			},
+			want: []*preload.Preload{
+				pl(`<https://example.com/data.json>;rel="preload";as="fetch"`),
+				pl(`<https://example.com/data.json>;rel="preload";as="script"`),
+			},
+		},
+		{
+			name: "DifferentCrossOrigin",
+			preloads: []*preload.Preload{
+				pl(`<https://example.com/font.woff2>;rel="preload";as="font"`),
+				pl(`<https://example.com/font.woff2>;rel="preload";as="font";crossorigin`),
+			},
+			want: []*preload.Preload{
+				pl(`<https://example.com/font.woff2>;rel="preload";as="font"`),
+				pl(`<https://example.com/font.woff2>;rel="preload";as="font";crossorigin`),
+			},
+		},
+		{
+			name: "DifferentURLs",
+			preloads: []*preload.Preload{
+				pl(`<https://example.com/style.css>;rel="preload";as="style"`),
+				pl(`<https://example.com/script.js>;rel="preload";as="script"`),
+				pl(`<https://example.com/style.css>;rel="preload"`),
+			},
+			want: []*preload.Preload{
+				pl(`<https://example.com/style.css>;rel="preload";as="style"`),
+				pl(`<https://example.com/script.js>;rel="preload";as="script"`),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := exchangetest.MakeEmptyResponse("https://example.com/index.html")
+			resp.Preloads = test.preloads
+
+			if err := commonproc.MergeDuplicatePreloads.Process(resp); err != nil {
+				t.Fatalf("got error(%q), want success", err)
+			}
+			if diff := cmp.Diff(test.want, resp.Preloads); diff != "" {
+				t.Errorf("resp.Preloads mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
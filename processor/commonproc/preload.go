@@ -30,6 +30,16 @@ const headerKey = "Link"
 // objects. The preload links will be added to the Preloads field and removed
 // from the Link HTTP headers. Note they will be eventually added back to
 // the Link HTTP headers when the response is turned into a signed exchange.
+// Preload links beyond the maximum number are dropped and recorded to
+// ExtraData with the key exchange.OverCapPreload.
+//
+// Preload links with an "as" value browsers do not recognize (see
+// preload.IsValidAs) are dropped with a warning. Duplicate preload links do
+// not count toward the maximum number. Other parameters, such as "nopush",
+// are kept as they are: "nopush" only disables HTTP/2 server push and
+// the link is still a preload. The preload links later discovered in
+// the document (e.g. by htmlproc) are deduplicated against these by
+// exchange.Response.AddPreload and MergeDuplicatePreloads.
 var ExtractPreloadHeaders processor.Processor = &extractPreloadHeaders{}
 
 // KeepNonPreloadLinkHeaders instruct the processor to include preload link
@@ -59,10 +69,18 @@ func (*extractPreloadHeaders) Process(resp *exchange.Response) error {
 		}
 		for _, link := range links {
 			if link.IsPreload() {
+				link.URL = resp.Request.URL.ResolveReference(link.URL)
+				if as, ok := link.Params[httplink.ParamAs]; ok && !preload.IsValidAs(as) {
+					log.Printf("warning: %v: dropped preload link %v with invalid as=%q",
+						resp.Request.URL, link.URL, as)
+					continue
+				}
 				if numPreloads < maxNumPreloads {
-					link.URL = resp.Request.URL.ResolveReference(link.URL)
-					resp.AddPreload(preload.NewPreloadForLink(link))
-					numPreloads++
+					if resp.AddPreload(preload.NewPreloadForLink(link)) {
+						numPreloads++
+					}
+				} else if !hasPreload(resp, link) {
+					resp.ExtraData.Add(exchange.OverCapPreload, link.URL.String())
 				}
 			} else if keepNonPreloadLinkHeaders {
 				resp.Header.Add(headerKey, link.String())
@@ -72,3 +90,13 @@ func (*extractPreloadHeaders) Process(resp *exchange.Response) error {
 
 	return nil
 }
+
+// hasPreload reports whether resp.Preloads already contains link.
+func hasPreload(resp *exchange.Response, link *httplink.Link) bool {
+	for _, p := range resp.Preloads {
+		if p.Link.Equal(link) {
+			return true
+		}
+	}
+	return false
+}
@@ -79,6 +79,53 @@ func TestExtractPreloadHeaders(t *testing.T) {
 				"Content-Type": []string{"text/html; charset=utf-8"},
 			},
 		},
+		{
+			name: "NoPush",
+			url:  "https://example.com/hello.html",
+			resp: fmt.Sprint(
+				"HTTP/1.1 200 OK\r\n",
+				"Link: <https://example.com/style.css>;rel=\"preload\";as=\"style\";nopush\r\n",
+				"Content-Type: text/html; charset=utf-8\r\n\r\n",
+			),
+			wantPreloads: []*preload.Preload{
+				pl(`<https://example.com/style.css>;rel="preload";as="style";nopush`),
+			},
+			wantHeader: http.Header{
+				"Content-Type": []string{"text/html; charset=utf-8"},
+			},
+		},
+		{
+			name: "InvalidAs",
+			url:  "https://example.com/hello.html",
+			resp: fmt.Sprint(
+				"HTTP/1.1 200 OK\r\n",
+				"Link: <https://example.com/style.css>;rel=\"preload\";as=\"stylesheet\"\r\n",
+				"Link: <https://example.com/photo.jpg>;rel=\"preload\";as=\"IMAGE\"\r\n",
+				"Content-Type: text/html; charset=utf-8\r\n\r\n",
+			),
+			wantPreloads: []*preload.Preload{
+				pl(`<https://example.com/photo.jpg>;rel="preload";as="image"`),
+			},
+			wantHeader: http.Header{
+				"Content-Type": []string{"text/html; charset=utf-8"},
+			},
+		},
+		{
+			name: "Duplicate",
+			url:  "https://example.com/hello.html",
+			resp: fmt.Sprint(
+				"HTTP/1.1 200 OK\r\n",
+				"Link: <https://example.com/style.css>;rel=\"preload\";as=\"style\"\r\n",
+				"Link: <style.css>;rel=\"preload\";as=\"style\"\r\n",
+				"Content-Type: text/html; charset=utf-8\r\n\r\n",
+			),
+			wantPreloads: []*preload.Preload{
+				pl(`<https://example.com/style.css>;rel="preload";as="style"`),
+			},
+			wantHeader: http.Header{
+				"Content-Type": []string{"text/html; charset=utf-8"},
+			},
+		},
 		{
 			name: "Multiple_HeaderRepeated",
 			url:  "https://example.com/hello.html",
@@ -49,13 +49,18 @@ var (
 // RemoveUncachedHeaders removes uncached header fields. Such header fields
 // are disallowed in signed exchanges.
 // https://tools.ietf.org/html/draft-yasskin-http-origin-signed-responses-07#section-4.1.
+// Each removed header is recorded in ExtraData[exchange.AppliedTransformation].
 var RemoveUncachedHeaders processor.Processor = &removeUncachedHeaders{}
 
 type removeUncachedHeaders struct{}
 
 func (*removeUncachedHeaders) Process(resp *exchange.Response) error {
 	for _, name := range uncachedHeaders {
+		if _, ok := resp.Header[name]; !ok {
+			continue
+		}
 		resp.Header.Del(name)
+		resp.ExtraData.Add(exchange.AppliedTransformation, "removed header "+name)
 	}
 	// TODO(yuizumi): Remove the header fields specified to the no-cache
 	// directive in the Cache-Control header.
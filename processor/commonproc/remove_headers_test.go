@@ -20,6 +20,7 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/layer0-platform/webpackager/exchange"
 	"github.com/layer0-platform/webpackager/exchange/exchangetest"
 	"github.com/layer0-platform/webpackager/processor/commonproc"
 )
@@ -52,4 +53,13 @@ func TestRemoveUncachedHeaders(t *testing.T) {
 	if diff := cmp.Diff(want, resp.Header); diff != "" {
 		t.Errorf("resp.Header mismatch (-want +got):\n%s", diff)
 	}
+
+	wantTransformations := []string{
+		"removed header Connection",
+		"removed header Keep-Alive",
+		"removed header Set-Cookie",
+	}
+	if diff := cmp.Diff(wantTransformations, resp.ExtraData[exchange.AppliedTransformation]); diff != "" {
+		t.Errorf("resp.ExtraData mismatch (-want +got):\n%s", diff)
+	}
 }
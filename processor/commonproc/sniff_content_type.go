@@ -0,0 +1,58 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commonproc
+
+import (
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+
+	"github.com/layer0-platform/webpackager/exchange"
+	"github.com/layer0-platform/webpackager/processor"
+)
+
+// SniffContentType populates the Content-Type header when it is absent,
+// inferring the media type from the file extension in the request URL or,
+// failing that, from the payload using http.DetectContentType. The inferred
+// value is also recorded in ExtraData with exchange.SniffedContentType as
+// the key, so subsequent processors can tell it was not sent by the server.
+//
+// SniffContentType is meant to be run as a preprocessor, so that the main
+// processors and ValidPeriodRules can use the inferred media type. It does
+// nothing on responses which already have Content-Type.
+var SniffContentType processor.Processor = &sniffContentType{}
+
+type sniffContentType struct{}
+
+func (*sniffContentType) Process(resp *exchange.Response) error {
+	if resp.Header.Get("Content-Type") != "" {
+		return nil
+	}
+
+	ctype := mime.TypeByExtension(path.Ext(resp.Request.URL.Path))
+	if ctype == "" {
+		ctype = http.DetectContentType(resp.Payload)
+	}
+	log.Printf("warning: %s is missing Content-Type; inferred %q.",
+		resp.Request.URL, ctype)
+	resp.Header.Set("Content-Type", ctype)
+	resp.ExtraData.Set(exchange.SniffedContentType, ctype)
+	resp.ExtraData.Add(exchange.AppliedTransformation,
+		fmt.Sprintf("set inferred Content-Type %q", ctype))
+
+	return nil
+}
@@ -26,12 +26,27 @@ var DefaultProcessor = NewComprehensiveProcessor(Config{})
 
 // Config customizes NewComprehensiveProcessor.
 type Config struct {
-	// Preverify is passed to preverify.CheckPrerequisites.
+	// Preverify is passed to preverify.CheckPrerequisites. Custom checks
+	// can be added through Preverify.CustomProcessors.
 	Preverify preverify.Config
 
 	// HTML is passed to htmlproc.NewHTMLProcessor.
 	HTML htmlproc.Config
 
+	// SniffContentType instructs ComprehensiveProcessor to infer the media
+	// type of responses missing Content-Type before the main processor is
+	// chosen. See commonproc.SniffContentType for details.
+	//
+	// When SniffContentType is false, responses without Content-Type still
+	// get Content-Type populated by commonproc.ContentTypeProcessor, but only
+	// after the main processors, thus are never processed by them.
+	SniffContentType bool
+
+	// RejectJS instructs ComprehensiveProcessor to refuse JavaScript with
+	// preverify.RejectJS. The check takes place after SniffContentType, so
+	// it also covers the media types inferred by sniffing.
+	RejectJS bool
+
 	// CustomMainProcessors is a map from media types to main processors.
 	//
 	// CustomMainProcessors takes the precedence over the default map.
@@ -57,6 +72,7 @@ var (
 	}
 	// EssentialPostprocessors contain always-run postprocessors.
 	EssentialPostprocessors = processor.SequentialProcessor{
+		commonproc.MergeDuplicatePreloads,
 		commonproc.ContentTypeProcessor,
 		commonproc.RemoveUncachedHeaders,
 	}
@@ -66,14 +82,22 @@ var (
 // on the provided Config.
 func NewComprehensiveProcessor(config Config) processor.Processor {
 	// TODO(yuizumi): Maybe flatten these processors.
-	return processor.SequentialProcessor{
+	p := processor.SequentialProcessor{
 		preverify.CheckPrerequisites(config.Preverify),
 		EssentialPreprocessors,
+	}
+	if config.SniffContentType {
+		p = append(p, commonproc.SniffContentType)
+	}
+	if config.RejectJS {
+		p = append(p, preverify.RejectJS)
+	}
+	return append(p,
 		config.CustomPreprocessors,
 		newMainProcessor(config),
 		EssentialPostprocessors,
 		config.CustomPostprocessors,
-	}
+	)
 }
 
 func newMainProcessor(config Config) processor.Processor {
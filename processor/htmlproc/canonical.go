@@ -0,0 +1,120 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package htmlproc
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/layer0-platform/webpackager/exchange"
+	"github.com/layer0-platform/webpackager/internal/urlutil"
+	"github.com/layer0-platform/webpackager/processor"
+	"github.com/layer0-platform/webpackager/processor/htmlproc/htmldoc"
+	"github.com/layer0-platform/webpackager/resource/httplink"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// CheckCanonical creates a Processor to check that the canonical URL of
+// each response, declared by a Link header with rel="canonical" or by
+// <link rel="canonical"> in HTML documents, matches the URL the signed
+// exchange is issued for, i.e. ExtraData[exchange.SignedURL], or the request
+// URL if it is missing. A mismatch suggests a non-canonical variant of
+// the page is being packaged, which may confuse search engines. The URLs are
+// compared after normalization (see urlutil.NormalizeIRI), ignoring
+// the fragment. Responses without canonical URLs pass the check. mode
+// specifies how to handle mismatches.
+//
+// The Processor should run before commonproc.ExtractPreloadHeaders, which
+// removes the Link headers, e.g. in preverify.Config.CustomProcessors.
+func CheckCanonical(mode ValidationMode) processor.Processor {
+	return newChecker(mode, "canonical URL mismatch", checkCanonical)
+}
+
+func checkCanonical(resp *exchange.Response) ([]string, error) {
+	signed := resp.Request.URL
+	if s := resp.ExtraData.Get(exchange.SignedURL); s != "" {
+		u, err := url.Parse(s)
+		if err != nil {
+			return nil, err
+		}
+		signed = u
+	}
+	want := canonicalString(signed)
+
+	var problems []string
+	for _, value := range resp.Header.Values("Link") {
+		links, err := httplink.Parse(value)
+		if err != nil {
+			continue // commonproc.ExtractPreloadHeaders warns about it.
+		}
+		for _, link := range links {
+			if !isCanonicalRel(link.Params.Get(httplink.ParamRel)) {
+				continue
+			}
+			u := resp.Request.URL.ResolveReference(link.URL)
+			if got := canonicalString(u); got != want {
+				problems = append(problems, fmt.Sprintf("Link header: %s, not %s", got, want))
+			}
+		}
+	}
+	if isHTML(resp) {
+		doc, err := htmldoc.NewDocument(resp.Payload, resp.Request.URL)
+		if err != nil {
+			return nil, err
+		}
+		htmldoc.Traverse(doc.Root, func(n *html.Node) error {
+			if n.Type != html.ElementNode || n.DataAtom != atom.Link {
+				return nil
+			}
+			if !isCanonicalRel(htmldoc.GetAttr(n, "rel")) {
+				return nil
+			}
+			href, err := url.Parse(strings.TrimSpace(htmldoc.GetAttr(n, "href")))
+			if err != nil {
+				problems = append(problems, fmt.Sprintf(`<link rel="canonical">: %v`, err))
+				return nil
+			}
+			if got := canonicalString(doc.ResolveReference(href)); got != want {
+				problems = append(problems, fmt.Sprintf(`<link rel="canonical">: %s, not %s`, got, want))
+			}
+			return nil
+		})
+	}
+	return problems, nil
+}
+
+func isCanonicalRel(rel string) bool {
+	for _, s := range strings.Fields(rel) {
+		if strings.EqualFold(s, "canonical") {
+			return true
+		}
+	}
+	return false
+}
+
+// canonicalString returns u normalized for comparison.
+func canonicalString(u *url.URL) string {
+	v := new(url.URL)
+	*v = *u
+	v.Fragment = ""
+	v.Scheme = strings.ToLower(v.Scheme)
+	urlutil.NormalizeIRI(v)
+	if v.Path == "" {
+		v.Path = "/"
+	}
+	return v.String()
+}
@@ -0,0 +1,134 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package htmlproc_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/layer0-platform/webpackager/exchange"
+	"github.com/layer0-platform/webpackager/exchange/exchangetest"
+	"github.com/layer0-platform/webpackager/processor/htmlproc"
+)
+
+func TestCheckCanonical(t *testing.T) {
+	tests := []struct {
+		name        string
+		url         string
+		signedURL   string
+		contentType string
+		header      string
+		body        string
+		wantErr     bool
+	}{
+		{
+			name:        "NoCanonical",
+			url:         "https://example.com/hello.html",
+			contentType: "text/html",
+			body:        `<!doctype html><link rel="stylesheet" href="style.css">`,
+			wantErr:     false,
+		},
+		{
+			name:        "MetaMatch",
+			url:         "https://example.com/hello.html",
+			contentType: "text/html",
+			body:        `<!doctype html><link rel="canonical" href="https://example.com/hello.html">`,
+			wantErr:     false,
+		},
+		{
+			name:        "MetaRelative",
+			url:         "https://example.com/hello.html",
+			contentType: "text/html",
+			body:        `<!doctype html><link rel="canonical" href="/hello.html#top">`,
+			wantErr:     false,
+		},
+		{
+			name:        "MetaMismatch",
+			url:         "https://example.com/hello.html?utm_source=feed",
+			contentType: "text/html",
+			body:        `<!doctype html><link rel="canonical" href="https://example.com/hello.html">`,
+			wantErr:     true,
+		},
+		{
+			name:        "MetaNormalized",
+			url:         "https://example.com/%E3%81%82.html",
+			contentType: "text/html; charset=utf-8",
+			body:        `<!doctype html><link rel="Canonical" href="https://EXAMPLE.com/あ.html">`,
+			wantErr:     false,
+		},
+		{
+			name:        "HeaderMatch",
+			url:         "https://example.com/photo.jpg",
+			contentType: "image/jpeg",
+			header:      "Link: <https://example.com/photo.jpg>; rel=\"canonical\"\r\n",
+			wantErr:     false,
+		},
+		{
+			name:        "HeaderMismatch",
+			url:         "https://example.com/photo.jpg",
+			contentType: "image/jpeg",
+			header:      "Link: <https://example.com/photo.jpg>; rel=\"preload\"; as=\"image\", </images/photo.jpg>; rel=\"canonical\"\r\n",
+			wantErr:     true,
+		},
+		{
+			name:        "SignedURLMatch",
+			url:         "https://backend.example.com/hello.html",
+			signedURL:   "https://example.com/hello.html",
+			contentType: "text/html",
+			body:        `<!doctype html><link rel="canonical" href="https://example.com/hello.html">`,
+			wantErr:     false,
+		},
+		{
+			name:        "SignedURLMismatch",
+			url:         "https://example.com/hello.html",
+			signedURL:   "https://example.com/hello.html?amp=1",
+			contentType: "text/html",
+			body:        `<!doctype html><link rel="canonical" href="https://example.com/hello.html">`,
+			wantErr:     true,
+		},
+		{
+			name:        "MetaInNonHTML",
+			url:         "https://example.com/hello.txt",
+			contentType: "text/plain",
+			body:        `<link rel="canonical" href="https://example.com/">`,
+			wantErr:     false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, mode := range []htmlproc.ValidationMode{htmlproc.ValidationOff, htmlproc.ValidationReport, htmlproc.ValidationFail} {
+				proc := htmlproc.CheckCanonical(mode)
+				resp := exchangetest.MakeResponse(test.url, fmt.Sprint(
+					"HTTP/1.1 200 OK\r\n",
+					"Content-Type: ", test.contentType, "\r\n",
+					test.header,
+					"\r\n",
+					test.body))
+				if test.signedURL != "" {
+					resp.ExtraData.Set(exchange.SignedURL, test.signedURL)
+				}
+				err := proc.Process(resp)
+				if mode == htmlproc.ValidationFail && test.wantErr {
+					if err == nil {
+						t.Errorf("mode %v: got success, want error", mode)
+					}
+				} else if err != nil {
+					t.Errorf("mode %v: got error(%q), want success", mode, err)
+				}
+			}
+		})
+	}
+}
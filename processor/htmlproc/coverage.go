@@ -0,0 +1,59 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package htmlproc
+
+import (
+	"github.com/layer0-platform/webpackager/exchange"
+	"github.com/layer0-platform/webpackager/processor"
+	"github.com/layer0-platform/webpackager/processor/htmlproc/htmldoc"
+	"github.com/layer0-platform/webpackager/processor/htmlproc/htmltask"
+)
+
+// CoverageReport is the preload coverage report for an HTML document.
+type CoverageReport struct {
+	// URL is the request URL of the document.
+	URL string `json:"url"`
+
+	// Subresources lists the subresources of the document. See
+	// htmltask.PreloadCoverage for details.
+	Subresources []htmltask.SubresourceCoverage `json:"subresources"`
+}
+
+// ReportPreloadCoverage creates a Processor to pass the preload coverage
+// report of each HTML document to report, which may be called concurrently.
+// The Processor should be placed after all other processors, to see the
+// final preload links. It never modifies responses.
+func ReportPreloadCoverage(report func(*CoverageReport)) processor.Processor {
+	return &preloadCoverage{report}
+}
+
+type preloadCoverage struct {
+	report func(*CoverageReport)
+}
+
+func (pc *preloadCoverage) Process(resp *exchange.Response) error {
+	if !isHTML(resp) {
+		return nil
+	}
+	htmlResp, err := htmldoc.NewHTMLResponse(resp)
+	if err != nil {
+		return err
+	}
+	pc.report(&CoverageReport{
+		URL:          resp.Request.URL.String(),
+		Subresources: htmltask.PreloadCoverage(htmlResp),
+	})
+	return nil
+}
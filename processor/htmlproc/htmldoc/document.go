@@ -62,7 +62,7 @@ func NewDocument(payload []byte, url *url.URL) (*Document, error) {
 		return nil, errors.New("missing <body>")
 	}
 
-	doc := &Document{root, head, body, url, url.ResolveReference(getBaseURL(head))}
+	doc := &Document{root, head, body, url, url.ResolveReference(getBaseURL(root))}
 	return doc, nil
 }
 
@@ -22,12 +22,27 @@ import (
 	"golang.org/x/net/html/atom"
 )
 
-func getBaseURL(head *html.Node) *url.URL {
-	base := FindNode(head, atom.Base)
-	if base == nil {
-		return &url.URL{}
-	}
-	href := FindAttr(base, "href")
+// getBaseURL returns the href of the first <base> element with an href
+// attribute in the document, in tree order, as the HTML Standard defines.
+// The element does not have to be in <head>, but those in <template> do not
+// count since they are not part of the document.
+func getBaseURL(root *html.Node) *url.URL {
+	var href *html.Attribute
+	Traverse(root, func(n *html.Node) error {
+		if n.Type != html.ElementNode {
+			return nil
+		}
+		if n.DataAtom == atom.Template {
+			return ErrSkip
+		}
+		if n.DataAtom != atom.Base {
+			return nil
+		}
+		if href = FindAttr(n, "href"); href != nil {
+			return ErrStop
+		}
+		return nil
+	})
 	if href == nil {
 		return &url.URL{}
 	}
@@ -21,7 +21,6 @@ import (
 
 	"github.com/layer0-platform/webpackager/internal/urlutil"
 	"golang.org/x/net/html"
-	"golang.org/x/net/html/atom"
 )
 
 func TestGetBaseURL(t *testing.T) {
@@ -61,8 +60,18 @@ func TestGetBaseURL(t *testing.T) {
 			want: &url.URL{},
 		},
 		{
-			name: "Misplaced",
+			name: "InBody",
 			html: `<!doctype html><body><base href="foo/"></body>`,
+			want: urlutil.MustParse("foo/"),
+		},
+		{
+			name: "FirstWithHref",
+			html: `<!doctype html><base target="_blank"><base href="foo/"><base href="bar/">`,
+			want: urlutil.MustParse("foo/"),
+		},
+		{
+			name: "InTemplate",
+			html: `<!doctype html><template><base href="foo/"></template>`,
 			want: &url.URL{},
 		},
 		{
@@ -90,11 +99,7 @@ func TestGetBaseURL(t *testing.T) {
 			if err != nil {
 				t.Fatal(err)
 			}
-			head := FindNode(doc, atom.Head)
-			if head == nil {
-				t.Fatal("head not found")
-			}
-			if got := getBaseURL(head); *got != *test.want {
+			if got := getBaseURL(doc); *got != *test.want {
 				t.Errorf("got %q, want %q", got, test.want)
 			}
 		})
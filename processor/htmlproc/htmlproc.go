@@ -40,12 +40,25 @@ type Config struct {
 	//
 	// Some HTMLTasks have an effect only when ModifyHTML is true.
 	ModifyHTML bool
+
+	// Validation specifies whether to check documents for serious errors
+	// before running TaskSet: unclosed critical elements (e.g. <script> and
+	// <div>), elements not allowed in <head>, and duplicate IDs. The parser
+	// recovers from such errors, often in a way different from what authors
+	// intend.
+	//
+	// Zero (ValidationOff) implies no check.
+	Validation ValidationMode
 }
 
 // NewHTMLProcessor creates and initializes a new Processor to process HTML
 // documents. The Processor turns provided exchange.Response into
 // htmldoc.HTMLResponse then runs the specified htmltask.HTMLTasks one by one.
 // The Processor fails immediately when some HTMLTask encounters an error.
+//
+// The Processor always runs htmltask.ExtractSubContentTypes before TaskSet,
+// so the documents with inline JavaScript are recognized (e.g. by
+// vprule.PerJSContentType) whatever TaskSet is.
 func NewHTMLProcessor(config Config) processor.Processor {
 	if len(config.TaskSet) == 0 {
 		config.TaskSet = htmltask.ConservativeTaskSet
@@ -53,6 +66,8 @@ func NewHTMLProcessor(config Config) processor.Processor {
 	return &htmlProcessor{config}
 }
 
+var extractSubContentTypes = htmltask.ExtractSubContentTypes()
+
 type htmlProcessor struct {
 	Config
 }
@@ -62,7 +77,13 @@ func (hp *htmlProcessor) Process(resp *exchange.Response) error {
 	if err != nil {
 		return err
 	}
+	if err := hp.validate(htmlResp); err != nil {
+		return err
+	}
 
+	if err := extractSubContentTypes.Run(htmlResp); err != nil {
+		return err
+	}
 	for _, task := range hp.TaskSet {
 		if err := task.Run(htmlResp); err != nil {
 			return err
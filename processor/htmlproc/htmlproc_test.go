@@ -161,3 +161,124 @@ func TestHTMLProcessor_Error(t *testing.T) {
 		t.Errorf("called = %q, want %q", called, "Task1;Task2;")
 	}
 }
+
+func TestHTMLProcessor_InlineJS(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want bool
+	}{
+		{
+			name: "InlineScript",
+			html: `<!doctype html><script>document.write("Hello");</script>`,
+			want: true,
+		},
+		{
+			name: "EmptyScript",
+			html: `<!doctype html><script> </script><p>Hello, world.</p>`,
+			want: false,
+		},
+		{
+			name: "JSONLD",
+			html: `<!doctype html><script type="application/ld+json">{}</script>`,
+			want: false,
+		},
+		{
+			name: "ExternalScript",
+			html: `<!doctype html><script src="script.js"></script>`,
+			want: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// The TaskSet lacks htmltask.ExtractSubContentTypes.
+			proc := htmlproc.NewHTMLProcessor(htmlproc.Config{
+				TaskSet: []htmltask.HTMLTask{htmltask.PreloadStylesheets()},
+			})
+			resp := makeResponse("https://example.com/test.html", test.html)
+			if err := proc.Process(resp); err != nil {
+				t.Fatalf("got error(%q), want success", err)
+			}
+			got := false
+			for _, v := range resp.ExtraData[exchange.SubContentType] {
+				if v == "application/javascript" {
+					got = true
+				}
+			}
+			if got != test.want {
+				t.Errorf("inline JS detected = %v, want %v (SubContentType: %q)",
+					got, test.want, resp.ExtraData[exchange.SubContentType])
+			}
+		})
+	}
+}
+
+func TestHTMLProcessor_Validation(t *testing.T) {
+	tests := []struct {
+		name    string
+		html    string
+		wantErr bool
+	}{
+		{
+			name: "WellFormed",
+			html: fmt.Sprint(
+				`<!doctype html>`,
+				`<head><title>Hello</title><link rel="stylesheet" href="style.css"></head>`,
+				`<body><div id="main"><p>Hello, world.</div></body>`,
+			),
+			wantErr: false,
+		},
+		{
+			name: "UnclosedScript",
+			html: fmt.Sprint(
+				`<!doctype html>`,
+				`<script>console.log('Hello');`,
+				`<p>Hello, world.</p>`,
+			),
+			wantErr: true,
+		},
+		{
+			name: "UnclosedDiv",
+			html: fmt.Sprint(
+				`<!doctype html>`,
+				`<div><div><p>Hello, world.</div>`,
+			),
+			wantErr: true,
+		},
+		{
+			name: "InvalidHead",
+			html: fmt.Sprint(
+				`<!doctype html>`,
+				`<head><title>Hello</title><img src="logo.png"></head>`,
+				`<body><p>Hello, world.</p></body>`,
+			),
+			wantErr: true,
+		},
+		{
+			name: "DuplicateID",
+			html: fmt.Sprint(
+				`<!doctype html>`,
+				`<p id="hello">Hello,</p><p id="hello">world.</p>`,
+			),
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, mode := range []htmlproc.ValidationMode{htmlproc.ValidationReport, htmlproc.ValidationFail} {
+				proc := htmlproc.NewHTMLProcessor(htmlproc.Config{Validation: mode})
+				resp := makeResponse("https://example.com/test.html", test.html)
+				err := proc.Process(resp)
+				if mode == htmlproc.ValidationFail && test.wantErr {
+					if err == nil {
+						t.Errorf("mode %v: got success, want error", mode)
+					}
+				} else if err != nil {
+					t.Errorf("mode %v: got error(%q), want success", mode, err)
+				}
+			}
+		})
+	}
+}
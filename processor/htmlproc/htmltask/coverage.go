@@ -0,0 +1,188 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package htmltask
+
+import (
+	"strings"
+
+	"github.com/layer0-platform/webpackager/exchange"
+	"github.com/layer0-platform/webpackager/internal/urlutil"
+	"github.com/layer0-platform/webpackager/processor/htmlproc/htmldoc"
+	"github.com/layer0-platform/webpackager/resource/preload"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// These are the reasons reported in SubresourceCoverage for subresources
+// not preloaded.
+const (
+	// The preload was dropped by commonproc.CheckPreloadTargets.
+	ReasonUnreachable = "unreachable"
+	// The preload link exceeded the maximum number of preload links in
+	// the Link header. See commonproc.ExtractPreloadHeaders.
+	ReasonOverCap = "over-cap"
+	// The subresource is not https while the document is. See
+	// exchange.Response.AddPreload.
+	ReasonMixedContent = "mixed-content"
+	// The subresource has a different origin from the document. See
+	// commonproc.ApplySameOriginPolicy.
+	ReasonCrossOrigin = "cross-origin"
+	// The stylesheet is for media other than screens, thus does not block
+	// the rendering. See PreloadStylesheets.
+	ReasonMedia = "media"
+	// The script is async or deferred, thus does not block the rendering.
+	// See InsecurePreloadScripts.
+	ReasonAsync = "async"
+	// No other reasons apply: the HTMLTasks just did not preload it.
+	ReasonNotConfigured = "not-configured"
+)
+
+// SubresourceCoverage tells whether a subresource is preloaded.
+type SubresourceCoverage struct {
+	// URL is the absolute URL of the subresource.
+	URL string `json:"url"`
+
+	// As is the destination of the subresource, e.g. "style", or empty
+	// if unknown.
+	As string `json:"as,omitempty"`
+
+	// Preloaded reports whether resp.Preloads covers the subresource.
+	Preloaded bool `json:"preloaded"`
+
+	// Reason is one of the Reason constants telling the likely reason why
+	// the subresource is not preloaded, or empty if Preloaded is true.
+	Reason string `json:"reason,omitempty"`
+}
+
+// PreloadCoverage lists the subresources discovered in the document, namely
+// stylesheets, scripts, images, and <link rel="preload"> targets, along with
+// the preload links dropped for exceeding the maximum number, and reports
+// whether each is preloaded in resp.Preloads. The subresources are listed in
+// the document order, without duplicates.
+//
+// PreloadCoverage is meant to run after all processors, to help tune the
+// preload configuration. It does not modify resp.
+func PreloadCoverage(resp *htmldoc.HTMLResponse) []SubresourceCoverage {
+	c := &coverageBuilder{
+		resp:      resp,
+		preloaded: make(map[string]bool),
+		seen:      make(map[string]bool),
+	}
+	for _, p := range resp.Preloads {
+		for _, r := range p.Resources {
+			c.preloaded[r.RequestURL.String()] = true
+		}
+	}
+
+	htmldoc.Traverse(resp.Doc.Root, func(n *html.Node) error {
+		if n.Type != html.ElementNode {
+			return nil
+		}
+		switch n.DataAtom {
+		case atom.Link:
+			c.handleLink(n)
+		case atom.Script:
+			reason := ""
+			if htmldoc.FindAttr(n, "async") != nil || htmldoc.FindAttr(n, "defer") != nil {
+				reason = ReasonAsync
+			}
+			c.add(htmldoc.FindAttr(n, "src"), preload.AsScript, reason)
+		case atom.Img:
+			c.add(htmldoc.FindAttr(n, "src"), preload.AsImage, "")
+		}
+		return nil
+	})
+
+	for _, u := range resp.ExtraData[exchange.OverCapPreload] {
+		if !c.seen[u] {
+			c.seen[u] = true
+			sc := SubresourceCoverage{URL: u, Preloaded: c.preloaded[u]}
+			if !sc.Preloaded {
+				sc.Reason = ReasonOverCap
+			}
+			c.list = append(c.list, sc)
+		}
+	}
+	return c.list
+}
+
+type coverageBuilder struct {
+	resp      *htmldoc.HTMLResponse
+	preloaded map[string]bool
+	seen      map[string]bool
+	list      []SubresourceCoverage
+}
+
+func (c *coverageBuilder) handleLink(n *html.Node) {
+	if isStylesheet(n) {
+		reason := ""
+		if !isRenderBlocking(n) {
+			reason = ReasonMedia
+		}
+		c.add(htmldoc.FindAttr(n, "href"), preload.AsStyle, reason)
+		return
+	}
+	for _, linkType := range strings.Fields(htmldoc.GetAttr(n, "rel")) {
+		if strings.EqualFold(linkType, "preload") {
+			as := strings.ToLower(strings.TrimSpace(htmldoc.GetAttr(n, "as")))
+			c.add(htmldoc.FindAttr(n, "href"), as, "")
+			return
+		}
+	}
+}
+
+// add appends the subresource referenced by a to the list. hint is the
+// reason to report, unless a more specific one applies, if the subresource
+// is not preloaded.
+func (c *coverageBuilder) add(a *html.Attribute, as, hint string) {
+	u := resolveURLAttr(a, c.resp.Doc)
+	if u == nil {
+		return
+	}
+	s := u.String()
+	if c.seen[s] {
+		return
+	}
+	c.seen[s] = true
+
+	sc := SubresourceCoverage{URL: s, As: as, Preloaded: c.preloaded[s]}
+	if !sc.Preloaded {
+		docURL := c.resp.Request.URL
+		switch {
+		case contains(c.resp.ExtraData[exchange.DroppedPreload], s):
+			sc.Reason = ReasonUnreachable
+		case contains(c.resp.ExtraData[exchange.OverCapPreload], s):
+			sc.Reason = ReasonOverCap
+		case docURL.Scheme == "https" && u.Scheme != "https":
+			sc.Reason = ReasonMixedContent
+		case !urlutil.HasSameOrigin(u, docURL):
+			sc.Reason = ReasonCrossOrigin
+		case hint != "":
+			sc.Reason = hint
+		default:
+			sc.Reason = ReasonNotConfigured
+		}
+	}
+	c.list = append(c.list, sc)
+}
+
+func contains(list []string, s string) bool {
+	for _, t := range list {
+		if t == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package htmltask_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/layer0-platform/webpackager/exchange"
+	"github.com/layer0-platform/webpackager/processor/commonproc"
+	"github.com/layer0-platform/webpackager/processor/htmlproc/htmltask"
+)
+
+func TestPreloadCoverage(t *testing.T) {
+	type sc = htmltask.SubresourceCoverage
+
+	tests := []struct {
+		name      string
+		url       string
+		html      string
+		extraData http.Header
+		want      []sc
+	}{
+		{
+			name: "Stylesheets",
+			url:  "https://example.com/hello/",
+			html: `<!doctype html>
+			       <link rel="stylesheet" href="style.css">
+			       <link rel="stylesheet" href="print.css" media="print">
+			       <link rel="stylesheet" href="https://cdn.example.com/font.css">
+			       <link rel="stylesheet" href="http://example.com/insecure.css">
+			       <link rel="stylesheet" href="style.css">`,
+			want: []sc{
+				{URL: "https://example.com/hello/style.css", As: "style", Preloaded: true},
+				{URL: "https://example.com/hello/print.css", As: "style", Reason: htmltask.ReasonMedia},
+				{URL: "https://cdn.example.com/font.css", As: "style", Reason: htmltask.ReasonCrossOrigin},
+				{URL: "http://example.com/insecure.css", As: "style", Reason: htmltask.ReasonMixedContent},
+			},
+		},
+		{
+			name: "ScriptsAndImages",
+			url:  "https://example.com/hello/",
+			html: `<!doctype html>
+			       <script src="async.js" async></script>
+			       <script src="defer.js" defer></script>
+			       <script src="main.js"></script>
+			       <script>inline();</script>
+			       <img src="photo.jpg">`,
+			want: []sc{
+				{URL: "https://example.com/hello/async.js", As: "script", Reason: htmltask.ReasonAsync},
+				{URL: "https://example.com/hello/defer.js", As: "script", Reason: htmltask.ReasonAsync},
+				{URL: "https://example.com/hello/main.js", As: "script", Reason: htmltask.ReasonNotConfigured},
+				{URL: "https://example.com/hello/photo.jpg", As: "image", Reason: htmltask.ReasonNotConfigured},
+			},
+		},
+		{
+			name: "PreloadTag",
+			url:  "https://example.com/hello/",
+			html: `<!doctype html>
+			       <link rel="preload" href="/fonts/icons.woff2" as="font">`,
+			want: []sc{
+				{URL: "https://example.com/fonts/icons.woff2", As: "font", Reason: htmltask.ReasonNotConfigured},
+			},
+		},
+		{
+			name: "ExtraData",
+			url:  "https://example.com/hello/",
+			html: `<!doctype html>
+			       <link rel="stylesheet" href="missing.css">
+			       <img src="photo.jpg">`,
+			extraData: http.Header{
+				exchange.DroppedPreload: {"https://example.com/hello/missing.css"},
+				exchange.OverCapPreload: {
+					"https://example.com/hello/photo.jpg",
+					"https://example.com/hello/photo2.jpg",
+				},
+			},
+			want: []sc{
+				{URL: "https://example.com/hello/missing.css", As: "style", Reason: htmltask.ReasonUnreachable},
+				{URL: "https://example.com/hello/photo.jpg", As: "image", Reason: htmltask.ReasonOverCap},
+				{URL: "https://example.com/hello/photo2.jpg", Reason: htmltask.ReasonOverCap},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := makeHTMLResponse(test.url, test.html)
+			for k, v := range test.extraData {
+				resp.ExtraData[k] = v
+			}
+			if err := htmltask.PreloadStylesheets().Run(resp); err != nil {
+				t.Fatalf("got error(%q), want success", err)
+			}
+			// Simulate the later processors dropping preloads.
+			if err := commonproc.ApplySameOriginPolicy.Process(resp.Response); err != nil {
+				t.Fatalf("got error(%q), want success", err)
+			}
+			dropped := make(map[string]bool)
+			for _, u := range test.extraData[exchange.DroppedPreload] {
+				dropped[u] = true
+			}
+			kept := resp.Preloads[:0]
+			for _, p := range resp.Preloads {
+				if !dropped[p.URL.String()] {
+					kept = append(kept, p)
+				}
+			}
+			resp.Preloads = kept
+
+			got := htmltask.PreloadCoverage(resp)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("PreloadCoverage() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
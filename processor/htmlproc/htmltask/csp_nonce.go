@@ -0,0 +1,201 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package htmltask
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/layer0-platform/webpackager/exchange"
+	"github.com/layer0-platform/webpackager/processor/htmlproc/htmldoc"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// cspHeaders are the header names InsecureFixedCSPNonce updates.
+var cspHeaders = []string{
+	"Content-Security-Policy",
+	"Content-Security-Policy-Report-Only",
+}
+
+// defaultCSP is the policy InsecureFixedCSPNonce adds when the response has
+// no Content-Security-Policy: only the inline scripts and styles with the
+// nonce are applied, while external ones are allowed from any https: origin
+// as before. It restricts nothing else.
+const defaultCSP = `script-src %[1]s https:; style-src %[1]s https:`
+
+var validNonce = regexp.MustCompile(`^[A-Za-z0-9+/_-]+=*$`)
+
+// InsecureFixedCSPNonce sets the nonce attribute of every inline <script>
+// and <style> element to nonce, and adds the nonce to the script and style
+// sources of Content-Security-Policy, replacing the existing nonces. External
+// scripts (<script src>) do not get the nonce, which would override the host
+// allowlist of the policy; only the nonces they already have are replaced.
+// Directives which allow all inline scripts or styles ('unsafe-inline')
+// without any nonces or hashes are left as is, since adding a nonce would
+// block inline event handlers.
+//
+// When the response has no Content-Security-Policy, InsecureFixedCSPNonce
+// adds one with script-src and style-src allowing the elements with the nonce
+// and anything from https: origins. Note this policy stops inline event
+// handlers (e.g. onclick), javascript: URLs and style attributes from working;
+// do not use InsecureFixedCSPNonce for pages relying on them without a policy
+// of their own.
+//
+// InsecureFixedCSPNonce makes the signed pages consistent with their CSP
+// and deterministic. USE WITH CAUTION: a nonce is meant to be unpredictable
+// and unique to each response; a fixed nonce is visible to anyone obtaining
+// the signed exchange and valid until it expires, so it no longer protects
+// against injected markup reusing the nonce. It is still better than
+// 'unsafe-inline', but the CSP relies more on the content being free of
+// injection points.
+//
+// InsecureFixedCSPNonce rewrites the payload on its own to keep the document
+// in sync with the header, regardless of htmlproc.Config.ModifyHTML. It fails
+// if nonce is not a valid base64 value.
+func InsecureFixedCSPNonce(nonce string) HTMLTask {
+	return &fixedCSPNonce{nonce}
+}
+
+type fixedCSPNonce struct {
+	nonce string
+}
+
+func (task *fixedCSPNonce) Run(resp *htmldoc.HTMLResponse) error {
+	if !validNonce.MatchString(task.nonce) {
+		return fmt.Errorf("invalid CSP nonce %q", task.nonce)
+	}
+
+	htmldoc.Traverse(resp.Doc.Root, func(n *html.Node) error {
+		if n.Type != html.ElementNode || (n.DataAtom != atom.Script && n.DataAtom != atom.Style) {
+			return nil
+		}
+		if htmldoc.FindAttr(n, "src") != nil {
+			if htmldoc.FindAttr(n, "nonce") != nil {
+				setAttr(n, "nonce", task.nonce)
+			}
+			return nil
+		}
+		setAttr(n, "nonce", task.nonce)
+		return nil
+	})
+
+	var payload bytes.Buffer
+	if err := html.Render(&payload, resp.Doc.Root); err != nil {
+		return err
+	}
+	resp.Payload = payload.Bytes()
+	resp.ExtraData.Add(exchange.AppliedTransformation, "set fixed CSP nonce on inline scripts and styles")
+
+	source := "'nonce-" + task.nonce + "'"
+	found := false
+	for _, name := range cspHeaders {
+		values := resp.Header.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		found = true
+		resp.Header.Del(name)
+		for _, v := range values {
+			resp.Header.Add(name, addNonceToPolicy(v, source))
+		}
+	}
+	if !found {
+		resp.Header.Set(cspHeaders[0], fmt.Sprintf(defaultCSP, source))
+	}
+	return nil
+}
+
+// addNonceToPolicy adds source to the directives of the serialized CSP
+// policy which restrict scripts and styles.
+func addNonceToPolicy(policy, source string) string {
+	var directives [][]string
+	index := make(map[string]int)
+	for _, d := range strings.Split(policy, ";") {
+		tokens := strings.Fields(d)
+		if len(tokens) == 0 {
+			continue
+		}
+		name := strings.ToLower(tokens[0])
+		if _, ok := index[name]; !ok {
+			index[name] = len(directives)
+		}
+		directives = append(directives, tokens)
+	}
+
+	update := make(map[int]bool)
+	for _, kind := range []string{"script", "style"} {
+		// Browsers fall back to default-src when kind-src is missing.
+		if i, ok := index[kind+"-src"]; ok {
+			update[i] = true
+		} else if i, ok := index["default-src"]; ok {
+			update[i] = true
+		}
+		if i, ok := index[kind+"-src-elem"]; ok {
+			update[i] = true
+		}
+	}
+
+	parts := make([]string, len(directives))
+	for i, tokens := range directives {
+		if update[i] {
+			tokens = addNonceToSources(tokens, source)
+		}
+		parts[i] = strings.Join(tokens, " ")
+	}
+	return strings.Join(parts, "; ")
+}
+
+// addNonceToSources replaces the nonce sources in the directive tokens with
+// source. It leaves tokens unmodified if they allow any inline content.
+func addNonceToSources(tokens []string, source string) []string {
+	unsafeInline, secured := false, false
+	for _, s := range tokens[1:] {
+		s = strings.ToLower(s)
+		switch {
+		case s == "'unsafe-inline'":
+			unsafeInline = true
+		case strings.HasPrefix(s, "'nonce-"), strings.HasPrefix(s, "'sha"):
+			secured = true
+		}
+	}
+	if unsafeInline && !secured {
+		return tokens
+	}
+
+	// 'none' must be the only source, thus is replaced with the nonce.
+	result := []string{tokens[0]}
+	for _, s := range tokens[1:] {
+		ls := strings.ToLower(s)
+		if !strings.HasPrefix(ls, "'nonce-") && ls != "'none'" {
+			result = append(result, s)
+		}
+	}
+	return append(result, source)
+}
+
+// setAttr sets the attribute named key of n to val, adding the attribute
+// if n does not have it.
+func setAttr(n *html.Node, key, val string) {
+	for i, a := range n.Attr {
+		if a.Namespace == "" && strings.EqualFold(a.Key, key) {
+			n.Attr[i].Val = val
+			return
+		}
+	}
+	n.Attr = append(n.Attr, html.Attribute{Key: key, Val: val})
+}
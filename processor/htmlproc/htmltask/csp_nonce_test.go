@@ -0,0 +1,125 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package htmltask_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/layer0-platform/webpackager/processor/htmlproc/htmltask"
+)
+
+func TestInsecureFixedCSPNonce(t *testing.T) {
+	const page = `<!doctype html>` +
+		`<script>console.log('Hello');</script>` +
+		`<script src="script.js" nonce="r4nd0m"></script>` +
+		`<script src="https://third-party.example/script.js"></script>` +
+		`<style>body { color: red; }</style>` +
+		`<p>Hello, world!</p>`
+
+	tests := []struct {
+		name    string
+		csp     []string
+		wantCSP []string
+	}{
+		{
+			name:    "NoPolicy",
+			csp:     nil,
+			wantCSP: []string{`script-src 'nonce-c3RhdGlj' https:; style-src 'nonce-c3RhdGlj' https:`},
+		},
+		{
+			name:    "ReplaceNonce",
+			csp:     []string{`script-src 'self' 'nonce-r4nd0m'; style-src 'self'`},
+			wantCSP: []string{`script-src 'self' 'nonce-c3RhdGlj'; style-src 'self' 'nonce-c3RhdGlj'`},
+		},
+		{
+			name:    "DefaultSrc",
+			csp:     []string{`default-src 'none'; img-src 'self'`},
+			wantCSP: []string{`default-src 'nonce-c3RhdGlj'; img-src 'self'`},
+		},
+		{
+			name:    "UnsafeInline",
+			csp:     []string{`script-src 'self' 'unsafe-inline'; style-src 'self'`},
+			wantCSP: []string{`script-src 'self' 'unsafe-inline'; style-src 'self' 'nonce-c3RhdGlj'`},
+		},
+		{
+			name: "MultiplePolicies",
+			csp: []string{
+				`script-src 'self'`,
+				`script-src https://example.com; report-uri /csp`,
+			},
+			wantCSP: []string{
+				`script-src 'self' 'nonce-c3RhdGlj'`,
+				`script-src https://example.com 'nonce-c3RhdGlj'; report-uri /csp`,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := makeHTMLResponse("https://example.com/hello.html", page)
+			for _, v := range test.csp {
+				resp.Header.Add("Content-Security-Policy", v)
+			}
+			if err := htmltask.InsecureFixedCSPNonce("c3RhdGlj").Run(resp); err != nil {
+				t.Fatalf("got error(%q), want success", err)
+			}
+			if diff := cmp.Diff(test.wantCSP, resp.Header["Content-Security-Policy"]); diff != "" {
+				t.Errorf("Content-Security-Policy mismatch (-want +got):\n%s", diff)
+			}
+			payload := string(resp.Payload)
+			if got := strings.Count(payload, `nonce="c3RhdGlj"`); got != 3 {
+				t.Errorf(`count of nonce="c3RhdGlj" = %d, want 3`, got)
+			}
+			if strings.Contains(payload, "r4nd0m") {
+				t.Errorf("payload still contains the original nonce: %s", payload)
+			}
+			if !strings.Contains(payload, `<script src="https://third-party.example/script.js"></script>`) {
+				t.Errorf("payload has the nonce set on the external script: %s", payload)
+			}
+		})
+	}
+}
+
+func TestInsecureFixedCSPNonce_ExternalScript(t *testing.T) {
+	// A nonce on the cross-origin script would override script-src 'self'.
+	const page = `<!doctype html>` +
+		`<script src="https://evil.example/inject.js"></script>` +
+		`<script>console.log('Hello');</script>`
+	const want = `<!DOCTYPE html><html><head>` +
+		`<script src="https://evil.example/inject.js"></script>` +
+		`<script nonce="c3RhdGlj">console.log('Hello');</script>` +
+		`</head><body></body></html>`
+
+	resp := makeHTMLResponse("https://example.com/hello.html", page)
+	resp.Header.Set("Content-Security-Policy", `script-src 'self'`)
+	if err := htmltask.InsecureFixedCSPNonce("c3RhdGlj").Run(resp); err != nil {
+		t.Fatalf("got error(%q), want success", err)
+	}
+	if got := string(resp.Payload); got != want {
+		t.Errorf("payload = %q, want %q", got, want)
+	}
+	if got, want := resp.Header.Get("Content-Security-Policy"), `script-src 'self' 'nonce-c3RhdGlj'`; got != want {
+		t.Errorf("Content-Security-Policy = %q, want %q", got, want)
+	}
+}
+
+func TestInsecureFixedCSPNonce_Invalid(t *testing.T) {
+	resp := makeHTMLResponse("https://example.com/hello.html", `<!doctype html>`)
+	if err := htmltask.InsecureFixedCSPNonce(`"><script>`).Run(resp); err == nil {
+		t.Error("got success, want error")
+	}
+}
@@ -33,15 +33,16 @@ type HTMLTask interface {
 
 // ConservativeTaskSet is the set of HTMLTasks used in the default config.
 // It consists only of HTMLTasks that almost always work well.
+//
+// The task sets do not include ExtractSubContentTypes, which htmlproc runs
+// before any TaskSet.
 var ConservativeTaskSet = []HTMLTask{
-	ExtractSubContentTypes(),
 	ExtractPreloadTags(),
 }
 
 // AggressiveTaskSet gets as many resources preloaded as Web Packager can.
 // It includes HTMLTasks that might make negative effect in some cases.
 var AggressiveTaskSet = []HTMLTask{
-	ExtractSubContentTypes(),
 	ExtractPreloadTags(),
 	PreloadStylesheets(),
 	InsecurePreloadScripts(),
@@ -0,0 +1,192 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package htmltask
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/layer0-platform/webpackager/exchange"
+	"github.com/layer0-platform/webpackager/fetch"
+	"github.com/layer0-platform/webpackager/processor"
+	"github.com/layer0-platform/webpackager/processor/htmlproc/htmldoc"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// IntegrityConfig holds the parameters to UpdateSubresourceIntegrity.
+type IntegrityConfig struct {
+	// FetchClient is used to retrieve the subresources. nil implies
+	// fetch.DefaultFetchClient.
+	FetchClient fetch.FetchClient
+
+	// Processor specifies the transformations the pipeline applies to
+	// the subresources, e.g. commonproc.ExternalCommand. It is typically
+	// not the processor running UpdateSubresourceIntegrity itself. nil
+	// implies the subresources are signed unchanged.
+	Processor processor.Processor
+}
+
+// Hash algorithms in SRI, from the weakest to the strongest.
+var sriAlgorithms = []struct {
+	name string
+	new  func() hash.Hash
+}{
+	{"sha256", sha256.New},
+	{"sha384", sha512.New384},
+	{"sha512", sha512.New},
+}
+
+// UpdateSubresourceIntegrity keeps the integrity attributes of <script> and
+// <link> elements consistent with the subresources to be signed. It fetches
+// each subresource with an integrity attribute and verifies its content
+// against the attribute, logging a warning on mismatch. If it matches and
+// config.Processor changes the content, the attribute is replaced with
+// the hash of the processed content, using the strongest algorithm in
+// the original attribute. Subresources failing the verification are left
+// as is, so SRI still blocks them.
+//
+// UpdateSubresourceIntegrity is expensive, as it costs an extra fetch for
+// each subresource with an integrity attribute. It has an effect only
+// when htmlproc.Config.ModifyHTML is true.
+func UpdateSubresourceIntegrity(config IntegrityConfig) HTMLTask {
+	if config.FetchClient == nil {
+		config.FetchClient = fetch.DefaultFetchClient
+	}
+	return &updateIntegrity{config}
+}
+
+type updateIntegrity struct {
+	config IntegrityConfig
+}
+
+func (task *updateIntegrity) Run(resp *htmldoc.HTMLResponse) error {
+	return htmldoc.Traverse(resp.Doc.Root, func(n *html.Node) error {
+		if n.Type != html.ElementNode {
+			return nil
+		}
+		var key string
+		switch n.DataAtom {
+		case atom.Script:
+			key = "src"
+		case atom.Link:
+			key = "href"
+		default:
+			return nil
+		}
+		integrity := htmldoc.FindAttr(n, "integrity")
+		if integrity == nil {
+			return nil
+		}
+		u := resolveURLAttr(htmldoc.FindAttr(n, key), resp.Doc)
+		if u == nil {
+			return nil
+		}
+		newVal, err := task.check(u, integrity.Val, resp.Request)
+		if err != nil {
+			log.Printf("warning: %v: integrity of %v: %v", resp.Request.URL, u, err)
+			return nil
+		}
+		if newVal != integrity.Val {
+			setAttr(n, "integrity", newVal)
+			resp.ExtraData.Add(exchange.AppliedTransformation,
+				fmt.Sprintf("updated integrity of %v to %s", u, newVal))
+		}
+		return nil
+	})
+}
+
+// check verifies the content of u against the integrity value and returns
+// the integrity value for the processed content.
+func (task *updateIntegrity) check(u *url.URL, integrity string, parent *http.Request) (string, error) {
+	strongest := -1
+	var digests []string
+	for _, token := range strings.Fields(integrity) {
+		// Strip the options ("?foo"), which have no defined meaning.
+		token = strings.SplitN(token, "?", 2)[0]
+		chunks := strings.SplitN(token, "-", 2)
+		if len(chunks) != 2 {
+			continue
+		}
+		for i, alg := range sriAlgorithms {
+			if !strings.EqualFold(chunks[0], alg.name) {
+				continue
+			}
+			if i > strongest {
+				strongest, digests = i, nil
+			}
+			if i == strongest {
+				digests = append(digests, chunks[1])
+			}
+		}
+	}
+	if strongest < 0 {
+		return "", fmt.Errorf("no supported hash algorithm in %q", integrity)
+	}
+	alg := sriAlgorithms[strongest]
+
+	sub, err := task.fetch(u, parent)
+	if err != nil {
+		return "", err
+	}
+	actual := computeDigest(alg.new(), sub.Payload)
+	matched := false
+	for _, d := range digests {
+		matched = matched || d == actual
+	}
+	if !matched {
+		return "", fmt.Errorf("content mismatches %q", integrity)
+	}
+
+	if task.config.Processor == nil {
+		return integrity, nil
+	}
+	if err := task.config.Processor.Process(sub); err != nil {
+		return "", err
+	}
+	if processed := computeDigest(alg.new(), sub.Payload); processed != actual {
+		return alg.name + "-" + processed, nil
+	}
+	return integrity, nil
+}
+
+func (task *updateIntegrity) fetch(u *url.URL, parent *http.Request) (*exchange.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Referer", parent.URL.String())
+	rawResp, err := task.config.FetchClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if rawResp.StatusCode != http.StatusOK {
+		rawResp.Body.Close()
+		return nil, fmt.Errorf("server responded with status code %d", rawResp.StatusCode)
+	}
+	return exchange.NewResponse(rawResp)
+}
+
+func computeDigest(h hash.Hash, payload []byte) string {
+	h.Write(payload)
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
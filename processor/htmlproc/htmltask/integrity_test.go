@@ -0,0 +1,103 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package htmltask_test
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/layer0-platform/webpackager/exchange"
+	"github.com/layer0-platform/webpackager/fetch/fetchtest"
+	"github.com/layer0-platform/webpackager/processor"
+	"github.com/layer0-platform/webpackager/processor/htmlproc/htmldoc"
+	"github.com/layer0-platform/webpackager/processor/htmlproc/htmltask"
+	"golang.org/x/net/html/atom"
+)
+
+const scriptJS = `console.log('Hello, world!');`
+
+func sri384(content string) string {
+	sum := sha512.Sum384([]byte(content))
+	return "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+type upperCaseProcessor struct{}
+
+func (upperCaseProcessor) Process(resp *exchange.Response) error {
+	resp.Payload = bytes.ToUpper(resp.Payload)
+	return nil
+}
+
+func TestUpdateSubresourceIntegrity(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/javascript")
+		w.Write([]byte(scriptJS))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		integrity string
+		processor processor.Processor
+		want      string
+	}{
+		{
+			name:      "Unchanged",
+			integrity: sri384(scriptJS),
+			processor: nil,
+			want:      sri384(scriptJS),
+		},
+		{
+			name:      "Transformed",
+			integrity: sri384(scriptJS),
+			processor: upperCaseProcessor{},
+			want:      sri384(`CONSOLE.LOG('HELLO, WORLD!');`),
+		},
+		{
+			name:      "Mismatch",
+			integrity: sri384("alert(1);"),
+			processor: upperCaseProcessor{},
+			want:      sri384("alert(1);"),
+		},
+		{
+			name:      "UnsupportedAlgorithm",
+			integrity: "md5-AAAAAAAAAAAAAAAAAAAAAA==",
+			processor: upperCaseProcessor{},
+			want:      "md5-AAAAAAAAAAAAAAAAAAAAAA==",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			page := `<!doctype html><script src="script.js" integrity="` + test.integrity + `"></script>`
+			resp := makeHTMLResponse("https://example.com/hello.html", page)
+			task := htmltask.UpdateSubresourceIntegrity(htmltask.IntegrityConfig{
+				FetchClient: fetchtest.NewFetchClient(server),
+				Processor:   test.processor,
+			})
+			if err := task.Run(resp); err != nil {
+				t.Fatalf("got error(%q), want success", err)
+			}
+			script := htmldoc.FindNode(resp.Doc.Root, atom.Script)
+			if got := htmldoc.GetAttr(script, "integrity"); got != test.want {
+				t.Errorf("integrity = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"log"
 	"net/url"
 
+	"github.com/layer0-platform/webpackager/internal/urlutil"
 	"github.com/layer0-platform/webpackager/processor/htmlproc/htmldoc"
 	"golang.org/x/net/html"
 )
@@ -31,5 +32,7 @@ func resolveURLAttr(a *html.Attribute, doc *htmldoc.Document) *url.URL {
 		log.Printf("warning: invalid %v value %q: %v", a.Key, a.Val, err)
 		return nil
 	}
-	return doc.ResolveReference(u)
+	resolved := doc.ResolveReference(u)
+	urlutil.NormalizeIRI(resolved)
+	return resolved
 }
@@ -0,0 +1,105 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package htmltask
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/layer0-platform/webpackager/exchange"
+	"github.com/layer0-platform/webpackager/processor/htmlproc/htmldoc"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// DefaultAboveFoldImages is the number of images ReportLazyAboveFold and
+// RemoveLazyAboveFold consider above the fold when their count is zero.
+const DefaultAboveFoldImages = 3
+
+// ReportLazyAboveFold detects images likely to be above the fold which are
+// lazy-loaded (<img loading="lazy">). Such images delay the Largest
+// Contentful Paint, especially when the page is prefetched. The first count
+// images (<img>) in the document are considered above the fold; zero implies
+// DefaultAboveFoldImages.
+//
+// ReportLazyAboveFold does not modify the document. It logs a warning and
+// records the image URL in ExtraData[exchange.LazyAboveFoldImage] for each
+// detected image.
+func ReportLazyAboveFold(count int) HTMLTask {
+	if count == 0 {
+		count = DefaultAboveFoldImages
+	}
+	return &lazyAboveFold{count, false}
+}
+
+// RemoveLazyAboveFold is like ReportLazyAboveFold, but also removes the
+// loading attribute from the detected images, so they are loaded eagerly.
+//
+// RemoveLazyAboveFold has an effect only when htmlproc.Config.ModifyHTML is
+// true; otherwise it works just as ReportLazyAboveFold.
+func RemoveLazyAboveFold(count int) HTMLTask {
+	if count == 0 {
+		count = DefaultAboveFoldImages
+	}
+	return &lazyAboveFold{count, true}
+}
+
+type lazyAboveFold struct {
+	count  int
+	remove bool
+}
+
+func (task *lazyAboveFold) Run(resp *htmldoc.HTMLResponse) error {
+	seen := 0
+
+	return htmldoc.Traverse(resp.Doc.Root, func(n *html.Node) error {
+		if seen >= task.count {
+			return htmldoc.ErrStop
+		}
+		if n.Type != html.ElementNode || n.DataAtom != atom.Img {
+			return nil
+		}
+		seen++
+
+		if !strings.EqualFold(htmldoc.GetAttr(n, "loading"), "lazy") {
+			return nil
+		}
+		src := htmldoc.GetAttr(n, "src")
+		if u := resolveURLAttr(htmldoc.FindAttr(n, "src"), resp.Doc); u != nil {
+			src = u.String()
+		}
+		log.Printf("warning: %v: above-the-fold image %q is lazy-loaded", resp.Request.URL, src)
+		resp.ExtraData.Add(exchange.LazyAboveFoldImage, src)
+
+		if task.remove {
+			removeAttr(n, "loading")
+			resp.ExtraData.Add(exchange.AppliedTransformation,
+				fmt.Sprintf("removed loading=lazy from image %s", src))
+		}
+		return nil
+	})
+}
+
+// removeAttr removes the attributes named key from n.
+func removeAttr(n *html.Node, key string) {
+	attrs := n.Attr[:0]
+	for _, a := range n.Attr {
+		if a.Namespace != "" || !strings.EqualFold(a.Key, key) {
+			attrs = append(attrs, a)
+		}
+	}
+	n.Attr = attrs
+}
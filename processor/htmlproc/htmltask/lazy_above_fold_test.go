@@ -0,0 +1,97 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package htmltask_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/layer0-platform/webpackager/exchange"
+	"github.com/layer0-platform/webpackager/processor/htmlproc/htmltask"
+	"golang.org/x/net/html"
+)
+
+func TestLazyAboveFold(t *testing.T) {
+	const page = `<!doctype html>
+	              <body>
+	                <img src="hero.png" loading="lazy">
+	                <img src="logo.png">
+	                <img src="banner.png" LOADING="Lazy">
+	                <img src="footer.png" loading="lazy">
+	              </body>`
+
+	tests := []struct {
+		name        string
+		task        htmltask.HTMLTask
+		wantImages  []string
+		wantRemoved bool
+	}{
+		{
+			name: "Report",
+			task: htmltask.ReportLazyAboveFold(0),
+			wantImages: []string{
+				"https://example.com/hello/hero.png",
+				"https://example.com/hello/banner.png",
+			},
+			wantRemoved: false,
+		},
+		{
+			name: "Remove",
+			task: htmltask.RemoveLazyAboveFold(0),
+			wantImages: []string{
+				"https://example.com/hello/hero.png",
+				"https://example.com/hello/banner.png",
+			},
+			wantRemoved: true,
+		},
+		{
+			name: "Count",
+			task: htmltask.ReportLazyAboveFold(1),
+			wantImages: []string{
+				"https://example.com/hello/hero.png",
+			},
+			wantRemoved: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := makeHTMLResponse("https://example.com/hello/", page)
+			if err := test.task.Run(resp); err != nil {
+				t.Fatalf("got error(%q), want success", err)
+			}
+
+			got := resp.ExtraData[exchange.LazyAboveFoldImage]
+			if diff := cmp.Diff(test.wantImages, got); diff != "" {
+				t.Errorf("resp.ExtraData[%q] mismatch (-want +got):\n%s", exchange.LazyAboveFoldImage, diff)
+			}
+
+			var rendered bytes.Buffer
+			if err := html.Render(&rendered, resp.Doc.Root); err != nil {
+				t.Fatal(err)
+			}
+			lazyCount := strings.Count(strings.ToLower(rendered.String()), `loading="lazy"`)
+			wantLazyCount := 3
+			if test.wantRemoved {
+				wantLazyCount = 1
+			}
+			if lazyCount != wantLazyCount {
+				t.Errorf("loading=lazy count = %d, want %d", lazyCount, wantLazyCount)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package htmltask_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/layer0-platform/webpackager/processor/htmlproc/htmltask"
+)
+
+func TestPreconnectCrossOrigins(t *testing.T) {
+	tests := []struct {
+		name  string
+		url   string
+		html  string
+		limit int
+		want  []string
+	}{
+		{
+			name: "Simple",
+			url:  "https://example.com/hello/",
+			html: `<!doctype html>
+			       <head>
+			         <link rel="stylesheet" href="https://fonts.example.net/font.css">
+			         <script src="https://cdn.example.org/lib.js"></script>
+			       </head>
+			       <body>
+			         <img src="https://img.example.org/hero.png">
+			       </body>`,
+			want: []string{
+				`<https://fonts.example.net>;rel="preconnect"`,
+				`<https://cdn.example.org>;rel="preconnect"`,
+				`<https://img.example.org>;rel="preconnect"`,
+			},
+		},
+		{
+			name: "SameOriginSkipped",
+			url:  "https://example.com/hello/",
+			html: `<!doctype html>
+			       <head>
+			         <link rel="stylesheet" href="style.css">
+			         <script src="https://example.com/lib.js"></script>
+			       </head>`,
+			want: nil,
+		},
+		{
+			name: "Deduplicated",
+			url:  "https://example.com/hello/",
+			html: `<!doctype html>
+			       <body>
+			         <img src="https://img.example.org/1.png">
+			         <img src="https://img.example.org/2.png">
+			         <img src="//img.example.org/3.png">
+			       </body>`,
+			want: []string{
+				`<https://img.example.org>;rel="preconnect"`,
+			},
+		},
+		{
+			name:  "Capped",
+			url:   "https://example.com/hello/",
+			limit: 2,
+			html: `<!doctype html>
+			       <body>
+			         <img src="https://a.example.org/1.png">
+			         <img src="https://b.example.org/2.png">
+			         <img src="https://c.example.org/3.png">
+			       </body>`,
+			want: []string{
+				`<https://a.example.org>;rel="preconnect"`,
+				`<https://b.example.org>;rel="preconnect"`,
+			},
+		},
+		{
+			name: "NavigationalLinksSkipped",
+			url:  "https://example.com/hello/",
+			html: `<!doctype html>
+			       <head>
+			         <link rel="canonical" href="https://www.example.net/hello/">
+			         <link rel="alternate" hreflang="ja" href="https://ja.example.net/hello/">
+			         <link rel="author" href="https://people.example.net/alice">
+			         <link rel="manifest" href="https://static.example.net/manifest.json">
+			         <link rel="shortcut icon" href="https://static.example.org/favicon.ico">
+			         <link rel="modulepreload" href="https://cdn.example.org/app.mjs">
+			       </head>`,
+			want: []string{
+				`<https://static.example.org>;rel="preconnect"`,
+				`<https://cdn.example.org>;rel="preconnect"`,
+			},
+		},
+		{
+			name: "NonHTTPSchemeSkipped",
+			url:  "https://example.com/hello/",
+			html: `<!doctype html>
+			       <body>
+			         <img src="data:image/png;base64,iVBORw0KGgo=">
+			       </body>`,
+			want: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := makeHTMLResponse(test.url, test.html)
+			if err := htmltask.PreconnectCrossOrigins(test.limit).Run(resp); err != nil {
+				t.Fatalf("got error(%q), want success", err)
+			}
+			if diff := cmp.Diff(test.want, resp.Header["Link"]); diff != "" {
+				t.Errorf(`resp.Header["Link"] mismatch (-want +got):\n%s`, diff)
+			}
+		})
+	}
+}
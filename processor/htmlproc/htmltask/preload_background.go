@@ -0,0 +1,103 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package htmltask
+
+import (
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/layer0-platform/webpackager/internal/urlutil"
+	"github.com/layer0-platform/webpackager/processor/htmlproc/htmldoc"
+	"github.com/layer0-platform/webpackager/resource/preload"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var (
+	cssCommentPattern = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	cssURLPattern     = regexp.MustCompile(`(?i)url\(\s*(?:"([^"]*)"|'([^']*)'|([^"'\s)]*))\s*\)`)
+)
+
+// PreloadBackgroundImages detects the images referenced by url() in the
+// background and background-image properties of inline <style> elements,
+// such as hero images, and adds them to the Preloads field as images. These
+// images are otherwise discovered only after the browser applies the styles,
+// thus load late.
+//
+// PreloadBackgroundImages ignores data: URLs and cross-origin images, which
+// cannot be preloaded as signed exchanges; see PreconnectCrossOrigins for
+// the latter. The CSS is not fully parsed: the properties are recognized
+// regardless of the selectors and at-rules enclosing them, e.g. @media.
+func PreloadBackgroundImages() HTMLTask {
+	return &preloadBackgroundImages{}
+}
+
+type preloadBackgroundImages struct{}
+
+func (task *preloadBackgroundImages) Run(resp *htmldoc.HTMLResponse) error {
+	return htmldoc.Traverse(resp.Doc.Root, func(n *html.Node) error {
+		if n.Type != html.ElementNode || n.DataAtom != atom.Style {
+			return nil
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.TextNode {
+				continue
+			}
+			for _, s := range findBackgroundURLs(c.Data) {
+				u, err := url.Parse(s)
+				if err != nil {
+					log.Printf("warning: invalid background URL %q: %v", s, err)
+					continue
+				}
+				u = resp.Doc.ResolveReference(u)
+				urlutil.NormalizeIRI(u)
+				if u.Scheme == "data" || !urlutil.HasSameOrigin(u, resp.Request.URL) {
+					continue
+				}
+				resp.AddPreload(preload.NewPreloadForURL(u, preload.AsImage))
+			}
+		}
+		return htmldoc.ErrSkip
+	})
+}
+
+// findBackgroundURLs returns the URLs in url() of the background and
+// background-image declarations in css, in the order of appearance.
+func findBackgroundURLs(css string) []string {
+	var urls []string
+	css = cssCommentPattern.ReplaceAllString(css, "")
+	decls := strings.FieldsFunc(css, func(r rune) bool {
+		return r == '{' || r == '}' || r == ';'
+	})
+	for _, decl := range decls {
+		chunks := strings.SplitN(decl, ":", 2)
+		if len(chunks) != 2 {
+			continue
+		}
+		name := strings.ToLower(strings.TrimSpace(chunks[0]))
+		if name != "background" && name != "background-image" {
+			continue
+		}
+		for _, m := range cssURLPattern.FindAllStringSubmatch(chunks[1], -1) {
+			s := strings.TrimSpace(m[1] + m[2] + m[3])
+			if s != "" {
+				urls = append(urls, s)
+			}
+		}
+	}
+	return urls
+}
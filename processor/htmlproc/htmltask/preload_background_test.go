@@ -0,0 +1,124 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package htmltask_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/layer0-platform/webpackager/processor/htmlproc/htmltask"
+	"github.com/layer0-platform/webpackager/resource/preload"
+	"github.com/layer0-platform/webpackager/resource/preload/preloadtest"
+)
+
+func TestPreloadBackgroundImages(t *testing.T) {
+	pl := preloadtest.NewPreloadForRawLink
+
+	tests := []struct {
+		name string
+		url  string
+		html string
+		want []*preload.Preload
+	}{
+		{
+			name: "BackgroundImage",
+			url:  "https://example.com/hello/",
+			html: `<!doctype html>
+			       <style>
+			         .hero { background-image: url("hero.jpg"); }
+			       </style>`,
+			want: []*preload.Preload{
+				pl(`<https://example.com/hello/hero.jpg>;rel="preload";as="image"`),
+			},
+		},
+		{
+			name: "Background",
+			url:  "https://example.com/hello/",
+			html: `<!doctype html>
+			       <style>
+			         .a { BACKGROUND: #fff url(/a.png) no-repeat }
+			         .b { background: url('b.png'), url(c.png) }
+			       </style>`,
+			want: []*preload.Preload{
+				pl(`<https://example.com/a.png>;rel="preload";as="image"`),
+				pl(`<https://example.com/hello/b.png>;rel="preload";as="image"`),
+				pl(`<https://example.com/hello/c.png>;rel="preload";as="image"`),
+			},
+		},
+		{
+			name: "BaseURL",
+			url:  "https://example.com/hello/",
+			html: `<!doctype html>
+			       <base href="/world/">
+			       <style>.hero { background-image: url(hero.jpg) }</style>`,
+			want: []*preload.Preload{
+				pl(`<https://example.com/world/hero.jpg>;rel="preload";as="image"`),
+			},
+		},
+		{
+			name: "Duplicates",
+			url:  "https://example.com/hello/",
+			html: `<!doctype html>
+			       <style>.a { background-image: url(hero.jpg) }</style>
+			       <style>.b { background-image: url(hero.jpg) }</style>`,
+			want: []*preload.Preload{
+				pl(`<https://example.com/hello/hero.jpg>;rel="preload";as="image"`),
+			},
+		},
+		{
+			name: "OtherProperties",
+			url:  "https://example.com/hello/",
+			html: `<!doctype html>
+			       <style>
+			         @font-face { src: url(font.woff2) }
+			         .a { list-style-image: url(bullet.png) }
+			         /* .b { background-image: url(commented.png) } */
+			       </style>`,
+			want: nil,
+		},
+		{
+			name: "DataAndCrossOrigin",
+			url:  "https://example.com/hello/",
+			html: `<!doctype html>
+			       <style>
+			         .a { background-image: url(data:image/gif;base64,R0lGODlhAQABAAAAACw=) }
+			         .b { background-image: url(https://cdn.example.net/b.jpg) }
+			         .c { background-image: url(c.jpg) }
+			       </style>`,
+			want: []*preload.Preload{
+				pl(`<https://example.com/hello/c.jpg>;rel="preload";as="image"`),
+			},
+		},
+		{
+			name: "StyleAttribute",
+			url:  "https://example.com/hello/",
+			html: `<!doctype html>
+			       <div style="background-image: url(hero.jpg)"></div>`,
+			want: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := makeHTMLResponse(test.url, test.html)
+			if err := htmltask.PreloadBackgroundImages().Run(resp); err != nil {
+				t.Fatalf("got error(%q), want success", err)
+			}
+			if diff := cmp.Diff(test.want, resp.Preloads); diff != "" {
+				t.Errorf("resp.Preloads mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
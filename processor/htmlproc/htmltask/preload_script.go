@@ -72,17 +72,27 @@ var (
 // to keep rendering the web page without waiting for scripts getting loaded
 // and executed, thus eliminate the need for preloading.
 func InsecurePreloadScripts() HTMLTask {
-	return &preloadScripts{}
+	return &preloadScripts{false}
 }
 
-type preloadScripts struct{}
+// InsecurePreloadAllScripts is like InsecurePreloadScripts, but also includes
+// the scripts loaded with async or defer attribute at the top of the document,
+// which do not block the rendering, thus usually are not worth preloading.
+// The same SECURITY NOTICE applies.
+func InsecurePreloadAllScripts() HTMLTask {
+	return &preloadScripts{true}
+}
+
+type preloadScripts struct {
+	all bool
+}
 
-func (*preloadScripts) Run(resp *htmldoc.HTMLResponse) error {
+func (task *preloadScripts) Run(resp *htmldoc.HTMLResponse) error {
 	return htmldoc.Traverse(resp.Doc.Root, func(n *html.Node) error {
 		switch n.Type {
 		case html.ElementNode:
 			if n.DataAtom == atom.Script {
-				handleScript(resp, n)
+				task.handleScript(resp, n)
 				return htmldoc.ErrSkip
 			}
 			if skipElements[n.DataAtom] {
@@ -111,11 +121,11 @@ func (*preloadScripts) Run(resp *htmldoc.HTMLResponse) error {
 	})
 }
 
-func handleScript(resp *htmldoc.HTMLResponse, n *html.Node) {
-	if htmldoc.FindAttr(n, "async") != nil {
+func (task *preloadScripts) handleScript(resp *htmldoc.HTMLResponse, n *html.Node) {
+	if !task.all && htmldoc.FindAttr(n, "async") != nil {
 		return
 	}
-	if htmldoc.FindAttr(n, "defer") != nil {
+	if !task.all && htmldoc.FindAttr(n, "defer") != nil {
 		return
 	}
 	if u := resolveURLAttr(htmldoc.FindAttr(n, "src"), resp.Doc); u != nil {
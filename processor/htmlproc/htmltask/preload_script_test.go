@@ -210,3 +210,25 @@ func TestScriptTask(t *testing.T) {
 		})
 	}
 }
+
+func TestInsecurePreloadAllScripts(t *testing.T) {
+	pl := preloadtest.NewPreloadForRawLink
+
+	resp := makeHTMLResponse("https://example.com/hello/", `<!doctype html>
+	       <head>
+	         <script src="analytics.js" async></script>
+	         <script src="defer.js" defer></script>
+	         <script src="main.js"></script>
+	       </head>`)
+	if err := htmltask.InsecurePreloadAllScripts().Run(resp); err != nil {
+		t.Fatalf("got error(%q), want success", err)
+	}
+	want := []*preload.Preload{
+		pl(`<https://example.com/hello/analytics.js>;rel="preload";as="script"`),
+		pl(`<https://example.com/hello/defer.js>;rel="preload";as="script"`),
+		pl(`<https://example.com/hello/main.js>;rel="preload";as="script"`),
+	}
+	if diff := cmp.Diff(want, resp.Preloads); diff != "" {
+		t.Errorf("resp.Preloads mismatch (-want +got):\n%s", diff)
+	}
+}
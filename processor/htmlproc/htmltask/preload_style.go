@@ -29,11 +29,24 @@ import (
 // PreloadStylesheets does not include stylesheets that have "alternate" in
 // the rel attribute. Those stylesheets are unused in the initial rendering.
 // They are not used at all on some unsupported browsers.
+//
+// PreloadStylesheets does not include stylesheets which do not block the
+// rendering on screens either, namely those with a media attribute only for
+// other media (e.g. media="print") and those with the disabled attribute.
+// Preloading them would just compete with the resources needed first.
 func PreloadStylesheets() HTMLTask {
-	return &preloadStylesheets{}
+	return &preloadStylesheets{false}
+}
+
+// PreloadAllStylesheets is like PreloadStylesheets, but also includes the
+// stylesheets which do not block the rendering, e.g. print stylesheets.
+func PreloadAllStylesheets() HTMLTask {
+	return &preloadStylesheets{true}
 }
 
-type preloadStylesheets struct{}
+type preloadStylesheets struct {
+	all bool
+}
 
 func isStylesheet(n *html.Node) bool {
 	if n.Type != html.ElementNode {
@@ -58,11 +71,41 @@ func isStylesheet(n *html.Node) bool {
 	return stylesheet
 }
 
-func (*preloadStylesheets) Run(resp *htmldoc.HTMLResponse) error {
+// isRenderBlocking reports whether the stylesheet n blocks the rendering on
+// screens, judging by its media and disabled attributes.
+func isRenderBlocking(n *html.Node) bool {
+	if htmldoc.FindAttr(n, "disabled") != nil {
+		return false
+	}
+	media := htmldoc.FindAttr(n, "media")
+	if media == nil || strings.TrimSpace(media.Val) == "" {
+		return true
+	}
+	for _, query := range strings.Split(strings.ToLower(media.Val), ",") {
+		tokens := strings.Fields(query)
+		if len(tokens) > 0 && tokens[0] == "only" {
+			tokens = tokens[1:]
+		}
+		// Treat "not" queries as matching screens, to err on the side of
+		// preloading.
+		if len(tokens) == 0 || tokens[0] == "not" || strings.HasPrefix(tokens[0], "(") {
+			return true
+		}
+		if tokens[0] == "all" || tokens[0] == "screen" {
+			return true
+		}
+	}
+	return false
+}
+
+func (task *preloadStylesheets) Run(resp *htmldoc.HTMLResponse) error {
 	return htmldoc.Traverse(resp.Doc.Head, func(n *html.Node) error {
 		if !isStylesheet(n) {
 			return nil
 		}
+		if !task.all && !isRenderBlocking(n) {
+			return nil
+		}
 		href := resolveURLAttr(htmldoc.FindAttr(n, "href"), resp.Doc)
 		if href == nil {
 			return nil
@@ -72,6 +72,26 @@ func TestPreloadStylesheets(t *testing.T) {
 				pl(`<https://example.com/hello/baz.css>;rel="preload";as="style"`),
 			},
 		},
+		{
+			name: "NonRenderBlocking",
+			url:  "https://example.com/hello/",
+			html: `<!doctype html>
+			       <head>
+			         <link rel="stylesheet" href="print.css" media="print">
+			         <link rel="stylesheet" href="speech.css" media="speech, print">
+			         <link rel="stylesheet" href="disabled.css" disabled>
+			         <link rel="stylesheet" href="screen.css" media="only screen">
+			         <link rel="stylesheet" href="narrow.css" media="(max-width: 600px)">
+			         <link rel="stylesheet" href="mixed.css" media="print, screen and (color)">
+			         <link rel="stylesheet" href="all.css" media="ALL">
+			       </head>`,
+			want: []*preload.Preload{
+				pl(`<https://example.com/hello/screen.css>;rel="preload";as="style"`),
+				pl(`<https://example.com/hello/narrow.css>;rel="preload";as="style"`),
+				pl(`<https://example.com/hello/mixed.css>;rel="preload";as="style"`),
+				pl(`<https://example.com/hello/all.css>;rel="preload";as="style"`),
+			},
+		},
 		{
 			name: "OutsideHead",
 			url:  "https://example.com/hello/",
@@ -82,6 +102,18 @@ func TestPreloadStylesheets(t *testing.T) {
 			       </body>`,
 			want: nil,
 		},
+		{
+			name: "MixedContent",
+			url:  "https://example.com/hello/",
+			html: `<!doctype html>
+			       <head>
+			         <link rel="stylesheet" href="http://example.com/hello/foo.css">
+			         <link rel="stylesheet" href="bar.css">
+			       </head>`,
+			want: []*preload.Preload{
+				pl(`<https://example.com/hello/bar.css>;rel="preload";as="style"`),
+			},
+		},
 		{
 			name: "CrossOrigin",
 			url:  "https://example.com/hello/",
@@ -120,3 +152,23 @@ func TestPreloadStylesheets(t *testing.T) {
 		})
 	}
 }
+
+func TestPreloadAllStylesheets(t *testing.T) {
+	pl := preloadtest.NewPreloadForRawLink
+
+	resp := makeHTMLResponse("https://example.com/hello/", `<!doctype html>
+	       <head>
+	         <link rel="stylesheet" href="print.css" media="print">
+	         <link rel="stylesheet" href="main.css">
+	       </head>`)
+	if err := htmltask.PreloadAllStylesheets().Run(resp); err != nil {
+		t.Fatalf("got error(%q), want success", err)
+	}
+	want := []*preload.Preload{
+		pl(`<https://example.com/hello/print.css>;rel="preload";as="style"`),
+		pl(`<https://example.com/hello/main.css>;rel="preload";as="style"`),
+	}
+	if diff := cmp.Diff(want, resp.Preloads); diff != "" {
+		t.Errorf("resp.Preloads mismatch (-want +got):\n%s", diff)
+	}
+}
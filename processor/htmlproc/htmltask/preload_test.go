@@ -57,6 +57,27 @@ func TestExtractPreloadTags(t *testing.T) {
 				pl(`<https://example.com/world/bar.jpg>;rel="preload";as="image"`),
 			},
 		},
+		{
+			name: "BaseURLWithoutHref",
+			url:  "https://example.com/hello/",
+			html: `<!doctype html>
+			       <base target="_blank">
+			       <base href="https://cdn.example.com/world/">
+			       <link href="bar.jpg" rel="preload" as="image">`,
+			want: []*preload.Preload{
+				pl(`<https://cdn.example.com/world/bar.jpg>;rel="preload";as="image"`),
+			},
+		},
+		{
+			name: "BaseURLInBody",
+			url:  "https://example.com/hello/",
+			html: `<!doctype html>
+			       <link href="foo.jpg" rel="preload" as="image">
+			       <body><base href="../world/"></body>`,
+			want: []*preload.Preload{
+				pl(`<https://example.com/world/foo.jpg>;rel="preload";as="image"`),
+			},
+		},
 		{
 			name: "WebFont",
 			url:  "https://example.com/hello/",
@@ -67,6 +88,17 @@ func TestExtractPreloadTags(t *testing.T) {
 				pl(`<https://example.com/fonts/icons.woff2>;rel="preload";as="font";crossorigin;type="font/woff2"`),
 			},
 		},
+		{
+			name: "NonASCII",
+			url:  "https://example.com/hello/",
+			html: `<!doctype html>
+			       <link href="изображение.jpg" rel="preload" as="image">
+			       <link href="/%e7%94%bb%e5%83%8f/写真.jpg" rel="preload" as="image">`,
+			want: []*preload.Preload{
+				pl(`<https://example.com/hello/%D0%B8%D0%B7%D0%BE%D0%B1%D1%80%D0%B0%D0%B6%D0%B5%D0%BD%D0%B8%D0%B5.jpg>;rel="preload";as="image"`),
+				pl(`<https://example.com/%E7%94%BB%E5%83%8F/%E5%86%99%E7%9C%9F.jpg>;rel="preload";as="image"`),
+			},
+		},
 		{
 			name: "Media",
 			url:  "https://example.com/hello/",
@@ -0,0 +1,89 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package htmltask
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/layer0-platform/webpackager/exchange"
+	"github.com/layer0-platform/webpackager/processor/htmlproc/htmldoc"
+	"golang.org/x/net/html"
+)
+
+// CommentConfig holds the parameters to StripComments.
+type CommentConfig struct {
+	// Keep specifies the comments to keep, e.g. edge-side include hints.
+	// A comment is kept if any of the patterns matches its text, i.e.
+	// the content between "<!--" and "-->".
+	Keep []*regexp.Regexp
+
+	// KeepConditional instructs to keep conditional comments, e.g.
+	// "<!--[if IE]>...<![endif]-->", including the downlevel-revealed ones.
+	KeepConditional bool
+}
+
+// StripComments removes HTML comments from the document to shrink the
+// payload, except those specified by config. Comments inside raw text
+// elements, such as <script> and <style>, are not HTML comments thus left
+// untouched.
+//
+// StripComments has an effect only when ModifyHTML is true in
+// htmlproc.Config.
+func StripComments(config CommentConfig) HTMLTask {
+	return &stripComments{config}
+}
+
+type stripComments struct {
+	CommentConfig
+}
+
+func (task *stripComments) Run(resp *htmldoc.HTMLResponse) error {
+	var comments []*html.Node
+	htmldoc.Traverse(resp.Doc.Root, func(n *html.Node) error {
+		if n.Type == html.CommentNode && !task.keep(n.Data) {
+			comments = append(comments, n)
+		}
+		return nil
+	})
+	for _, n := range comments {
+		n.Parent.RemoveChild(n)
+	}
+	if len(comments) > 0 {
+		resp.ExtraData.Add(exchange.AppliedTransformation,
+			fmt.Sprintf("stripped %d HTML comments", len(comments)))
+	}
+	return nil
+}
+
+func (task *stripComments) keep(text string) bool {
+	if task.KeepConditional && isConditionalComment(text) {
+		return true
+	}
+	for _, re := range task.Keep {
+		if re.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// isConditionalComment reports whether the comment with text starts or ends
+// a conditional comment, e.g. "[if IE]>" or "<![endif]".
+func isConditionalComment(text string) bool {
+	text = strings.ToLower(strings.TrimSpace(text))
+	return strings.HasPrefix(text, "[if ") || strings.HasSuffix(text, "[endif]")
+}
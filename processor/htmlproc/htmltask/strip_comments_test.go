@@ -0,0 +1,79 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package htmltask_test
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+
+	"github.com/layer0-platform/webpackager/processor/htmlproc/htmltask"
+	"golang.org/x/net/html"
+)
+
+func TestStripComments(t *testing.T) {
+	const doc = `<!doctype html>` +
+		`<!-- build: 1234 -->` +
+		`<!--esi <esi:include src="/header"/> -->` +
+		`<!--[if IE]><p>Upgrade your browser.</p><![endif]-->` +
+		`<!--[if !IE]><!--><p>Hello</p><!--<![endif]-->` +
+		`<script>/* <!-- not a comment --> */</script>`
+
+	tests := []struct {
+		name   string
+		config htmltask.CommentConfig
+		want   string
+	}{
+		{
+			name:   "StripAll",
+			config: htmltask.CommentConfig{},
+			want: `<!DOCTYPE html><html><head></head><body><p>Hello</p>` +
+				`<script>/* <!-- not a comment --> */</script></body></html>`,
+		},
+		{
+			name: "KeepPattern",
+			config: htmltask.CommentConfig{
+				Keep: []*regexp.Regexp{regexp.MustCompile(`^esi\b`)},
+			},
+			want: `<!DOCTYPE html><!--esi <esi:include src="/header"/> -->` +
+				`<html><head></head><body><p>Hello</p>` +
+				`<script>/* <!-- not a comment --> */</script></body></html>`,
+		},
+		{
+			name:   "KeepConditional",
+			config: htmltask.CommentConfig{KeepConditional: true},
+			want: `<!DOCTYPE html>` +
+				`<!--[if IE]><p>Upgrade your browser.</p><![endif]-->` +
+				`<!--[if !IE]><!--><html><head></head><body><p>Hello</p><!--<![endif]-->` +
+				`<script>/* <!-- not a comment --> */</script></body></html>`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := makeHTMLResponse("https://example.com/hello/", doc)
+			if err := htmltask.StripComments(test.config).Run(resp); err != nil {
+				t.Fatalf("got error(%q), want success", err)
+			}
+			var buf bytes.Buffer
+			if err := html.Render(&buf, resp.Doc.Root); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package htmltask
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/layer0-platform/webpackager/exchange"
+	"github.com/layer0-platform/webpackager/processor/htmlproc/htmldoc"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// StripQueryParams removes the query parameters named params from the URLs
+// of subresources, so the same subresource gets the same URL regardless of
+// tracking or cache-busting parameters which vary across pages or requests.
+// A name ending with "*" matches all parameters with the preceding prefix,
+// e.g. "utm_*" matches "utm_source" and "utm_medium". Other parameters are
+// kept in the original order.
+//
+// StripQueryParams rewrites the href attribute of <link> elements and the
+// src attribute of <script> and <img> elements, as well as the preloads
+// already in the Preloads field, e.g. those from Link headers. It should
+// run before the HTMLTasks adding preloads, such as ExtractPreloadTags and
+// PreloadStylesheets, to get the preloads normalized.
+//
+// StripQueryParams has an effect on the document only when ModifyHTML is
+// true in htmlproc.Config; otherwise the page still requests the original
+// URLs, which no longer match the preloads.
+func StripQueryParams(params ...string) HTMLTask {
+	return &stripQueryParams{params}
+}
+
+type stripQueryParams struct {
+	params []string
+}
+
+func (task *stripQueryParams) Run(resp *htmldoc.HTMLResponse) error {
+	htmldoc.Traverse(resp.Doc.Root, func(n *html.Node) error {
+		if n.Type != html.ElementNode {
+			return nil
+		}
+		var key string
+		switch n.DataAtom {
+		case atom.Link:
+			key = "href"
+		case atom.Script, atom.Img:
+			key = "src"
+		default:
+			return nil
+		}
+		a := htmldoc.FindAttr(n, key)
+		if a == nil {
+			return nil
+		}
+		orig := a.Val
+		u, err := url.Parse(orig)
+		if err != nil {
+			return nil
+		}
+		if query, ok := task.strip(u.RawQuery); ok {
+			u.RawQuery = query
+			u.ForceQuery = false
+			setAttr(n, key, u.String())
+			resp.ExtraData.Add(exchange.AppliedTransformation,
+				fmt.Sprintf("stripped query parameters from %q", orig))
+		}
+		return nil
+	})
+
+	for _, p := range resp.Preloads {
+		if stripped, ok := task.stripURL(p.Link.URL); ok {
+			p.Link.URL = stripped
+		}
+		for _, r := range p.Resources {
+			if stripped, ok := task.stripURL(r.RequestURL); ok {
+				r.RequestURL = stripped
+			}
+		}
+	}
+	return nil
+}
+
+// stripURL returns a copy of u with the parameters removed. ok is false if
+// u has none of the parameters.
+func (task *stripQueryParams) stripURL(u *url.URL) (stripped *url.URL, ok bool) {
+	if u == nil {
+		return nil, false
+	}
+	query, ok := task.strip(u.RawQuery)
+	if !ok {
+		return nil, false
+	}
+	stripped = new(url.URL)
+	*stripped = *u
+	stripped.RawQuery = query
+	stripped.ForceQuery = false
+	return stripped, true
+}
+
+// strip removes the parameters from the raw query. ok is false if query has
+// none of the parameters.
+func (task *stripQueryParams) strip(query string) (stripped string, ok bool) {
+	if query == "" {
+		return query, false
+	}
+	var kept []string
+	for _, pair := range strings.Split(query, "&") {
+		name := pair
+		if i := strings.IndexByte(pair, '='); i >= 0 {
+			name = pair[:i]
+		}
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if task.match(name) {
+			ok = true
+			continue
+		}
+		kept = append(kept, pair)
+	}
+	return strings.Join(kept, "&"), ok
+}
+
+func (task *stripQueryParams) match(name string) bool {
+	for _, param := range task.params {
+		if strings.HasSuffix(param, "*") {
+			if strings.HasPrefix(name, strings.TrimSuffix(param, "*")) {
+				return true
+			}
+		} else if name == param {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package htmltask_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/layer0-platform/webpackager/processor/htmlproc/htmltask"
+	"github.com/layer0-platform/webpackager/resource/preload"
+	"github.com/layer0-platform/webpackager/resource/preload/preloadtest"
+	"golang.org/x/net/html"
+)
+
+func TestStripQueryParams(t *testing.T) {
+	pl := preloadtest.NewPreloadForRawLink
+
+	tests := []struct {
+		name     string
+		params   []string
+		html     string
+		preloads []*preload.Preload
+		want     []*preload.Preload
+		wantHTML []string
+	}{
+		{
+			name:   "Stylesheet",
+			params: []string{"utm"},
+			html: `<!doctype html>
+			       <link rel="stylesheet" href="style.css?utm=x">`,
+			want: []*preload.Preload{
+				pl(`<https://example.com/hello/style.css>;rel="preload";as="style"`),
+			},
+			wantHTML: []string{`<link rel="stylesheet" href="style.css"/>`},
+		},
+		{
+			name:   "KeepOtherParams",
+			params: []string{"utm"},
+			html: `<!doctype html>
+			       <link rel="stylesheet" href="style.css?v=abc123&amp;utm=x&amp;lang=en">`,
+			want: []*preload.Preload{
+				pl(`<https://example.com/hello/style.css?v=abc123&lang=en>;rel="preload";as="style"`),
+			},
+			wantHTML: []string{`<link rel="stylesheet" href="style.css?v=abc123&amp;lang=en"/>`},
+		},
+		{
+			name:   "Wildcard",
+			params: []string{"utm_*", "fbclid"},
+			html: `<!doctype html>
+			       <link rel="stylesheet" href="/a.css?utm_source=x&amp;utm_medium=y">
+			       <link rel="stylesheet" href="/b.css?fbclid=z&amp;utm=w">
+			       <script src="/c.js?utm_campaign=q"></script>`,
+			want: []*preload.Preload{
+				pl(`<https://example.com/a.css>;rel="preload";as="style"`),
+				pl(`<https://example.com/b.css?utm=w>;rel="preload";as="style"`),
+			},
+			wantHTML: []string{
+				`<link rel="stylesheet" href="/a.css"/>`,
+				`<link rel="stylesheet" href="/b.css?utm=w"/>`,
+				`<script src="/c.js"></script>`,
+			},
+		},
+		{
+			name:   "ExistingPreloads",
+			params: []string{"utm"},
+			html:   `<!doctype html>`,
+			preloads: []*preload.Preload{
+				pl(`<https://example.com/font.woff2?utm=x>;rel="preload";as="font"`),
+			},
+			want: []*preload.Preload{
+				pl(`<https://example.com/font.woff2>;rel="preload";as="font"`),
+			},
+		},
+		{
+			name:   "NoMatch",
+			params: []string{"utm"},
+			html: `<!doctype html>
+			       <link rel="stylesheet" href="style.css?utm_source=x">`,
+			want: []*preload.Preload{
+				pl(`<https://example.com/hello/style.css?utm_source=x>;rel="preload";as="style"`),
+			},
+			wantHTML: []string{`<link rel="stylesheet" href="style.css?utm_source=x"/>`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := makeHTMLResponse("https://example.com/hello/", test.html)
+			resp.Preloads = test.preloads
+			tasks := []htmltask.HTMLTask{
+				htmltask.StripQueryParams(test.params...),
+				htmltask.PreloadStylesheets(),
+			}
+			for _, task := range tasks {
+				if err := task.Run(resp); err != nil {
+					t.Fatalf("got error(%q), want success", err)
+				}
+			}
+			if diff := cmp.Diff(test.want, resp.Preloads); diff != "" {
+				t.Errorf("resp.Preloads mismatch (-want +got):\n%s", diff)
+			}
+
+			var buf bytes.Buffer
+			if err := html.Render(&buf, resp.Doc.Root); err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range test.wantHTML {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("got %s, want to contain %s", buf.String(), want)
+				}
+			}
+		})
+	}
+}
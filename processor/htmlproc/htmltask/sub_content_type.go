@@ -15,7 +15,11 @@
 package htmltask
 
 import (
+	"mime"
+	"strings"
+
 	"github.com/layer0-platform/webpackager/exchange"
+	"github.com/layer0-platform/webpackager/exchange/vprule"
 	"github.com/layer0-platform/webpackager/processor/htmlproc/htmldoc"
 	"golang.org/x/net/html"
 	"golang.org/x/net/html/atom"
@@ -28,10 +32,39 @@ const (
 	mimeTypeSVG    = "image/svg+xml"
 )
 
+// legacyJSMediaTypes are the media types browsers still execute as
+// JavaScript in <script type>, in addition to vprule.JSMediaTypes.
+var legacyJSMediaTypes = map[string]bool{
+	"application/ecmascript":   true,
+	"application/x-ecmascript": true,
+	"text/ecmascript":          true,
+	"text/javascript1.0":       true,
+	"text/javascript1.1":       true,
+	"text/javascript1.2":       true,
+	"text/javascript1.3":       true,
+	"text/javascript1.4":       true,
+	"text/javascript1.5":       true,
+	"text/jscript":             true,
+	"text/livescript":          true,
+	"text/x-ecmascript":        true,
+	"text/x-javascript":        true,
+}
+
 // ExtractSubContentTypes detects internal subcontents in HTML docuemnt,
 // such as CSS (<style>) and JavaScript (<script> without src attribute),
 // and adds their MIME types (e.g. "text/style", "application/javascript")
 // to ExtraData, using exchange.SubContentType as the key.
+//
+// Executable inline JavaScript is reported as "application/javascript",
+// including module scripts (<script type="module">), scripts with legacy
+// JavaScript types (e.g. "text/ecmascript"), event handler attributes (e.g.
+// onclick) and javascript: URLs, so vprule.PerJSContentType applies the rule
+// for JavaScript to the documents containing any of them. Scripts with no
+// content but whitespace are ignored. Data blocks such as JSON-LD are
+// reported with their own types.
+//
+// htmlproc.NewHTMLProcessor runs ExtractSubContentTypes on every document
+// by itself, so it need not be in htmlproc.Config.TaskSet.
 func ExtractSubContentTypes() HTMLTask {
 	return &extractSubContentTypes{}
 }
@@ -43,6 +76,9 @@ func (*extractSubContentTypes) Run(resp *htmldoc.HTMLResponse) error {
 		if n.Type != html.ElementNode {
 			return nil
 		}
+		if hasInlineJSAttr(n) {
+			addSubContentType(resp, mimeTypeJS)
+		}
 
 		switch n.DataAtom {
 		case atom.Math:
@@ -52,8 +88,11 @@ func (*extractSubContentTypes) Run(resp *htmldoc.HTMLResponse) error {
 			if htmldoc.FindAttr(n, "src") != nil {
 				return nil
 			}
-			mimeType := htmldoc.GetAttr(n, "type")
-			if mimeType == "" {
+			mimeType := strings.TrimSpace(htmldoc.GetAttr(n, "type"))
+			if isJSScriptType(mimeType) {
+				if isBlankScript(n) {
+					return nil
+				}
 				mimeType = mimeTypeJS
 			}
 			addSubContentType(resp, mimeType)
@@ -73,6 +112,52 @@ func (*extractSubContentTypes) Run(resp *htmldoc.HTMLResponse) error {
 	})
 }
 
+// isJSScriptType reports whether <script type> with value t is executed as
+// JavaScript.
+func isJSScriptType(t string) bool {
+	if t == "" || strings.EqualFold(t, "module") {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(t)
+	if err != nil && err != mime.ErrInvalidMediaParameter {
+		return false
+	}
+	return vprule.IsJSMediaType(mediaType) || legacyJSMediaTypes[mediaType]
+}
+
+// isBlankScript reports whether the <script> element n has no content but
+// whitespace.
+func isBlankScript(n *html.Node) bool {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.TextNode && strings.TrimSpace(c.Data) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// hasInlineJSAttr reports whether n has an event handler attribute (e.g.
+// onclick) or a javascript: URL.
+func hasInlineJSAttr(n *html.Node) bool {
+	for _, a := range n.Attr {
+		if a.Namespace != "" {
+			continue
+		}
+		// All event handlers are "on" followed by the event name, at least
+		// three letters long (e.g. "oncut"). This excludes e.g. "only".
+		if len(a.Key) > len("only") && strings.HasPrefix(a.Key, "on") {
+			return true
+		}
+		switch a.Key {
+		case "href", "src", "action", "formaction":
+			if strings.HasPrefix(strings.ToLower(strings.TrimSpace(a.Val)), "javascript:") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func addSubContentType(resp *htmldoc.HTMLResponse, mimeType string) {
 	for _, v := range resp.ExtraData[exchange.SubContentType] {
 		if v == mimeType {
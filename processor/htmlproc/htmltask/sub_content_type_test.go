@@ -65,6 +65,48 @@ func TestExtractSubContentTypes(t *testing.T) {
 			html: `<!doctype html><script type="text/plain">template</script>`,
 			want: []string{"text/plain"},
 		},
+		{
+			name: "JS_Module",
+			url:  "https://example.com/hello/",
+			html: `<!doctype html><script type="module">import "./app.js";</script>`,
+			want: []string{"application/javascript"},
+		},
+		{
+			name: "JS_LegacyMIMEType",
+			url:  "https://example.com/hello/",
+			html: `<!doctype html><script type="Text/ECMAScript">go();</script>`,
+			want: []string{"application/javascript"},
+		},
+		{
+			name: "JS_Empty",
+			url:  "https://example.com/hello/",
+			html: "<!doctype html><script>\n  </script><script type=\"module\"></script>",
+			want: nil,
+		},
+		{
+			name: "JS_JSONLD",
+			url:  "https://example.com/hello/",
+			html: `<!doctype html><script type="application/ld+json">{"@type": "Thing"}</script>`,
+			want: []string{"application/ld+json"},
+		},
+		{
+			name: "JS_EventHandler",
+			url:  "https://example.com/hello/",
+			html: `<!doctype html><button onclick="go()">Go</button>`,
+			want: []string{"application/javascript"},
+		},
+		{
+			name: "JS_URL",
+			url:  "https://example.com/hello/",
+			html: `<!doctype html><a href=" JavaScript:go()">Go</a>`,
+			want: []string{"application/javascript"},
+		},
+		{
+			name: "JS_NotEventHandler",
+			url:  "https://example.com/hello/",
+			html: `<!doctype html><p only="true"><a href="javascript.html">JS</a></p>`,
+			want: nil,
+		},
 		{
 			name: "SVG_Internal",
 			url:  "https://example.com/hello/",
@@ -0,0 +1,79 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package htmlproc
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/layer0-platform/webpackager/exchange"
+	"github.com/layer0-platform/webpackager/processor"
+	"github.com/layer0-platform/webpackager/processor/htmlproc/htmldoc"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// CheckJSONLD creates a Processor to check, in mode, that the JSON-LD
+// structured data blocks (<script type="application/ld+json">) in HTML
+// documents are valid JSON. It is meant to run after all other processors,
+// e.g. as the last one in complexproc.Config.CustomPostprocessors, to catch
+// blocks broken by transformations, which would make search engines ignore
+// the data.
+func CheckJSONLD(mode ValidationMode) processor.Processor {
+	p := newChecker(mode, "invalid JSON-LD", func(resp *exchange.Response) ([]string, error) {
+		doc, err := htmldoc.NewDocument(resp.Payload, resp.Request.URL)
+		if err != nil {
+			return nil, err
+		}
+		return findInvalidJSONLD(doc), nil
+	})
+	return processor.MultiplexedProcessor{
+		"text/html":             p,
+		"application/xhtml+xml": p,
+	}
+}
+
+// findInvalidJSONLD returns the descriptions of the JSON-LD blocks in doc
+// which fail to parse as JSON.
+func findInvalidJSONLD(doc *htmldoc.Document) []string {
+	var problems []string
+	index := 0
+
+	htmldoc.Traverse(doc.Root, func(n *html.Node) error {
+		if n.Type != html.ElementNode || n.DataAtom != atom.Script {
+			return nil
+		}
+		typ := strings.TrimSpace(htmldoc.GetAttr(n, "type"))
+		if !strings.EqualFold(typ, "application/ld+json") {
+			return nil
+		}
+		index++
+
+		var text strings.Builder
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.TextNode {
+				text.WriteString(c.Data)
+			}
+		}
+		var v interface{}
+		if err := json.Unmarshal([]byte(text.String()), &v); err != nil {
+			problems = append(problems, fmt.Sprintf("block #%d: %v", index, err))
+		}
+		return nil
+	})
+
+	return problems
+}
@@ -0,0 +1,104 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package htmlproc_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/layer0-platform/webpackager/exchange/exchangetest"
+	"github.com/layer0-platform/webpackager/processor/htmlproc"
+)
+
+func TestCheckJSONLD(t *testing.T) {
+	tests := []struct {
+		name    string
+		html    string
+		wantErr bool
+	}{
+		{
+			name: "Valid",
+			html: fmt.Sprint(
+				`<!doctype html>`,
+				`<script type="application/ld+json">`,
+				`{"@context": "https://schema.org", "@type": "Article", "headline": "a < b"}`,
+				`</script>`,
+				`<script type="Application/LD+JSON">[{"@type": "Person"}]</script>`,
+			),
+			wantErr: false,
+		},
+		{
+			name: "Broken",
+			html: fmt.Sprint(
+				`<!doctype html>`,
+				`<script type="application/ld+json">{"@type": "Article",}</script>`,
+			),
+			wantErr: true,
+		},
+		{
+			name: "Empty",
+			html: fmt.Sprint(
+				`<!doctype html>`,
+				`<script type="application/ld+json"></script>`,
+			),
+			wantErr: true,
+		},
+		{
+			name: "OtherScripts",
+			html: fmt.Sprint(
+				`<!doctype html>`,
+				`<script>var x = {a: 1,};</script>`,
+				`<script type="application/json">{,}</script>`,
+			),
+			wantErr: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, mode := range []htmlproc.ValidationMode{htmlproc.ValidationReport, htmlproc.ValidationFail} {
+				proc := htmlproc.CheckJSONLD(mode)
+				resp := makeResponse("https://example.com/test.html", test.html)
+				err := proc.Process(resp)
+				if mode == htmlproc.ValidationFail && test.wantErr {
+					if err == nil {
+						t.Errorf("mode %v: got success, want error", mode)
+					}
+				} else if err != nil {
+					t.Errorf("mode %v: got error(%q), want success", mode, err)
+				}
+				if got := string(resp.Payload); got != test.html {
+					t.Errorf("mode %v: payload = %q, want %q", mode, got, test.html)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckJSONLD_NonHTML(t *testing.T) {
+	payload := `<script type="application/ld+json">{,}</script>`
+	resp := exchangetest.MakeResponse("https://example.com/test.txt", fmt.Sprint(
+		"HTTP/1.1 200 OK\r\n",
+		"Content-Type: text/plain\r\n",
+		"\r\n",
+		payload))
+	if err := htmlproc.CheckJSONLD(htmlproc.ValidationFail).Process(resp); err != nil {
+		t.Errorf("got error(%q), want success", err)
+	}
+	if !bytes.Equal(resp.Payload, []byte(payload)) {
+		t.Errorf("payload = %q, want %q", resp.Payload, payload)
+	}
+}
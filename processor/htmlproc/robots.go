@@ -0,0 +1,116 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package htmlproc
+
+import (
+	"fmt"
+	"mime"
+	"strings"
+
+	"github.com/layer0-platform/webpackager/exchange"
+	"github.com/layer0-platform/webpackager/processor"
+	"github.com/layer0-platform/webpackager/processor/htmlproc/htmldoc"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const xRobotsTag = "X-Robots-Tag"
+
+// DefaultRobotsDirectives are the robots directives CheckRobots looks for
+// when none are specified.
+var DefaultRobotsDirectives = []string{"noindex", "noarchive"}
+
+// CheckRobots creates a Processor to check responses for robots directives
+// (e.g. "noindex") which indicate the content should not be indexed or
+// cached by third parties, thus should not be distributed as signed
+// exchanges either. The directives are read from the X-Robots-Tag header
+// for all responses, and from <meta name="robots"> elements for HTML
+// documents. Directives for specific crawlers (e.g. "googlebot: noindex")
+// count as well. "none" counts as "noindex".
+//
+// directives specifies the directives to look for, matched in a case-
+// insensitive manner. nil implies DefaultRobotsDirectives. mode specifies
+// how to handle the responses carrying them.
+func CheckRobots(mode ValidationMode, directives []string) processor.Processor {
+	if directives == nil {
+		directives = DefaultRobotsDirectives
+	}
+	c := &robotsChecker{make(map[string]bool)}
+	for _, d := range directives {
+		c.directives[strings.ToLower(d)] = true
+	}
+	return newChecker(mode, "robots directive against distribution", c.check)
+}
+
+type robotsChecker struct {
+	directives map[string]bool
+}
+
+func (c *robotsChecker) check(resp *exchange.Response) ([]string, error) {
+	var problems []string
+	for _, v := range resp.Header.Values(xRobotsTag) {
+		for _, d := range c.findDirectives(v) {
+			problems = append(problems, fmt.Sprintf("%s: %s", xRobotsTag, d))
+		}
+	}
+	if isHTML(resp) {
+		doc, err := htmldoc.NewDocument(resp.Payload, resp.Request.URL)
+		if err != nil {
+			return nil, err
+		}
+		htmldoc.Traverse(doc.Root, func(n *html.Node) error {
+			if n.Type != html.ElementNode || n.DataAtom != atom.Meta {
+				return nil
+			}
+			if !strings.EqualFold(strings.TrimSpace(htmldoc.GetAttr(n, "name")), "robots") {
+				return nil
+			}
+			for _, d := range c.findDirectives(htmldoc.GetAttr(n, "content")) {
+				problems = append(problems, fmt.Sprintf(`<meta name="robots">: %s`, d))
+			}
+			return nil
+		})
+	}
+	return problems, nil
+}
+
+// findDirectives returns the directives in value, a comma-separated list
+// of robots directives, that c looks for.
+func (c *robotsChecker) findDirectives(value string) []string {
+	var found []string
+	for _, token := range strings.Split(value, ",") {
+		d := strings.ToLower(strings.TrimSpace(token))
+		// Strip the crawler name, e.g. "googlebot: noindex". Directives
+		// with a value (e.g. "max-snippet: 20") do not match anyway.
+		if i := strings.IndexByte(d, ':'); i >= 0 {
+			d = strings.TrimSpace(d[i+1:])
+		}
+		switch {
+		case c.directives[d]:
+			found = append(found, d)
+		case d == "none" && c.directives["noindex"]:
+			found = append(found, d)
+		}
+	}
+	return found
+}
+
+func isHTML(resp *exchange.Response) bool {
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil && err != mime.ErrInvalidMediaParameter {
+		return false
+	}
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
@@ -0,0 +1,128 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package htmlproc_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/layer0-platform/webpackager/exchange/exchangetest"
+	"github.com/layer0-platform/webpackager/processor/htmlproc"
+)
+
+func TestCheckRobots(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		header      string
+		body        string
+		directives  []string
+		wantErr     bool
+	}{
+		{
+			name:        "NoDirectives",
+			contentType: "text/html",
+			body:        `<!doctype html><meta name="description" content="noindex">`,
+			wantErr:     false,
+		},
+		{
+			name:        "MetaNoIndex",
+			contentType: "text/html",
+			body:        `<!doctype html><meta name="robots" content="noindex, nofollow">`,
+			wantErr:     true,
+		},
+		{
+			name:        "MetaNoArchive",
+			contentType: "text/html; charset=utf-8",
+			body:        `<!doctype html><meta name="ROBOTS" content="NoArchive">`,
+			wantErr:     true,
+		},
+		{
+			name:        "MetaNone",
+			contentType: "text/html",
+			body:        `<!doctype html><meta name="robots" content="none">`,
+			wantErr:     true,
+		},
+		{
+			name:        "MetaNoFollowOnly",
+			contentType: "text/html",
+			body:        `<!doctype html><meta name="robots" content="nofollow">`,
+			wantErr:     false,
+		},
+		{
+			name:        "Header",
+			contentType: "image/png",
+			header:      "X-Robots-Tag: noindex\r\n",
+			body:        "",
+			wantErr:     true,
+		},
+		{
+			name:        "HeaderForCrawler",
+			contentType: "text/html",
+			header:      "X-Robots-Tag: googlebot: noarchive\r\n",
+			body:        `<!doctype html>`,
+			wantErr:     true,
+		},
+		{
+			name:        "HeaderWithValue",
+			contentType: "text/html",
+			header:      "X-Robots-Tag: max-snippet: 20, unavailable_after: 2020-05-01\r\n",
+			body:        `<!doctype html>`,
+			wantErr:     false,
+		},
+		{
+			name:        "MetaInNonHTML",
+			contentType: "text/plain",
+			body:        `<meta name="robots" content="noindex">`,
+			wantErr:     false,
+		},
+		{
+			name:        "CustomDirectives",
+			contentType: "text/html",
+			body:        `<!doctype html><meta name="robots" content="noindex, nosnippet">`,
+			directives:  []string{"nosnippet"},
+			wantErr:     true,
+		},
+		{
+			name:        "CustomDirectives_NoMatch",
+			contentType: "text/html",
+			body:        `<!doctype html><meta name="robots" content="noindex">`,
+			directives:  []string{"nosnippet"},
+			wantErr:     false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, mode := range []htmlproc.ValidationMode{htmlproc.ValidationOff, htmlproc.ValidationReport, htmlproc.ValidationFail} {
+				proc := htmlproc.CheckRobots(mode, test.directives)
+				resp := exchangetest.MakeResponse("https://example.com/test", fmt.Sprint(
+					"HTTP/1.1 200 OK\r\n",
+					"Content-Type: ", test.contentType, "\r\n",
+					test.header,
+					"\r\n",
+					test.body))
+				err := proc.Process(resp)
+				if mode == htmlproc.ValidationFail && test.wantErr {
+					if err == nil {
+						t.Errorf("mode %v: got success, want error", mode)
+					}
+				} else if err != nil {
+					t.Errorf("mode %v: got error(%q), want success", mode, err)
+				}
+			}
+		})
+	}
+}
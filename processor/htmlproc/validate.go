@@ -0,0 +1,205 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package htmlproc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/layer0-platform/webpackager/exchange"
+	"github.com/layer0-platform/webpackager/processor"
+	"github.com/layer0-platform/webpackager/processor/htmlproc/htmldoc"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// ValidationMode specifies how a check handles the problems it finds, such
+// as serious errors in HTML documents (see Config.Validation), which the
+// parser otherwise recovers from silently. The checks never modify
+// responses.
+type ValidationMode int
+
+const (
+	// ValidationOff disables the check.
+	ValidationOff ValidationMode = iota
+	// ValidationReport logs the problems as warnings and continues.
+	ValidationReport
+	// ValidationFail makes the processor fail on the problems, so
+	// the responses are not signed.
+	ValidationFail
+)
+
+// report handles problems, found in the response for u, as specified by
+// mode. what describes the kind of the problems, e.g. "invalid HTML".
+func (mode ValidationMode) report(u *url.URL, what string, problems []string) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	switch mode {
+	case ValidationFail:
+		return fmt.Errorf("%s: %s", what, strings.Join(problems, "; "))
+	case ValidationReport:
+		for _, p := range problems {
+			log.Printf("warning: %v: %s: %s", u, what, p)
+		}
+	}
+	return nil
+}
+
+// checker is a Processor running check on responses and handling the
+// problems it returns as specified by mode.
+type checker struct {
+	mode  ValidationMode
+	what  string
+	check func(resp *exchange.Response) ([]string, error)
+}
+
+func newChecker(mode ValidationMode, what string, check func(resp *exchange.Response) ([]string, error)) processor.Processor {
+	return &checker{mode, what, check}
+}
+
+func (c *checker) Process(resp *exchange.Response) error {
+	if c.mode == ValidationOff {
+		return nil
+	}
+	problems, err := c.check(resp)
+	if err != nil {
+		return err
+	}
+	return c.mode.report(resp.Request.URL, c.what, problems)
+}
+
+// Elements whose end tags are required and whose absence swallows or
+// misplaces the content following them.
+var criticalElements = map[atom.Atom]bool{
+	atom.A:        true,
+	atom.Div:      true,
+	atom.Form:     true,
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Table:    true,
+	atom.Template: true,
+	atom.Textarea: true,
+	atom.Title:    true,
+}
+
+// Elements allowed in <head>. Any other element implicitly closes <head>.
+var headElements = map[atom.Atom]bool{
+	atom.Base:     true,
+	atom.Link:     true,
+	atom.Meta:     true,
+	atom.Noscript: true,
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Template: true,
+	atom.Title:    true,
+}
+
+// validateHTML checks payload and its parse tree for unclosed critical
+// elements, invalid nesting in <head>, and duplicate IDs. It returns
+// the descriptions of the errors found.
+func validateHTML(payload []byte, doc *htmldoc.Document) []string {
+	problems := checkTokens(payload)
+
+	seen := make(map[string]bool)
+	htmldoc.Traverse(doc.Root, func(n *html.Node) error {
+		if n.Type != html.ElementNode {
+			return nil
+		}
+		id := htmldoc.GetAttr(n, "id")
+		if id == "" {
+			return nil
+		}
+		if seen[id] {
+			problems = append(problems, fmt.Sprintf("duplicate id %q", id))
+		}
+		seen[id] = true
+		return nil
+	})
+
+	return problems
+}
+
+func checkTokens(payload []byte) []string {
+	var problems []string
+	var order []atom.Atom
+	opened := make(map[atom.Atom]int)
+	inHead := false
+	inTemplate := 0
+
+	z := html.NewTokenizer(bytes.NewReader(payload))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if z.Err() != io.EOF {
+				problems = append(problems, z.Err().Error())
+			}
+			break
+		}
+		tok := z.Token()
+
+		switch tt {
+		case html.StartTagToken:
+			if criticalElements[tok.DataAtom] {
+				if opened[tok.DataAtom] == 0 {
+					order = append(order, tok.DataAtom)
+				}
+				opened[tok.DataAtom]++
+			}
+			if tok.DataAtom == atom.Template {
+				inTemplate++
+			}
+		case html.EndTagToken:
+			if criticalElements[tok.DataAtom] && opened[tok.DataAtom] > 0 {
+				opened[tok.DataAtom]--
+			}
+			if tok.DataAtom == atom.Template && inTemplate > 0 {
+				inTemplate--
+			}
+		}
+
+		switch {
+		case tt == html.StartTagToken && tok.DataAtom == atom.Head:
+			inHead = true
+		case tt == html.StartTagToken && tok.DataAtom == atom.Body,
+			tt == html.EndTagToken && tok.DataAtom == atom.Head:
+			inHead = false
+		case inHead && inTemplate == 0 && (tt == html.StartTagToken || tt == html.SelfClosingTagToken):
+			if !headElements[tok.DataAtom] {
+				problems = append(problems, fmt.Sprintf("<%s> not allowed in <head>", tok.Data))
+				inHead = false
+			}
+		}
+	}
+
+	for _, a := range order {
+		if n := opened[a]; n > 0 {
+			problems = append(problems, fmt.Sprintf("%d unclosed <%s>", n, a))
+		}
+	}
+	return problems
+}
+
+func (hp *htmlProcessor) validate(resp *htmldoc.HTMLResponse) error {
+	if hp.Validation == ValidationOff {
+		return nil
+	}
+	problems := validateHTML(resp.Payload, resp.Doc)
+	return hp.Validation.report(resp.Request.URL, "invalid HTML", problems)
+}
@@ -0,0 +1,111 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package htmlproc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/layer0-platform/webpackager/exchange"
+	"github.com/layer0-platform/webpackager/processor"
+	"github.com/layer0-platform/webpackager/processor/htmlproc/htmldoc"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// CheckViewport creates a Processor to check, in mode, that HTML documents
+// declare a viewport suitable for mobile devices with <meta name="viewport">
+// in the <head>. The viewport is considered suitable if it has
+// "width=device-width", or if it has "initial-scale" without "width",
+// which browsers treat alike. Documents lacking it render poorly on
+// mobile devices, where signed exchanges are typically served.
+func CheckViewport(mode ValidationMode) processor.Processor {
+	p := newChecker(mode, "unsuitable viewport", func(resp *exchange.Response) ([]string, error) {
+		doc, err := htmldoc.NewDocument(resp.Payload, resp.Request.URL)
+		if err != nil {
+			return nil, err
+		}
+		if problem := checkViewport(doc); problem != "" {
+			return []string{problem}, nil
+		}
+		return nil, nil
+	})
+	return processor.MultiplexedProcessor{
+		"text/html":             p,
+		"application/xhtml+xml": p,
+	}
+}
+
+// checkViewport describes why doc lacks a suitable viewport, or returns
+// empty if it has one. The last <meta name="viewport"> in the <head> takes
+// effect.
+func checkViewport(doc *htmldoc.Document) string {
+	head := htmldoc.FindNode(doc.Root, atom.Head)
+	if head == nil {
+		return `no <meta name="viewport"> in <head>`
+	}
+	var content *string
+	htmldoc.Traverse(head, func(n *html.Node) error {
+		if n.Type != html.ElementNode || n.DataAtom != atom.Meta {
+			return nil
+		}
+		if !strings.EqualFold(strings.TrimSpace(htmldoc.GetAttr(n, "name")), "viewport") {
+			return nil
+		}
+		s := htmldoc.GetAttr(n, "content")
+		content = &s
+		return nil
+	})
+	if content == nil {
+		return `no <meta name="viewport"> in <head>`
+	}
+
+	props := parseViewport(*content)
+	width, hasWidth := props["width"]
+	_, hasScale := props["initial-scale"]
+	switch {
+	case hasWidth && strings.EqualFold(width, "device-width"):
+		return ""
+	case !hasWidth && hasScale:
+		return ""
+	case hasWidth:
+		return fmt.Sprintf("width=%s, not device-width", width)
+	default:
+		return fmt.Sprintf("neither width nor initial-scale in %q", *content)
+	}
+}
+
+// parseViewport parses the content of <meta name="viewport">, a list of
+// key=value pairs separated by commas (or semicolons, which browsers
+// tolerate), into a map keyed by the lowercased keys.
+func parseViewport(content string) map[string]string {
+	props := make(map[string]string)
+	fields := strings.FieldsFunc(content, func(r rune) bool {
+		return r == ',' || r == ';'
+	})
+	for _, f := range fields {
+		kv := strings.SplitN(f, "=", 2)
+		key := strings.ToLower(strings.TrimSpace(kv[0]))
+		if key == "" {
+			continue
+		}
+		value := ""
+		if len(kv) == 2 {
+			value = strings.TrimSpace(kv[1])
+		}
+		props[key] = value
+	}
+	return props
+}
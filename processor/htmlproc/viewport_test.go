@@ -0,0 +1,108 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package htmlproc_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/layer0-platform/webpackager/exchange/exchangetest"
+	"github.com/layer0-platform/webpackager/processor/htmlproc"
+)
+
+func TestCheckViewport(t *testing.T) {
+	tests := []struct {
+		name    string
+		html    string
+		wantErr bool
+	}{
+		{
+			name:    "DeviceWidth",
+			html:    `<!doctype html><meta name="viewport" content="width=device-width, initial-scale=1">`,
+			wantErr: false,
+		},
+		{
+			name:    "InitialScaleOnly",
+			html:    `<!doctype html><meta name="viewport" content="initial-scale=1.0">`,
+			wantErr: false,
+		},
+		{
+			name:    "CaseAndSemicolons",
+			html:    `<!doctype html><head><META NAME="Viewport" content="Width = Device-Width; user-scalable=no"></head>`,
+			wantErr: false,
+		},
+		{
+			name:    "Missing",
+			html:    `<!doctype html><head><title>Hello</title></head><body>Hello</body>`,
+			wantErr: true,
+		},
+		{
+			name:    "FixedWidth",
+			html:    `<!doctype html><meta name="viewport" content="width=1024, initial-scale=1">`,
+			wantErr: true,
+		},
+		{
+			name:    "NoWidthNorScale",
+			html:    `<!doctype html><meta name="viewport" content="user-scalable=no">`,
+			wantErr: true,
+		},
+		{
+			name:    "InBody",
+			html:    `<!doctype html><body><p>Hello</p><meta name="viewport" content="width=device-width"></body>`,
+			wantErr: true,
+		},
+		{
+			name: "LastWins",
+			html: fmt.Sprint(
+				`<!doctype html><head>`,
+				`<meta name="viewport" content="width=device-width">`,
+				`<meta name="viewport" content="width=980">`,
+				`</head>`,
+			),
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, mode := range []htmlproc.ValidationMode{htmlproc.ValidationOff, htmlproc.ValidationReport, htmlproc.ValidationFail} {
+				proc := htmlproc.CheckViewport(mode)
+				resp := makeResponse("https://example.com/test.html", test.html)
+				err := proc.Process(resp)
+				if mode == htmlproc.ValidationFail && test.wantErr {
+					if err == nil {
+						t.Errorf("mode %v: got success, want error", mode)
+					}
+				} else if err != nil {
+					t.Errorf("mode %v: got error(%q), want success", mode, err)
+				}
+				if got := string(resp.Payload); got != test.html {
+					t.Errorf("mode %v: payload = %q, want %q", mode, got, test.html)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckViewport_NonHTML(t *testing.T) {
+	resp := exchangetest.MakeResponse("https://example.com/test.txt", fmt.Sprint(
+		"HTTP/1.1 200 OK\r\n",
+		"Content-Type: text/plain\r\n",
+		"\r\n",
+		"Hello, world!"))
+	if err := htmlproc.CheckViewport(htmlproc.ValidationFail).Process(resp); err != nil {
+		t.Errorf("got error(%q), want success", err)
+	}
+}
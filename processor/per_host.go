@@ -0,0 +1,42 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"strings"
+
+	"github.com/layer0-platform/webpackager/exchange"
+)
+
+// PerHostProcessor selects the processor based on the host of the request
+// URL. The keys of Hosts should be all in lowercase and include no port
+// numbers (e.g. "example.com", not "Example.COM" or "example.com:443").
+type PerHostProcessor struct {
+	// Hosts maps host names to processors.
+	Hosts map[string]Processor
+
+	// Default is used for the hosts not found in Hosts.
+	Default Processor
+}
+
+// Process invokes the processor for the host of resp.Request.URL, or
+// p.Default if Hosts has no entry for the host.
+func (p *PerHostProcessor) Process(resp *exchange.Response) error {
+	host := strings.ToLower(resp.Request.URL.Hostname())
+	if proc, ok := p.Hosts[host]; ok {
+		return proc.Process(resp)
+	}
+	return p.Default.Process(resp)
+}
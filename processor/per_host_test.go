@@ -0,0 +1,62 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/layer0-platform/webpackager/exchange/exchangetest"
+	"github.com/layer0-platform/webpackager/processor"
+)
+
+func TestPerHostProcessor(t *testing.T) {
+	p := &processor.PerHostProcessor{
+		Hosts: map[string]processor.Processor{
+			"static.example.com": newTestingProcessor("static"),
+		},
+		Default: newTestingProcessor("default"),
+	}
+
+	tests := []struct {
+		url  string
+		want []string
+	}{
+		{
+			url:  "https://static.example.com/style.css",
+			want: []string{"static"},
+		},
+		{
+			url:  "https://STATIC.example.com:8443/style.css",
+			want: []string{"static"},
+		},
+		{
+			url:  "https://www.example.com/index.html",
+			want: []string{"default"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			resp := exchangetest.MakeEmptyResponse(test.url)
+			if err := p.Process(resp); err != nil {
+				t.Errorf("got error(%q), want success", err)
+			}
+			if diff := cmp.Diff(test.want, resp.Header["X-Testing"]); diff != "" {
+				t.Errorf("resp.Header[\"X-Testing\"] mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
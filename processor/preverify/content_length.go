@@ -16,25 +16,73 @@ package preverify
 
 import (
 	"fmt"
+	"strconv"
 
 	"github.com/layer0-platform/webpackager/exchange"
 	"github.com/layer0-platform/webpackager/processor"
 )
 
 // MaxContentLength requires the content (the response body) to be not
-// larger then limit.
+// larger then limit. It fails with ContentSizeError otherwise.
 func MaxContentLength(limit int) processor.Processor {
-	return &maxContentLength{limit}
+	return &maxContentLength{limit, false}
+}
+
+// SkipOversizedContent is like MaxContentLength, but wraps ContentSizeError
+// in SkipError, so the oversized resources are skipped with a warning rather
+// than failing.
+func SkipOversizedContent(limit int) processor.Processor {
+	return &maxContentLength{limit, true}
 }
 
 type maxContentLength struct {
 	limit int
+	skip  bool
 }
 
 func (mcl *maxContentLength) Process(resp *exchange.Response) error {
-	if len(resp.Payload) > mcl.limit {
-		return fmt.Errorf("oversized content (%d bytes; limit: %d bytes)",
-			len(resp.Payload), mcl.limit)
+	if len(resp.Payload) <= mcl.limit {
+		return nil
+	}
+	err := NewContentSizeError(len(resp.Payload), mcl.limit)
+	if mcl.skip {
+		return NewSkipError(SkipReasonSizeLimit, err)
+	}
+	return err
+}
+
+// ContentLengthMatch ensures the response to have Content-Length equal to
+// the actual length of the payload, if Content-Length is present. Its Process
+// method returns a ContentLengthError on mismatch. It guards against signing
+// truncated or padded responses.
+var ContentLengthMatch processor.Processor = &contentLengthMatch{fix: false}
+
+// FixContentLength is like ContentLengthMatch, but corrects Content-Length
+// to the actual length of the payload instead of returning an error.
+var FixContentLength processor.Processor = &contentLengthMatch{fix: true}
+
+type contentLengthMatch struct {
+	fix bool
+}
+
+func (clm *contentLengthMatch) Process(resp *exchange.Response) error {
+	value := resp.Header.Get("Content-Length")
+	if value == "" {
+		return nil
+	}
+	actual := len(resp.Payload)
+	declared, err := strconv.Atoi(value)
+	if err == nil && declared == actual {
+		return nil
+	}
+	if clm.fix {
+		resp.Header.Set("Content-Length", strconv.Itoa(actual))
+		resp.ExtraData.Add(exchange.AppliedTransformation,
+			fmt.Sprintf("corrected Content-Length from %q to %d", value, actual))
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("invalid Content-Length %q", value)
 	}
-	return nil
+	return NewContentLengthError(declared, actual)
 }
@@ -15,6 +15,7 @@
 package preverify_test
 
 import (
+	"errors"
 	"fmt"
 	"testing"
 
@@ -135,3 +136,114 @@ func TestMaxContentLength_Error(t *testing.T) {
 		})
 	}
 }
+
+func TestSkipOversizedContent(t *testing.T) {
+	resp := exchangetest.MakeResponse("https://example.org/hello.html", fmt.Sprint(
+		"HTTP/1.1 200 OK\r\n",
+		"Content-Type: text/html; charset=utf-8\r\n",
+		"\r\n",
+		"<!doctype html><p>abcdefghijklmnopqrstuvwxyz!</p>",
+	))
+	err := preverify.SkipOversizedContent(48).Process(resp)
+	var skipErr *preverify.SkipError
+	if !errors.As(err, &skipErr) {
+		t.Fatalf("got %v, want SkipError", err)
+	}
+	if skipErr.Reason != preverify.SkipReasonSizeLimit {
+		t.Errorf("Reason = %q, want %q", skipErr.Reason, preverify.SkipReasonSizeLimit)
+	}
+	var sizeErr *preverify.ContentSizeError
+	if !errors.As(err, &sizeErr) {
+		t.Fatalf("got %v, want ContentSizeError", err)
+	}
+	if want := (preverify.ContentSizeError{Size: 49, Limit: 48}); *sizeErr != want {
+		t.Errorf("got %+v, want %+v", *sizeErr, want)
+	}
+
+	if err := preverify.SkipOversizedContent(49).Process(resp); err != nil {
+		t.Errorf("got error(%q), want success", err)
+	}
+}
+
+func TestContentLengthMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		resp    string
+		wantErr *preverify.ContentLengthError
+	}{
+		{
+			name: "Matching",
+			resp: fmt.Sprint(
+				"HTTP/1.1 200 OK\r\n",
+				"Content-Length: 35\r\n",
+				"Content-Type: text/html; charset=utf-8\r\n",
+				"\r\n",
+				"<!doctype html><p>Hello, world!</p>",
+			),
+			wantErr: nil,
+		},
+		{
+			name: "NoContentLengthHeader",
+			resp: fmt.Sprint(
+				"HTTP/1.1 200 OK\r\n",
+				"Content-Type: text/html; charset=utf-8\r\n",
+				"\r\n",
+				"<!doctype html><p>Hello, world!</p>",
+			),
+			wantErr: nil,
+		},
+		{
+			name: "Truncated",
+			resp: fmt.Sprint(
+				"HTTP/1.1 200 OK\r\n",
+				"Content-Length: 35\r\n",
+				"Content-Type: text/html; charset=utf-8\r\n",
+				"\r\n",
+				"<!doctype html><p>Hello, world!</p>",
+			),
+			wantErr: &preverify.ContentLengthError{Declared: 48, Actual: 35},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := exchangetest.MakeResponse("https://example.org/hello.html", test.resp)
+			// Simulate the origin having sent a wrong Content-Length.
+			if test.wantErr != nil {
+				resp.Header.Set("Content-Length", fmt.Sprint(test.wantErr.Declared))
+			}
+			err := preverify.ContentLengthMatch.Process(resp)
+			if test.wantErr == nil {
+				if err != nil {
+					t.Errorf("got error(%q), want success", err)
+				}
+				return
+			}
+			var got *preverify.ContentLengthError
+			if !errors.As(err, &got) {
+				t.Fatalf("got %v, want ContentLengthError", err)
+			}
+			if *got != *test.wantErr {
+				t.Errorf("got %+v, want %+v", *got, *test.wantErr)
+			}
+		})
+	}
+}
+
+func TestFixContentLength(t *testing.T) {
+	resp := exchangetest.MakeResponse("https://example.org/hello.html", fmt.Sprint(
+		"HTTP/1.1 200 OK\r\n",
+		"Content-Length: 35\r\n",
+		"Content-Type: text/html; charset=utf-8\r\n",
+		"\r\n",
+		"<!doctype html><p>Hello, world!</p>",
+	))
+	resp.Header.Set("Content-Length", "48")
+
+	if err := preverify.FixContentLength.Process(resp); err != nil {
+		t.Fatalf("got error(%q), want success", err)
+	}
+	if got := resp.Header.Get("Content-Length"); got != "35" {
+		t.Errorf(`resp.Header.Get("Content-Length") = %q, want %q`, got, "35")
+	}
+}
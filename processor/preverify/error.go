@@ -16,20 +16,154 @@ package preverify
 
 import (
 	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/layer0-platform/webpackager/exchange"
 )
 
 // HTTPStatusError represents an HTTP status error.
 type HTTPStatusError struct {
 	// StatusCode represents the HTTP status code returned.
 	StatusCode int
+
+	// Header and Body represent the response returned, so it can be
+	// relayed to the client (e.g. by webpkgserver). They are nil if the
+	// response is not known, and are not reflected in Error.
+	Header http.Header
+	Body   []byte
 }
 
 // NewHTTPStatusError creates a new HTTPStatusError.
 func NewHTTPStatusError(statusCode int) *HTTPStatusError {
-	return &HTTPStatusError{statusCode}
+	return &HTTPStatusError{StatusCode: statusCode}
+}
+
+// NewHTTPStatusErrorForResponse creates a new HTTPStatusError carrying
+// the status code, the header, and the payload of resp.
+func NewHTTPStatusErrorForResponse(resp *exchange.Response) *HTTPStatusError {
+	return &HTTPStatusError{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header.Clone(),
+		Body:       resp.Payload,
+	}
 }
 
 // Error implements the error interface.
 func (e *HTTPStatusError) Error() string {
 	return fmt.Sprintf("server responded with status code %d", e.StatusCode)
 }
+
+// ContentLengthError represents a mismatch between the Content-Length and
+// the actual length of the payload.
+type ContentLengthError struct {
+	// Declared represents the value of Content-Length.
+	Declared int
+	// Actual represents the actual length of the payload.
+	Actual int
+}
+
+// NewContentLengthError creates and initializes a new ContentLengthError.
+func NewContentLengthError(declared, actual int) *ContentLengthError {
+	return &ContentLengthError{declared, actual}
+}
+
+func (e *ContentLengthError) Error() string {
+	return fmt.Sprintf("Content-Length (%d bytes) mismatches the payload (%d bytes)",
+		e.Declared, e.Actual)
+}
+
+// ContentSizeError represents the content (the response body) larger than
+// the limit. See MaxContentLength.
+type ContentSizeError struct {
+	// Size represents the size of the content in bytes.
+	Size int
+	// Limit represents the maximum size allowed.
+	Limit int
+}
+
+// NewContentSizeError creates and initializes a new ContentSizeError.
+func NewContentSizeError(size, limit int) *ContentSizeError {
+	return &ContentSizeError{size, limit}
+}
+
+func (e *ContentSizeError) Error() string {
+	return fmt.Sprintf("oversized content (%d bytes; limit: %d bytes)", e.Size, e.Limit)
+}
+
+// These are the SkipError reasons for the Processors in this package.
+const (
+	// SkipReasonSizeLimit is the reason for oversized content.
+	SkipReasonSizeLimit = "size limit"
+	// SkipReasonNoJS is the reason for JavaScript refused by RejectJS.
+	SkipReasonNoJS = "no_js"
+)
+
+// SkipError indicates the response should be skipped rather than fail:
+// webpackager.Packager logs a warning and produces no signed exchange for
+// the resource, without reporting an error. See Packager.SkipCounts.
+type SkipError struct {
+	// Reason describes briefly why the response is skipped, e.g. "size
+	// limit", to summarize the skipped resources.
+	Reason string
+	// Err represents the underlying error.
+	Err error
+}
+
+// NewSkipError creates and initializes a new SkipError.
+func NewSkipError(reason string, err error) *SkipError {
+	return &SkipError{reason, err}
+}
+
+func (e *SkipError) Error() string {
+	return fmt.Sprintf("%v (skipped due to %s)", e.Err, e.Reason)
+}
+
+// Unwrap returns the underlying error.
+func (e *SkipError) Unwrap() error {
+	return e.Err
+}
+
+// UTF8Error represents an invalid UTF-8 sequence in a text payload.
+type UTF8Error struct {
+	// Offset represents the byte offset of the first invalid sequence.
+	Offset int
+}
+
+// NewUTF8Error creates and initializes a new UTF8Error.
+func NewUTF8Error(offset int) *UTF8Error {
+	return &UTF8Error{offset}
+}
+
+func (e *UTF8Error) Error() string {
+	return fmt.Sprintf("invalid UTF-8 sequence at byte offset %d", e.Offset)
+}
+
+// HeaderSizeError represents the response headers too large to sign.
+type HeaderSizeError struct {
+	// Size represents the size of the response headers in bytes.
+	Size int
+	// Limit represents the maximum size allowed.
+	Limit int
+	// Fields breaks down Size by header names, largest first.
+	Fields []HeaderFieldSize
+}
+
+// HeaderFieldSize represents the total size of the header fields with
+// the same name.
+type HeaderFieldSize struct {
+	Name string
+	Size int
+}
+
+func (e *HeaderSizeError) Error() string {
+	var largest []string
+	for i, f := range e.Fields {
+		if i == 3 {
+			break
+		}
+		largest = append(largest, fmt.Sprintf("%s (%d bytes)", f.Name, f.Size))
+	}
+	return fmt.Sprintf("oversized response headers (%d bytes; limit: %d bytes); largest: %s",
+		e.Size, e.Limit, strings.Join(largest, ", "))
+}
@@ -0,0 +1,86 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package preverify
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/layer0-platform/webpackager/exchange"
+	"github.com/layer0-platform/webpackager/processor"
+)
+
+// integrityLength is the length of the header-integrity parameter in
+// allowed-alt-sxg links ("sha256-" followed by 44 characters of base64).
+const integrityLength = 51
+
+// MaxHeaderSize requires the response headers to be not larger than limit
+// when signed, in bytes. Its Process method returns a HeaderSizeError
+// otherwise.
+//
+// The size is the total length of the header names and values. It includes
+// the preload links in resp.Preloads, as well as the allowed-alt-sxg links
+// added for the preloaded resources once they are signed; those are counted
+// even if the preloaded resources fail to be signed, since it is not known
+// yet. MaxHeaderSize is meant to run after the main processor, e.g. as part
+// of complexproc.Config.CustomPostprocessors, so the preloads added by the
+// main processor are taken into account.
+func MaxHeaderSize(limit int) processor.Processor {
+	return &maxHeaderSize{limit}
+}
+
+type maxHeaderSize struct {
+	limit int
+}
+
+func (mhs *maxHeaderSize) Process(resp *exchange.Response) error {
+	sizes := make(map[string]int)
+	total := 0
+	add := func(key string, valueLen int) {
+		key = http.CanonicalHeaderKey(key)
+		sizes[key] += len(key) + valueLen
+		total += len(key) + valueLen
+	}
+	for key, values := range resp.Header {
+		for _, value := range values {
+			add(key, len(value))
+		}
+	}
+	for _, p := range resp.Preloads {
+		add("Link", len(p.Link.String()))
+		for _, r := range p.Resources {
+			valueLen := len(r.AllowedAltSXGHeader())
+			if r.Integrity == "" {
+				valueLen += integrityLength
+			}
+			add("Link", valueLen)
+		}
+	}
+	if total <= mhs.limit {
+		return nil
+	}
+
+	fields := make([]HeaderFieldSize, 0, len(sizes))
+	for key, size := range sizes {
+		fields = append(fields, HeaderFieldSize{key, size})
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		if fields[i].Size != fields[j].Size {
+			return fields[i].Size > fields[j].Size
+		}
+		return fields[i].Name < fields[j].Name
+	})
+	return &HeaderSizeError{Size: total, Limit: mhs.limit, Fields: fields}
+}
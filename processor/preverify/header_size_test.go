@@ -0,0 +1,108 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package preverify_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/layer0-platform/webpackager/exchange/exchangetest"
+	"github.com/layer0-platform/webpackager/processor/preverify"
+	"github.com/layer0-platform/webpackager/resource/preload/preloadtest"
+)
+
+func TestMaxHeaderSize(t *testing.T) {
+	// "Content-Type" + "text/html" = 21 bytes.
+	// "Cache-Control" + "public, max-age=1209600" = 36 bytes.
+	// "Link" + `<https://example.org/style.css>;rel="preload";as="style"` = 60 bytes.
+	// "Link" + `<https://example.org/style.css>;rel="allowed-alt-sxg";header-integrity="sha256-..."` = 128 bytes.
+	tests := []struct {
+		name     string
+		limit    int
+		preloads []string
+		wantErr  *preverify.HeaderSizeError
+	}{
+		{
+			name:    "NoPreloads",
+			limit:   57,
+			wantErr: nil,
+		},
+		{
+			name:     "WithPreloads",
+			limit:    245,
+			preloads: []string{"https://example.org/style.css"},
+			wantErr:  nil,
+		},
+		{
+			name:     "TooManyPreloads",
+			limit:    245,
+			preloads: []string{"https://example.org/style.css", "https://example.org/style.css?v=2"},
+			wantErr: &preverify.HeaderSizeError{
+				Size:  441,
+				Limit: 245,
+				Fields: []preverify.HeaderFieldSize{
+					{Name: "Link", Size: 384},
+					{Name: "Cache-Control", Size: 36},
+					{Name: "Content-Type", Size: 21},
+				},
+			},
+		},
+		{
+			name:  "OneByteLarger",
+			limit: 56,
+			wantErr: &preverify.HeaderSizeError{
+				Size:  57,
+				Limit: 56,
+				Fields: []preverify.HeaderFieldSize{
+					{Name: "Cache-Control", Size: 36},
+					{Name: "Content-Type", Size: 21},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := exchangetest.MakeResponse(
+				"https://example.org/hello.html",
+				fmt.Sprint(
+					"HTTP/1.1 200 OK\r\n",
+					"Cache-Control: public, max-age=1209600\r\n",
+					"Content-Type: text/html\r\n",
+					"\r\n",
+					"<!doctype html><p>Hello, world!</p>",
+				))
+			for _, u := range test.preloads {
+				resp.AddPreload(preloadtest.NewPreloadForRawURL(u, "style"))
+			}
+			err := preverify.MaxHeaderSize(test.limit).Process(resp)
+			if test.wantErr == nil {
+				if err != nil {
+					t.Errorf("got error(%q), want success", err)
+				}
+				return
+			}
+			var got *preverify.HeaderSizeError
+			if !errors.As(err, &got) {
+				t.Fatalf("got %v, want HeaderSizeError", err)
+			}
+			if diff := cmp.Diff(test.wantErr, got); diff != "" {
+				t.Errorf("error mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
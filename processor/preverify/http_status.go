@@ -25,7 +25,8 @@ import (
 var HTTPStatusOK = HTTPStatusCode(http.StatusOK)
 
 // HTTPStatusCode ensures the response to have one of the provided HTTP
-// status codes. Its Process method returns an HTTPStatusError on error.
+// status codes. Its Process method returns an HTTPStatusError on error,
+// carrying the response.
 func HTTPStatusCode(expectedCodes ...int) processor.Processor {
 	expectedCodeSet := make(map[int]bool, len(expectedCodes))
 	for _, code := range expectedCodes {
@@ -40,7 +41,7 @@ type httpStatusCode struct {
 
 func (h *httpStatusCode) Process(resp *exchange.Response) error {
 	if !h.expected[resp.StatusCode] {
-		return NewHTTPStatusError(resp.StatusCode)
+		return NewHTTPStatusErrorForResponse(resp)
 	}
 	return nil
 }
@@ -20,6 +20,7 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/layer0-platform/webpackager/exchange/exchangetest"
 	"github.com/layer0-platform/webpackager/processor"
 	"github.com/layer0-platform/webpackager/processor/preverify"
@@ -83,6 +84,7 @@ func TestHTTPStatusCode_Error(t *testing.T) {
 		proc processor.Processor
 		resp string
 		err  error
+		body string
 	}{
 		{
 			name: "NoContent_Excluded",
@@ -95,7 +97,8 @@ func TestHTTPStatusCode_Error(t *testing.T) {
 				"Cache-Control: public, max-age=1209600\r\n",
 				"\r\n",
 			),
-			err: preverify.NewHTTPStatusError(204),
+			err:  preverify.NewHTTPStatusError(204),
+			body: "",
 		},
 		{
 			name: "NotFound",
@@ -112,16 +115,29 @@ func TestHTTPStatusCode_Error(t *testing.T) {
 				"\r\n",
 				"<!doctype html><p>404 Not Found</p>",
 			),
-			err: preverify.NewHTTPStatusError(404),
+			err:  preverify.NewHTTPStatusError(404),
+			body: "<!doctype html><p>404 Not Found</p>",
 		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			resp := exchangetest.MakeResponse(test.url, test.resp)
 			err := test.proc.Process(resp)
-			if diff := cmp.Diff(test.err, err); diff != "" {
+			// Compare on the status code; the response is checked below.
+			ignore := cmpopts.IgnoreFields(preverify.HTTPStatusError{}, "Header", "Body")
+			if diff := cmp.Diff(test.err, err, ignore); diff != "" {
 				t.Errorf("Process() = %v, want %v", err, test.err)
 			}
+			httpErr, ok := err.(*preverify.HTTPStatusError)
+			if !ok {
+				t.Fatalf("Process() = %#v, want *HTTPStatusError", err)
+			}
+			if got := string(httpErr.Body); got != test.body {
+				t.Errorf("Body = %q, want %q", got, test.body)
+			}
+			if got, want := httpErr.Header.Get("Cache-Control"), "public, max-age=1209600"; got != want {
+				t.Errorf(`Header.Get("Cache-Control") = %q, want %q`, got, want)
+			}
 		})
 	}
 }
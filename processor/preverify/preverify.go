@@ -15,7 +15,8 @@
 // Package preverify implements processors to verify that HTTP responses
 // can be distributed as signed exchanges. These processors do not mutate
 // the provided exchange.Response: they just inspect it and report an error
-// when it does not meet the criteria.
+// when it does not meet the criteria. The only exception is FixContentLength,
+// which corrects Content-Length in place.
 package preverify
 
 import (
@@ -36,8 +37,56 @@ type Config struct {
 	//
 	// Zero implies DefaultMaxContentLength; a negative implies "unlimited."
 	MaxContentLength int
+
+	// SizeLimitAction specifies how to handle responses larger than
+	// MaxContentLength.
+	//
+	// Zero (SizeLimitFail) implies to fail.
+	SizeLimitAction SizeLimitAction
+
+	// ContentLengthMismatch specifies how to handle responses whose
+	// Content-Length disagrees with the actual payload length.
+	//
+	// Zero (ContentLengthIgnore) implies no check.
+	ContentLengthMismatch ContentLengthPolicy
+
+	// RequireValidUTF8 instructs CheckPrerequisites to reject text responses
+	// whose payload is not well-formed UTF-8. See ValidUTF8 for details.
+	RequireValidUTF8 bool
+
+	// CustomProcessors are additional checks run after the built-in ones
+	// above. Like the built-in ones, they should report an error without
+	// mutating the response when it does not meet their criteria. The first
+	// error stops the subsequent checks.
+	CustomProcessors processor.SequentialProcessor
 }
 
+// ContentLengthPolicy represents how to handle responses whose Content-Length
+// disagrees with the actual payload length.
+type ContentLengthPolicy int
+
+const (
+	// ContentLengthIgnore does not check Content-Length.
+	ContentLengthIgnore ContentLengthPolicy = iota
+	// ContentLengthReject rejects the responses with ContentLengthMatch.
+	ContentLengthReject
+	// ContentLengthFix corrects Content-Length with FixContentLength.
+	ContentLengthFix
+)
+
+// SizeLimitAction represents how to handle responses larger than
+// Config.MaxContentLength.
+type SizeLimitAction int
+
+const (
+	// SizeLimitFail fails with ContentSizeError, using MaxContentLength.
+	SizeLimitFail SizeLimitAction = iota
+	// SizeLimitSkip skips the responses with a warning, using
+	// SkipOversizedContent. Useful in batch runs over sites with a few
+	// huge resources.
+	SizeLimitSkip
+)
+
 // The default value(s) used by Config.
 const (
 	DefaultMaxContentLength = 4194304 // 4 MiB
@@ -58,12 +107,31 @@ func CheckPrerequisites(config Config) processor.Processor {
 	}
 
 	if config.MaxContentLength >= 0 {
-		if config.MaxContentLength == 0 {
-			p = append(p, MaxContentLength(DefaultMaxContentLength))
+		limit := config.MaxContentLength
+		if limit == 0 {
+			limit = DefaultMaxContentLength
+		}
+		if config.SizeLimitAction == SizeLimitSkip {
+			p = append(p, SkipOversizedContent(limit))
 		} else {
-			p = append(p, MaxContentLength(config.MaxContentLength))
+			p = append(p, MaxContentLength(limit))
 		}
 	}
 
+	switch config.ContentLengthMismatch {
+	case ContentLengthReject:
+		p = append(p, ContentLengthMatch)
+	case ContentLengthFix:
+		p = append(p, FixContentLength)
+	}
+
+	if config.RequireValidUTF8 {
+		p = append(p, ValidUTF8)
+	}
+
+	if len(config.CustomProcessors) > 0 {
+		p = append(p, config.CustomProcessors)
+	}
+
 	return p
 }
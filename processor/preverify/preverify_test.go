@@ -0,0 +1,108 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package preverify_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/layer0-platform/webpackager/exchange"
+	"github.com/layer0-platform/webpackager/exchange/exchangetest"
+	"github.com/layer0-platform/webpackager/processor"
+	"github.com/layer0-platform/webpackager/processor/preverify"
+)
+
+type recordingProcessor struct {
+	name  string
+	err   error
+	calls *[]string
+}
+
+func (p *recordingProcessor) Process(resp *exchange.Response) error {
+	*p.calls = append(*p.calls, p.name)
+	return p.err
+}
+
+func TestCheckPrerequisites_CustomProcessors(t *testing.T) {
+	tests := []struct {
+		name      string
+		resp      string
+		errs      []error
+		wantCalls []string
+		wantErr   bool
+	}{
+		{
+			name: "AllPass",
+			resp: fmt.Sprint(
+				"HTTP/1.1 200 OK\r\n",
+				"Content-Type: text/plain\r\n",
+				"\r\n",
+				"Hello, world!",
+			),
+			errs:      []error{nil, nil},
+			wantCalls: []string{"first", "second"},
+			wantErr:   false,
+		},
+		{
+			name: "CustomFails",
+			resp: fmt.Sprint(
+				"HTTP/1.1 200 OK\r\n",
+				"Content-Type: text/plain\r\n",
+				"\r\n",
+				"Hello, world!",
+			),
+			errs:      []error{errors.New("rejected"), nil},
+			wantCalls: []string{"first"},
+			wantErr:   true,
+		},
+		{
+			name: "BuiltinFails",
+			resp: fmt.Sprint(
+				"HTTP/1.1 404 Not Found\r\n",
+				"Content-Type: text/plain\r\n",
+				"\r\n",
+				"Not found.",
+			),
+			errs:      []error{nil, nil},
+			wantCalls: nil,
+			wantErr:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var calls []string
+			config := preverify.Config{
+				CustomProcessors: processor.SequentialProcessor{
+					&recordingProcessor{"first", test.errs[0], &calls},
+					&recordingProcessor{"second", test.errs[1], &calls},
+				},
+			}
+			resp := exchangetest.MakeResponse("https://example.org/hello.txt", test.resp)
+			err := preverify.CheckPrerequisites(config).Process(resp)
+			if test.wantErr && err == nil {
+				t.Error("got success, want error")
+			}
+			if !test.wantErr && err != nil {
+				t.Errorf("got error(%q), want success", err)
+			}
+			if diff := cmp.Diff(test.wantCalls, calls); diff != "" {
+				t.Errorf("calls mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
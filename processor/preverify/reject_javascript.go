@@ -0,0 +1,52 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package preverify
+
+import (
+	"fmt"
+	"mime"
+
+	"github.com/layer0-platform/webpackager/exchange"
+	"github.com/layer0-platform/webpackager/exchange/vprule"
+	"github.com/layer0-platform/webpackager/processor"
+)
+
+// RejectJS refuses responses with a JavaScript media type, as identified by
+// vprule.JSMediaTypes. It is useful for sites consisting only of contents,
+// to eliminate the risk of scripts remaining cached until the expiry.
+// The error is a SkipError with SkipReasonNoJS, so the scripts are skipped
+// with a warning rather than failing the run.
+//
+// RejectJS examines only Content-Type, hence responses missing Content-Type
+// pass through unless commonproc.SniffContentType is run beforehand.
+var RejectJS processor.Processor = &rejectJS{}
+
+type rejectJS struct{}
+
+func (*rejectJS) Process(resp *exchange.Response) error {
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil && err != mime.ErrInvalidMediaParameter {
+		return nil
+	}
+	if vprule.IsJSMediaType(mediaType) {
+		err := fmt.Errorf("JavaScript (%s) not allowed to be signed", mediaType)
+		return NewSkipError(SkipReasonNoJS, err)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package preverify_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/layer0-platform/webpackager/exchange/exchangetest"
+	"github.com/layer0-platform/webpackager/processor/preverify"
+)
+
+func TestRejectJS(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		resp    string
+		wantErr bool
+	}{
+		{
+			name: "HTML",
+			url:  "https://example.org/hello.html",
+			resp: fmt.Sprint(
+				"HTTP/1.1 200 OK\r\n",
+				"Content-Type: text/html; charset=utf-8\r\n",
+				"\r\n",
+				"<!doctype html><p>Hello, world!</p>",
+			),
+			wantErr: false,
+		},
+		{
+			name: "NoContentType",
+			url:  "https://example.org/script.js",
+			resp: fmt.Sprint(
+				"HTTP/1.1 200 OK\r\n",
+				"\r\n",
+				"console.log('Hello, world!');",
+			),
+			wantErr: false,
+		},
+		{
+			name: "ApplicationJavaScript",
+			url:  "https://example.org/script.js",
+			resp: fmt.Sprint(
+				"HTTP/1.1 200 OK\r\n",
+				"Content-Type: application/javascript\r\n",
+				"\r\n",
+				"console.log('Hello, world!');",
+			),
+			wantErr: true,
+		},
+		{
+			name: "TextJavaScriptWithCharset",
+			url:  "https://example.org/script.js",
+			resp: fmt.Sprint(
+				"HTTP/1.1 200 OK\r\n",
+				"Content-Type: Text/JavaScript; charset=utf-8\r\n",
+				"\r\n",
+				"console.log('Hello, world!');",
+			),
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := exchangetest.MakeResponse(test.url, test.resp)
+			err := preverify.RejectJS.Process(resp)
+			if test.wantErr {
+				var skipErr *preverify.SkipError
+				if !errors.As(err, &skipErr) {
+					t.Fatalf("got %v, want SkipError", err)
+				}
+				if skipErr.Reason != preverify.SkipReasonNoJS {
+					t.Errorf("skipErr.Reason = %q, want %q", skipErr.Reason, preverify.SkipReasonNoJS)
+				}
+			}
+			if !test.wantErr && err != nil {
+				t.Errorf("got error(%q), want success", err)
+			}
+		})
+	}
+}
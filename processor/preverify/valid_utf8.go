@@ -0,0 +1,87 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package preverify
+
+import (
+	"mime"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/layer0-platform/webpackager/exchange"
+	"github.com/layer0-platform/webpackager/processor"
+)
+
+// ValidUTF8 verifies the payload of text responses is well-formed UTF-8,
+// to catch encoding bugs before they get signed and distributed. It reports
+// the byte offset of the first invalid sequence with UTF8Error.
+//
+// ValidUTF8 only examines text/*, application/json, application/xml, and
+// media types with the +json or +xml suffix. It also skips responses with
+// a charset parameter other than UTF-8; those without the charset parameter
+// are assumed to be UTF-8. Responses missing Content-Type pass through.
+var ValidUTF8 processor.Processor = &validUTF8{}
+
+type validUTF8 struct{}
+
+func (*validUTF8) Process(resp *exchange.Response) error {
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		return nil
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil && err != mime.ErrInvalidMediaParameter {
+		return nil
+	}
+	if !isTextMediaType(mediaType) {
+		return nil
+	}
+	if charset, ok := params["charset"]; ok && !isUTF8Charset(charset) {
+		return nil
+	}
+	if offset := findInvalidUTF8(resp.Payload); offset >= 0 {
+		return NewUTF8Error(offset)
+	}
+	return nil
+}
+
+func isTextMediaType(mediaType string) bool {
+	switch {
+	case strings.HasPrefix(mediaType, "text/"):
+		return true
+	case mediaType == "application/json", mediaType == "application/xml":
+		return true
+	case strings.HasSuffix(mediaType, "+json"), strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	return false
+}
+
+func isUTF8Charset(charset string) bool {
+	charset = strings.ToLower(charset)
+	return charset == "utf-8" || charset == "utf8"
+}
+
+// findInvalidUTF8 returns the byte offset of the first invalid sequence in
+// b, or -1 if b is well-formed UTF-8.
+func findInvalidUTF8(b []byte) int {
+	for offset := 0; offset < len(b); {
+		r, size := utf8.DecodeRune(b[offset:])
+		if r == utf8.RuneError && size == 1 {
+			return offset
+		}
+		offset += size
+	}
+	return -1
+}
@@ -0,0 +1,112 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package preverify_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/layer0-platform/webpackager/exchange/exchangetest"
+	"github.com/layer0-platform/webpackager/processor/preverify"
+)
+
+func TestValidUTF8(t *testing.T) {
+	tests := []struct {
+		name    string
+		resp    string
+		wantErr error
+	}{
+		{
+			name: "ValidHTML",
+			resp: fmt.Sprint(
+				"HTTP/1.1 200 OK\r\n",
+				"Content-Type: text/html; charset=utf-8\r\n",
+				"\r\n",
+				"<!doctype html><p>こんにちは</p>",
+			),
+			wantErr: nil,
+		},
+		{
+			name: "InvalidHTML",
+			resp: fmt.Sprint(
+				"HTTP/1.1 200 OK\r\n",
+				"Content-Type: text/html; charset=utf-8\r\n",
+				"\r\n",
+				"<!doctype html><p>caf\xe9</p>",
+			),
+			wantErr: preverify.NewUTF8Error(21),
+		},
+		{
+			name: "InvalidWithoutCharset",
+			resp: fmt.Sprint(
+				"HTTP/1.1 200 OK\r\n",
+				"Content-Type: text/css\r\n",
+				"\r\n",
+				"\xff",
+			),
+			wantErr: preverify.NewUTF8Error(0),
+		},
+		{
+			name: "InvalidJSONSuffix",
+			resp: fmt.Sprint(
+				"HTTP/1.1 200 OK\r\n",
+				"Content-Type: application/ld+json\r\n",
+				"\r\n",
+				"{\"name\": \"\xc3\"}",
+			),
+			wantErr: preverify.NewUTF8Error(10),
+		},
+		{
+			name: "OtherCharset",
+			resp: fmt.Sprint(
+				"HTTP/1.1 200 OK\r\n",
+				"Content-Type: text/html; charset=iso-8859-1\r\n",
+				"\r\n",
+				"<!doctype html><p>caf\xe9</p>",
+			),
+			wantErr: nil,
+		},
+		{
+			name: "Binary",
+			resp: fmt.Sprint(
+				"HTTP/1.1 200 OK\r\n",
+				"Content-Type: image/png\r\n",
+				"\r\n",
+				"\x89PNG\r\n\x1a\n",
+			),
+			wantErr: nil,
+		},
+		{
+			name: "NoContentType",
+			resp: fmt.Sprint(
+				"HTTP/1.1 200 OK\r\n",
+				"\r\n",
+				"\xff",
+			),
+			wantErr: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := exchangetest.MakeResponse("https://example.org/", test.resp)
+			err := preverify.ValidUTF8.Process(resp)
+			if diff := cmp.Diff(test.wantErr, err); diff != "" {
+				t.Errorf("Process() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
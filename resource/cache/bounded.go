@@ -71,9 +71,15 @@ func (c *boundedCache) Store(r *resource.Resource) error {
 	return nil
 }
 
+func (c *boundedCache) Remove(r *resource.Resource) error {
+	c.cache.Remove(r.RequestURL.String())
+	return nil
+}
+
 type cache interface {
 	Add(key, value interface{})
 	Get(key interface{}) (value interface{}, ok bool)
+	Remove(key interface{})
 }
 
 // Compiler check that both lruCache and lru.TwoQueueCache implement cache:
@@ -95,3 +101,7 @@ func (c lruCache) Add(key, value interface{}) {
 func (c lruCache) Get(key interface{}) (value interface{}, ok bool) {
 	return c.lru.Get(key)
 }
+
+func (c lruCache) Remove(key interface{}) {
+	c.lru.Remove(key)
+}
@@ -39,3 +39,11 @@ type ResourceCache interface {
 	// Store stores the provided Resource r into the cache.
 	Store(r *resource.Resource) error
 }
+
+// Remover is implemented by ResourceCaches able to remove Resources, e.g.
+// to discard the Resources whose output files have been removed.
+type Remover interface {
+	// Remove removes the Resource matching r.RequestURL from the cache.
+	// It does nothing when the cache contains no such Resource.
+	Remove(r *resource.Resource) error
+}
@@ -27,6 +27,45 @@ type Config struct {
 	// exchange files. nil is equivalent to MapToDevNull.
 	ExchangeMapping MappingRule
 
-	// ValidityMapping is currently unused.
+	// NextExchangeMapping specifies the rule to determine the location of
+	// the signed exchange files for the next period (Resource.NextExchange).
+	// nil is equivalent to MapToDevNull.
+	NextExchangeMapping MappingRule
+
+	// UnsignedResponseMapping specifies the rule to determine the location
+	// of the unsigned HTTP response files (Resource.UnsignedResponse), which
+	// are written in the HTTP/1.1 wire format. nil is equivalent to
+	// MapToDevNull.
+	UnsignedResponseMapping MappingRule
+
+	// ValidityMapping specifies the rule to determine the location of
+	// the validity data files (Resource.ValidityData). nil is equivalent to
+	// MapToDevNull.
 	ValidityMapping MappingRule
+
+	// MaxTotalBytes specifies the cap on the total size of the files this
+	// ResourceCache writes under OutputDir, in bytes. Store fails with
+	// ErrTotalSizeExceeded, or evicts the oldest files when EvictOldest is
+	// true, rather than exceeding the cap. Zero or negative means no cap.
+	MaxTotalBytes int64
+
+	// OutputDir specifies the directory accounted for MaxTotalBytes. It is
+	// scanned on the first Store for the files written by previous
+	// invocations (see OutputExts). OutputDir is required when MaxTotalBytes
+	// is set.
+	OutputDir string
+
+	// OutputExts lists the suffixes of the files written by this
+	// ResourceCache, typically the extensions given to AppendExt (e.g.
+	// ".sxg"). Files found under OutputDir on the first Store count towards
+	// MaxTotalBytes, and may be evicted, only if their names end with one of
+	// them; other files (e.g. certificates) are left untouched. nil means
+	// only the files written by this ResourceCache instance are counted.
+	OutputExts []string
+
+	// EvictOldest instructs the ResourceCache to remove the tracked files
+	// with the oldest modification time to stay under MaxTotalBytes. When
+	// a signed exchange file is removed, the Resource is also removed from
+	// BaseCache if it implements cache.Remover, so it gets regenerated.
+	EvictOldest bool
 }
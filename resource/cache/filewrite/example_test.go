@@ -15,8 +15,10 @@
 package filewrite_test
 
 import (
+	"crypto/sha256"
 	"fmt"
 	"log"
+	"path"
 
 	"github.com/layer0-platform/webpackager/internal/urlutil"
 	"github.com/layer0-platform/webpackager/resource"
@@ -59,6 +61,29 @@ func ExampleAppendExt() {
 	// hello/world/index.html.sxg
 }
 
+func ExampleMappingFunc() {
+	urlParse := urlutil.MustParse // Like url.Parse, panicking on an error.
+
+	r := resource.NewResource(urlParse("https://example.com/hello/world/"))
+	r.PhysicalURL = urlParse("https://example.com/hello/world/index.html")
+	r.ValidityURL = urlParse("https://example.com/hello/world/index.html.validity.1564617600")
+
+	// Flatten the directories, using the hash of the physical URL.
+	flatten := filewrite.MappingFunc(func(r *resource.Resource) (string, error) {
+		sum := sha256.Sum256([]byte(r.PhysicalURL.String()))
+		return fmt.Sprintf("%x%s", sum[:8], path.Ext(r.PhysicalURL.Path)), nil
+	})
+	mapping := filewrite.AddBaseDir(filewrite.AppendExt(flatten, ".sxg"), "/tmp")
+
+	got, err := mapping.Map(r)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(got)
+	// Output:
+	// /tmp/f2e8656254d41d35.html.sxg
+}
+
 func ExampleStripDir() {
 	urlParse := urlutil.MustParse // Like url.Parse, panicking on an error.
 
@@ -15,6 +15,7 @@
 package filewrite
 
 import (
+	"bytes"
 	"io"
 	"net/http"
 	"os"
@@ -25,13 +26,25 @@ import (
 )
 
 // NewFileWriteCache creates and initializes a new ResourceCache that also
-// saves signed exchanges to files on the Store operations.
+// saves signed exchanges to files on the Store operations. It panics when
+// config.MaxTotalBytes is set without config.OutputDir.
 func NewFileWriteCache(config Config) cache.ResourceCache {
-	return &fileWriteCache{config}
+	var usage *diskUsage
+	if config.MaxTotalBytes > 0 {
+		if config.OutputDir == "" {
+			panic("OutputDir is required with MaxTotalBytes")
+		}
+		usage = newDiskUsage(config.OutputDir, config.OutputExts, config.MaxTotalBytes, config.EvictOldest)
+		if remover, ok := config.BaseCache.(cache.Remover); ok {
+			usage.onEvict = remover.Remove
+		}
+	}
+	return &fileWriteCache{config, usage}
 }
 
 type fileWriteCache struct {
 	Config
+	usage *diskUsage
 }
 
 func (fsc *fileWriteCache) Lookup(req *http.Request) (*resource.Resource, error) {
@@ -43,7 +56,22 @@ func (fsc *fileWriteCache) Store(r *resource.Resource) error {
 		return err
 	}
 	if fsc.ExchangeMapping != nil && r.Exchange != nil {
-		if err := write(fsc.ExchangeMapping, r, r.Exchange); err != nil {
+		if err := write(fsc.ExchangeMapping, r, r.Exchange, fsc.usage, true); err != nil {
+			return err
+		}
+	}
+	if fsc.NextExchangeMapping != nil && r.NextExchange != nil {
+		if err := write(fsc.NextExchangeMapping, r, r.NextExchange, fsc.usage, false); err != nil {
+			return err
+		}
+	}
+	if fsc.UnsignedResponseMapping != nil && r.UnsignedResponse != nil {
+		if err := write(fsc.UnsignedResponseMapping, r, rawBytes(r.UnsignedResponse), fsc.usage, false); err != nil {
+			return err
+		}
+	}
+	if fsc.ValidityMapping != nil && r.ValidityData != nil {
+		if err := write(fsc.ValidityMapping, r, rawBytes(r.ValidityData), fsc.usage, false); err != nil {
 			return err
 		}
 	}
@@ -55,7 +83,17 @@ type writable interface {
 	Write(w io.Writer) error
 }
 
-func write(mapping MappingRule, r *resource.Resource, data writable) error {
+// rawBytes is a writable writing the bytes as is.
+type rawBytes []byte
+
+func (b rawBytes) Write(w io.Writer) error {
+	_, err := w.Write(b)
+	return err
+}
+
+// write writes data to the file determined by mapping for r. isExchange
+// reports whether data is the signed exchange of r.
+func write(mapping MappingRule, r *resource.Resource, data writable, usage *diskUsage, isExchange bool) error {
 	path, err := mapping.Map(r)
 	if err != nil {
 		return err
@@ -63,6 +101,18 @@ func write(mapping MappingRule, r *resource.Resource, data writable) error {
 	if path == "" {
 		return nil
 	}
+	if usage == nil {
+		return writeFile(path, data)
+	}
+
+	var buf bytes.Buffer
+	if err := data.Write(&buf); err != nil {
+		return err
+	}
+	return usage.write(path, buf.Bytes(), r, isExchange)
+}
+
+func writeFile(path string, data writable) error {
 	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
 		return err
 	}
@@ -16,6 +16,7 @@ package filewrite_test
 
 import (
 	"bytes"
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"os"
@@ -23,6 +24,7 @@ import (
 	"testing"
 
 	"github.com/WICG/webpackage/go/signedexchange"
+	"github.com/layer0-platform/webpackager/internal/urlutil"
 	"github.com/layer0-platform/webpackager/resource"
 	"github.com/layer0-platform/webpackager/resource/cache"
 	"github.com/layer0-platform/webpackager/resource/cache/filewrite"
@@ -82,6 +84,54 @@ func TestStore(t *testing.T) {
 		}
 	})
 
+	t.Run("WriteUnsignedResponse", func(t *testing.T) {
+		resp := []byte("HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nhi")
+		withResp := *r
+		withResp.UnsignedResponse = resp
+
+		tempFile := filepath.Join(tempDir, "standalone.http")
+		cache := filewrite.NewFileWriteCache(filewrite.Config{
+			BaseCache:               cache.NewOnMemoryCache(),
+			ExchangeMapping:         filewrite.MapToDevNull(),
+			UnsignedResponseMapping: FixedMappingRule(tempFile)})
+
+		if err := cache.Store(&withResp); err != nil {
+			t.Fatalf("cache.Store()  = error(%q), want success", err)
+		}
+
+		gotBytes, err := ioutil.ReadFile(tempFile)
+		if err != nil {
+			t.Fatalf("ioutil.ReadFile() = error(%q), want success", err)
+		}
+		if !bytes.Equal(gotBytes, resp) {
+			t.Errorf("ioutil.ReadFile() = %q, want %q", gotBytes, resp)
+		}
+	})
+
+	t.Run("WriteValidityData", func(t *testing.T) {
+		data := []byte{0xa0}
+		withData := *r
+		withData.ValidityData = data
+
+		tempFile := filepath.Join(tempDir, "standalone.validity")
+		cache := filewrite.NewFileWriteCache(filewrite.Config{
+			BaseCache:       cache.NewOnMemoryCache(),
+			ExchangeMapping: filewrite.MapToDevNull(),
+			ValidityMapping: FixedMappingRule(tempFile)})
+
+		if err := cache.Store(&withData); err != nil {
+			t.Fatalf("cache.Store()  = error(%q), want success", err)
+		}
+
+		gotBytes, err := ioutil.ReadFile(tempFile)
+		if err != nil {
+			t.Fatalf("ioutil.ReadFile() = error(%q), want success", err)
+		}
+		if !bytes.Equal(gotBytes, data) {
+			t.Errorf("ioutil.ReadFile() = %x, want %x", gotBytes, data)
+		}
+	})
+
 	t.Run("WriteToNone", func(t *testing.T) {
 		cache := filewrite.NewFileWriteCache(filewrite.Config{
 			BaseCache:       cache.NewOnMemoryCache(),
@@ -100,3 +150,181 @@ func TestStore(t *testing.T) {
 		}
 	})
 }
+
+func TestStore_MaxTotalBytes(t *testing.T) {
+	sxgBytes, err := ioutil.ReadFile("../../../testdata/sxg/standalone.sxg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sxg, err := signedexchange.ReadExchange(bytes.NewReader(sxgBytes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(http.MethodGet, sxg.RequestURI, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := resource.NewResource(req.URL)
+	if err = r.SetExchange(sxg); err != nil {
+		t.Fatal(err)
+	}
+
+	// oldFile is a file left by a previous invocation. otherFile is a file
+	// not written by the cache, which must be left untouched.
+	setup := func(t *testing.T) (tempDir, oldFile, otherFile string) {
+		tempDir, err := ioutil.TempDir("", "fswriter_test_")
+		if err != nil {
+			t.Fatal(err)
+		}
+		oldFile = filepath.Join(tempDir, "old.sxg")
+		if err := ioutil.WriteFile(oldFile, sxgBytes, 0644); err != nil {
+			t.Fatal(err)
+		}
+		otherFile = filepath.Join(tempDir, "cert.cbor")
+		if err := ioutil.WriteFile(otherFile, sxgBytes, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return tempDir, oldFile, otherFile
+	}
+
+	t.Run("UnderLimit", func(t *testing.T) {
+		tempDir, _, _ := setup(t)
+		defer os.RemoveAll(tempDir)
+
+		cache := filewrite.NewFileWriteCache(filewrite.Config{
+			BaseCache:       cache.NewOnMemoryCache(),
+			ExchangeMapping: FixedMappingRule(filepath.Join(tempDir, "new.sxg")),
+			MaxTotalBytes:   int64(2 * len(sxgBytes)),
+			OutputDir:       tempDir,
+			OutputExts:      []string{".sxg"},
+		})
+		if err := cache.Store(r); err != nil {
+			t.Fatalf("cache.Store() = error(%q), want success", err)
+		}
+	})
+
+	t.Run("OverLimit", func(t *testing.T) {
+		tempDir, oldFile, otherFile := setup(t)
+		defer os.RemoveAll(tempDir)
+
+		cache := filewrite.NewFileWriteCache(filewrite.Config{
+			BaseCache:       cache.NewOnMemoryCache(),
+			ExchangeMapping: FixedMappingRule(filepath.Join(tempDir, "new.sxg")),
+			MaxTotalBytes:   int64(2*len(sxgBytes) - 1),
+			OutputDir:       tempDir,
+			OutputExts:      []string{".sxg"},
+		})
+		if err := cache.Store(r); !errors.Is(err, filewrite.ErrTotalSizeExceeded) {
+			t.Fatalf("cache.Store() = %v, want ErrTotalSizeExceeded", err)
+		}
+		if _, err := os.Stat(oldFile); err != nil {
+			t.Errorf("os.Stat(oldFile) = error(%q), want success", err)
+		}
+		if _, err := os.Stat(otherFile); err != nil {
+			t.Errorf("os.Stat(otherFile) = error(%q), want success", err)
+		}
+	})
+
+	t.Run("EvictOldest", func(t *testing.T) {
+		tempDir, oldFile, otherFile := setup(t)
+		defer os.RemoveAll(tempDir)
+
+		newFile := filepath.Join(tempDir, "new.sxg")
+		cache := filewrite.NewFileWriteCache(filewrite.Config{
+			BaseCache:       cache.NewOnMemoryCache(),
+			ExchangeMapping: FixedMappingRule(newFile),
+			MaxTotalBytes:   int64(2*len(sxgBytes) - 1),
+			OutputDir:       tempDir,
+			OutputExts:      []string{".sxg"},
+			EvictOldest:     true,
+		})
+		if err := cache.Store(r); err != nil {
+			t.Fatalf("cache.Store() = error(%q), want success", err)
+		}
+		if _, err := os.Stat(oldFile); !os.IsNotExist(err) {
+			t.Errorf("os.Stat(oldFile) = %v, want not-exist error", err)
+		}
+		if _, err := os.Stat(newFile); err != nil {
+			t.Errorf("os.Stat(newFile) = error(%q), want success", err)
+		}
+		if _, err := os.Stat(otherFile); err != nil {
+			t.Errorf("os.Stat(otherFile) = error(%q), want success", err)
+		}
+	})
+
+	t.Run("EvictFromBaseCache", func(t *testing.T) {
+		tempDir, _, otherFile := setup(t)
+		defer os.RemoveAll(tempDir)
+
+		r1 := resource.NewResource(urlutil.MustParse("https://example.org/one.html"))
+		r2 := resource.NewResource(urlutil.MustParse("https://example.org/two.html"))
+		for _, r := range []*resource.Resource{r1, r2} {
+			if err = r.SetExchange(sxg); err != nil {
+				t.Fatal(err)
+			}
+		}
+		base := cache.NewOnMemoryCache()
+		cache := filewrite.NewFileWriteCache(filewrite.Config{
+			BaseCache: base,
+			ExchangeMapping: filewrite.MappingFunc(func(r *resource.Resource) (string, error) {
+				return filepath.Join(tempDir, filepath.Base(r.RequestURL.Path)+".sxg"), nil
+			}),
+			// Fits only one of old.sxg, one.html.sxg, and two.html.sxg.
+			MaxTotalBytes: int64(2*len(sxgBytes) - 1),
+			OutputDir:     tempDir,
+			OutputExts:    []string{".sxg"},
+			EvictOldest:   true,
+		})
+		if err := cache.Store(r1); err != nil {
+			t.Fatalf("cache.Store(r1) = error(%q), want success", err)
+		}
+		if err := cache.Store(r2); err != nil {
+			t.Fatalf("cache.Store(r2) = error(%q), want success", err)
+		}
+
+		if got, err := base.Lookup(&http.Request{URL: r1.RequestURL}); got != nil || err != nil {
+			t.Errorf("base.Lookup(r1) = (%v, %v), want (nil, nil)", got, err)
+		}
+		if got, err := base.Lookup(&http.Request{URL: r2.RequestURL}); got != r2 || err != nil {
+			t.Errorf("base.Lookup(r2) = (%v, %v), want (r2, nil)", got, err)
+		}
+		if _, err := os.Stat(otherFile); err != nil {
+			t.Errorf("os.Stat(otherFile) = error(%q), want success", err)
+		}
+	})
+
+	t.Run("KeepFilesOfSameStore", func(t *testing.T) {
+		tempDir, oldFile, _ := setup(t)
+		defer os.RemoveAll(tempDir)
+
+		r := resource.NewResource(urlutil.MustParse("https://example.org/one.html"))
+		if err = r.SetExchange(sxg); err != nil {
+			t.Fatal(err)
+		}
+		r.ValidityData = make([]byte, len(sxgBytes))
+		sxgFile := filepath.Join(tempDir, "one.html.sxg")
+		base := cache.NewOnMemoryCache()
+		cache := filewrite.NewFileWriteCache(filewrite.Config{
+			BaseCache:       base,
+			ExchangeMapping: FixedMappingRule(sxgFile),
+			ValidityMapping: FixedMappingRule(filepath.Join(tempDir, "one.html.validity")),
+			// Fits only one of old.sxg, one.html.sxg, and one.html.validity.
+			MaxTotalBytes: int64(2*len(sxgBytes) - 1),
+			OutputDir:     tempDir,
+			OutputExts:    []string{".sxg"},
+			EvictOldest:   true,
+		})
+		if err := cache.Store(r); !errors.Is(err, filewrite.ErrTotalSizeExceeded) {
+			t.Fatalf("cache.Store() = %v, want ErrTotalSizeExceeded", err)
+		}
+		if _, err := os.Stat(oldFile); !os.IsNotExist(err) {
+			t.Errorf("os.Stat(oldFile) = %v, want not-exist error", err)
+		}
+		if _, err := os.Stat(sxgFile); err != nil {
+			t.Errorf("os.Stat(sxgFile) = error(%q), want success", err)
+		}
+		if got, err := base.Lookup(&http.Request{URL: r.RequestURL}); got != r || err != nil {
+			t.Errorf("base.Lookup(r) = (%v, %v), want (r, nil)", got, err)
+		}
+	})
+}
@@ -15,7 +15,9 @@
 package filewrite
 
 import (
+	"crypto/sha256"
 	"errors"
+	"fmt"
 	"path/filepath"
 	"strings"
 
@@ -28,6 +30,8 @@ var (
 		"filewrite: PhysicalURL is unclean or missing a filename")
 	errBadValidityURL = errors.New(
 		"filewrite: ValidityURL is unclean or missing a filename")
+	errQueryRejected = errors.New(
+		"filewrite: PhysicalURL has a query")
 )
 
 // MappingRule defines the rule of mapping Resources into files.
@@ -41,6 +45,18 @@ type MappingRule interface {
 	Map(r *resource.Resource) (string, error)
 }
 
+// MappingFunc is an adapter to allow the use of ordinary functions as
+// MappingRules, for custom file layouts. The function receives the Resource,
+// thus can use PhysicalURL and the signed exchange for mapping. MappingFunc
+// can be combined with other MappingRules such as AddBaseDir and AppendExt
+// like any other MappingRule.
+type MappingFunc func(r *resource.Resource) (string, error)
+
+// Map calls f(r).
+func (f MappingFunc) Map(r *resource.Resource) (string, error) {
+	return f(r)
+}
+
 // MapToDevNull returns a MappingRule to write no files.
 func MapToDevNull() MappingRule {
 	return &mapToDevNull{}
@@ -142,6 +158,62 @@ func (rule *appendExt) Map(r *resource.Resource) (string, error) {
 	return (path + rule.ext), nil
 }
 
+// QueryPolicy specifies how ApplyQueryPolicy handles the query string in
+// PhysicalURL.
+type QueryPolicy int
+
+const (
+	// DropQuery ignores the query, like UsePhysicalURLPath alone does. Note
+	// URLs differing only in the query (e.g. "index.php?id=42" and
+	// "index.php?id=43") are mapped to the same file and overwrite each
+	// other.
+	DropQuery QueryPolicy = iota
+
+	// HashQuery appends "~" and a hash of the raw query to the path, e.g.
+	// "index.php~1a2b3c4d5e6f7a8b" for "index.php?id=42", so each query
+	// variant gets its own file. Paths for URLs without a query are left
+	// unchanged.
+	HashQuery
+
+	// RejectQuery makes the mapping fail for URLs with a query.
+	RejectQuery
+)
+
+// ApplyQueryPolicy returns a new MappingRule that calls rule.Map then handles
+// the query in PhysicalURL as specified by policy. It is meant to decorate
+// UsePhysicalURLPath, before other decorators like AppendExt.
+func ApplyQueryPolicy(rule MappingRule, policy QueryPolicy) MappingRule {
+	if policy == DropQuery {
+		return rule
+	}
+	return &applyQueryPolicy{rule, policy}
+}
+
+type applyQueryPolicy struct {
+	base   MappingRule
+	policy QueryPolicy
+}
+
+func (rule *applyQueryPolicy) Map(r *resource.Resource) (string, error) {
+	path, err := rule.base.Map(r)
+	if path == "" || err != nil {
+		return "", err
+	}
+	query := r.PhysicalURL.RawQuery
+	if query == "" {
+		return path, nil
+	}
+	switch rule.policy {
+	case HashQuery:
+		sum := sha256.Sum256([]byte(query))
+		return fmt.Sprintf("%s~%x", path, sum[:8]), nil
+	case RejectQuery:
+		return "", errQueryRejected
+	default:
+		return path, nil
+	}
+}
+
 // StripDir returns a new MappingRule that calls rule.Map then eliminates the
 // directory part (anything but the last element) from the returned path.
 func StripDir(rule MappingRule) MappingRule {
@@ -183,3 +183,72 @@ func TestDecoratorRules(t *testing.T) {
 		})
 	}
 }
+
+func TestApplyQueryPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		policy  filewrite.QueryPolicy
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "DropQuery",
+			url:    "https://example.com/index.php?id=42",
+			policy: filewrite.DropQuery,
+			want:   "index.php.sxg",
+		},
+		{
+			name:   "HashQuery",
+			url:    "https://example.com/index.php?id=42",
+			policy: filewrite.HashQuery,
+			want:   "index.php~2a553fd53e66b69f.sxg",
+		},
+		{
+			name:   "HashQuery_OtherQuery",
+			url:    "https://example.com/index.php?id=43",
+			policy: filewrite.HashQuery,
+			want:   "index.php~5dc6f16a89ab7ab6.sxg",
+		},
+		{
+			name:   "HashQuery_NoQuery",
+			url:    "https://example.com/index.php",
+			policy: filewrite.HashQuery,
+			want:   "index.php.sxg",
+		},
+		{
+			name:    "RejectQuery",
+			url:     "https://example.com/index.php?id=42",
+			policy:  filewrite.RejectQuery,
+			wantErr: true,
+		},
+		{
+			name:   "RejectQuery_NoQuery",
+			url:    "https://example.com/index.php",
+			policy: filewrite.RejectQuery,
+			want:   "index.php.sxg",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := &resource.Resource{PhysicalURL: urlutil.MustParse(test.url)}
+			rule := filewrite.AppendExt(
+				filewrite.ApplyQueryPolicy(filewrite.UsePhysicalURLPath(), test.policy),
+				".sxg")
+			got, err := rule.Map(r)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("got %q, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got error(%q), want success", err)
+			}
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
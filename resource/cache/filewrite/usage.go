@@ -0,0 +1,202 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filewrite
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/layer0-platform/webpackager/resource"
+)
+
+// ErrTotalSizeExceeded is returned by Store when writing the file would make
+// the total size under Config.OutputDir exceed Config.MaxTotalBytes.
+var ErrTotalSizeExceeded = errors.New("filewrite: total size limit exceeded")
+
+// diskUsage keeps track of the files under dir written by the cache and
+// their total size. Files left by previous invocations are tracked only if
+// their names end with one of exts.
+type diskUsage struct {
+	dir   string
+	exts  []string
+	limit int64
+	evict bool
+
+	// onEvict is called with the Resource whose signed exchange is evicted.
+	onEvict func(r *resource.Resource) error
+
+	mu      sync.Mutex
+	scanned bool
+	files   map[string]fileEntry
+	total   int64
+}
+
+type fileEntry struct {
+	size    int64
+	modTime time.Time
+	// owner is the Resource the file was written for, or nil if the file
+	// is left by a previous invocation.
+	owner *resource.Resource
+	// isExchange reports whether the file holds the signed exchange of owner.
+	isExchange bool
+}
+
+func newDiskUsage(dir string, exts []string, limit int64, evict bool) *diskUsage {
+	return &diskUsage{dir: dir, exts: exts, limit: limit, evict: evict}
+}
+
+// write writes content to path for owner, keeping the total size under
+// the limit. isExchange reports whether content is the signed exchange of
+// owner. The files written for owner are never evicted to make room.
+func (u *diskUsage) write(path string, content []byte, owner *resource.Resource, isExchange bool) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if !u.scanned {
+		if err := u.scan(); err != nil {
+			return err
+		}
+		u.scanned = true
+	}
+
+	key, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	size := int64(len(content))
+	if size > u.limit {
+		return fmt.Errorf("%w (%s: %d bytes; limit: %d bytes)",
+			ErrTotalSizeExceeded, path, size, u.limit)
+	}
+	total := u.total - u.files[key].size + size
+	if total > u.limit {
+		if !u.evict {
+			return fmt.Errorf("%w (%s: %d bytes in total; limit: %d bytes)",
+				ErrTotalSizeExceeded, path, total, u.limit)
+		}
+		if err := u.evictOldest(total-u.limit, key, owner); err != nil {
+			return err
+		}
+		// The files written for owner may take up the rest.
+		if total := u.total - u.files[key].size + size; total > u.limit {
+			return fmt.Errorf("%w (%s: %d bytes in total; limit: %d bytes)",
+				ErrTotalSizeExceeded, path, total, u.limit)
+		}
+	}
+
+	if err := writeFile(path, rawBytes(content)); err != nil {
+		return err
+	}
+	u.add(key, fileEntry{size, time.Now(), owner, isExchange})
+	return nil
+}
+
+// scan populates u.files with the files existing under u.dir which have
+// one of the extensions in u.exts.
+func (u *diskUsage) scan() error {
+	u.files = make(map[string]fileEntry)
+	u.total = 0
+
+	dir, err := filepath.Abs(u.dir)
+	if err != nil {
+		return err
+	}
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == dir {
+				return nil
+			}
+			return err
+		}
+		if info.Mode().IsRegular() && u.hasExt(path) {
+			u.add(path, fileEntry{info.Size(), info.ModTime(), nil, false})
+		}
+		return nil
+	})
+	return err
+}
+
+func (u *diskUsage) hasExt(path string) bool {
+	for _, ext := range u.exts {
+		if strings.HasSuffix(path, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// evictOldest removes the oldest tracked files until at least n bytes are
+// freed. The file at keep and the files written for owner are never removed,
+// so a Store does not evict what it has just written. The Resources whose
+// signed exchanges are removed are passed to u.onEvict.
+func (u *diskUsage) evictOldest(n int64, keep string, owner *resource.Resource) error {
+	paths := make([]string, 0, len(u.files))
+	for path, e := range u.files {
+		if path != keep && !sameResource(e.owner, owner) {
+			paths = append(paths, path)
+		}
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		return u.files[paths[i]].modTime.Before(u.files[paths[j]].modTime)
+	})
+
+	for _, path := range paths {
+		if n <= 0 {
+			break
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		log.Printf("evicted %s to stay under the total size limit", path)
+		e := u.files[path]
+		n -= e.size
+		u.remove(path)
+		if e.isExchange && u.onEvict != nil {
+			if err := u.onEvict(e.owner); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// sameResource reports whether r1 and r2 are for the same URL. It reports
+// false if either of them is nil.
+func sameResource(r1, r2 *resource.Resource) bool {
+	if r1 == nil || r2 == nil {
+		return false
+	}
+	return r1.RequestURL.String() == r2.RequestURL.String()
+}
+
+func (u *diskUsage) add(path string, e fileEntry) {
+	u.remove(path)
+	u.files[path] = e
+	u.total += e.size
+}
+
+func (u *diskUsage) remove(path string) {
+	if e, ok := u.files[path]; ok {
+		u.total -= e.size
+		delete(u.files, path)
+	}
+}
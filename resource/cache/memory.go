@@ -38,3 +38,8 @@ func (mc onMemoryCache) Store(r *resource.Resource) error {
 	mc[r.RequestURL.String()] = r
 	return nil
 }
+
+func (mc onMemoryCache) Remove(r *resource.Resource) error {
+	delete(mc, r.RequestURL.String())
+	return nil
+}
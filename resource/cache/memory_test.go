@@ -67,4 +67,29 @@ func TestOnMemoryCache(t *testing.T) {
 	if err := mc.Store(bar); err != nil {
 		t.Errorf("mc.Store(bar) = error(%q), want success", err)
 	}
+
+	if err := mc.(cache.Remover).Remove(foo); err != nil {
+		t.Errorf("mc.Remove(foo) = error(%q), want success", err)
+	}
+
+	// foo is no longer present.
+	{
+		got, err := mc.Lookup(reqFoo)
+		if err != nil {
+			t.Errorf("mc.Lookup(reqFoo) = error(%q), want success", err)
+		}
+		if got != nil {
+			t.Errorf("mc.Lookup(reqFoo) = %v, want %v", got, nil)
+		}
+	}
+	// bar is still present.
+	{
+		got, err := mc.Lookup(reqBar)
+		if err != nil {
+			t.Errorf("mc.Lookup(reqBar) = error(%q), want success", err)
+		}
+		if got != bar {
+			t.Errorf("mc.Lookup(reqBar) = %v, want %v", got, bar)
+		}
+	}
 }
@@ -34,7 +34,8 @@ const (
 // Special parameter values recognized by LinkParams.
 const (
 	// Value(s) for the "rel" parameter.
-	RelPreload = "preload"
+	RelPreload    = "preload"
+	RelPreconnect = "preconnect"
 
 	// Value(s) for the "crossorigin" parameter.
 	CrossOriginAnonymous = "anonymous"
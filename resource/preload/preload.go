@@ -17,6 +17,7 @@ package preload
 
 import (
 	"net/url"
+	"strings"
 
 	"github.com/layer0-platform/webpackager/resource"
 	"github.com/layer0-platform/webpackager/resource/httplink"
@@ -38,6 +39,18 @@ const (
 	AsVideo    = "video"
 )
 
+// IsValidAs reports whether as is one of the "as" attribute values above,
+// in a case-insensitive manner. Browsers ignore preload links with other
+// values.
+func IsValidAs(as string) bool {
+	switch strings.ToLower(as) {
+	case AsAudio, AsDocument, AsEmbed, AsFetch, AsFont, AsImage, AsObject,
+		AsScript, AsStyle, AsTrack, AsWorker, AsVideo:
+		return true
+	}
+	return false
+}
+
 // Preload represents a preload link.
 type Preload struct {
 	*httplink.Link
@@ -19,6 +19,7 @@ package resource
 import (
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/binary"
 	"fmt"
 	"net/url"
 
@@ -44,6 +45,15 @@ type Resource struct {
 	// See also: Package urlrewrite.
 	PhysicalURL *url.URL
 
+	// SignedURL is the URL the signed exchange is signed under, i.e. the
+	// request URL embedded in Exchange. It is usually identical to
+	// RequestURL, but can be different when the public URL differs from
+	// the location to fetch the content from. RequestURL still drives
+	// the fetch in that case.
+	//
+	// See also: webpackager.Config.SignedURLRule.
+	SignedURL *url.URL
+
 	// ValidityURL represents the location of the validity data.
 	ValidityURL *url.URL
 
@@ -60,6 +70,51 @@ type Resource struct {
 	//
 	// Integrity is set by the SetExchange method.
 	Integrity string
+
+	// NextExchange represents a signed exchange for the period following
+	// Exchange, overlapping with it, so distributors can switch to it
+	// before Exchange expires. It shares the payload with Exchange.
+	//
+	// NextExchange is nil unless webpackager.Config.NextExchangeOverlap is
+	// set. It is not reflected in the Integrity field.
+	NextExchange *signedexchange.Exchange
+
+	// ValidityData represents the validity data, encoded in CBOR, to serve
+	// at ValidityURL. It carries an update signature for the period
+	// following Exchange (see validity.Data).
+	//
+	// ValidityData is nil unless webpackager.Config.SignValidityData is set.
+	ValidityData []byte
+
+	// UnsignedResponse represents the HTTP response used to produce
+	// Exchange, after processing but before signing, in the HTTP/1.1 wire
+	// format. It allows distributors to serve the plain content alongside
+	// the signed exchange.
+	//
+	// UnsignedResponse is nil unless webpackager.Config.KeepUnsignedResponse
+	// is set.
+	UnsignedResponse []byte
+
+	// Transformations contains human-readable descriptions of the changes
+	// the processors made to the HTTP response before signing, e.g.
+	// "removed header Set-Cookie", for auditing. It is empty when
+	// the signed exchange is reused from ResourceCache.
+	Transformations []string
+
+	// MIRecordSize represents the Merkle Integrity record size used to
+	// encode the payload of Exchange. It is zero when the payload is not
+	// MI-encoded or is too short to carry the record size.
+	//
+	// MIRecordSize is set by the SetExchange method.
+	MIRecordSize int
+
+	// ContentHash represents the hash of the payload as fetched from
+	// the server, before any processing, prefixed by the algorithm and
+	// base64-encoded ("sha256-..."). It tells whether the content has
+	// changed since the signed exchange was produced.
+	//
+	// See also: webpackager.Config.PreviousResources.
+	ContentHash string
 }
 
 // NewResource creates and initializes a new Resource for url.
@@ -67,14 +122,21 @@ func NewResource(url *url.URL) *Resource {
 	return &Resource{RequestURL: url}
 }
 
+// NewContentHash returns the value for ContentHash for payload.
+func NewContentHash(payload []byte) string {
+	sum := sha256.Sum256(payload)
+	return "sha256-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
 // String returns a string representing the Resource.
 func (r *Resource) String() string {
 	return fmt.Sprintf("<%s>", r.RequestURL)
 }
 
 // SetExchange populates r.Exchange with the provided signed exchange e and
-// updates r.Integrity accordingly. It returns a non-nil error when it fails
-// to compute the new integrity value, in which case r is not mutated.
+// updates r.Integrity and r.MIRecordSize accordingly. It returns a non-nil
+// error when it fails to compute the new integrity value, in which case r is
+// not mutated.
 func (r *Resource) SetExchange(e *signedexchange.Exchange) error {
 	hasher := sha256.New()
 
@@ -85,9 +147,23 @@ func (r *Resource) SetExchange(e *signedexchange.Exchange) error {
 
 	r.Exchange = e
 	r.Integrity = "sha256-" + base64.StdEncoding.EncodeToString(sum)
+	r.MIRecordSize = getMIRecordSize(e)
 	return nil
 }
 
+// getMIRecordSize extracts the record size from the MI-encoded payload of e.
+// It returns zero when the record size is not available.
+func getMIRecordSize(e *signedexchange.Exchange) int {
+	enc := e.Version.MiceEncoding().ContentEncoding()
+	if e.ResponseHeaders.Get("Content-Encoding") != enc {
+		return 0
+	}
+	if len(e.Payload) < 8 {
+		return 0
+	}
+	return int(binary.BigEndian.Uint64(e.Payload[:8]))
+}
+
 // AllowedAltSXGHeader returns the value of a Link HTTP header to allow the
 // resource to be distributed from different domains (rel="allowed-alt-sxg").
 func (r *Resource) AllowedAltSXGHeader() string {
@@ -39,6 +39,9 @@ func TestSetExchange(t *testing.T) {
 	if r.Integrity != integrity {
 		t.Errorf("r.Integrity = %q, want %q", r.Integrity, integrity)
 	}
+	if r.MIRecordSize != 16384 {
+		t.Errorf("r.MIRecordSize = %d, want %d", r.MIRecordSize, 16384)
+	}
 }
 
 func TestAllowedAltSXGHeader(t *testing.T) {
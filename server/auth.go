@@ -0,0 +1,83 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/layer0-platform/webpackager/internal/timeutil"
+	"golang.org/x/xerrors"
+)
+
+const (
+	// AuthTimestampParam is the query parameter to carry the timestamp of
+	// authenticated signing requests, in UNIX time (seconds).
+	AuthTimestampParam = "ts"
+	// AuthSignatureParam is the query parameter to carry the signature of
+	// authenticated signing requests, computed by SignAuthRequest.
+	AuthSignatureParam = "sig"
+)
+
+var errBadSignature = errors.New("signature mismatch")
+
+// SignAuthRequest computes the signature for an authenticated signing
+// request to produce the signed exchange for signURL. The signature is the
+// hex-encoded HMAC-SHA256 of signURL and the timestamp in UNIX time, joined
+// by a newline, keyed with key.
+//
+// The request to the authenticated doc handler looks like:
+//
+//	/priv/authdoc?sign=<signURL>&ts=<timestamp>&sig=<signature>
+func SignAuthRequest(key []byte, signURL string, timestamp time.Time) string {
+	return hex.EncodeToString(computeAuthMAC(key, signURL, formatUnixTime(timestamp)))
+}
+
+func computeAuthMAC(key []byte, signURL, timestamp string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(signURL + "\n" + timestamp))
+	return mac.Sum(nil)
+}
+
+func formatUnixTime(t time.Time) string {
+	return strconv.FormatInt(t.Unix(), 10)
+}
+
+// verifyAuthRequest verifies the signature over signURL and timestamp, and
+// also rejects timestamps away from the current time by more than maxSkew
+// to prevent replays.
+func verifyAuthRequest(key []byte, maxSkew time.Duration, signURL, timestamp, signature string) error {
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return xerrors.Errorf("invalid timestamp %q", timestamp)
+	}
+	skew := timeutil.Now().Sub(time.Unix(sec, 0))
+	if skew > maxSkew || skew < -maxSkew {
+		return xerrors.Errorf("stale timestamp %q (skew: %v)", timestamp, skew)
+	}
+
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return errBadSignature
+	}
+	if !hmac.Equal(got, computeAuthMAC(key, signURL, timestamp)) {
+		return errBadSignature
+	}
+	return nil
+}
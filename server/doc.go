@@ -89,15 +89,29 @@ format. The request looks like:
 
 where "/webpkg/cert" can be customized through CertPath and "47DEQpj8..." is
 an example of unique stable identifier, which is RawChain.Digest of the served
-AugmentedChain.
+AugmentedChain. certchainutil.CertURLPath computes it for a given chain.
+The cert handler serves any AugmentedChain found in the CertManager's Cache,
+as well as the current one and those replaced within RetainPrevious, so
+signed exchanges produced before a certificate rotation remain valid.
 
-The validity handler serves validity data. Currently, it constantly returns
-an empty CBOR map (a single byte of 0xa0), which is interpreted as "no update
-available." The request looks like:
+The validity handler serves validity data produced by ValidityBuilder in
+Config. By default, it constantly returns an empty CBOR map (a single byte of
+0xa0), which is interpreted as "no update available." The request looks like:
 
     /webpkg/validity
 
 where "/webpkg/validity" can be customized through ValidityPath. It does not
 take any argument, such as the document URL, at this moment.
+
+Handler can also have an authenticated doc handler, enabled by AuthKey in
+Config. It is similar to the doc handler but does not require the Accept
+header; instead, each request carries an HMAC over the document URL and the
+timestamp, computed with the shared key. The request looks like:
+
+	/priv/authdoc?sign=https%3A%2F%2Fexample.com%2Findex.html&ts=1588291200&sig=...
+
+where "/priv/authdoc" can be customized through AuthDocPath. See
+SignAuthRequest for the signature. Requests with a timestamp too far from
+the current time (AuthMaxSkew) are rejected to prevent replays.
 */
 package server
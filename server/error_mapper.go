@@ -0,0 +1,71 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/layer0-platform/webpackager/fetch"
+	"github.com/layer0-platform/webpackager/processor/preverify"
+	"golang.org/x/xerrors"
+)
+
+// ErrorMapper determines the HTTP response Handler replies with when
+// Packager fails to produce the signed exchange. It returns the status
+// code and the response body. A nil body makes Handler reply with the
+// standard text for the status code, e.g. "404 Not Found".
+//
+// err does not contain errors with subresources: Handler ignores them.
+type ErrorMapper func(err error) (status int, body []byte)
+
+// DefaultErrorMapper is the ErrorMapper used when Config.ErrorMapper is nil.
+// It replies with 400 (Bad Request) to fetch.ErrURLMismatch, 503 (Service
+// Unavailable) to fetch.ErrTooManyFetches, 504 (Gateway Timeout) to
+// fetch.TimeoutError, and 500 (Internal Server Error) to other errors,
+// logging the last two. Note errors from the upstream server, such as
+// preverify.HTTPStatusError wrapped in webpackager.Error, also result in
+// 500; use PassThroughErrorMapper to pass through their status codes.
+func DefaultErrorMapper(err error) (int, []byte) {
+	var timeoutErr *fetch.TimeoutError
+	if httpErr, ok := err.(*preverify.HTTPStatusError); ok {
+		return httpErr.StatusCode, nil
+	}
+	switch {
+	case xerrors.As(err, &timeoutErr):
+		log.Printf("Packager.RunForRequest: origin %s timeout for %s: %v",
+			timeoutErr.Phase, timeoutErr.URL, timeoutErr.Err)
+		return http.StatusGatewayTimeout, nil
+	case xerrors.Is(err, fetch.ErrURLMismatch):
+		return http.StatusBadRequest, nil
+	case xerrors.Is(err, fetch.ErrTooManyFetches):
+		return http.StatusServiceUnavailable, nil
+	default:
+		log.Printf("Packager.RunForRequest: %v", err)
+		return http.StatusInternalServerError, nil
+	}
+}
+
+// PassThroughErrorMapper is an ErrorMapper to reply with the status code
+// from the upstream server to errors with preverify.HTTPStatusError (e.g.
+// from preverify.HTTPStatusCode), like a reverse proxy. It falls back to
+// DefaultErrorMapper for other errors.
+func PassThroughErrorMapper(err error) (int, []byte) {
+	var httpErr *preverify.HTTPStatusError
+	if xerrors.As(err, &httpErr) {
+		return httpErr.StatusCode, nil
+	}
+	return DefaultErrorMapper(err)
+}
@@ -0,0 +1,117 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/layer0-platform/webpackager"
+	"github.com/layer0-platform/webpackager/fetch"
+	"github.com/layer0-platform/webpackager/internal/urlutil"
+	"github.com/layer0-platform/webpackager/processor/preverify"
+	"github.com/layer0-platform/webpackager/server"
+)
+
+func TestDefaultErrorMapper(t *testing.T) {
+	u := urlutil.MustParse("https://example.com/public/hello.html")
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{
+			name: "HTTPStatusError",
+			err:  webpackager.WrapError(preverify.NewHTTPStatusError(http.StatusNotFound), u),
+			want: http.StatusInternalServerError,
+		},
+		{
+			name: "URLMismatch",
+			err:  webpackager.WrapError(fetch.ErrURLMismatch, u),
+			want: http.StatusBadRequest,
+		},
+		{
+			name: "TooManyFetches",
+			err:  webpackager.WrapError(fetch.ErrTooManyFetches, u),
+			want: http.StatusServiceUnavailable,
+		},
+		{
+			name: "Timeout",
+			err: webpackager.WrapError(&fetch.TimeoutError{
+				Phase: fetch.PhaseResponseHeader,
+				URL:   u.String(),
+				Err:   errors.New("timeout awaiting response headers"),
+			}, u),
+			want: http.StatusGatewayTimeout,
+		},
+		{
+			name: "Other",
+			err:  webpackager.WrapError(errors.New("something went wrong"), u),
+			want: http.StatusInternalServerError,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status, body := server.DefaultErrorMapper(test.err)
+			if status != test.want {
+				t.Errorf("status = %v, want %v", status, test.want)
+			}
+			if body != nil {
+				t.Errorf("body = %q, want nil", body)
+			}
+		})
+	}
+}
+
+func TestPassThroughErrorMapper(t *testing.T) {
+	u := urlutil.MustParse("https://example.com/public/hello.html")
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{
+			name: "HTTPStatusError",
+			err:  webpackager.WrapError(preverify.NewHTTPStatusError(http.StatusNotFound), u),
+			want: http.StatusNotFound,
+		},
+		{
+			name: "HTTPStatusError_ServerError",
+			err:  webpackager.WrapError(preverify.NewHTTPStatusError(http.StatusBadGateway), u),
+			want: http.StatusBadGateway,
+		},
+		{
+			name: "Other",
+			err:  webpackager.WrapError(fetch.ErrTooManyFetches, u),
+			want: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status, body := server.PassThroughErrorMapper(test.err)
+			if status != test.want {
+				t.Errorf("status = %v, want %v", status, test.want)
+			}
+			if body != nil {
+				t.Errorf("body = %q, want nil", body)
+			}
+		})
+	}
+}
@@ -19,9 +19,9 @@ import (
 	"crypto"
 	"encoding/base64"
 	"net/url"
-	"path"
 
 	"github.com/WICG/webpackage/go/signedexchange/version"
+	"github.com/layer0-platform/webpackager/certchain/certchainutil"
 	"github.com/layer0-platform/webpackager/certchain/certmanager"
 	"github.com/layer0-platform/webpackager/exchange"
 	"golang.org/x/xerrors"
@@ -66,6 +66,14 @@ type ExchangeConfig struct {
 	// that don't have the corresponding allowed-alt-sxg with a valid
 	// header-integrity.
 	KeepNonSXGPreloads bool
+
+	// AbsolutePreloadURLs instructs Factory to resolve the URLs of preload
+	// link headers against the request URL.
+	AbsolutePreloadURLs bool
+
+	// SigningLimiter limits the number of signing operations running at
+	// the same time across all Factories returned by Get. nil sets no limit.
+	SigningLimiter *exchange.SigningLimiter
 }
 
 // NewExchangeMetaFactory creates a new ExchangeMetaFactory.
@@ -94,19 +102,18 @@ func (e *ExchangeMetaFactory) Get() (*exchange.Factory, error) {
 			Opaque: "application/cert-chain+cbor;base64," + encoded,
 		}
 	} else {
-		// Use path.Join so the last path element in e.CertURLBase is kept
-		// whether or not it has the trailing slash.
-		urlPath := path.Join(e.CertURLBase.Path, chain.Digest)
-		certURL = e.CertURLBase.ResolveReference(&url.URL{Path: urlPath})
+		certURL = certchainutil.CertURL(e.CertURLBase, chain)
 	}
 
 	config := exchange.Config{
-		Version:            e.Version,
-		MIRecordSize:       e.MIRecordSize,
-		CertChain:          chain,
-		CertURL:            certURL,
-		PrivateKey:         e.PrivateKey,
-		KeepNonSXGPreloads: e.KeepNonSXGPreloads,
+		Version:             e.Version,
+		MIRecordSize:        e.MIRecordSize,
+		CertChain:           chain,
+		CertURL:             certURL,
+		PrivateKey:          e.PrivateKey,
+		KeepNonSXGPreloads:  e.KeepNonSXGPreloads,
+		AbsolutePreloadURLs: e.AbsolutePreloadURLs,
+		SigningLimiter:      e.SigningLimiter,
 	}
 	return exchange.NewFactory(config), nil
 }
@@ -15,13 +15,16 @@
 package server
 
 import (
+	"bytes"
 	"crypto/tls"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/layer0-platform/webpackager/certchain/certmanager/acmeclient"
@@ -29,6 +32,7 @@ import (
 	"github.com/layer0-platform/webpackager"
 	"github.com/layer0-platform/webpackager/certchain/certchainutil"
 	"github.com/layer0-platform/webpackager/certchain/certmanager"
+	"github.com/layer0-platform/webpackager/exchange"
 	"github.com/layer0-platform/webpackager/exchange/vprule"
 	"github.com/layer0-platform/webpackager/fetch"
 	"github.com/layer0-platform/webpackager/processor"
@@ -51,6 +55,10 @@ func FromTOMLConfig(c *tomlconfig.Config) (*Server, error) {
 	errs = multierror.Append(errs, err)
 	exchangeFactory, err := makeExchangeFactory(c)
 	errs = multierror.Append(errs, err)
+	authKey, err := readAuthKey(c)
+	errs = multierror.Append(errs, err)
+	clientCerts, err := readClientCertificates(c)
+	errs = multierror.Append(errs, err)
 
 	if err := errs.ErrorOrNil(); err != nil {
 		return nil, err
@@ -69,14 +77,22 @@ func FromTOMLConfig(c *tomlconfig.Config) (*Server, error) {
 		IdleTimeout:       120 * time.Second,
 	}
 
+	fetchClient, fetchLimiter := makeFetchClient(c, clientCerts)
 	pc := webpackager.Config{
-		FetchClient:     makeFetchClient(c),
+		FetchClient:     fetchClient,
 		ValidityURLRule: makeValidityURLRule(c),
 		Processor:       makeProcessor(c),
 		ValidPeriodRule: makeValidPeriodRule(c),
 		ExchangeFactory: exchangeFactory,
 	}
 
+	if d := c.Cache.GetStaleIfError(); d > 0 {
+		pc.StaleIfError = d
+		// Keep the unsigned responses to serve them in place of
+		// the expired signed exchanges.
+		pc.KeepUnsignedResponse = true
+	}
+
 	if size := c.Cache.MaxEntries; size > 0 {
 		pc.ResourceCache = cache.NewBoundedInMemoryCache(size)
 	} else if size == 0 {
@@ -86,15 +102,52 @@ func FromTOMLConfig(c *tomlconfig.Config) (*Server, error) {
 	}
 
 	config := Config{
-		Packager:      webpackager.NewPackager(pc),
-		CertManager:   exchangeFactory.CertManager,
-		ServerConfig:  c.Server,
-		AllowTestCert: c.SXG.Cert.AllowTestCert,
+		Packager:       webpackager.NewPackager(pc),
+		CertManager:    exchangeFactory.CertManager,
+		ServerConfig:   c.Server,
+		AllowTestCert:  c.SXG.Cert.AllowTestCert,
+		FetchLimiter:   fetchLimiter,
+		SigningLimiter: exchangeFactory.SigningLimiter,
+	}
+	if authKey != nil {
+		config.AuthKey = authKey
+		config.AuthDocPath = c.Auth.DocPath
+		config.AuthMaxSkew = c.Auth.GetMaxSkew()
 	}
 
 	return NewServer(server, config), nil
 }
 
+func readAuthKey(c *tomlconfig.Config) ([]byte, error) {
+	if !c.Auth.Enable {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(c.Auth.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	key := bytes.TrimSpace(data)
+	if len(key) == 0 {
+		return nil, fmt.Errorf("%s: empty auth key", c.Auth.KeyFile)
+	}
+	return key, nil
+}
+
+func readClientCertificates(c *tomlconfig.Config) ([]fetch.ClientCertificate, error) {
+	if c.Fetch.ClientCertFile == "" {
+		return nil, nil
+	}
+	cert, err := fetch.LoadClientCertificate(
+		c.Fetch.ClientCertFile,
+		c.Fetch.ClientKeyFile,
+		c.Fetch.ClientCertHosts,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return []fetch.ClientCertificate{cert}, nil
+}
+
 func makeTLSConfig(c *tomlconfig.Config) (*tls.Config, error) {
 	if c.Listen.TLS.PEMFile == "" && c.Listen.TLS.KeyFile == "" {
 		return nil, nil
@@ -109,7 +162,10 @@ func makeTLSConfig(c *tomlconfig.Config) (*tls.Config, error) {
 	return &tls.Config{Certificates: []tls.Certificate{cert}}, nil
 }
 
-func makeFetchClient(c *tomlconfig.Config) fetch.FetchClient {
+// makeFetchClient returns the FetchClient to use in the Packager, and the
+// LimitedFetchClient within it. The latter is nil when [Fetch] MaxFetches
+// is zero. certs are presented to the backend servers requesting them.
+func makeFetchClient(c *tomlconfig.Config, certs []fetch.ClientCertificate) (fetch.FetchClient, *fetch.LimitedFetchClient) {
 	allow := make([]urlmatcher.Matcher, len(c.Sign))
 	for i, uc := range c.Sign {
 		allow[i] = urlmatcher.AllOf(
@@ -120,7 +176,24 @@ func makeFetchClient(c *tomlconfig.Config) fetch.FetchClient {
 		)
 	}
 	selector := &fetch.Selector{Allow: allow}
-	return fetch.WithSelector(fetch.DefaultFetchClient, selector)
+	var client fetch.FetchClient = fetch.NewHTTPFetchClient(fetch.TransportConfig{
+		MaxIdleConnsPerHost:   c.Fetch.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       c.Fetch.MaxConnsPerHost,
+		IdleConnTimeout:       c.Fetch.GetIdleConnTimeout(),
+		DialTimeout:           c.Fetch.GetDialTimeout(),
+		TLSHandshakeTimeout:   c.Fetch.GetTLSHandshakeTimeout(),
+		ResponseHeaderTimeout: c.Fetch.GetResponseHeaderTimeout(),
+		RequestTimeout:        c.Fetch.GetRequestTimeout(),
+		ResolveOverrides:      c.Fetch.GetResolveOverrides(),
+		ClientCertificates:    certs,
+	})
+	var limiter *fetch.LimitedFetchClient
+	if c.Fetch.MaxFetches > 0 {
+		limiter = fetch.WithConcurrencyLimit(
+			client, c.Fetch.MaxFetches, c.Fetch.GetFetchQueueTimeout())
+		client = limiter
+	}
+	return fetch.WithSelector(client, selector), limiter
 }
 
 func makeValidityURLRule(c *tomlconfig.Config) validity.URLRule {
@@ -128,19 +201,35 @@ func makeValidityURLRule(c *tomlconfig.Config) validity.URLRule {
 }
 
 func makeProcessor(c *tomlconfig.Config) processor.Processor {
+	if len(c.Host) == 0 {
+		return makeProcessorFor(&c.Processor)
+	}
+
+	hosts := make(map[string]processor.Processor)
+	for _, hc := range c.Host {
+		pc := hc.OverrideProcessor(c.Processor)
+		hosts[strings.ToLower(hc.Domain)] = makeProcessorFor(&pc)
+	}
+	return &processor.PerHostProcessor{
+		Hosts:   hosts,
+		Default: makeProcessorFor(&c.Processor),
+	}
+}
+
+func makeProcessorFor(c *tomlconfig.ProcessorConfig) processor.Processor {
 	var tasks []htmltask.HTMLTask
 
 	tasks = append(tasks, htmltask.ConservativeTaskSet...)
 
-	if c.Processor.PreloadCSS {
+	if c.PreloadCSS {
 		tasks = append(tasks, htmltask.PreloadStylesheets())
 	}
-	if c.Processor.PreloadJS {
+	if c.PreloadJS {
 		tasks = append(tasks, htmltask.InsecurePreloadScripts())
 	}
 
 	config := complexproc.Config{
-		Preverify: preverify.Config{MaxContentLength: c.Processor.SizeLimit},
+		Preverify: preverify.Config{MaxContentLength: c.SizeLimit},
 		HTML:      htmlproc.Config{TaskSet: tasks},
 	}
 
@@ -148,15 +237,24 @@ func makeProcessor(c *tomlconfig.Config) processor.Processor {
 }
 
 func makeValidPeriodRule(c *tomlconfig.Config) vprule.Rule {
-	jsExpiry := c.SXG.GetJSExpiry()
-	expiry := c.SXG.GetExpiry()
-
-	return vprule.PerContentType(
-		map[string]vprule.Rule{
-			"application/javascript":   vprule.FixedLifetime(jsExpiry),
-			"application/x-javascript": vprule.FixedLifetime(jsExpiry),
-			"text/javascript":          vprule.FixedLifetime(jsExpiry),
-		},
+	if len(c.Host) == 0 {
+		return makeValidPeriodRuleFor(&c.SXG)
+	}
+
+	rules := make(map[string]vprule.Rule)
+	for _, hc := range c.Host {
+		sc := hc.OverrideSXG(c.SXG)
+		rules[strings.ToLower(hc.Domain)] = makeValidPeriodRuleFor(&sc)
+	}
+	return vprule.PerHost(rules, makeValidPeriodRuleFor(&c.SXG))
+}
+
+func makeValidPeriodRuleFor(c *tomlconfig.SXGConfig) vprule.Rule {
+	jsExpiry := c.GetJSExpiry()
+	expiry := c.GetExpiry()
+
+	return vprule.PerJSContentType(
+		vprule.FixedLifetime(jsExpiry),
 		vprule.FixedLifetime(expiry),
 	)
 }
@@ -172,6 +270,10 @@ func makeExchangeFactory(c *tomlconfig.Config) (*ExchangeMetaFactory, error) {
 	ec.PrivateKey, err = certchainutil.ReadPrivateKeyFile(c.SXG.Cert.KeyFile)
 	errs = multierror.Append(errs, err)
 	ec.KeepNonSXGPreloads = c.SXG.KeepNonSXGPreloads
+	ec.AbsolutePreloadURLs = c.SXG.AbsolutePreloads
+	if c.SXG.MaxSignings > 0 {
+		ec.SigningLimiter = exchange.NewSigningLimiter(c.SXG.MaxSignings)
+	}
 
 	if err := errs.ErrorOrNil(); err != nil {
 		return nil, err
@@ -210,10 +312,21 @@ func makeCertManager(c *tomlconfig.Config) (*certmanager.Manager, error) {
 			return nil, err
 		}
 	} else {
-		rcs = certmanager.NewLocalCertFile(certmanager.LocalCertFileConfig{
+		// KeyFile is read only once while PEMFile is reread, so make sure
+		// the reloaded chain still matches the key.
+		key, err := certchainutil.ReadPrivateKeyFile(c.SXG.Cert.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		lc := certmanager.LocalCertFileConfig{
 			Path:          c.SXG.Cert.PEMFile,
 			AllowTestCert: c.SXG.Cert.AllowTestCert,
-		})
+			PrivateKey:    key,
+		}
+		if d := c.SXG.Cert.GetWatchInterval(); d > 0 {
+			lc.FetchTiming = certmanager.FetchOnFileChange(c.SXG.Cert.PEMFile, d)
+		}
+		rcs = certmanager.NewLocalCertFile(lc)
 	}
 
 	mc := certmanager.Config{
@@ -223,6 +336,7 @@ func makeCertManager(c *tomlconfig.Config) (*certmanager.Manager, error) {
 				AllowTestCert: c.SXG.Cert.AllowTestCert,
 			},
 		),
+		RetainPrevious: c.SXG.Cert.GetRetainPrevious(),
 	}
 	if c.SXG.Cert.CacheDir != "" {
 		fmt.Printf("Creating SXG certificate cache directory: %s\n", c.SXG.Cert.CacheDir)
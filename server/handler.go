@@ -17,18 +17,23 @@ package server
 import (
 	"bytes"
 	"errors"
+	"log"
 	"net/http"
 	"net/url"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/layer0-platform/webpackager"
 	"github.com/layer0-platform/webpackager/certchain/certmanager"
+	"github.com/layer0-platform/webpackager/exchange"
 	"github.com/layer0-platform/webpackager/fetch"
 	"github.com/layer0-platform/webpackager/internal/timeutil"
 	"github.com/layer0-platform/webpackager/internal/urlutil"
 	"github.com/layer0-platform/webpackager/processor/preverify"
 	"github.com/layer0-platform/webpackager/server/tomlconfig"
+	"github.com/layer0-platform/webpackager/validity"
+	"github.com/WICG/webpackage/go/signedexchange"
 	"github.com/hashicorp/go-multierror"
 	"golang.org/x/xerrors"
 )
@@ -39,10 +44,6 @@ const (
 	mimeTypeValidity  = "application/cbor"
 )
 
-var (
-	emptyMapCBOR = []byte{0xa0}
-)
-
 // Handler handles HTTP requests. See the package GoDoc for details.
 type Handler struct {
 	mux *http.ServeMux
@@ -65,6 +66,40 @@ type Config struct {
 	// AllowTestCert indicates if it's ok to allow test certs.
 	AllowTestCert bool
 
+	// AuthKey is the shared key to authenticate signing requests sent to
+	// AuthDocPath. nil or empty disables the authenticated doc handler.
+	// See SignAuthRequest for how requests are authenticated.
+	AuthKey []byte
+
+	// AuthDocPath specifies the endpoint of the authenticated doc handler.
+	// Unlike DocPath, it does not require the Accept header. The signed
+	// URL is specified only through SignParam.
+	AuthDocPath string
+
+	// AuthMaxSkew specifies how much the timestamps of authenticated
+	// signing requests may be away from the current time.
+	AuthMaxSkew time.Duration
+
+	// FetchLimiter is the LimitedFetchClient within the FetchClient of
+	// Packager, or nil if there is none. Handler does not use it; it is
+	// kept here to tell the number of fetches in flight for monitoring.
+	FetchLimiter *fetch.LimitedFetchClient
+
+	// SigningLimiter is the SigningLimiter shared by the Factories from
+	// the ExchangeFactory of Packager, or nil if there is none. Handler
+	// does not use it; it is kept here to tell the queue depth and the
+	// wait time of signing operations for monitoring.
+	SigningLimiter *exchange.SigningLimiter
+
+	// ErrorMapper determines the HTTP responses to packaging errors.
+	// If ErrorMapper is nil, Handler uses DefaultErrorMapper.
+	ErrorMapper ErrorMapper
+
+	// ValidityBuilder produces the validity data served at ValidityPath.
+	// If ValidityBuilder is nil, Handler uses validity.DefaultBuilder,
+	// which serves an empty CBOR map.
+	ValidityBuilder validity.Builder
+
 	// ServerConfig specifies the endpoints. All fields must contain a valid
 	// value as described in cmd/webpkgserver/webpkgserver.example.toml.
 	tomlconfig.ServerConfig
@@ -77,6 +112,12 @@ func NewHandler(c Config) *Handler {
 	c.CertPath = path.Clean(c.CertPath)
 	c.ValidityPath = path.Clean(c.ValidityPath)
 	c.HealthPath = path.Clean(c.HealthPath)
+	if c.ErrorMapper == nil {
+		c.ErrorMapper = DefaultErrorMapper
+	}
+	if c.ValidityBuilder == nil {
+		c.ValidityBuilder = validity.DefaultBuilder
+	}
 
 	h := &Handler{new(http.ServeMux), c}
 
@@ -85,6 +126,11 @@ func NewHandler(c Config) *Handler {
 	h.mux.HandleFunc(c.ValidityPath, h.handleValidity)
 	h.mux.HandleFunc(c.HealthPath, h.handleHealth)
 
+	if len(c.AuthKey) > 0 {
+		h.AuthDocPath = path.Clean(c.AuthDocPath)
+		h.mux.HandleFunc(h.AuthDocPath, h.handleAuthDoc)
+	}
+
 	return h
 }
 
@@ -116,6 +162,18 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 func (h *Handler) handleCert(w http.ResponseWriter, req *http.Request) {
 	digest := strings.TrimPrefix(req.URL.Path, h.CertPath+"/")
 	ac, err := h.CertManager.Cache.Read(digest)
+	if err != nil {
+		// Serve the chain held in memory, either the current one or one
+		// retained after the rotation, if it is the requested one. This
+		// keeps the endpoint working without a cache and during transient
+		// cache failures.
+		if mem := h.CertManager.GetAugmentedChainByDigest(digest); mem != nil {
+			if !errors.Is(err, certmanager.ErrNotFound) {
+				log.Printf("warning: unable to read cert from cache; serving the one in memory: %v", err)
+			}
+			ac, err = mem, nil
+		}
+	}
 	if errors.Is(err, certmanager.ErrNotFound) {
 		replyError(w, http.StatusNotFound)
 		return
@@ -137,11 +195,29 @@ func (h *Handler) handleDoc(w http.ResponseWriter, req *http.Request) {
 	h.handleDocImpl(w, req, req.URL.Query().Get(h.SignParam))
 }
 
+func (h *Handler) handleAuthDoc(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	signURL := query.Get(h.SignParam)
+	err := verifyAuthRequest(
+		h.AuthKey, h.AuthMaxSkew, signURL,
+		query.Get(AuthTimestampParam), query.Get(AuthSignatureParam))
+	if err != nil {
+		log.Printf("unauthenticated request: %v", err)
+		replyError(w, http.StatusForbidden)
+		return
+	}
+	h.serveExchange(w, signURL)
+}
+
 func (h *Handler) handleDocImpl(w http.ResponseWriter, req *http.Request, signURL string) {
 	if err := verifyAcceptHeader(req); err != nil {
 		replyClientError(w, err)
 		return
 	}
+	h.serveExchange(w, signURL)
+}
+
+func (h *Handler) serveExchange(w http.ResponseWriter, signURL string) {
 	u, err := parseSignURL(signURL)
 	if err != nil {
 		replyClientError(w, xerrors.Errorf("invalid sign url: %w", err))
@@ -155,36 +231,37 @@ func (h *Handler) handleDocImpl(w http.ResponseWriter, req *http.Request, signUR
 	}
 	r, err := h.Packager.RunForRequest(newReq, timeutil.Now())
 	if err != nil {
-		err = filterError(err, u.String())
-		// TODO(banaag): ideally, we should pass through that error response
-		// from the upstream.
-		if httpErr, ok := err.(*preverify.HTTPStatusError); ok {
-			replyError(w, httpErr.StatusCode)
-			return
-		}
-		if xerrors.Is(err, fetch.ErrURLMismatch) {
-			replyClientErrorSilent(w)
-			return
-		}
-		if err != nil {
-			replyServerError(w, xerrors.Errorf("Packager.RunForRequest: %w", err))
+		if err := filterError(err, u.String()); err != nil {
+			var httpErr *preverify.HTTPStatusError
+			if h.RelayUpstreamErrors && xerrors.As(err, &httpErr) && httpErr.Header != nil {
+				replyUpstream(w, httpErr, u)
+				return
+			}
+			status, body := h.ErrorMapper(err)
+			replyErrorBody(w, status, body)
 			return
 		}
 	}
-	if r == nil {
+	if r == nil || r.Exchange == nil {
 		replyServerError(w, xerrors.Errorf("no resource for %s", u.String()))
 		return
 	}
-	var body bytes.Buffer
-	if err := r.Exchange.Write(&body); err != nil {
-		replyServerError(w, xerrors.Errorf("serializing exchange: %w", err))
+	if r.UnsignedResponse != nil && isExpired(r.Exchange, timeutil.Now()) {
+		// Packager reused the expired signed exchange as the origin is
+		// unavailable. Browsers would reject it; serve the plain content.
+		replyStale(w, r.UnsignedResponse)
 		return
 	}
-	replyOK(w, body.Bytes(), r.Exchange.Version.MimeType())
+	replyExchange(w, r.Exchange)
 }
 
 func (h *Handler) handleValidity(w http.ResponseWriter, req *http.Request) {
-	replyOK(w, emptyMapCBOR, mimeTypeValidity)
+	data, err := h.ValidityBuilder.Build(req, h.CertManager.GetAugmentedChain())
+	if err != nil {
+		replyServerError(w, xerrors.Errorf("building validity data: %w", err))
+		return
+	}
+	replyOK(w, data, mimeTypeValidity)
 }
 
 func (h *Handler) handleHealth(w http.ResponseWriter, req *http.Request) {
@@ -203,6 +280,12 @@ func (h *Handler) handleHealth(w http.ResponseWriter, req *http.Request) {
 	w.Write([]byte("ok"))
 }
 
+// isExpired reports whether e is past its expiry at now.
+func isExpired(e *signedexchange.Exchange, now time.Time) bool {
+	vp, err := exchange.GetValidPeriod(e)
+	return err == nil && now.After(vp.Expires())
+}
+
 func filterError(err error, url string) error {
 	switch err := err.(type) {
 	case *webpackager.Error:
@@ -247,9 +330,10 @@ func parseSignURL(rawurl string) (*url.URL, error) {
 
 	// Prevent malformed URLs from eluding the PathRE protections.
 	u.Path = urlutil.GetCleanPath(u)
-	// Escape special characters in the query component such as "<" or "|"
-	// (but not "&" or "=").
-	u.RawQuery = url.PathEscape(u.RawQuery)
+	// Sign IRIs under the canonical URIs. This also escapes special
+	// characters in the query component such as "<" or "|" (but not "&"
+	// or "=").
+	urlutil.NormalizeIRI(u)
 
 	return u, nil
 }
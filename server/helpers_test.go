@@ -60,16 +60,21 @@ var _ certmanager.Cache = (*stubCache)(nil)
 type stubCache struct {
 	avail    chan struct{}
 	chainMap map[string]*certchain.AugmentedChain
+	readErr  error // Returned by Read if non-nil.
 }
 
 func newStubCache() *stubCache {
 	return &stubCache{
 		make(chan struct{}, 1),
 		make(map[string]*certchain.AugmentedChain),
+		nil,
 	}
 }
 
 func (c *stubCache) Read(digest string) (*certchain.AugmentedChain, error) {
+	if c.readErr != nil {
+		return nil, c.readErr
+	}
 	if _, ok := c.chainMap[digest]; !ok {
 		return nil, certmanager.ErrNotFound
 	}
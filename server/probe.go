@@ -0,0 +1,97 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// ProbeResult is the outcome of probing a URL with Probe.
+type ProbeResult struct {
+	// URL is the probed URL.
+	URL string
+
+	// Problems describes why the response is not likely to be signed,
+	// e.g. "status 404, want 200". Empty if none is found.
+	Problems []string
+}
+
+// Probe fetches ProbeURLs, canary URLs on the backend servers, and checks
+// the responses are signable: the status code is 200, Cache-Control allows
+// shared caches (no "no-store" or "private"), and no cookies are set. It is
+// meant to catch misconfigured backends at deploy time, before the first
+// requests arrive. Probe logs a warning for each problem found and returns
+// the results; it never fails.
+//
+// The requests are sent through the FetchClient of Packager, with its
+// RequestTweaker applied, thus in the same way as packaging requests.
+func (s *Server) Probe() []ProbeResult {
+	results := make([]ProbeResult, len(s.ProbeURLs))
+	for i, u := range s.ProbeURLs {
+		results[i] = ProbeResult{u, s.probeURL(u)}
+		if len(results[i].Problems) == 0 {
+			log.Printf("probe ok for %s", u)
+		}
+		for _, p := range results[i].Problems {
+			log.Printf("warning: probe failed for %s: %s", u, p)
+		}
+	}
+	return results
+}
+
+func (s *Server) probeURL(u string) []string {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	if err := s.Packager.RequestTweaker.Tweak(req, nil); err != nil {
+		return []string{err.Error()}
+	}
+	resp, err := s.Packager.FetchClient.Do(req)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	var problems []string
+	if resp.StatusCode != http.StatusOK {
+		problems = append(problems, fmt.Sprintf("status %d, want 200", resp.StatusCode))
+	}
+	for _, value := range resp.Header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			name := strings.ToLower(strings.TrimSpace(strings.SplitN(directive, "=", 2)[0]))
+			if name == "no-store" || name == "private" {
+				problems = append(problems, fmt.Sprintf("not cacheable (Cache-Control: %s)", name))
+			}
+		}
+	}
+	if len(resp.Header.Values("Set-Cookie")) > 0 {
+		problems = append(problems, "sets cookies, which are removed from signed exchanges")
+	}
+	return problems
+}
+
+// startProbe runs Probe in background if ProbeURLs is set.
+func (s *Server) startProbe() {
+	if len(s.ProbeURLs) > 0 {
+		go s.Probe()
+	}
+}
@@ -15,10 +15,19 @@
 package server
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
 	"strconv"
+
+	"github.com/WICG/webpackage/go/signedexchange"
+	"github.com/layer0-platform/webpackager/exchange"
+	"github.com/layer0-platform/webpackager/processor/preverify"
+	"golang.org/x/xerrors"
 )
 
 func replyOK(w http.ResponseWriter, body []byte, mimeType string) {
@@ -31,6 +40,100 @@ func replyOK(w http.ResponseWriter, body []byte, mimeType string) {
 	}
 }
 
+// replyExchange writes e to w. The payload is streamed from e rather than
+// copied into a buffer with the whole exchange, to save memory for large
+// resources. Content-Length is computed with exchange.EncodedSize, which also
+// detects errors e.Write would report before anything is sent.
+func replyExchange(w http.ResponseWriter, e *signedexchange.Exchange) {
+	size, err := exchange.EncodedSize(e)
+	if err != nil {
+		replyServerError(w, xerrors.Errorf("serializing exchange: %w", err))
+		return
+	}
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	w.Header().Set("Content-Type", e.Version.MimeType())
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	if err := e.Write(w); err != nil {
+		log.Printf("i/o error: %v", err) // Already sent StatusOK, so just log.
+	}
+}
+
+// replyStale relays the unsigned HTTP response raw, in the HTTP/1.1 wire
+// format, with a Warning header telling the response is stale.
+func replyStale(w http.ResponseWriter, raw []byte) {
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(raw)), nil)
+	if err != nil {
+		replyServerError(w, xerrors.Errorf("parsing unsigned response: %w", err))
+		return
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		replyServerError(w, xerrors.Errorf("parsing unsigned response: %w", err))
+		return
+	}
+	for key, val := range resp.Header {
+		w.Header()[key] = val
+	}
+	w.Header().Add("Warning", `110 - "Response is Stale"`)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(resp.StatusCode)
+	if _, err := w.Write(body); err != nil {
+		log.Printf("i/o error: %v", err) // Already sent the status, so just log.
+	}
+}
+
+// replyErrorBody replies with status and body, or with the standard text
+// for status if body is nil.
+func replyErrorBody(w http.ResponseWriter, status int, body []byte) {
+	if body == nil {
+		replyError(w, status)
+		return
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.Header().Set("Content-Type", http.DetectContentType(body))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	if _, err := w.Write(body); err != nil {
+		log.Printf("i/o error: %v", err) // Already sent the status, so just log.
+	}
+}
+
+// hopByHopHeaders lists the headers meaningful only for a single transport
+// connection, which are not relayed by replyUpstream.
+var hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// replyUpstream relays the response from the upstream server carried by
+// httpErr. u is the URL requested to the upstream server, against which
+// a relative Location is resolved.
+func replyUpstream(w http.ResponseWriter, httpErr *preverify.HTTPStatusError, u *url.URL) {
+	header := w.Header()
+	for key, val := range httpErr.Header {
+		header[key] = append([]string(nil), val...)
+	}
+	for _, key := range hopByHopHeaders {
+		header.Del(key)
+	}
+	if loc, err := url.Parse(header.Get("Location")); err == nil && loc.String() != "" {
+		header.Set("Location", u.ResolveReference(loc).String())
+	}
+	header.Set("Content-Length", strconv.Itoa(len(httpErr.Body)))
+	w.WriteHeader(httpErr.StatusCode)
+	if _, err := w.Write(httpErr.Body); err != nil {
+		log.Printf("i/o error: %v", err) // Already sent the status, so just log.
+	}
+}
+
 func replyServerError(w http.ResponseWriter, err error) {
 	log.Print(err)
 	replyError(w, http.StatusInternalServerError)
@@ -41,10 +144,6 @@ func replyClientError(w http.ResponseWriter, err error) {
 	replyError(w, http.StatusBadRequest)
 }
 
-func replyClientErrorSilent(w http.ResponseWriter) {
-	replyError(w, http.StatusBadRequest)
-}
-
 func replyError(w http.ResponseWriter, code int) {
 	http.Error(w, fmt.Sprintf("%d %s", code, http.StatusText(code)), code)
 }
@@ -20,7 +20,10 @@ import (
 )
 
 // Server encapsulates http.Server and Config so it can start and stop
-// CertManager automatically in Serve.
+// CertManager automatically in Serve. Serve and the similar methods also
+// warm up the cache with WarmUpURLs in background from startup, and
+// periodically when WarmUpInterval is set; the warm-up in progress is
+// canceled when they return. They also probe ProbeURLs on startup.
 type Server struct {
 	*http.Server
 	Config
@@ -39,6 +42,8 @@ func (s *Server) ListenAndServe() error {
 		return err
 	}
 	defer s.CertManager.Stop()
+	s.startProbe()
+	defer s.startWarmUp()()
 	return s.Server.ListenAndServe()
 }
 
@@ -49,6 +54,8 @@ func (s *Server) ListenAndServeTLS(certFile, keyFile string) error {
 		return err
 	}
 	defer s.CertManager.Stop()
+	s.startProbe()
+	defer s.startWarmUp()()
 	return s.Server.ListenAndServeTLS(certFile, keyFile)
 }
 
@@ -58,6 +65,8 @@ func (s *Server) Serve(l net.Listener) error {
 		return err
 	}
 	defer s.CertManager.Stop()
+	s.startProbe()
+	defer s.startWarmUp()()
 	return s.Server.Serve(l)
 }
 
@@ -67,5 +76,7 @@ func (s *Server) ServeTLS(l net.Listener, certFile, keyFile string) error {
 		return err
 	}
 	defer s.CertManager.Stop()
+	s.startProbe()
+	defer s.startWarmUp()()
 	return s.Server.ServeTLS(l, certFile, keyFile)
 }
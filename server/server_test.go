@@ -15,11 +15,15 @@
 package server_test
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"regexp"
 	"strings"
@@ -29,6 +33,7 @@ import (
 	"github.com/WICG/webpackage/go/signedexchange"
 	"github.com/google/go-cmp/cmp"
 	"github.com/layer0-platform/webpackager"
+	"github.com/layer0-platform/webpackager/certchain"
 	"github.com/layer0-platform/webpackager/certchain/certmanager"
 	"github.com/layer0-platform/webpackager/fetch"
 	"github.com/layer0-platform/webpackager/fetch/fetchtest"
@@ -43,16 +48,31 @@ import (
 
 const cborFile = "../testdata/certs/cbor/ecdsap256_nosct.cbor"
 
+var authKey = []byte("webpackager_test_key")
+
 func setupServer(www *httptest.Server) (*server.Server, string) {
+	return setupServerWithCache(www, newStubCache())
+}
+
+func setupServerWithCache(www *httptest.Server, cache certmanager.Cache) (*server.Server, string) {
+	return setupServerImpl(www, cache, func(*server.Config) {})
+}
+
+// setupServerWithConfig is like setupServer, but lets tweak modify the Config.
+func setupServerWithConfig(www *httptest.Server, tweak func(*server.Config)) (*server.Server, string) {
+	return setupServerImpl(www, newStubCache(), tweak)
+}
+
+func setupServerImpl(www *httptest.Server, cache certmanager.Cache, tweak func(*server.Config)) (*server.Server, string) {
 	ac := certchaintest.MustReadAugmentedChainFile(cborFile)
 
 	certManager := certmanager.NewManager(certmanager.Config{
 		RawChainSource: &stubRawChainSource{ac.RawChain},
 		OCSPRespSource: &stubOCSPRespSource{ac.OCSPResp},
-		Cache:          newStubCache(),
+		Cache:          cache,
 	})
 
-	s := server.NewServer(new(http.Server), server.Config{
+	config := server.Config{
 		ServerConfig: tomlconfig.ServerConfig{
 			DocPath:      "/priv/doc",
 			CertPath:     "/webpkg/cert",
@@ -61,6 +81,9 @@ func setupServer(www *httptest.Server) (*server.Server, string) {
 			SignParam:    "sign",
 		},
 		AllowTestCert: true,
+		AuthKey:       authKey,
+		AuthDocPath:   "/priv/authdoc",
+		AuthMaxSkew:   5 * time.Minute,
 		CertManager:   certManager,
 		Packager: webpackager.NewPackager(webpackager.Config{
 			FetchClient: fetch.WithSelector(
@@ -81,7 +104,9 @@ func setupServer(www *httptest.Server) (*server.Server, string) {
 				PrivateKey:  certchaintest.MustReadPrivateKeyFile("../testdata/keys/ecdsap256.key"),
 			}),
 		}),
-	})
+	}
+	tweak(&config)
+	s := server.NewServer(new(http.Server), config)
 
 	l, err := net.Listen("tcp", "localhost:0")
 	if err != nil {
@@ -108,6 +133,25 @@ func setupContentServer() *httptest.Server {
 			http.Error(w, "404 Not Found", http.StatusNotFound)
 		}
 	})
+	mux.HandleFunc("/public/привет.html", func(w http.ResponseWriter, r *http.Request) {
+		html := "<!doctype html><p>Привет, мир!</p>"
+		http.ServeContent(w, r, "hello.html", time.Time{}, strings.NewReader(html))
+	})
+	mux.HandleFunc("/public/moved.html", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "hello.html")
+		w.WriteHeader(http.StatusMovedPermanently)
+		w.Write([]byte("<p>Moved to hello.html.</p>"))
+	})
+	mux.HandleFunc("/public/cookie.html", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=1234")
+		html := "<!doctype html><p>Hello, world!</p>"
+		http.ServeContent(w, r, "hello.html", time.Time{}, strings.NewReader(html))
+	})
+	mux.HandleFunc("/public/nostore.html", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60, no-store")
+		html := "<!doctype html><p>Hello, world!</p>"
+		http.ServeContent(w, r, "hello.html", time.Time{}, strings.NewReader(html))
+	})
 	mux.HandleFunc("/private/hello.html", func(w http.ResponseWriter, r *http.Request) {
 		html := "<!doctype html><p>hello, world</p>"
 		http.ServeContent(w, r, "hello.html", time.Time{}, strings.NewReader(html))
@@ -123,9 +167,10 @@ func TestHandleDoc(t *testing.T) {
 	defer s.Close()
 
 	tests := []struct {
-		name   string
-		url    string
-		accept string
+		name    string
+		url     string
+		accept  string
+		wantURI string
 	}{
 		{
 			name:   "EmbeddedURL",
@@ -142,6 +187,18 @@ func TestHandleDoc(t *testing.T) {
 			url:    "http://" + addr + "/priv/doc?sign=https%3A%2F%2Fexample.com%2Fpublic%2Fhello.html",
 			accept: "application/signed-exchange;v=b3",
 		},
+		{
+			name:    "SignParamIRI",
+			url:     "http://" + addr + "/priv/doc?sign=" + url.QueryEscape("https://example.com/public/привет.html?q=мир"),
+			accept:  "application/signed-exchange;v=b3",
+			wantURI: "https://example.com/public/%D0%BF%D1%80%D0%B8%D0%B2%D0%B5%D1%82.html?q=%D0%BC%D0%B8%D1%80",
+		},
+		{
+			name:    "SignParamLowerHex",
+			url:     "http://" + addr + "/priv/doc?sign=" + url.QueryEscape("https://example.com/public/%d0%bf%d1%80%d0%b8%d0%b2%d0%b5%d1%82.html"),
+			accept:  "application/signed-exchange;v=b3",
+			wantURI: "https://example.com/public/%D0%BF%D1%80%D0%B8%D0%B2%D0%B5%D1%82.html",
+		},
 		{
 			name:   "ComplexAcceptHeader",
 			url:    "http://" + addr + "/priv/doc/https://example.com/public/hello.html",
@@ -177,7 +234,15 @@ func TestHandleDoc(t *testing.T) {
 				t.Errorf("[X-Content-Type-Options] = %q, want %q", got, "nosniff")
 			}
 
-			sxg, err := signedexchange.ReadExchange(resp.Body)
+			body, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := resp.ContentLength, int64(len(body)); got != want {
+				t.Errorf("ContentLength = %d, want %d", got, want)
+			}
+
+			sxg, err := signedexchange.ReadExchange(bytes.NewReader(body))
 			if err != nil {
 				t.Errorf("ReadExchange() = error(%q), want success", err)
 			}
@@ -189,6 +254,106 @@ func TestHandleDoc(t *testing.T) {
 			if !ok {
 				t.Fatalf("Verify() = !ok, want ok")
 			}
+			if test.wantURI != "" && sxg.RequestURI != test.wantURI {
+				t.Errorf("RequestURI = %q, want %q", sxg.RequestURI, test.wantURI)
+			}
+		})
+	}
+}
+
+func TestHandleDoc_UpstreamError(t *testing.T) {
+	www := setupContentServer()
+	defer www.Close()
+
+	mapper := func(err error) (int, []byte) {
+		return http.StatusBadGateway, []byte("upstream failed")
+	}
+
+	tests := []struct {
+		name         string
+		path         string
+		tweak        func(*server.Config)
+		wantStatus   int
+		wantBody     string
+		wantLocation string
+	}{
+		{
+			name:       "Default",
+			path:       "/public/page.cgi?id=nope",
+			tweak:      func(*server.Config) {},
+			wantStatus: http.StatusInternalServerError,
+			wantBody:   "500 Internal Server Error\n",
+		},
+		{
+			name:       "PassThrough",
+			path:       "/public/page.cgi?id=nope",
+			tweak:      func(c *server.Config) { c.ErrorMapper = server.PassThroughErrorMapper },
+			wantStatus: http.StatusNotFound,
+			wantBody:   "404 Not Found\n",
+		},
+		{
+			name:       "ErrorMapper",
+			path:       "/public/page.cgi?id=nope",
+			tweak:      func(c *server.Config) { c.ErrorMapper = mapper },
+			wantStatus: http.StatusBadGateway,
+			wantBody:   "upstream failed",
+		},
+		{
+			name:       "Redirect_PassThrough",
+			path:       "/public/moved.html",
+			tweak:      func(c *server.Config) { c.ErrorMapper = server.PassThroughErrorMapper },
+			wantStatus: http.StatusMovedPermanently,
+			wantBody:   "301 Moved Permanently\n",
+		},
+		{
+			name:         "Redirect_Relay",
+			path:         "/public/moved.html",
+			tweak:        func(c *server.Config) { c.RelayUpstreamErrors = true },
+			wantStatus:   http.StatusMovedPermanently,
+			wantBody:     "<p>Moved to hello.html.</p>",
+			wantLocation: "https://example.com/public/hello.html",
+		},
+	}
+
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, addr := setupServerWithConfig(www, test.tweak)
+			defer s.Close()
+
+			timeutil.StubNowToAdjust(time.Date(2020, time.May, 1, 0, 0, 0, 0, time.UTC))
+
+			url := "http://" + addr + "/priv/doc/https://example.com" + test.path
+			req, err := http.NewRequest(http.MethodGet, url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Add("Accept", "application/signed-exchange;v=b3")
+
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if got := resp.StatusCode; got != test.wantStatus {
+				t.Errorf("StatusCode = %v, want %v", got, test.wantStatus)
+			}
+			if got := resp.Header.Get("Location"); got != test.wantLocation {
+				t.Errorf("[Location] = %q, want %q", got, test.wantLocation)
+			}
+			body, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(body); got != test.wantBody {
+				t.Errorf("Body = %q, want %q", got, test.wantBody)
+			}
 		})
 	}
 }
@@ -287,6 +452,87 @@ func TestHandleDoc_ClientError(t *testing.T) {
 	}
 }
 
+func TestHandleAuthDoc(t *testing.T) {
+	www := setupContentServer()
+	defer www.Close()
+	s, addr := setupServer(www)
+	defer s.Close()
+
+	now := time.Date(2020, time.May, 1, 0, 0, 0, 0, time.UTC)
+	signURL := "https://example.com/public/hello.html"
+
+	makeURL := func(signURL string, ts time.Time, sig string) string {
+		query := url.Values{}
+		query.Set("sign", signURL)
+		query.Set(server.AuthTimestampParam, fmt.Sprint(ts.Unix()))
+		query.Set(server.AuthSignatureParam, sig)
+		return "http://" + addr + "/priv/authdoc?" + query.Encode()
+	}
+
+	tests := []struct {
+		name string
+		url  string
+		want int
+	}{
+		{
+			name: "Success",
+			url:  makeURL(signURL, now, server.SignAuthRequest(authKey, signURL, now)),
+			want: http.StatusOK,
+		},
+		{
+			name: "SlightlySkewed",
+			url: makeURL(signURL, now.Add(-time.Minute),
+				server.SignAuthRequest(authKey, signURL, now.Add(-time.Minute))),
+			want: http.StatusOK,
+		},
+		{
+			name: "WrongKey",
+			url:  makeURL(signURL, now, server.SignAuthRequest([]byte("wrong"), signURL, now)),
+			want: http.StatusForbidden,
+		},
+		{
+			name: "WrongURL",
+			url: makeURL(signURL, now,
+				server.SignAuthRequest(authKey, "https://example.com/public/page.cgi?id=hello", now)),
+			want: http.StatusForbidden,
+		},
+		{
+			name: "StaleTimestamp",
+			url: makeURL(signURL, now.Add(-time.Hour),
+				server.SignAuthRequest(authKey, signURL, now.Add(-time.Hour))),
+			want: http.StatusForbidden,
+		},
+		{
+			name: "MissingSignature",
+			url:  makeURL(signURL, now, ""),
+			want: http.StatusForbidden,
+		},
+		{
+			name: "SignURL_NotForFetch",
+			url: makeURL("https://example.com/private/hello.html", now,
+				server.SignAuthRequest(authKey, "https://example.com/private/hello.html", now)),
+			want: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			timeutil.StubNowToAdjust(now)
+
+			// No Accept header is required.
+			resp, err := http.Get(test.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if got := resp.StatusCode; got != test.want {
+				t.Errorf("StatusCode = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
 func TestHandleCert(t *testing.T) {
 	www := setupContentServer()
 	defer www.Close()
@@ -348,6 +594,70 @@ func TestHandlerCert_DigestMismatch(t *testing.T) {
 	}
 }
 
+func TestHandleCert_CacheUnavailable(t *testing.T) {
+	www := setupContentServer()
+	defer www.Close()
+	cache := newStubCache()
+	cache.readErr = errors.New("cache unavailable")
+	s, addr := setupServerWithCache(www, cache)
+	defer s.Close()
+
+	// Getting a response ensures the CertManager has started.
+	resp, err := http.Get("http://" + addr + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	wantBody, err := ioutil.ReadFile(cborFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		digest     string
+		wantStatus int
+		wantBody   []byte
+	}{
+		{
+			name:       "CurrentChain",
+			digest:     "qwk4hz4Swff9wKMvr1hri3YH4MeFAH8_PE9jnJ9nx6A",
+			wantStatus: http.StatusOK,
+			wantBody:   wantBody,
+		},
+		{
+			name:       "OtherChain",
+			digest:     "k8HZqkHWuFLy34Bc0R-QKD0Vkb7LwoM_ckBc_li0Nzc",
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp, err := http.Get("http://" + addr + "/webpkg/cert/" + test.digest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if got := resp.StatusCode; got != test.wantStatus {
+				t.Errorf("StatusCode = %v, want %v", got, test.wantStatus)
+			}
+			if test.wantBody == nil {
+				return
+			}
+			gotBody, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.wantBody, gotBody); diff != "" {
+				t.Errorf("Body mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
 func TestHandleHealth(t *testing.T) {
 	www := setupContentServer()
 	defer www.Close()
@@ -411,3 +721,189 @@ func TestHandleValidity(t *testing.T) {
 		t.Errorf("Body mismatch (-want +got):\n%s", diff)
 	}
 }
+
+func TestHandleValidity_CustomBuilder(t *testing.T) {
+	www := setupContentServer()
+	defer www.Close()
+
+	errBuild := errors.New("build failed")
+
+	tests := []struct {
+		name       string
+		builder    validity.BuilderFunc
+		wantStatus int
+		wantBody   []byte
+	}{
+		{
+			name: "Success",
+			builder: func(req *http.Request, chain *certchain.AugmentedChain) ([]byte, error) {
+				if chain == nil {
+					return nil, errors.New("chain is nil")
+				}
+				// A CBOR text string of the request path.
+				return append([]byte{0x60 + byte(len(req.URL.Path))}, req.URL.Path...), nil
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   append([]byte{0x70}, "/webpkg/validity"...),
+		},
+		{
+			name: "Error",
+			builder: func(*http.Request, *certchain.AugmentedChain) ([]byte, error) {
+				return nil, errBuild
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, addr := setupServerWithConfig(www, func(c *server.Config) {
+				c.ValidityBuilder = test.builder
+			})
+			defer s.Close()
+
+			// Getting a response ensures the CertManager has started.
+			resp, err := http.Get("http://" + addr + "/healthz")
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			resp, err = http.Get("http://" + addr + "/webpkg/validity")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if got := resp.StatusCode; got != test.wantStatus {
+				t.Errorf("StatusCode = %v, want %v", got, test.wantStatus)
+			}
+			if test.wantBody == nil {
+				return
+			}
+			gotBody, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.wantBody, gotBody); diff != "" {
+				t.Errorf("Body mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWarmUp(t *testing.T) {
+	www := setupContentServer()
+	defer www.Close()
+	s, addr := setupServer(www)
+	defer s.Close()
+
+	timeutil.StubNowToAdjust(time.Date(2020, time.May, 1, 0, 0, 0, 0, time.UTC))
+
+	// Getting a response ensures the CertManager has started.
+	resp, err := http.Get("http://" + addr + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	s.WarmUpURLs = []string{
+		"https://example.com/public/hello.html",
+		"https://example.com/public/page.cgi?id=hello",
+		"https://example.com/private/hello.html",
+	}
+	s.WarmUpConcurrency = 2
+	s.WarmUp()
+
+	tests := []struct {
+		url        string
+		wantCached bool
+	}{
+		{"https://example.com/public/hello.html", true},
+		{"https://example.com/public/page.cgi?id=hello", true},
+		{"https://example.com/private/hello.html", false},
+	}
+
+	for _, test := range tests {
+		req, err := http.NewRequest(http.MethodGet, test.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := s.Packager.ResourceCache.Lookup(req)
+		if err != nil {
+			t.Fatalf("Lookup(%q) = error(%q), want success", test.url, err)
+		}
+		if got := r != nil && r.Exchange != nil; got != test.wantCached {
+			t.Errorf("cached(%q) = %v, want %v", test.url, got, test.wantCached)
+		}
+	}
+}
+
+func TestWarmUp_CanceledOnClose(t *testing.T) {
+	started := make(chan struct{})
+	canceled := make(chan struct{})
+	www := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		select {
+		case <-r.Context().Done():
+			close(canceled)
+		case <-time.After(10 * time.Second):
+		}
+	}))
+	defer www.Close()
+
+	timeutil.StubNowToAdjust(time.Date(2020, time.May, 1, 0, 0, 0, 0, time.UTC))
+
+	s, _ := setupServerWithConfig(www, func(c *server.Config) {
+		c.WarmUpURLs = []string{"https://example.com/public/slow.html"}
+		c.WarmUpConcurrency = 1
+	})
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("warm-up did not start")
+	}
+	s.Close()
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+		t.Error("warm-up was not canceled on Close")
+	}
+}
+
+func TestProbe(t *testing.T) {
+	www := setupContentServer()
+	defer www.Close()
+	s, _ := setupServer(www)
+	defer s.Close()
+
+	tests := []struct {
+		url         string
+		wantProblem bool
+	}{
+		{"https://example.com/public/hello.html", false},
+		{"https://example.com/public/moved.html", true},
+		{"https://example.com/public/cookie.html", true},
+		{"https://example.com/public/nostore.html", true},
+		{"https://example.com/private/hello.html", true},
+	}
+
+	s.ProbeURLs = nil
+	for _, test := range tests {
+		s.ProbeURLs = append(s.ProbeURLs, test.url)
+	}
+	results := s.Probe()
+	if len(results) != len(tests) {
+		t.Fatalf("len(results) = %d, want %d", len(results), len(tests))
+	}
+	for i, test := range tests {
+		r := results[i]
+		if r.URL != test.url {
+			t.Errorf("results[%d].URL = %q, want %q", i, r.URL, test.url)
+		}
+		if got := len(r.Problems) > 0; got != test.wantProblem {
+			t.Errorf("problems(%q) = %q, want problems: %v", test.url, r.Problems, test.wantProblem)
+		}
+	}
+}
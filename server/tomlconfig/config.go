@@ -28,8 +28,11 @@ type Config struct {
 	Server    ServerConfig
 	SXG       SXGConfig
 	Sign      SignConfig
+	Host      HostConfig
 	Processor ProcessorConfig
 	Cache     CacheConfig
+	Fetch     FetchConfig
+	Auth      AuthConfig
 }
 
 // ListenConfig represents the [Listen] section.
@@ -52,6 +55,14 @@ type ServerConfig struct {
 	ValidityPath string `default:"/webpkg/validity"`
 	HealthPath   string `default:"/healthz"`
 	SignParam    string `default:"sign"`
+
+	RelayUpstreamErrors bool
+
+	WarmUpURLs        []string
+	WarmUpInterval    string
+	WarmUpConcurrency int `default:"4"`
+
+	ProbeURLs []string
 }
 
 // SXGConfig represents the [SXG] section.
@@ -61,16 +72,20 @@ type SXGConfig struct {
 	CertURLBase        string `default:"/webpkg/cert"`
 	ValidityURL        string `default:"/webpkg/validity"`
 	KeepNonSXGPreloads bool
+	AbsolutePreloads   bool
+	MaxSignings        int
 	Cert               SXGCertConfig
 	ACME               SXGACMEConfig
 }
 
 // SXGCertConfig represents the [SXG.Cert] section.
 type SXGCertConfig struct {
-	PEMFile       string
-	KeyFile       string
-	CacheDir      string
-	AllowTestCert bool
+	PEMFile        string
+	KeyFile        string
+	CacheDir       string
+	AllowTestCert  bool
+	RetainPrevious string `default:"168h"`
+	WatchInterval  string
 }
 
 // SXGACMEConfig represents the [SXG.ACME] section.
@@ -97,6 +112,20 @@ type URLConfig struct {
 	QueryRE string `default:""`
 }
 
+// HostConfig represents the [[Host]] sections.
+type HostConfig []HostOverrideConfig
+
+// HostOverrideConfig represents each of the [[Host]] sections. Empty or
+// unset fields inherit the values from the [SXG] and [Processor] sections.
+type HostOverrideConfig struct {
+	Domain     string
+	Expiry     string
+	JSExpiry   string
+	PreloadCSS *bool
+	PreloadJS  *bool
+	SizeLimit  int
+}
+
 // ProcessorConfig represents the [Processor] section.
 type ProcessorConfig struct {
 	SizeLimit  int `default:"4194304"`
@@ -106,7 +135,33 @@ type ProcessorConfig struct {
 
 // CacheConfig represents the [Cache] section.
 type CacheConfig struct {
-	MaxEntries int `default:"200"`
+	MaxEntries   int    `default:"200"`
+	StaleIfError string `default:"0s"`
+}
+
+// FetchConfig represents the [Fetch] section.
+type FetchConfig struct {
+	MaxIdleConnsPerHost   int
+	MaxConnsPerHost       int
+	IdleConnTimeout       string `default:"90s"`
+	DialTimeout           string
+	TLSHandshakeTimeout   string `default:"10s"`
+	ResponseHeaderTimeout string
+	RequestTimeout        string
+	MaxFetches            int
+	FetchQueueTimeout     string `default:"5s"`
+	Resolve               []string
+	ClientCertFile        string
+	ClientKeyFile         string
+	ClientCertHosts       []string
+}
+
+// AuthConfig represents the [Auth] section.
+type AuthConfig struct {
+	Enable  bool
+	DocPath string `default:"/priv/authdoc"`
+	KeyFile string
+	MaxSkew string `default:"5m"`
 }
 
 // ReadFromFile reads a Config from filename. It also validates all fields
@@ -21,6 +21,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/layer0-platform/webpackager/fetch"
 	"github.com/layer0-platform/webpackager/internal/urlutil"
 	"golang.org/x/xerrors"
 )
@@ -84,6 +85,186 @@ func parseJSExpiry(value string) (time.Duration, error) {
 	return d, nil
 }
 
+// GetMaxSkew returns a parsed c.MaxSkew. It panics if c.MaxSkew contains an
+// invalid value; it should not happen if c is obtained using ParseConfig or
+// ReadFromFile.
+func (c *AuthConfig) GetMaxSkew() time.Duration {
+	d, err := parseMaxSkew(c.MaxSkew)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func parseMaxSkew(value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, errors.New("must be positive")
+	}
+	return d, nil
+}
+
+// GetRetainPrevious returns a parsed c.RetainPrevious. It panics if
+// c.RetainPrevious contains an invalid value; it should not happen if c is
+// obtained using ParseConfig or ReadFromFile.
+func (c *SXGCertConfig) GetRetainPrevious() time.Duration {
+	d, err := parseNonNegativeDuration(c.RetainPrevious)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// GetWatchInterval returns a parsed c.WatchInterval, or zero if it is empty.
+// It panics if c.WatchInterval contains an invalid value; it should not
+// happen if c is obtained using ParseConfig or ReadFromFile.
+func (c *SXGCertConfig) GetWatchInterval() time.Duration {
+	d, err := parseOptionalTimeout(c.WatchInterval)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// GetIdleConnTimeout returns a parsed c.IdleConnTimeout. It panics if
+// c.IdleConnTimeout contains an invalid value; it should not happen if c is
+// obtained using ParseConfig or ReadFromFile.
+func (c *FetchConfig) GetIdleConnTimeout() time.Duration {
+	d, err := parseTimeout(c.IdleConnTimeout)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// GetTLSHandshakeTimeout returns a parsed c.TLSHandshakeTimeout. It panics
+// if c.TLSHandshakeTimeout contains an invalid value; it should not happen
+// if c is obtained using ParseConfig or ReadFromFile.
+func (c *FetchConfig) GetTLSHandshakeTimeout() time.Duration {
+	d, err := parseTimeout(c.TLSHandshakeTimeout)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// GetDialTimeout returns a parsed c.DialTimeout, or zero if it is empty.
+// It panics if c.DialTimeout contains an invalid value; it should not happen
+// if c is obtained using ParseConfig or ReadFromFile.
+func (c *FetchConfig) GetDialTimeout() time.Duration {
+	d, err := parseOptionalTimeout(c.DialTimeout)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// GetResponseHeaderTimeout returns a parsed c.ResponseHeaderTimeout, or zero
+// if it is empty. It panics if c.ResponseHeaderTimeout contains an invalid
+// value; it should not happen if c is obtained using ParseConfig or
+// ReadFromFile.
+func (c *FetchConfig) GetResponseHeaderTimeout() time.Duration {
+	d, err := parseOptionalTimeout(c.ResponseHeaderTimeout)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// GetRequestTimeout returns a parsed c.RequestTimeout, or zero if it is
+// empty. It panics if c.RequestTimeout contains an invalid value; it should
+// not happen if c is obtained using ParseConfig or ReadFromFile.
+func (c *FetchConfig) GetRequestTimeout() time.Duration {
+	d, err := parseOptionalTimeout(c.RequestTimeout)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// GetResolveOverrides returns a parsed c.Resolve. It panics if c.Resolve
+// contains an invalid value; it should not happen if c is obtained using
+// ParseConfig or ReadFromFile.
+func (c *FetchConfig) GetResolveOverrides() map[string]string {
+	overrides, err := fetch.ParseResolveOverrides(c.Resolve)
+	if err != nil {
+		panic(err)
+	}
+	return overrides
+}
+
+// GetFetchQueueTimeout returns a parsed c.FetchQueueTimeout. It panics if
+// c.FetchQueueTimeout contains an invalid value; it should not happen if c
+// is obtained using ParseConfig or ReadFromFile.
+func (c *FetchConfig) GetFetchQueueTimeout() time.Duration {
+	d, err := parseNonNegativeDuration(c.FetchQueueTimeout)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// GetStaleIfError returns a parsed c.StaleIfError. It panics if
+// c.StaleIfError contains an invalid value; it should not happen if c is
+// obtained using ParseConfig or ReadFromFile.
+func (c *CacheConfig) GetStaleIfError() time.Duration {
+	d, err := parseNonNegativeDuration(c.StaleIfError)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func parseNonNegativeDuration(value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, errors.New("must not be negative")
+	}
+	return d, nil
+}
+
+func parseTimeout(value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, errors.New("must be positive")
+	}
+	return d, nil
+}
+
+// GetWarmUpInterval returns a parsed c.WarmUpInterval, or zero if it is
+// empty. It panics if c.WarmUpInterval contains an invalid value; it should
+// not happen if c is obtained using ParseConfig or ReadFromFile.
+func (c *ServerConfig) GetWarmUpInterval() time.Duration {
+	d, err := parseWarmUpInterval(c.WarmUpInterval)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func parseOptionalTimeout(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return parseTimeout(value)
+}
+
+func parseWarmUpInterval(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return parseTimeout(value)
+}
+
 // GetCertURLBase returns a parsed c.CertURLBase. It panics if c.CertURLBase
 // cannot be parsed; it should not happen if c is obtained using ParseConfig
 // or ReadFromFile.
@@ -115,3 +296,30 @@ func (c *URLConfig) GetQueryRE() *regexp.Regexp {
 func mustCompileFullMatch(pattern string) *regexp.Regexp {
 	return regexp.MustCompile(`\A(?:` + pattern + `)\z`)
 }
+
+// OverrideSXG returns a copy of base with Expiry and JSExpiry replaced by
+// the values specified in c, if any.
+func (c *HostOverrideConfig) OverrideSXG(base SXGConfig) SXGConfig {
+	if c.Expiry != "" {
+		base.Expiry = c.Expiry
+	}
+	if c.JSExpiry != "" {
+		base.JSExpiry = c.JSExpiry
+	}
+	return base
+}
+
+// OverrideProcessor returns a copy of base with the fields replaced by the
+// values specified in c, if any.
+func (c *HostOverrideConfig) OverrideProcessor(base ProcessorConfig) ProcessorConfig {
+	if c.SizeLimit != 0 {
+		base.SizeLimit = c.SizeLimit
+	}
+	if c.PreloadCSS != nil {
+		base.PreloadCSS = *c.PreloadCSS
+	}
+	if c.PreloadJS != nil {
+		base.PreloadJS = *c.PreloadJS
+	}
+	return base
+}
@@ -23,6 +23,7 @@ import (
 	"strings"
 
 	"github.com/hashicorp/go-multierror"
+	"github.com/layer0-platform/webpackager/fetch"
 )
 
 var (
@@ -47,9 +48,24 @@ func (c *Config) Verify() error {
 	if err := c.Sign.verify(); err != nil {
 		errs = multierror.Append(errs, wrapError("Sign", err))
 	}
+	if err := c.Host.verify(); err != nil {
+		errs = multierror.Append(errs, wrapError("Host", err))
+	}
 	if err := c.Processor.verify(); err != nil {
 		errs = multierror.Append(errs, wrapError("Processor", err))
 	}
+	if err := c.Cache.verify(); err != nil {
+		errs = multierror.Append(errs, wrapError("Cache", err))
+	}
+	if err := c.Fetch.verify(); err != nil {
+		errs = multierror.Append(errs, wrapError("Fetch", err))
+	}
+	if err := c.Auth.verify(); err != nil {
+		errs = multierror.Append(errs, wrapError("Auth", err))
+	}
+	if c.Auth.Enable && path.Clean(c.Auth.DocPath) == path.Clean(c.Server.DocPath) {
+		errs = multierror.Append(errs, newError("Auth.DocPath", "must differ from Server.DocPath"))
+	}
 
 	return errs.ErrorOrNil() // TODO(yuizumi): Format it better.
 }
@@ -92,6 +108,22 @@ func (c *ServerConfig) verify() error {
 	if err := verifyParamName(c.SignParam); err != nil {
 		errs = multierror.Append(errs, wrapError("SignParam", err))
 	}
+	for i, u := range c.WarmUpURLs {
+		if err := verifyWarmUpURL(u); err != nil {
+			errs = multierror.Append(errs, wrapError(fmt.Sprintf("WarmUpURLs[%d]", i), err))
+		}
+	}
+	for i, u := range c.ProbeURLs {
+		if err := verifyWarmUpURL(u); err != nil {
+			errs = multierror.Append(errs, wrapError(fmt.Sprintf("ProbeURLs[%d]", i), err))
+		}
+	}
+	if _, err := parseWarmUpInterval(c.WarmUpInterval); err != nil {
+		errs = multierror.Append(errs, wrapError("WarmUpInterval", err))
+	}
+	if c.WarmUpConcurrency <= 0 {
+		errs = multierror.Append(errs, wrapError("WarmUpConcurrency", errRange))
+	}
 
 	return errs.ErrorOrNil()
 }
@@ -111,6 +143,9 @@ func (c *SXGConfig) verify() error {
 	if err := verifyValidityURL(c.ValidityURL); err != nil {
 		errs = multierror.Append(errs, wrapError("ValidityURL", err))
 	}
+	if c.MaxSignings < 0 {
+		errs = multierror.Append(errs, wrapError("MaxSignings", errRange))
+	}
 	if err := c.Cert.verify(); err != nil {
 		errs = multierror.Append(errs, wrapError("Cert", err))
 	}
@@ -130,6 +165,12 @@ func (c *SXGCertConfig) verify() error {
 	if c.KeyFile == "" {
 		errs = multierror.Append(errs, wrapError("KeyFile", errEmpty))
 	}
+	if _, err := parseNonNegativeDuration(c.RetainPrevious); err != nil {
+		errs = multierror.Append(errs, wrapError("RetainPrevious", err))
+	}
+	if _, err := parseOptionalTimeout(c.WatchInterval); err != nil {
+		errs = multierror.Append(errs, wrapError("WatchInterval", err))
+	}
 
 	return errs.ErrorOrNil()
 }
@@ -194,6 +235,52 @@ func (c *URLConfig) verify() error {
 	return errs.ErrorOrNil()
 }
 
+func (c HostConfig) verify() error {
+	var errs *multierror.Error
+
+	seen := make(map[string]int)
+	for i, hc := range c {
+		if err := hc.verify(); err != nil {
+			errs = multierror.Append(errs, wrapError(fmt.Sprintf("[%d]", i), err))
+			continue
+		}
+		key := strings.ToLower(hc.Domain)
+		if j, ok := seen[key]; ok {
+			errs = multierror.Append(errs, newError(
+				fmt.Sprintf("[%d].Domain", i),
+				fmt.Sprintf("overlaps with [%d].Domain", j),
+			))
+			continue
+		}
+		seen[key] = i
+	}
+
+	return errs.ErrorOrNil()
+}
+
+func (c *HostOverrideConfig) verify() error {
+	var errs *multierror.Error
+
+	if err := verifyHostName(c.Domain); err != nil {
+		errs = multierror.Append(errs, wrapError("Domain", err))
+	}
+	if c.Expiry != "" {
+		if _, err := parseExpiry(c.Expiry); err != nil {
+			errs = multierror.Append(errs, wrapError("Expiry", err))
+		}
+	}
+	if c.JSExpiry != "" {
+		if _, err := parseJSExpiry(c.JSExpiry); err != nil {
+			errs = multierror.Append(errs, wrapError("JSExpiry", err))
+		}
+	}
+	if c.SizeLimit < 0 {
+		errs = multierror.Append(errs, wrapError("SizeLimit", errRange))
+	}
+
+	return errs.ErrorOrNil()
+}
+
 func (c *ProcessorConfig) verify() error {
 	var errs *multierror.Error
 
@@ -204,6 +291,84 @@ func (c *ProcessorConfig) verify() error {
 	return errs.ErrorOrNil()
 }
 
+func (c *CacheConfig) verify() error {
+	if _, err := parseNonNegativeDuration(c.StaleIfError); err != nil {
+		return wrapError("StaleIfError", err)
+	}
+	return nil
+}
+
+func (c *FetchConfig) verify() error {
+	var errs *multierror.Error
+
+	if c.MaxIdleConnsPerHost < 0 {
+		errs = multierror.Append(errs, wrapError("MaxIdleConnsPerHost", errRange))
+	}
+	if c.MaxConnsPerHost < 0 {
+		errs = multierror.Append(errs, wrapError("MaxConnsPerHost", errRange))
+	}
+	if _, err := parseTimeout(c.IdleConnTimeout); err != nil {
+		errs = multierror.Append(errs, wrapError("IdleConnTimeout", err))
+	}
+	if _, err := parseOptionalTimeout(c.DialTimeout); err != nil {
+		errs = multierror.Append(errs, wrapError("DialTimeout", err))
+	}
+	if _, err := parseTimeout(c.TLSHandshakeTimeout); err != nil {
+		errs = multierror.Append(errs, wrapError("TLSHandshakeTimeout", err))
+	}
+	if _, err := parseOptionalTimeout(c.ResponseHeaderTimeout); err != nil {
+		errs = multierror.Append(errs, wrapError("ResponseHeaderTimeout", err))
+	}
+	if _, err := parseOptionalTimeout(c.RequestTimeout); err != nil {
+		errs = multierror.Append(errs, wrapError("RequestTimeout", err))
+	}
+	if c.MaxFetches < 0 {
+		errs = multierror.Append(errs, wrapError("MaxFetches", errRange))
+	}
+	if _, err := parseNonNegativeDuration(c.FetchQueueTimeout); err != nil {
+		errs = multierror.Append(errs, wrapError("FetchQueueTimeout", err))
+	}
+	if _, err := fetch.ParseResolveOverrides(c.Resolve); err != nil {
+		errs = multierror.Append(errs, wrapError("Resolve", err))
+	}
+	if c.ClientCertFile != "" && c.ClientKeyFile == "" {
+		errs = multierror.Append(errs, errors.New("ClientCertFile specified without ClientKeyFile"))
+	}
+	if c.ClientKeyFile != "" && c.ClientCertFile == "" {
+		errs = multierror.Append(errs, errors.New("ClientKeyFile specified without ClientCertFile"))
+	}
+	if len(c.ClientCertHosts) > 0 && c.ClientCertFile == "" {
+		errs = multierror.Append(errs, errors.New("ClientCertHosts specified without ClientCertFile"))
+	}
+	for i, host := range c.ClientCertHosts {
+		if err := verifyHostName(host); err != nil {
+			errs = multierror.Append(errs, wrapError(fmt.Sprintf("ClientCertHosts[%d]", i), err))
+		}
+	}
+
+	return errs.ErrorOrNil()
+}
+
+func (c *AuthConfig) verify() error {
+	var errs *multierror.Error
+
+	if !c.Enable {
+		return nil
+	}
+
+	if err := verifyServePath(c.DocPath); err != nil {
+		errs = multierror.Append(errs, wrapError("DocPath", err))
+	}
+	if c.KeyFile == "" {
+		errs = multierror.Append(errs, wrapError("KeyFile", errEmpty))
+	}
+	if _, err := parseMaxSkew(c.MaxSkew); err != nil {
+		errs = multierror.Append(errs, wrapError("MaxSkew", err))
+	}
+
+	return errs.ErrorOrNil()
+}
+
 func verifyParamName(value string) error {
 	if value == "" {
 		return errEmpty
@@ -211,6 +376,16 @@ func verifyParamName(value string) error {
 	return nil // TODO(yuizumi): Restrict to alphanumerics?
 }
 
+func verifyHostName(value string) error {
+	if value == "" {
+		return errEmpty
+	}
+	if strings.ContainsAny(value, "*:/") {
+		return errors.New("must be a plain host name without wildcards or port")
+	}
+	return nil
+}
+
 func verifyServePath(value string) error {
 	if value == "" {
 		return errEmpty
@@ -247,6 +422,21 @@ func verifyAbsoluteURL(value string) error {
 	return verifyServePath(u.Path)
 }
 
+func verifyWarmUpURL(value string) error {
+	if value == "" {
+		return errEmpty
+	}
+
+	u, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return errors.New("must be absolute https:// URL")
+	}
+	return nil
+}
+
 const verifyValidityURLMessage string = "must be https:// URL or absolute path"
 const verifyCertURLMessage string = "must be https:// URL, data:, or absolute path"
 
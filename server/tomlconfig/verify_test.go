@@ -180,3 +180,345 @@ func TestVerifyCertURL(t *testing.T) {
 		t.Errorf("verifyCertURL(%q) = error(%q), want success", testURL, err)
 	}
 }
+
+func TestVerifyAuthConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  AuthConfig
+		wantErr bool
+	}{
+		{
+			name:    "Disabled",
+			config:  AuthConfig{},
+			wantErr: false,
+		},
+		{
+			name:    "Enabled",
+			config:  AuthConfig{Enable: true, DocPath: "/priv/authdoc", KeyFile: "auth.key", MaxSkew: "5m"},
+			wantErr: false,
+		},
+		{
+			name:    "MissingKeyFile",
+			config:  AuthConfig{Enable: true, DocPath: "/priv/authdoc", MaxSkew: "5m"},
+			wantErr: true,
+		},
+		{
+			name:    "NonPositiveMaxSkew",
+			config:  AuthConfig{Enable: true, DocPath: "/priv/authdoc", KeyFile: "auth.key", MaxSkew: "0s"},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.config.verify()
+			if test.wantErr && err == nil {
+				t.Error("verify() = success, want error")
+			}
+			if !test.wantErr && err != nil {
+				t.Errorf("verify() = error(%q), want success", err)
+			}
+		})
+	}
+}
+
+func TestVerifyCacheConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  CacheConfig
+		wantErr bool
+	}{
+		{
+			name:    "Defaults",
+			config:  CacheConfig{MaxEntries: 200, StaleIfError: "0s"},
+			wantErr: false,
+		},
+		{
+			name:    "StaleIfError",
+			config:  CacheConfig{MaxEntries: 200, StaleIfError: "1h"},
+			wantErr: false,
+		},
+		{
+			name:    "NegativeStaleIfError",
+			config:  CacheConfig{MaxEntries: 200, StaleIfError: "-1h"},
+			wantErr: true,
+		},
+		{
+			name:    "InvalidStaleIfError",
+			config:  CacheConfig{MaxEntries: 200, StaleIfError: "forever"},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.config.verify()
+			if test.wantErr && err == nil {
+				t.Error("verify() = success, want error")
+			}
+			if !test.wantErr && err != nil {
+				t.Errorf("verify() = error(%q), want success", err)
+			}
+		})
+	}
+}
+
+func TestVerifyFetchConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  FetchConfig
+		wantErr bool
+	}{
+		{
+			name:    "Defaults",
+			config:  FetchConfig{IdleConnTimeout: "90s", TLSHandshakeTimeout: "10s", FetchQueueTimeout: "5s"},
+			wantErr: false,
+		},
+		{
+			name:    "Tuned",
+			config:  FetchConfig{MaxIdleConnsPerHost: 8, MaxConnsPerHost: 16, IdleConnTimeout: "30s", TLSHandshakeTimeout: "5s", FetchQueueTimeout: "5s"},
+			wantErr: false,
+		},
+		{
+			name:    "NegativeMaxConnsPerHost",
+			config:  FetchConfig{MaxConnsPerHost: -1, IdleConnTimeout: "90s", TLSHandshakeTimeout: "10s", FetchQueueTimeout: "5s"},
+			wantErr: true,
+		},
+		{
+			name:    "InvalidIdleConnTimeout",
+			config:  FetchConfig{IdleConnTimeout: "forever", TLSHandshakeTimeout: "10s", FetchQueueTimeout: "5s"},
+			wantErr: true,
+		},
+		{
+			name:    "Timeouts",
+			config:  FetchConfig{IdleConnTimeout: "90s", DialTimeout: "5s", TLSHandshakeTimeout: "10s", ResponseHeaderTimeout: "30s", RequestTimeout: "60s", FetchQueueTimeout: "5s"},
+			wantErr: false,
+		},
+		{
+			name:    "InvalidDialTimeout",
+			config:  FetchConfig{IdleConnTimeout: "90s", DialTimeout: "0s", TLSHandshakeTimeout: "10s", FetchQueueTimeout: "5s"},
+			wantErr: true,
+		},
+		{
+			name:    "InvalidRequestTimeout",
+			config:  FetchConfig{IdleConnTimeout: "90s", TLSHandshakeTimeout: "10s", RequestTimeout: "-1s", FetchQueueTimeout: "5s"},
+			wantErr: true,
+		},
+		{
+			name:    "MaxFetches",
+			config:  FetchConfig{IdleConnTimeout: "90s", TLSHandshakeTimeout: "10s", MaxFetches: 32, FetchQueueTimeout: "0s"},
+			wantErr: false,
+		},
+		{
+			name:    "NegativeMaxFetches",
+			config:  FetchConfig{IdleConnTimeout: "90s", TLSHandshakeTimeout: "10s", MaxFetches: -1, FetchQueueTimeout: "5s"},
+			wantErr: true,
+		},
+		{
+			name:    "NegativeFetchQueueTimeout",
+			config:  FetchConfig{IdleConnTimeout: "90s", TLSHandshakeTimeout: "10s", FetchQueueTimeout: "-1s"},
+			wantErr: true,
+		},
+		{
+			name:    "Resolve",
+			config:  FetchConfig{IdleConnTimeout: "90s", TLSHandshakeTimeout: "10s", FetchQueueTimeout: "5s", Resolve: []string{"www.example.com:443:10.0.0.1"}},
+			wantErr: false,
+		},
+		{
+			name:    "InvalidResolve",
+			config:  FetchConfig{IdleConnTimeout: "90s", TLSHandshakeTimeout: "10s", FetchQueueTimeout: "5s", Resolve: []string{"www.example.com:443:staging"}},
+			wantErr: true,
+		},
+		{
+			name:    "ClientCert",
+			config:  FetchConfig{IdleConnTimeout: "90s", TLSHandshakeTimeout: "10s", FetchQueueTimeout: "5s", ClientCertFile: "client.pem", ClientKeyFile: "client.key", ClientCertHosts: []string{"backend.example.com"}},
+			wantErr: false,
+		},
+		{
+			name:    "ClientCertWithoutKey",
+			config:  FetchConfig{IdleConnTimeout: "90s", TLSHandshakeTimeout: "10s", FetchQueueTimeout: "5s", ClientCertFile: "client.pem"},
+			wantErr: true,
+		},
+		{
+			name:    "ClientCertHostsWithoutCert",
+			config:  FetchConfig{IdleConnTimeout: "90s", TLSHandshakeTimeout: "10s", FetchQueueTimeout: "5s", ClientCertHosts: []string{"backend.example.com"}},
+			wantErr: true,
+		},
+		{
+			name:    "InvalidClientCertHost",
+			config:  FetchConfig{IdleConnTimeout: "90s", TLSHandshakeTimeout: "10s", FetchQueueTimeout: "5s", ClientCertFile: "client.pem", ClientKeyFile: "client.key", ClientCertHosts: []string{"backend.example.com:443"}},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.config.verify()
+			if test.wantErr && err == nil {
+				t.Error("verify() = success, want error")
+			}
+			if !test.wantErr && err != nil {
+				t.Errorf("verify() = error(%q), want success", err)
+			}
+		})
+	}
+}
+
+func TestVerifyServerConfigWarmUp(t *testing.T) {
+	base := ServerConfig{
+		DocPath:           "/priv/doc",
+		CertPath:          "/webpkg/cert",
+		ValidityPath:      "/webpkg/validity",
+		HealthPath:        "/healthz",
+		SignParam:         "sign",
+		WarmUpConcurrency: 4,
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(c *ServerConfig)
+		wantErr bool
+	}{
+		{
+			name:    "NoWarmUp",
+			modify:  func(c *ServerConfig) {},
+			wantErr: false,
+		},
+		{
+			name: "WarmUpURLs",
+			modify: func(c *ServerConfig) {
+				c.WarmUpURLs = []string{"https://example.com/", "https://example.com/index.html?q=1"}
+				c.WarmUpInterval = "1h"
+			},
+			wantErr: false,
+		},
+		{
+			name: "RelativeWarmUpURL",
+			modify: func(c *ServerConfig) {
+				c.WarmUpURLs = []string{"/index.html"}
+			},
+			wantErr: true,
+		},
+		{
+			name: "HTTPWarmUpURL",
+			modify: func(c *ServerConfig) {
+				c.WarmUpURLs = []string{"http://example.com/"}
+			},
+			wantErr: true,
+		},
+		{
+			name: "ProbeURLs",
+			modify: func(c *ServerConfig) {
+				c.ProbeURLs = []string{"https://example.com/canary.html"}
+			},
+			wantErr: false,
+		},
+		{
+			name: "RelativeProbeURL",
+			modify: func(c *ServerConfig) {
+				c.ProbeURLs = []string{"/canary.html"}
+			},
+			wantErr: true,
+		},
+		{
+			name: "InvalidWarmUpInterval",
+			modify: func(c *ServerConfig) {
+				c.WarmUpInterval = "-1h"
+			},
+			wantErr: true,
+		},
+		{
+			name: "ZeroWarmUpConcurrency",
+			modify: func(c *ServerConfig) {
+				c.WarmUpConcurrency = 0
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := base
+			test.modify(&config)
+			err := config.verify()
+			if test.wantErr && err == nil {
+				t.Error("verify() = success, want error")
+			}
+			if !test.wantErr && err != nil {
+				t.Errorf("verify() = error(%q), want success", err)
+			}
+		})
+	}
+}
+
+func TestVerifyHostConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  HostConfig
+		wantErr bool
+	}{
+		{
+			name:    "Empty",
+			config:  nil,
+			wantErr: false,
+		},
+		{
+			name: "DistinctHosts",
+			config: HostConfig{
+				{Domain: "www.example.com", Expiry: "72h"},
+				{Domain: "static.example.com", JSExpiry: "12h", SizeLimit: 1024},
+			},
+			wantErr: false,
+		},
+		{
+			name: "OverlappingHosts",
+			config: HostConfig{
+				{Domain: "www.example.com"},
+				{Domain: "WWW.Example.com"},
+			},
+			wantErr: true,
+		},
+		{
+			name:    "EmptyDomain",
+			config:  HostConfig{{Expiry: "72h"}},
+			wantErr: true,
+		},
+		{
+			name:    "Wildcard",
+			config:  HostConfig{{Domain: "*.example.com"}},
+			wantErr: true,
+		},
+		{
+			name:    "WithPort",
+			config:  HostConfig{{Domain: "example.com:8443"}},
+			wantErr: true,
+		},
+		{
+			name:    "ExpiryTooLong",
+			config:  HostConfig{{Domain: "example.com", Expiry: "200h"}},
+			wantErr: true,
+		},
+		{
+			name:    "JSExpiryWithoutUnsafe",
+			config:  HostConfig{{Domain: "example.com", JSExpiry: "48h"}},
+			wantErr: true,
+		},
+		{
+			name:    "NegativeSizeLimit",
+			config:  HostConfig{{Domain: "example.com", SizeLimit: -1}},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.config.verify()
+			if test.wantErr && err == nil {
+				t.Error("verify() = success, want error")
+			}
+			if !test.wantErr && err != nil {
+				t.Errorf("verify() = error(%q), want success", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/layer0-platform/webpackager/internal/timeutil"
+)
+
+// WarmUp packages all WarmUpURLs, running at most WarmUpConcurrency
+// packaging tasks concurrently, so their signed exchanges are put into
+// the ResourceCache. WarmUp returns after all tasks are complete. Errors
+// are only logged.
+func (s *Server) WarmUp() {
+	s.warmUp(context.Background())
+}
+
+// warmUp is like WarmUp, but stops packaging once ctx is done.
+func (s *Server) warmUp(ctx context.Context) {
+	concurrency := s.WarmUpConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+	for _, u := range s.WarmUpURLs {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		}
+		wg.Add(1)
+		go func(u string) {
+			defer func() { <-sem }()
+			defer wg.Done()
+			s.warmUpURL(ctx, u)
+		}(u)
+	}
+	wg.Wait()
+}
+
+func (s *Server) warmUpURL(ctx context.Context, u string) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		log.Printf("warning: warm-up failed for %s: %v", u, err)
+		return
+	}
+	if _, err := s.Packager.RunForRequest(req, timeutil.Now()); err != nil && ctx.Err() == nil {
+		log.Printf("warning: warm-up failed for %s: %v", u, err)
+	}
+}
+
+// startWarmUp runs WarmUp in background, once immediately then every
+// WarmUpInterval if it is set. Requests are served meanwhile, thus may miss
+// the cache until the first WarmUp completes. It returns a function to stop
+// warming up, which cancels the packaging in progress and waits for it.
+func (s *Server) startWarmUp() (stop func()) {
+	if len(s.WarmUpURLs) == 0 {
+		return func() {}
+	}
+	interval := s.GetWarmUpInterval()
+	ctx, cancel := context.WithCancel(context.Background())
+	finished := make(chan struct{})
+
+	go func() {
+		defer close(finished)
+		s.warmUp(ctx)
+		if interval == 0 {
+			return
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.warmUp(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-finished
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webpackager
+
+import (
+	"fmt"
+	"time"
+)
+
+// Stats describes how Packager produced the signed exchange for the main
+// resource. It does not cover subresources.
+//
+// See also: Packager.RunForRequestWithStats.
+type Stats struct {
+	// CacheHit reports whether the signed exchange was taken from
+	// ResourceCache rather than newly produced.
+	CacheHit bool
+
+	// Stale reports whether the signed exchange was taken from ResourceCache
+	// after its expiry because the origin failed to respond. See
+	// Config.StaleIfError.
+	Stale bool
+
+	// FetchDuration is the time taken to retrieve the response, including
+	// reading the body.
+	FetchDuration time.Duration
+
+	// ProcessDuration is the time taken to run Processor.
+	ProcessDuration time.Duration
+
+	// SignDuration is the time taken to produce and verify the signed
+	// exchanges.
+	SignDuration time.Duration
+
+	// TotalDuration is the total time taken, including the time spent on
+	// subresources.
+	TotalDuration time.Duration
+
+	// PayloadSize is the size of the payload, after processing but before
+	// the MI encoding, in bytes. It is zero on cache hits.
+	PayloadSize int
+
+	// ExchangeSize is the size of the serialized signed exchange in bytes.
+	// It is zero if no signed exchange was produced.
+	ExchangeSize int
+}
+
+// String returns a human-readable summary of the Stats.
+func (s *Stats) String() string {
+	return fmt.Sprintf("cache_hit=%t stale=%t fetch=%v process=%v sign=%v total=%v payload=%d sxg=%d",
+		s.CacheHit, s.Stale, s.FetchDuration, s.ProcessDuration, s.SignDuration,
+		s.TotalDuration, s.PayloadSize, s.ExchangeSize)
+}
@@ -15,6 +15,7 @@
 package webpackager
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"log"
@@ -24,7 +25,9 @@ import (
 
 	"github.com/WICG/webpackage/go/signedexchange"
 	"github.com/layer0-platform/webpackager/exchange"
+	"github.com/layer0-platform/webpackager/processor/preverify"
 	"github.com/layer0-platform/webpackager/resource"
+	"github.com/layer0-platform/webpackager/validity"
 	multierror "github.com/hashicorp/go-multierror"
 	"golang.org/x/xerrors"
 )
@@ -68,24 +71,48 @@ func (runner *packagerTaskRunner) err() error {
 	return runner.errs.ErrorOrNil()
 }
 
-func (runner *packagerTaskRunner) run(parent *packagerTask, req *http.Request, r *resource.Resource) {
+func (runner *packagerTaskRunner) run(parent *packagerTask, req *http.Request, r *resource.Resource) *Stats {
+	return runner.runTask(parent, req, r, (*packagerTask).run)
+}
+
+// runForResponse is like run, but processes and signs sxgResp instead of
+// fetching the resource.
+func (runner *packagerTaskRunner) runForResponse(sxgResp *exchange.Response, r *resource.Resource) *Stats {
+	return runner.runTask(nil, sxgResp.Request, r, func(task *packagerTask) error {
+		return task.runForResponse(sxgResp)
+	})
+}
+
+func (runner *packagerTaskRunner) runTask(parent *packagerTask, req *http.Request, r *resource.Resource, f func(*packagerTask) error) *Stats {
 	url := r.RequestURL.String()
+	stats := new(Stats)
 	var err error
 
 	if runner.active[url] {
 		err = errReferenceLoop
+	} else if !runner.limit.take(parent.takeReserved()) {
+		log.Printf("warning: skipped %v: reached the limit of %d resources", url, runner.MaxResources)
 	} else {
 		log.Printf("processing %v ...", url)
 		runner.active[url] = true
-		err = (&packagerTask{runner, parent, req, r}).run()
+		start := time.Now()
+		err = f(&packagerTask{runner, parent, req, r, stats, 0})
+		stats.TotalDuration = time.Since(start)
 		delete(runner.active, url)
 	}
 
+	var skipErr *preverify.SkipError
+	if errors.As(err, &skipErr) {
+		log.Printf("warning: skipped %v: %v", url, skipErr.Err)
+		runner.skips.add(skipErr.Reason)
+		err = nil
+	}
 	if err != nil {
 		err = WrapError(err, r.RequestURL)
 		runner.errs = multierror.Append(runner.errs, err)
 		log.Print(err)
 	}
+	return stats
 }
 
 type packagerTask struct {
@@ -94,6 +121,11 @@ type packagerTask struct {
 	parent   *packagerTask
 	request  *http.Request
 	resource *resource.Resource
+	stats    *Stats
+
+	// reserved is the number of slots of MaxResources left reserved for
+	// the direct subresources of the main resource.
+	reserved int
 }
 
 func (task *packagerTask) parentRequest() *http.Request {
@@ -103,6 +135,44 @@ func (task *packagerTask) parentRequest() *http.Request {
 	return task.parent.request
 }
 
+// takeReserved reports whether a subresource of task can use a slot reserved
+// for the direct subresources of the main resource, and consumes it if so.
+func (task *packagerTask) takeReserved() bool {
+	if task == nil || task.parent != nil || task.reserved == 0 {
+		return false
+	}
+	task.reserved--
+	return true
+}
+
+// verifyAtExpiry checks sxg remains valid until the end of vp.
+func (task *packagerTask) verifyAtExpiry(sxg *signedexchange.Exchange, vp exchange.ValidPeriod) error {
+	if err := task.sxgFactory.CheckCertCoverage(vp); err != nil {
+		return err
+	}
+	if _, err := task.sxgFactory.Verify(sxg, vp.Expires()); err != nil {
+		return fmt.Errorf("verifying at expiry %v: %v", vp.Expires(), err)
+	}
+	return nil
+}
+
+// verifyCached checks if the cached resource can be reused as is. It returns
+// a non-nil error describing the reason when the resource needs renewal.
+func (task *packagerTask) verifyCached(cached *resource.Resource) error {
+	if _, err := task.sxgFactory.Verify(cached.Exchange, task.date); err != nil {
+		return err
+	}
+	// The record size affects the payload integrity, so the exchange would
+	// not match what is produced with the current configuration.
+	if got := cached.MIRecordSize; got != 0 {
+		want := task.sxgFactory.MIRecordSizeFor(decodedPayloadSize(cached.Exchange.Payload, got))
+		if got != want {
+			return fmt.Errorf("MI record size changed from %d to %d", got, want)
+		}
+	}
+	return nil
+}
+
 func (task *packagerTask) run() error {
 	r := task.resource
 
@@ -116,8 +186,9 @@ func (task *packagerTask) run() error {
 		return err
 	}
 	if cached != nil {
-		if _, err := task.sxgFactory.Verify(cached.Exchange, task.date); err == nil {
+		if err := task.verifyCached(cached); err == nil {
 			log.Printf("reusing the existing signed exchange for %s", r.RequestURL)
+			task.stats.CacheHit = true
 			*r = *cached
 			return nil
 		} else {
@@ -125,27 +196,117 @@ func (task *packagerTask) run() error {
 		}
 	}
 
+	fetchStart := time.Now()
 	rawResp, err := task.FetchClient.Do(req)
+	task.stats.FetchDuration += time.Since(fetchStart)
 	if err != nil {
-		return err
+		return task.reuseStale(cached, err)
+	}
+
+	fetchStart = time.Now()
+	sxgResp, err := exchange.NewResponse(rawResp)
+	task.stats.FetchDuration += time.Since(fetchStart)
+	if err != nil {
+		return task.reuseStale(cached, err)
 	}
-	if isRedirectCode[rawResp.StatusCode] {
-		dest, err := rawResp.Location()
+	if task.ResponseTweaker != nil {
+		if err := task.ResponseTweaker(sxgResp); err != nil {
+			return err
+		}
+	}
+	if isRedirectCode[sxgResp.StatusCode] {
+		dest, err := sxgResp.Location()
 		if err != nil {
 			return err
 		}
 		r.RedirectURL = dest
 		// TODO(yuizumi): Consider allowing redirects for main resources.
-		return fmt.Errorf("redirected to %v", dest)
+		return xerrors.Errorf("redirected to %v: %w", dest,
+			preverify.NewHTTPStatusErrorForResponse(sxgResp))
+	}
+	r.ContentHash = resource.NewContentHash(sxgResp.Payload)
+	if reused, err := task.reusePrevious(req); reused || err != nil {
+		return err
 	}
+	err = task.runForResponse(sxgResp)
+	var statusErr *preverify.HTTPStatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode >= 500 {
+		return task.reuseStale(cached, err)
+	}
+	return err
+}
 
-	purl, err := task.getPhysicalURL(r, rawResp)
+// reusePrevious reuses the resource in PreviousResources matching req, if
+// it has the same ContentHash as task.resource and its signed exchange does
+// not need renewal. It reports whether the resource has been reused.
+func (task *packagerTask) reusePrevious(req *http.Request) (bool, error) {
+	if task.PreviousResources == nil {
+		return false, nil
+	}
+	r := task.resource
+	prev, err := task.PreviousResources.Lookup(req)
+	if err != nil || prev == nil || prev.Exchange == nil {
+		return false, err
+	}
+	if prev.ContentHash != r.ContentHash {
+		log.Printf("renewing the signed exchange for %s: content changed", r.RequestURL)
+		return false, nil
+	}
+	if err := task.verifyCached(prev); err != nil {
+		log.Printf("renewing the signed exchange for %s: %v", r.RequestURL, err)
+		return false, nil
+	}
+	vp, err := exchange.GetValidPeriod(prev.Exchange)
+	if err != nil {
+		return false, err
+	}
+	if renewAt := vp.Expires().Add(-task.RenewBefore); !task.date.Before(renewAt) {
+		log.Printf("renewing the signed exchange for %s: expires at %v", r.RequestURL, vp.Expires())
+		return false, nil
+	}
+	log.Printf("reusing the previous signed exchange for %s: content unchanged", r.RequestURL)
+	task.stats.CacheHit = true
+	*r = *prev
+	return true, task.ResourceCache.Store(r)
+}
+
+// reuseStale is called when the origin fails to respond with err. It reuses
+// cached for the resource and returns nil if cached has expired no longer
+// than StaleIfError ago. Otherwise it returns err as is.
+func (task *packagerTask) reuseStale(cached *resource.Resource, err error) error {
+	if cached == nil || cached.Exchange == nil || task.StaleIfError <= 0 {
+		return err
+	}
+	vp, vpErr := exchange.GetValidPeriod(cached.Exchange)
+	if vpErr != nil || task.date.After(vp.Expires().Add(task.StaleIfError)) {
+		return err
+	}
+	log.Printf("warning: reusing the stale signed exchange for %s: %v", task.resource.RequestURL, err)
+	task.stats.CacheHit = true
+	task.stats.Stale = true
+	*task.resource = *cached
+	return nil
+}
+
+// runForResponse produces the signed exchange for the resource from sxgResp,
+// which is either fetched by run or provided by Packager.RunForResponse.
+func (task *packagerTask) runForResponse(sxgResp *exchange.Response) error {
+	r := task.resource
+
+	purl, err := task.getPhysicalURL(r, sxgResp.Response)
 	if err != nil {
 		return err
 	}
 	r.PhysicalURL = purl
 
-	sxg, err := task.createExchange(rawResp)
+	surl, err := task.getSignedURL(r, sxgResp.Response)
+	if err != nil {
+		return err
+	}
+	r.SignedURL = surl
+	sxgResp.ExtraData.Set(exchange.SignedURL, surl.String())
+
+	sxg, err := task.createExchange(sxgResp)
 	if err != nil {
 		return err
 	}
@@ -153,8 +314,6 @@ func (task *packagerTask) run() error {
 		return err
 	}
 
-	// TODO(yuizumi): Generate the validity data.
-
 	return task.ResourceCache.Store(r)
 }
 
@@ -165,14 +324,97 @@ func (task *packagerTask) getPhysicalURL(r *resource.Resource, resp *http.Respon
 	return u, nil
 }
 
-func (task *packagerTask) createExchange(rawResp *http.Response) (*signedexchange.Exchange, error) {
-	sxgResp, err := exchange.NewResponse(rawResp)
+// createNextExchange produces the signed exchange for the period following
+// vp by re-signing sxg. It returns nil if NextExchangeOverlap is not set or
+// the next exchange cannot be produced.
+func (task *packagerTask) createNextExchange(sxg *signedexchange.Exchange, vp exchange.ValidPeriod) *signedexchange.Exchange {
+	overlap := task.NextExchangeOverlap
+	if overlap <= 0 {
+		return nil
+	}
+	if overlap >= vp.Lifetime() {
+		log.Printf("warning: overlap %v not shorter than lifetime %v; skipped the next exchange for %s",
+			overlap, vp.Lifetime(), task.resource.RequestURL)
+		return nil
+	}
+	next := exchange.NewValidPeriodWithLifetime(vp.Expires().Add(-overlap), vp.Lifetime())
+	e, err := task.sxgFactory.ReSign(sxg, next)
 	if err != nil {
-		return nil, err
+		log.Printf("warning: failed to produce the next exchange for %s: %v",
+			task.resource.RequestURL, err)
+		return nil
+	}
+	return e
+}
+
+// createValidityData produces the validity data carrying the signature of
+// sxg for the period following vp. It returns nil if SignValidityData is not
+// set or the signature cannot be produced.
+func (task *packagerTask) createValidityData(sxg *signedexchange.Exchange, vp exchange.ValidPeriod) []byte {
+	if !task.SignValidityData {
+		return nil
+	}
+	start := vp.Expires()
+	if overlap := task.NextExchangeOverlap; overlap > 0 && overlap < vp.Lifetime() {
+		start = start.Add(-overlap)
+	}
+	next := exchange.NewValidPeriodWithLifetime(start, vp.Lifetime())
+	sig, err := task.sxgFactory.NewSignature(sxg, next)
+	if err != nil {
+		log.Printf("warning: failed to sign the validity data for %s: %v",
+			task.resource.RequestURL, err)
+		return nil
+	}
+	data, err := (&validity.Data{Signatures: []string{sig}}).Encode()
+	if err != nil {
+		log.Printf("warning: failed to encode the validity data for %s: %v",
+			task.resource.RequestURL, err)
+		return nil
+	}
+	return data
+}
+
+func (task *packagerTask) getSignedURL(r *resource.Resource, resp *http.Response) (*url.URL, error) {
+	u := new(url.URL)
+	*u = *r.RequestURL
+	task.SignedURLRule.Rewrite(u, resp.Header)
+	if *u == *r.RequestURL {
+		return u, nil
+	}
+	if r.RequestURL.Scheme != "https" {
+		return nil, fmt.Errorf("fetch URL %v is not https", r.RequestURL)
+	}
+	if u.Scheme != "https" {
+		return nil, fmt.Errorf("signed URL %v is not https", u)
 	}
-	if err := task.Processor.Process(sxgResp); err != nil {
+	return u, nil
+}
+
+// recordTransformations copies the changes recorded by the processors to
+// the resource, and logs them and adds them to the response as Warning
+// headers if requested.
+func (task *packagerTask) recordTransformations(sxgResp *exchange.Response) {
+	ts := sxgResp.ExtraData[exchange.AppliedTransformation]
+	task.resource.Transformations = ts
+	for _, t := range ts {
+		if task.LogTransformations {
+			log.Printf("%s: %s", task.resource.RequestURL, t)
+		}
+		if task.SignTransformations {
+			sxgResp.Header.Add("Warning", newTransformationWarning(t))
+		}
+	}
+}
+
+func (task *packagerTask) createExchange(sxgResp *exchange.Response) (*signedexchange.Exchange, error) {
+	processStart := time.Now()
+	err := task.Processor.Process(sxgResp)
+	task.stats.ProcessDuration = time.Since(processStart)
+	if err != nil {
 		return nil, err
 	}
+	task.stats.PayloadSize = len(sxgResp.Payload)
+	task.recordTransformations(sxgResp)
 
 	vp := task.ValidPeriodRule.Get(sxgResp, task.date)
 
@@ -183,6 +425,22 @@ func (task *packagerTask) createExchange(rawResp *http.Response) (*signedexchang
 	}
 	task.resource.ValidityURL = vu
 
+	if task.KeepUnsignedResponse {
+		var buf bytes.Buffer
+		if err := sxgResp.WriteUnsigned(&buf); err != nil {
+			return nil, err
+		}
+		task.resource.UnsignedResponse = buf.Bytes()
+	}
+
+	if task.parent == nil {
+		n := 0
+		for _, p := range sxgResp.Preloads {
+			n += len(p.Resources)
+		}
+		task.reserved = task.limit.reserve(n)
+		defer func() { task.limit.release(task.reserved) }()
+	}
 	for _, p := range sxgResp.Preloads {
 		for _, r := range p.Resources {
 			req, err := newGetRequest(r.RequestURL)
@@ -193,13 +451,37 @@ func (task *packagerTask) createExchange(rawResp *http.Response) (*signedexchang
 		}
 	}
 
-	sxg, err := task.sxgFactory.NewExchange(sxgResp, vp, vu)
+	signStart := time.Now()
+	defer func() { task.stats.SignDuration = time.Since(signStart) }()
+
+	sxg, err := task.sxgFactory.NewExchangeForURL(task.resource.SignedURL, sxgResp, vp, vu)
 	if err != nil {
 		return nil, err
 	}
 	if _, err := task.sxgFactory.Verify(sxg, task.date); err != nil {
 		return nil, err
 	}
+	if task.VerifyAtExpiry {
+		if err := task.verifyAtExpiry(sxg, vp); err != nil {
+			return nil, err
+		}
+	}
+	task.resource.NextExchange = task.createNextExchange(sxg, vp)
+	task.resource.ValidityData = task.createValidityData(sxg, vp)
 
 	return sxg, nil
 }
+
+// decodedPayloadSize returns the size of the payload before the Merkle
+// Integrity encoding, given the encoded payload and its record size. The
+// encoding prepends the record size (8 bytes) and inserts a 32-byte proof
+// between the records.
+func decodedPayloadSize(encoded []byte, recordSize int) int {
+	const headerSize, proofSize = 8, 32
+	if len(encoded) <= headerSize {
+		return 0
+	}
+	// n records take n*recordSize bytes at most, plus (n-1) proofs.
+	n := (len(encoded) - headerSize + proofSize + recordSize + proofSize - 1) / (recordSize + proofSize)
+	return len(encoded) - headerSize - (n-1)*proofSize
+}
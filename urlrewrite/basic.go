@@ -15,9 +15,11 @@
 package urlrewrite
 
 import (
+	"errors"
 	"net/http"
 	"net/url"
 	"path"
+	"strings"
 
 	"github.com/layer0-platform/webpackager/internal/urlutil"
 )
@@ -35,6 +37,26 @@ func (r *cleanPath) Rewrite(u *url.URL, respHeader http.Header) {
 	u.Path = urlutil.GetCleanPath(u)
 }
 
+// CheckCleanPath returns an error if u.Path needs cleaning by CleanPath,
+// i.e. contains multiple slashes, "." or ".." segments, or if the escaped
+// path contains percent-encoded slashes, backslashes, or dots, which may be
+// decoded to such segments by some servers. It returns nil otherwise.
+//
+// CheckCleanPath helps reject unexpected URLs instead of having them
+// silently rewritten by CleanPath.
+func CheckCleanPath(u *url.URL) error {
+	if u.Path != "" && u.Path != urlutil.GetCleanPath(u) {
+		return errors.New("path needs cleaning")
+	}
+	escaped := strings.ToUpper(u.EscapedPath())
+	for _, s := range []string{"%2F", "%5C", "%2E"} {
+		if strings.Contains(escaped, s) {
+			return errors.New("path contains encoded " + s)
+		}
+	}
+	return nil
+}
+
 // IndexRule appends indexFile to the path when it ends with a slash ("/")
 // or otherwise likely represents a directory. indexFile is supposed to match
 // the index file on the target server (typically "index.html").
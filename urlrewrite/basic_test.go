@@ -68,3 +68,73 @@ func TestBasic(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckCleanPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		wantErr bool
+	}{
+		{
+			name:    "Clean",
+			url:     "https://example.com/hello/world.html",
+			wantErr: false,
+		},
+		{
+			name:    "TrailingSlash",
+			url:     "https://example.com/hello/",
+			wantErr: false,
+		},
+		{
+			name:    "EmptyPath",
+			url:     "https://example.com",
+			wantErr: false,
+		},
+		{
+			name:    "EncodedSpace",
+			url:     "https://example.com/hello%20world.html",
+			wantErr: false,
+		},
+		{
+			name:    "DotDot",
+			url:     "https://example.com/hello/../world.html",
+			wantErr: true,
+		},
+		{
+			name:    "Dot",
+			url:     "https://example.com/hello/./world.html",
+			wantErr: true,
+		},
+		{
+			name:    "DoubleSlash",
+			url:     "https://example.com/hello//world.html",
+			wantErr: true,
+		},
+		{
+			name:    "EncodedSlash",
+			url:     "https://example.com/hello%2fworld.html",
+			wantErr: true,
+		},
+		{
+			name:    "EncodedDotDot",
+			url:     "https://example.com/hello/%2e%2e/world.html",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			u, err := url.Parse(test.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = urlrewrite.CheckCleanPath(u)
+			if test.wantErr && err == nil {
+				t.Error("CheckCleanPath() = nil, want error")
+			}
+			if !test.wantErr && err != nil {
+				t.Errorf("CheckCleanPath() = error(%q), want nil", err)
+			}
+		})
+	}
+}
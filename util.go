@@ -17,6 +17,7 @@ package webpackager
 import (
 	"net/http"
 	"net/url"
+	"strings"
 )
 
 func newGetRequest(url *url.URL) (*http.Request, error) {
@@ -25,3 +26,10 @@ func newGetRequest(url *url.URL) (*http.Request, error) {
 	// always valid; url is an already parsed value.
 	return http.NewRequest(http.MethodGet, url.String(), nil)
 }
+
+// newTransformationWarning returns the Warning header value to indicate
+// the transformation described by text.
+func newTransformationWarning(text string) string {
+	text = strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(text)
+	return `214 webpackager "` + text + `"`
+}
@@ -85,6 +85,22 @@ func (rule *appendExtDotExchangeDate) Apply(physurl *url.URL, resp *exchange.Res
 	return toValidityURL(physurl, rule.ext, vp.Date())
 }
 
+// AppendExtDotFixedDate is like AppendExtDotLastModified but always uses
+// date instead of the last modified time. It gives stable validity URLs
+// across rebuilds of identical content, e.g. for reproducible deploys.
+func AppendExtDotFixedDate(ext string, date time.Time) URLRule {
+	return &appendExtDotFixedDate{ext, date}
+}
+
+type appendExtDotFixedDate struct {
+	ext  string
+	date time.Time
+}
+
+func (rule *appendExtDotFixedDate) Apply(physurl *url.URL, resp *exchange.Response, vp exchange.ValidPeriod) (*url.URL, error) {
+	return toValidityURL(physurl, rule.ext, rule.date)
+}
+
 func toValidityURL(physurl *url.URL, ext string, date time.Time) (*url.URL, error) {
 	// We do not care whether physurl is normalized or not: we can append
 	// the extension as long as it has a filename.
@@ -93,6 +93,18 @@ func TestAppendExtDotUnixTime(t *testing.T) {
 				time.Unix(1561939200, 0), 24*time.Hour),
 			want: "https://example.com/index.html.validity.1561939200",
 		},
+		{
+			name: "FixedDate",
+			url:  "https://example.com/index.html",
+			header: http.Header{
+				"Last-Modified": []string{"Mon, 01 Jul 2019 12:34:56 GMT"},
+				"Content-Type":  []string{"text/html; charset=utf-8"},
+			},
+			rule: validity.AppendExtDotFixedDate(".validity", time.Unix(1500000000, 0)),
+			vp: exchange.NewValidPeriodWithLifetime(
+				time.Unix(1561939200, 0), 24*time.Hour),
+			want: "https://example.com/index.html.validity.1500000000",
+		},
 	}
 
 	for _, test := range tests {
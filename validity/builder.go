@@ -0,0 +1,51 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validity
+
+import (
+	"net/http"
+
+	"github.com/layer0-platform/webpackager/certchain"
+)
+
+// Builder produces the validity data served at validity URLs.
+type Builder interface {
+	// Build returns the validity data, encoded in CBOR, for the request
+	// to the validity URL. chain is the certificate chain currently used
+	// to sign exchanges; it may be nil if there is none available yet.
+	//
+	// Implementations may use them, for example, to include update
+	// signatures or the digest of chain.
+	Build(req *http.Request, chain *certchain.AugmentedChain) ([]byte, error)
+}
+
+// BuilderFunc turns a function into a Builder.
+type BuilderFunc func(req *http.Request, chain *certchain.AugmentedChain) ([]byte, error)
+
+// Build implements the Builder interface.
+func (f BuilderFunc) Build(req *http.Request, chain *certchain.AugmentedChain) ([]byte, error) {
+	return f(req, chain)
+}
+
+// DefaultBuilder is the Builder used when none is specified.
+var DefaultBuilder Builder = EmptyMap()
+
+// EmptyMap returns a Builder to produce an empty CBOR map (a single byte
+// 0xa0) for all requests, which is valid validity data carrying nothing.
+func EmptyMap() Builder {
+	return BuilderFunc(func(*http.Request, *certchain.AugmentedChain) ([]byte, error) {
+		return []byte{0xa0}, nil
+	})
+}
@@ -0,0 +1,180 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validity
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/WICG/webpackage/go/signedexchange/cbor"
+	"github.com/WICG/webpackage/go/signedexchange/structuredheader"
+)
+
+const (
+	keySignatures = "signatures"
+	keyUpdate     = "update"
+	keySize       = "size"
+)
+
+// requiredSignatureParams are the parameters each signature in the validity
+// data must have.
+var requiredSignatureParams = []string{"sig", "integrity", "validity-url", "date", "expires"}
+
+// Data represents the validity data, which lets clients update the signature
+// of a signed exchange without downloading the whole signed exchange again.
+//
+// See https://wicg.github.io/webpackage/draft-yasskin-http-origin-signed-responses.html#updating-validity
+type Data struct {
+	// Signatures are the new signatures, each in the format of the Signature
+	// header value. They must sign the same headers and payload as the
+	// signed exchange, e.g. produced by exchange.Factory.NewSignature.
+	Signatures []string
+
+	// Update reports whether the resource has been updated, thus clients
+	// should fetch it again rather than update the signature.
+	Update bool
+
+	// UpdateSize is the size of the updated resource in bytes, or zero if
+	// unknown. It is ignored unless Update is true.
+	UpdateSize uint64
+}
+
+// Encode returns the CBOR representation of d.
+func (d *Data) Encode() ([]byte, error) {
+	var entries []*cbor.MapEntryEncoder
+	if len(d.Signatures) > 0 {
+		entries = append(entries, cbor.GenerateMapEntry(func(keyE, valueE *cbor.Encoder) {
+			keyE.EncodeTextString(keySignatures)
+			valueE.EncodeArrayHeader(len(d.Signatures))
+			for _, sig := range d.Signatures {
+				valueE.EncodeByteString([]byte(sig))
+			}
+		}))
+	}
+	if d.Update {
+		entries = append(entries, cbor.GenerateMapEntry(func(keyE, valueE *cbor.Encoder) {
+			keyE.EncodeTextString(keyUpdate)
+			var update []*cbor.MapEntryEncoder
+			if d.UpdateSize > 0 {
+				update = append(update, cbor.GenerateMapEntry(func(keyE, valueE *cbor.Encoder) {
+					keyE.EncodeTextString(keySize)
+					valueE.EncodeUint(d.UpdateSize)
+				}))
+			}
+			valueE.EncodeMap(update)
+		}))
+	}
+
+	var buf bytes.Buffer
+	if err := cbor.NewEncoder(&buf).EncodeMap(entries); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode parses the validity data in CBOR and checks it conforms to
+// the format in the specification: the signatures must be parameterised
+// lists with the sig, integrity, validity-url, date, and expires parameters.
+// Unknown keys are rejected.
+func Decode(data []byte) (*Data, error) {
+	dec := cbor.NewDecoder(bytes.NewReader(data))
+	n, err := dec.DecodeMapHeader()
+	if err != nil {
+		return nil, fmt.Errorf("validity: %v", err)
+	}
+	d := new(Data)
+	for i := uint64(0); i < n; i++ {
+		key, err := dec.DecodeTextString()
+		if err != nil {
+			return nil, fmt.Errorf("validity: %v", err)
+		}
+		switch key {
+		case keySignatures:
+			err = d.decodeSignatures(dec)
+		case keyUpdate:
+			err = d.decodeUpdate(dec)
+		default:
+			err = fmt.Errorf("unknown key %q", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("validity: %s: %v", key, err)
+		}
+	}
+	if _, err := dec.ReadByte(); err == nil {
+		return nil, errors.New("validity: trailing data")
+	}
+	return d, nil
+}
+
+func (d *Data) decodeSignatures(dec *cbor.Decoder) error {
+	n, err := dec.DecodeArrayHeader()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errors.New("empty array")
+	}
+	for i := uint64(0); i < n; i++ {
+		b, err := dec.DecodeByteString()
+		if err != nil {
+			return err
+		}
+		if err := checkSignature(string(b)); err != nil {
+			return fmt.Errorf("[%d]: %v", i, err)
+		}
+		d.Signatures = append(d.Signatures, string(b))
+	}
+	return nil
+}
+
+func (d *Data) decodeUpdate(dec *cbor.Decoder) error {
+	n, err := dec.DecodeMapHeader()
+	if err != nil {
+		return err
+	}
+	d.Update = true
+	for i := uint64(0); i < n; i++ {
+		key, err := dec.DecodeTextString()
+		if err != nil {
+			return err
+		}
+		if key != keySize {
+			return fmt.Errorf("unknown key %q", key)
+		}
+		if d.UpdateSize, err = dec.DecodeUint(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkSignature(value string) error {
+	sigs, err := structuredheader.ParseParameterisedList(value)
+	if err != nil {
+		return err
+	}
+	if len(sigs) == 0 {
+		return errors.New("no signature")
+	}
+	for _, sig := range sigs {
+		for _, name := range requiredSignatureParams {
+			if _, ok := sig.Params[structuredheader.Key(name)]; !ok {
+				return fmt.Errorf("signature %q missing %s", sig.Label, name)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validity_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/layer0-platform/webpackager/validity"
+)
+
+const testSignature = `label;sig=*MEUCIQ==*;integrity="digest/mi-sha256-03";` +
+	`cert-url="https://example.com/cert.cbor";cert-sha256=*AAAA*;` +
+	`validity-url="https://example.com/index.html.validity";date=1587583800;expires=1588188600`
+
+func TestData(t *testing.T) {
+	tests := []struct {
+		name string
+		data validity.Data
+	}{
+		{
+			name: "Empty",
+			data: validity.Data{},
+		},
+		{
+			name: "Signatures",
+			data: validity.Data{Signatures: []string{testSignature, testSignature}},
+		},
+		{
+			name: "Update",
+			data: validity.Data{Update: true},
+		},
+		{
+			name: "UpdateWithSize",
+			data: validity.Data{Update: true, UpdateSize: 1024},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			encoded, err := test.data.Encode()
+			if err != nil {
+				t.Fatalf("Encode() = error(%q), want success", err)
+			}
+			got, err := validity.Decode(encoded)
+			if err != nil {
+				t.Fatalf("Decode() = error(%q), want success", err)
+			}
+			if diff := cmp.Diff(&test.data, got); diff != "" {
+				t.Errorf("Decode(Encode()) mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestData_EmptyMap(t *testing.T) {
+	got, err := (&validity.Data{}).Encode()
+	if err != nil {
+		t.Fatalf("Encode() = error(%q), want success", err)
+	}
+	if want := []byte{0xa0}; string(got) != string(want) {
+		t.Errorf("Encode() = %x, want %x", got, want)
+	}
+}
+
+func TestDecode_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data validity.Data
+	}{
+		{
+			name: "MalformedSignature",
+			data: validity.Data{Signatures: []string{"label;sig="}},
+		},
+		{
+			name: "MissingExpires",
+			data: validity.Data{Signatures: []string{
+				`label;sig=*MEUCIQ==*;integrity="digest/mi-sha256-03";` +
+					`validity-url="https://example.com/index.html.validity";date=1587583800`,
+			}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			encoded, err := test.data.Encode()
+			if err != nil {
+				t.Fatalf("Encode() = error(%q), want success", err)
+			}
+			if got, err := validity.Decode(encoded); err == nil {
+				t.Errorf("Decode() = %+v, want error", got)
+			}
+		})
+	}
+
+	raw := map[string][]byte{
+		"NotMap":       {0x80},
+		"UnknownKey":   {0xa1, 0x63, 'f', 'o', 'o', 0xa0},
+		"EmptyArray":   {0xa1, 0x6a, 's', 'i', 'g', 'n', 'a', 't', 'u', 'r', 'e', 's', 0x80},
+		"TrailingData": {0xa0, 0xa0},
+	}
+	for name, data := range raw {
+		t.Run(name, func(t *testing.T) {
+			if got, err := validity.Decode(data); err == nil {
+				t.Errorf("Decode() = %+v, want error", got)
+			}
+		})
+	}
+}
@@ -12,6 +12,6 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Package validity handles the validity data of signed exchanges. It only
-// defines validity URLs at the moment though.
+// Package validity handles the validity data of signed exchanges. It defines
+// validity URLs and the interface to produce the validity data.
 package validity
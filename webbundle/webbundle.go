@@ -0,0 +1,144 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package webbundle produces Web Bundles from the signed exchanges generated
+// by webpackager.Packager.
+//
+// The bundles themselves are currently not signed. Each exchange in the
+// bundle is keyed by the request URL of a signed exchange and carries the
+// signed exchange as is, served with the application/signed-exchange content
+// type, so the signatures are verified as usual when the bundle is loaded.
+package webbundle
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/WICG/webpackage/go/bundle"
+	"github.com/WICG/webpackage/go/bundle/version"
+	"github.com/WICG/webpackage/go/signedexchange"
+	"github.com/layer0-platform/webpackager/resource"
+	"github.com/layer0-platform/webpackager/resource/cache"
+	"github.com/layer0-platform/webpackager/resource/httplink"
+)
+
+// Version is the Web Bundle format version produced by this package.
+const Version = version.VersionB1
+
+// Build creates a Web Bundle for r, which has r as the primary exchange and
+// includes the subresources r preloads, recursively. The subresources are
+// looked up in rc, thus should have been processed by the same Packager;
+// those missing in rc are skipped with a warning.
+//
+// Build returns an error if r does not have a signed exchange.
+func Build(r *resource.Resource, rc cache.ResourceCache) (*bundle.Bundle, error) {
+	if r.Exchange == nil {
+		return nil, errors.New("no signed exchange to bundle")
+	}
+	primary, err := url.Parse(r.Exchange.RequestURI)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &bundle.Bundle{Version: Version, PrimaryURL: primary}
+	queue := []*resource.Resource{r}
+	seen := map[string]bool{r.Exchange.RequestURI: true}
+
+	for len(queue) > 0 {
+		r := queue[0]
+		queue = queue[1:]
+
+		e, err := newBundleExchange(r.Exchange)
+		if err != nil {
+			return nil, fmt.Errorf("bundling %v: %v", r.RequestURL, err)
+		}
+		b.Exchanges = append(b.Exchanges, e)
+
+		for _, u := range preloadURLs(r.Exchange) {
+			sub, err := lookup(rc, u)
+			if err != nil {
+				return nil, err
+			}
+			if sub == nil || sub.Exchange == nil {
+				log.Printf("warning: no signed exchange for %v to bundle", u)
+				continue
+			}
+			if !seen[sub.Exchange.RequestURI] {
+				seen[sub.Exchange.RequestURI] = true
+				queue = append(queue, sub)
+			}
+		}
+	}
+
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// newBundleExchange creates a bundle exchange serving e in the signed
+// exchange format.
+func newBundleExchange(e *signedexchange.Exchange) (*bundle.Exchange, error) {
+	u, err := url.Parse(e.RequestURI)
+	if err != nil {
+		return nil, err
+	}
+	var body bytes.Buffer
+	if err := e.Write(&body); err != nil {
+		return nil, err
+	}
+	header := make(http.Header)
+	header.Set("Content-Type", e.Version.MimeType())
+	header.Set("X-Content-Type-Options", "nosniff")
+	return &bundle.Exchange{
+		Request: bundle.Request{
+			URL:    u,
+			Header: make(http.Header),
+		},
+		Response: bundle.Response{
+			Status: http.StatusOK,
+			Header: header,
+			Body:   body.Bytes(),
+		},
+	}, nil
+}
+
+// preloadURLs returns the URLs of the preload links in e.
+func preloadURLs(e *signedexchange.Exchange) []*url.URL {
+	var urls []*url.URL
+	for _, value := range e.ResponseHeaders["Link"] {
+		links, err := httplink.Parse(value)
+		if err != nil {
+			continue
+		}
+		for _, link := range links {
+			if link.IsPreload() {
+				urls = append(urls, link.URL)
+			}
+		}
+	}
+	return urls
+}
+
+func lookup(rc cache.ResourceCache, u *url.URL) (*resource.Resource, error) {
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return rc.Lookup(req)
+}
@@ -0,0 +1,127 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webbundle_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/WICG/webpackage/go/bundle"
+	"github.com/WICG/webpackage/go/signedexchange"
+	"github.com/google/go-cmp/cmp"
+	"github.com/layer0-platform/webpackager"
+	"github.com/layer0-platform/webpackager/exchange"
+	"github.com/layer0-platform/webpackager/fetch/fetchtest"
+	"github.com/layer0-platform/webpackager/internal/certchaintest"
+	"github.com/layer0-platform/webpackager/internal/urlutil"
+	"github.com/layer0-platform/webpackager/processor/complexproc"
+	"github.com/layer0-platform/webpackager/processor/htmlproc"
+	"github.com/layer0-platform/webpackager/processor/htmlproc/htmltask"
+	"github.com/layer0-platform/webpackager/resource"
+	"github.com/layer0-platform/webpackager/webbundle"
+)
+
+func stubHandler(text, ctype string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=604800")
+		w.Header().Set("Content-Type", ctype)
+		io.WriteString(w, text)
+	})
+}
+
+func TestBuild(t *testing.T) {
+	handlers := http.NewServeMux()
+	handlers.Handle("example.org/hello.html", stubHandler(
+		`<!doctype html><link rel="stylesheet" href="style.css"><p>Hello, world!</p>`,
+		"text/html; charset=utf-8"))
+	handlers.Handle("example.org/style.css", stubHandler(
+		`body { font-family: sans-serif; }`, "text/css"))
+	server := httptest.NewTLSServer(handlers)
+	defer server.Close()
+
+	var tasks []htmltask.HTMLTask
+	tasks = append(tasks, htmltask.ConservativeTaskSet...)
+	tasks = append(tasks, htmltask.PreloadStylesheets())
+
+	pkg := webpackager.NewPackager(webpackager.Config{
+		FetchClient: fetchtest.NewFetchClient(server),
+		Processor: complexproc.NewComprehensiveProcessor(complexproc.Config{
+			HTML: htmlproc.Config{TaskSet: tasks},
+		}),
+		ExchangeFactory: exchange.NewFactory(exchange.Config{
+			CertChain:  certchaintest.MustReadAugmentedChainFile("../testdata/certs/cbor/ecdsap256_nosct.cbor"),
+			CertURL:    urlutil.MustParse("https://example.org/cert.cbor"),
+			PrivateKey: certchaintest.MustReadPrivateKeyFile("../testdata/keys/ecdsap256.key"),
+		}),
+	})
+	date := time.Date(2019, time.May, 13, 10, 30, 0, 0, time.UTC)
+	r, err := pkg.Run(urlutil.MustParse("https://example.org/hello.html"), date)
+	if err != nil {
+		t.Fatalf("pkg.Run() = error(%q), want success", err)
+	}
+
+	b, err := webbundle.Build(r, pkg.ResourceCache)
+	if err != nil {
+		t.Fatalf("webbundle.Build() = error(%q), want success", err)
+	}
+	var buf bytes.Buffer
+	if _, err := b.WriteTo(&buf); err != nil {
+		t.Fatalf("b.WriteTo() = error(%q), want success", err)
+	}
+	got, err := bundle.Read(&buf)
+	if err != nil {
+		t.Fatalf("bundle.Read() = error(%q), want success", err)
+	}
+
+	if got, want := got.PrimaryURL.String(), "https://example.org/hello.html"; got != want {
+		t.Errorf("PrimaryURL = %q, want %q", got, want)
+	}
+	var urls []string
+	for _, e := range got.Exchanges {
+		u := e.Request.URL.String()
+		urls = append(urls, u)
+		if got, want := e.Response.Header.Get("Content-Type"), "application/signed-exchange;v=b3"; got != want {
+			t.Errorf("%s: Content-Type = %q, want %q", u, got, want)
+		}
+		sxg, err := signedexchange.ReadExchange(bytes.NewReader(e.Response.Body))
+		if err != nil {
+			t.Errorf("%s: signedexchange.ReadExchange() = error(%q), want success", u, err)
+			continue
+		}
+		if sxg.RequestURI != u {
+			t.Errorf("%s: RequestURI = %q, want %q", u, sxg.RequestURI, u)
+		}
+	}
+	sort.Strings(urls) // The index section does not retain the order.
+	want := []string{
+		"https://example.org/hello.html",
+		"https://example.org/style.css",
+	}
+	if diff := cmp.Diff(want, urls); diff != "" {
+		t.Errorf("Exchanges mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestBuild_NoExchange(t *testing.T) {
+	r := resource.NewResource(urlutil.MustParse("https://example.org/hello.html"))
+	if _, err := webbundle.Build(r, nil); err == nil {
+		t.Error("webbundle.Build() = success, want error")
+	}
+}